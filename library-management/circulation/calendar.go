@@ -0,0 +1,100 @@
+package circulation
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+// dateLayout is the layout of Holiday.Date
+const dateLayout = "2006-01-02"
+
+// Calendar knows which days the library is open. Without any opening hours
+// or holidays configured, every day is an open day.
+type Calendar struct {
+	hours    map[time.Weekday]models.OpeningHours
+	holidays map[string]bool
+}
+
+// LoadCalendar reads the opening hours and holidays from the database
+func LoadCalendar(db *gorm.DB) (*Calendar, error) {
+	var hours []models.OpeningHours
+	if err := db.Find(&hours).Error; err != nil {
+		return nil, err
+	}
+	var holidays []models.Holiday
+	if err := db.Find(&holidays).Error; err != nil {
+		return nil, err
+	}
+
+	return NewCalendar(hours, holidays), nil
+}
+
+// NewCalendar returns a calendar of the given opening hours and holidays
+func NewCalendar(hours []models.OpeningHours, holidays []models.Holiday) *Calendar {
+	cal := &Calendar{
+		hours:    make(map[time.Weekday]models.OpeningHours, len(hours)),
+		holidays: make(map[string]bool, len(holidays)),
+	}
+	for _, h := range hours {
+		cal.hours[time.Weekday(h.Weekday)] = h
+	}
+	for _, h := range holidays {
+		cal.holidays[h.Date] = true
+	}
+	return cal
+}
+
+// IsOpen reports whether the library is open on the day of t
+func (cal *Calendar) IsOpen(t time.Time) bool {
+	if cal.holidays[t.Format(dateLayout)] {
+		return false
+	}
+	if h, ok := cal.hours[t.Weekday()]; ok && h.Closed {
+		return false
+	}
+	return true
+}
+
+// NextOpenDay returns t if the library is open that day, otherwise the same
+// time on the next open day. It gives up after a year of closures.
+func (cal *Calendar) NextOpenDay(t time.Time) time.Time {
+	for i := 0; i < 366 && !cal.IsOpen(t); i++ {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// OpenDaysBetween counts the open days after the day of from, up to and
+// including the day of to
+func (cal *Calendar) OpenDaysBetween(from, to time.Time) int {
+	day := startOfDay(from).AddDate(0, 0, 1)
+	end := startOfDay(to)
+	count := 0
+	for !day.After(end) {
+		if cal.IsOpen(day) {
+			count++
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return count
+}
+
+// AtClosingTime moves t to the closing time of its day when opening hours
+// are configured for that weekday
+func (cal *Calendar) AtClosingTime(t time.Time) time.Time {
+	h, ok := cal.hours[t.Weekday()]
+	if !ok || h.Closes == "" {
+		return t
+	}
+	closes, err := time.Parse("15:04", h.Closes)
+	if err != nil {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), closes.Hour(), closes.Minute(), 0, 0, t.Location())
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
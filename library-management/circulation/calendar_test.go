@@ -0,0 +1,60 @@
+package circulation_test
+
+import (
+	"testing"
+	"time"
+
+	"library-management/circulation"
+	"library-management/models"
+)
+
+// Sundays are closed, Tuesdays close at 17:30 and 27 January is a holiday
+var testCalendar = circulation.NewCalendar(
+	[]models.OpeningHours{
+		{Weekday: int(time.Sunday), Closed: true},
+		{Weekday: int(time.Tuesday), Opens: "09:00", Closes: "17:30"},
+	},
+	[]models.Holiday{{Date: "2025-01-27", Name: "Republic Day (observed)"}},
+)
+
+func TestDueDateSkipsClosedDays(t *testing.T) {
+	rule := models.CirculationRule{LoanPeriodDays: 14}
+	for _, tc := range []struct {
+		issued, want string
+	}{
+		// Due on an open day without hours: the time of issue is kept
+		{"2025-01-15T10:00:00Z", "2025-01-29T10:00:00Z"},
+		// Due on a Sunday, then a holiday: rolls to Tuesday's closing time
+		{"2025-01-12T10:00:00Z", "2025-01-28T17:30:00Z"},
+		// Due on the holiday alone
+		{"2025-01-13T10:00:00Z", "2025-01-28T17:30:00Z"},
+		// Due on a Tuesday: moved to its closing time
+		{"2025-01-07T10:00:00Z", "2025-01-21T17:30:00Z"},
+	} {
+		issued, _ := time.Parse(time.RFC3339, tc.issued)
+		if got := circulation.DueDate(testCalendar, rule, issued).Format(time.RFC3339); got != tc.want {
+			t.Errorf("issued %s: due %s, want %s", tc.issued, got, tc.want)
+		}
+	}
+}
+
+func TestLateFeeSkipsClosedDays(t *testing.T) {
+	rule := models.CirculationRule{FinePerDayMinor: 100}
+	due := time.Date(2025, 1, 24, 17, 0, 0, 0, time.UTC) // Friday
+	for returned, want := range map[time.Time]int64{
+		due:                  0,
+		due.Add(time.Hour):   0,
+		due.AddDate(0, 0, 1): 100, // Saturday
+		due.AddDate(0, 0, 3): 100, // Sunday is closed and Monday a holiday
+		due.AddDate(0, 0, 4): 200,
+	} {
+		if got := circulation.LateFee(testCalendar, rule, due, returned); got != want {
+			t.Errorf("returned %s: fee %d, want %d", returned.Format(time.RFC3339), got, want)
+		}
+	}
+
+	rule.FineCapMinor = 150
+	if got := circulation.LateFee(testCalendar, rule, due, due.AddDate(0, 0, 10)); got != 150 {
+		t.Errorf("capped fee = %d", got)
+	}
+}
@@ -0,0 +1,217 @@
+package circulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/notify"
+	"library-management/utils"
+)
+
+// MaxOutstandingBalance is the balance, in minor units, above which a
+// student cannot borrow until they pay
+var MaxOutstandingBalance int64 = 50000
+
+var (
+	ErrBalanceTooHigh = errors.New("the student's outstanding fines are above the borrowing limit")
+	ErrInvalidAmount  = errors.New("amount must be greater than zero")
+	ErrWaiverTooLarge = errors.New("a waiver cannot be larger than the outstanding balance")
+)
+
+// Balance returns what the student owes in minor units
+func Balance(db *gorm.DB, studentUSN string) (int64, error) {
+	var balance int64
+	err := db.Model(&models.LedgerEntry{}).
+		Where("student_usn = ?", studentUSN).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// CheckBalanceAllowsLoan refuses checkout while the balance is over the limit
+func CheckBalanceAllowsLoan(db *gorm.DB, student models.Student) error {
+	balance, err := Balance(db, student.USN)
+	if err != nil {
+		return err
+	}
+	if balance > MaxOutstandingBalance {
+		return ErrBalanceTooHigh
+	}
+	return nil
+}
+
+// AddLedgerEntry records a charge or credit. The amount is given as a
+// positive value and stored with the sign its type implies. Every entry
+// gets a receipt number.
+func AddLedgerEntry(tx *gorm.DB, entry *models.LedgerEntry) error {
+	if entry.AmountMinor <= 0 {
+		return ErrInvalidAmount
+	}
+	if entry.IsCredit() {
+		entry.AmountMinor = -entry.AmountMinor
+	}
+
+	// Receipt numbers follow the row ID, so fill them in after the insert
+	entry.ReceiptNumber = ""
+	if err := tx.Omit("ReceiptNumber").Create(entry).Error; err != nil {
+		return err
+	}
+	entry.ReceiptNumber = fmt.Sprintf("RCPT-%d-%06d", entry.CreatedAt.Year(), entry.ID)
+	return tx.Model(entry).Update("receipt_number", entry.ReceiptNumber).Error
+}
+
+// GrantWaiver records a waiver approved by staff. It cannot be larger than
+// the outstanding balance; corrections such as recomputed late fees use
+// AddLedgerEntry and may leave the student in credit.
+func GrantWaiver(tx *gorm.DB, entry *models.LedgerEntry) error {
+	// Lock the student first so concurrent waivers see each other
+	if err := tx.Exec("UPDATE students SET id = id WHERE usn = ?", entry.StudentUSN).Error; err != nil {
+		return err
+	}
+	balance, err := Balance(tx, entry.StudentUSN)
+	if err != nil {
+		return err
+	}
+	if entry.AmountMinor > balance {
+		return ErrWaiverTooLarge
+	}
+	entry.Type = models.CreditWaiver
+	return AddLedgerEntry(tx, entry)
+}
+
+// NotifyCharge sends the student a fine notice for a charge on their
+// account. Failures are logged and retried from the outbox.
+func NotifyCharge(db *gorm.DB, entry models.LedgerEntry) {
+	if entry.IsCredit() {
+		return
+	}
+	var student models.Student
+	if err := db.First(&student, "usn = ?", entry.StudentUSN).Error; err != nil {
+		log.Println("Error fetching student with USN:", entry.StudentUSN, err)
+		return
+	}
+	balance, err := Balance(db, student.USN)
+	if err != nil {
+		log.Println("Error fetching balance:", err)
+		return
+	}
+
+	var loan *models.Transaction
+	if entry.TransactionID != nil {
+		var transaction models.Transaction
+		if err := db.First(&transaction, *entry.TransactionID).Error; err == nil {
+			loan = &transaction
+		}
+	}
+	data := notify.DataFor(db, student, loan)
+	data.Fine = utils.FormatMinor(entry.AmountMinor)
+	data.Balance = utils.FormatMinor(balance)
+
+	_, err = notify.SendTemplate(context.Background(), db, student, notify.Notification{
+		Template:      models.TemplateFine,
+		TransactionID: entry.TransactionID,
+		DedupKey:      fmt.Sprintf("fine_notice:%d", entry.ID),
+	}, data)
+	if err != nil {
+		log.Println("Error sending fine notice:", err)
+	}
+}
+
+// LateFeeCorrection is the reason on waivers that undo part of a late fee
+// after the fee was recomputed
+const LateFeeCorrection = "Late fee recomputed"
+
+// FeeCorrection is a returned loan whose charged late fee differs from the
+// fee under the current rules and calendar
+type FeeCorrection struct {
+	TransactionID uint
+	StudentUSN    string
+	ChargedMinor  int64
+	FeeMinor      int64
+}
+
+// RecomputeLateFees recalculates the late fee of every returned loan. With
+// apply set, the difference is charged as a late fee or credited as a
+// waiver, and the loan's late fee is updated.
+func RecomputeLateFees(db *gorm.DB, apply bool) ([]FeeCorrection, error) {
+	cal, err := LoadCalendar(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var loans []models.Transaction
+	if err := db.Preload("Student").Preload("Copy").Where("return_date IS NOT NULL").Order("id").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+
+	var corrections []FeeCorrection
+	for _, loan := range loans {
+		rule, err := FindRule(db, loan.Student, loan.Copy)
+		if err != nil {
+			return corrections, err
+		}
+		fee := LateFee(cal, rule, loan.DueDate, *loan.ReturnDate)
+
+		// What was charged is read in the transaction that corrects it, after
+		// the loan is locked, so concurrent runs cannot correct a fee twice
+		var correction *FeeCorrection
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if apply {
+				if err := tx.Exec("UPDATE transactions SET id = id WHERE id = ?", loan.ID).Error; err != nil {
+					return err
+				}
+			}
+			charged, err := chargedLateFee(tx, loan.ID)
+			if err != nil || charged == fee {
+				return err
+			}
+			correction = &FeeCorrection{
+				TransactionID: loan.ID,
+				StudentUSN:    loan.StudentUSN,
+				ChargedMinor:  charged,
+				FeeMinor:      fee,
+			}
+			if !apply {
+				return nil
+			}
+
+			entry := &models.LedgerEntry{
+				StudentUSN:    loan.StudentUSN,
+				TransactionID: &loan.ID,
+				Type:          models.ChargeLateFee,
+				AmountMinor:   fee - charged,
+			}
+			if fee < charged {
+				entry.Type = models.CreditWaiver
+				entry.AmountMinor = charged - fee
+				entry.Reason = LateFeeCorrection
+			}
+			if err := AddLedgerEntry(tx, entry); err != nil {
+				return err
+			}
+			return tx.Model(&models.Transaction{}).Where("id = ?", loan.ID).Update("late_fee_minor", fee).Error
+		})
+		if err != nil {
+			return corrections, err
+		}
+		if correction != nil {
+			corrections = append(corrections, *correction)
+		}
+	}
+	return corrections, nil
+}
+
+// chargedLateFee sums the late fees charged for a loan. Earlier corrections
+// count towards what was charged.
+func chargedLateFee(db *gorm.DB, loanID uint) (int64, error) {
+	var charged int64
+	err := db.Model(&models.LedgerEntry{}).
+		Where("transaction_id = ? AND (type = ? OR (type = ? AND reason = ?))", loanID, models.ChargeLateFee, models.CreditWaiver, LateFeeCorrection).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Scan(&charged).Error
+	return charged, err
+}
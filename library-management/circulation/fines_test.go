@@ -0,0 +1,134 @@
+package circulation_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func addEntry(db *gorm.DB, usn, entryType string, amount int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		entry := &models.LedgerEntry{StudentUSN: usn, Type: entryType, AmountMinor: amount}
+		if entryType == models.CreditWaiver {
+			return circulation.GrantWaiver(tx, entry)
+		}
+		return circulation.AddLedgerEntry(tx, entry)
+	})
+}
+
+func TestWaiversAreCappedAtTheBalance(t *testing.T) {
+	db := databasetest.Open(t)
+	student := models.Student{USN: "1AB21CS001", Name: "Asha"}
+	if err := db.Create(&student).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := addEntry(db, student.USN, models.ChargeLateFee, 500); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := addEntry(db, student.USN, models.CreditWaiver, 501); err != circulation.ErrWaiverTooLarge {
+		t.Errorf("waiving more than the balance gave %v", err)
+	}
+	if err := addEntry(db, student.USN, models.CreditWaiver, 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := addEntry(db, student.USN, models.CreditWaiver, 201); err != circulation.ErrWaiverTooLarge {
+		t.Errorf("waiving more than what is left gave %v", err)
+	}
+	if err := addEntry(db, student.USN, models.CreditWaiver, 200); err != nil {
+		t.Fatal(err)
+	}
+
+	// Payments may still leave the student in credit
+	if err := addEntry(db, student.USN, models.CreditPayment, 100); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := circulation.Balance(db, student.USN); balance != -100 {
+		t.Errorf("balance = %d", balance)
+	}
+}
+
+func TestRecomputeLateFees(t *testing.T) {
+	db := databasetest.Open(t)
+	book := models.Book{Title: "Go", Author: "Donovan", Edition: 1, Copies: []models.Copy{{SerialNumber: "S-1", Status: models.CopyAvailable}}}
+	student := models.Student{USN: "1AB21CS001", Name: "Asha"}
+	rule := models.CirculationRule{LoanPeriodDays: 14, MaxLoans: 2, FinePerDayMinor: 100}
+	for _, record := range []interface{}{&book, &student, &rule} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Four days late, but only two were charged
+	due := time.Date(2025, 1, 24, 17, 0, 0, 0, time.UTC)
+	returned := time.Date(2025, 1, 28, 12, 0, 0, 0, time.UTC)
+	loan := models.Transaction{StudentUSN: student.USN, CopyID: book.Copies[0].ID, IssueDate: due.AddDate(0, 0, -14), DueDate: due, ReturnDate: &returned, LateFeeMinor: 200}
+	if err := db.Create(&loan).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return circulation.AddLedgerEntry(tx, &models.LedgerEntry{StudentUSN: student.USN, TransactionID: &loan.ID, Type: models.ChargeLateFee, AmountMinor: 200})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	entries := func() int64 {
+		var count int64
+		db.Model(&models.LedgerEntry{}).Count(&count)
+		return count
+	}
+
+	corrections, err := circulation.RecomputeLateFees(db, false)
+	want := circulation.FeeCorrection{TransactionID: loan.ID, StudentUSN: student.USN, ChargedMinor: 200, FeeMinor: 400}
+	if err != nil || len(corrections) != 1 || corrections[0] != want {
+		t.Fatalf("dry run gave %+v, %v", corrections, err)
+	}
+	if n := entries(); n != 1 {
+		t.Errorf("a dry run added %d ledger entries", n-1)
+	}
+
+	// Runs at the same time charge the difference once
+	var wg sync.WaitGroup
+	applied := make(chan int, 4)
+	for i := 0; i < cap(applied); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			corrections, err := circulation.RecomputeLateFees(db, true)
+			if err != nil {
+				t.Error(err)
+			}
+			applied <- len(corrections)
+		}()
+	}
+	wg.Wait()
+	close(applied)
+	total := 0
+	for n := range applied {
+		total += n
+	}
+	if balance, _ := circulation.Balance(db, student.USN); total != 1 || balance != 400 {
+		t.Errorf("%d corrections applied, balance %d", total, balance)
+	}
+
+	// Once the fee is paid, a holiday added later is credited back
+	if err := addEntry(db, student.USN, models.CreditPayment, 400); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Holiday{Date: "2025-01-27", Name: "Holiday"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if corrections, err := circulation.RecomputeLateFees(db, true); err != nil || len(corrections) != 1 {
+		t.Fatalf("recomputing after the holiday gave %+v, %v", corrections, err)
+	}
+	db.First(&loan, loan.ID)
+	if balance, _ := circulation.Balance(db, student.USN); balance != -100 || loan.LateFeeMinor != 300 {
+		t.Errorf("balance %d, late fee %d", balance, loan.LateFeeMinor)
+	}
+	if corrections, _ := circulation.RecomputeLateFees(db, false); len(corrections) != 0 {
+		t.Errorf("corrected fees still differ: %+v", corrections)
+	}
+}
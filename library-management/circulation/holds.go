@@ -0,0 +1,281 @@
+package circulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/notify"
+)
+
+// PickupWindow is how long a trapped copy waits on the hold shelf
+var PickupWindow = 3 * 24 * time.Hour
+
+var (
+	ErrCopiesAvailable = errors.New("a copy of this book is available; borrow it instead of placing a hold")
+	ErrAlreadyHolding  = errors.New("the student already has an active hold on this book")
+	ErrHoldNotActive   = errors.New("the hold is no longer active")
+	ErrHeldForOther    = errors.New("this book is held for another student")
+)
+
+// PlaceHold adds the student to the end of the queue for a title. Holds can
+// only be placed while no copy is on the shelf.
+func PlaceHold(db *gorm.DB, student models.Student, book models.Book) (models.Hold, error) {
+	var hold models.Hold
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var available int64
+		if err := tx.Model(&models.Copy{}).Where("book_id = ? AND status = ?", book.ID, models.CopyAvailable).Count(&available).Error; err != nil {
+			return err
+		}
+		if available > 0 {
+			return ErrCopiesAvailable
+		}
+
+		var active int64
+		if err := tx.Model(&models.Hold{}).
+			Where("book_id = ? AND student_usn = ? AND status IN ?", book.ID, student.USN, []string{models.HoldWaiting, models.HoldReady}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrAlreadyHolding
+		}
+
+		hold = models.Hold{
+			StudentUSN: student.USN,
+			BookID:     book.ID,
+			Status:     models.HoldWaiting,
+			PlacedAt:   time.Now(),
+		}
+		return tx.Create(&hold).Error
+	})
+	return hold, err
+}
+
+// CancelHold withdraws a waiting or ready hold. A copy trapped for the hold
+// moves on to the next student in the queue, whose hold is returned. The
+// hold is only cancelled if it is still in the status it was read in, so a
+// hold that has meanwhile been picked up, expired or trapped gives
+// ErrHoldNotActive.
+func CancelHold(db *gorm.DB, hold *models.Hold) (*models.Hold, error) {
+	if hold.Status != models.HoldWaiting && hold.Status != models.HoldReady {
+		return nil, ErrHoldNotActive
+	}
+
+	var next *models.Hold
+	err := db.Transaction(func(tx *gorm.DB) error {
+		wasReady := hold.Status == models.HoldReady
+		result := tx.Model(&models.Hold{}).Where("id = ? AND status = ?", hold.ID, hold.Status).Update("status", models.HoldCancelled)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrHoldNotActive
+		}
+		hold.Status = models.HoldCancelled
+		if !wasReady || hold.CopyID == nil {
+			return nil
+		}
+
+		var bookCopy models.Copy
+		if err := tx.First(&bookCopy, *hold.CopyID).Error; err != nil {
+			return err
+		}
+		if bookCopy.Status != models.CopyOnHold {
+			return nil
+		}
+		var err error
+		next, err = TrapForNextHold(tx, bookCopy)
+		return err
+	})
+	return next, err
+}
+
+// HoldForCheckout checks whether holds prevent the student from borrowing
+// the copy. It returns the student's own hold that the loan fulfils, if any.
+// A copy on the hold shelf is only issued for its ready hold; one that no
+// hold points at must be reshelved first.
+func HoldForCheckout(db *gorm.DB, student models.Student, bookCopy models.Copy) (*models.Hold, error) {
+	if bookCopy.Status == models.CopyOnHold {
+		var hold models.Hold
+		err := db.Where("copy_id = ? AND status = ?", bookCopy.ID, models.HoldReady).First(&hold).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrHeldForOther
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hold.StudentUSN != student.USN {
+			return nil, ErrHeldForOther
+		}
+		return &hold, nil
+	}
+
+	// A copy on the shelf only goes to the head of the queue while holds are waiting
+	var next models.Hold
+	err := db.Where("book_id = ? AND status = ?", bookCopy.BookID, models.HoldWaiting).
+		Order("placed_at, id").First(&next).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if next.StudentUSN != student.USN {
+		return nil, ErrHeldForOther
+	}
+	return &next, nil
+}
+
+// FulfillHold marks a hold as satisfied by a loan
+func FulfillHold(tx *gorm.DB, hold *models.Hold, copyID uint) error {
+	hold.Status = models.HoldFulfilled
+	hold.CopyID = &copyID
+	return tx.Model(hold).Select("status", "copy_id").Updates(hold).Error
+}
+
+// TrapForNextHold puts a returned copy on the hold shelf for the next
+// waiting student, or back on the open shelf if nobody is waiting. It
+// returns the hold that became ready. A hold cancelled while it is being
+// trapped is passed over for the one after it.
+func TrapForNextHold(tx *gorm.DB, bookCopy models.Copy) (*models.Hold, error) {
+	for {
+		var hold models.Hold
+		err := tx.Where("book_id = ? AND status = ?", bookCopy.BookID, models.HoldWaiting).
+			Order("placed_at, id").First(&hold).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil, tx.Model(&models.Copy{}).Where("id = ?", bookCopy.ID).Update("status", models.CopyAvailable).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(PickupWindow)
+		hold.Status = models.HoldReady
+		hold.CopyID = &bookCopy.ID
+		hold.ReadyAt = &now
+		hold.ExpiresAt = &expiresAt
+		result := tx.Model(&models.Hold{}).Where("id = ? AND status = ?", hold.ID, models.HoldWaiting).
+			Select("status", "copy_id", "ready_at", "expires_at").Updates(&hold)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := tx.Model(&models.Copy{}).Where("id = ?", bookCopy.ID).Update("status", models.CopyOnHold).Error; err != nil {
+			return nil, err
+		}
+		return &hold, nil
+	}
+}
+
+// ReleaseHeldCopy takes a copy off the hold shelf when it leaves
+// circulation. The hold it was trapped for goes back to the queue, keeping
+// its place, and is trapped with another copy on the shelf if there is one.
+// It returns the hold that became ready with the other copy.
+func ReleaseHeldCopy(tx *gorm.DB, bookCopy models.Copy) (*models.Hold, error) {
+	var hold models.Hold
+	err := tx.Where("copy_id = ? AND status = ?", bookCopy.ID, models.HoldReady).First(&hold).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&models.Hold{}).Where("id = ? AND status = ?", hold.ID, models.HoldReady).
+		Updates(map[string]interface{}{"status": models.HoldWaiting, "copy_id": nil, "ready_at": nil, "expires_at": nil}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var other models.Copy
+	err = tx.Where("book_id = ? AND status = ? AND id <> ?", bookCopy.BookID, models.CopyAvailable, bookCopy.ID).
+		Order("id").First(&other).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return TrapForNextHold(tx, other)
+}
+
+// ExpireHolds ends ready holds whose pickup window has passed and passes
+// their copies on. It returns how many holds expired and the holds that
+// became ready as a result.
+func ExpireHolds(db *gorm.DB) (int, []models.Hold, error) {
+	var due []models.Hold
+	if err := db.Where("status = ? AND expires_at < ?", models.HoldReady, time.Now()).Find(&due).Error; err != nil {
+		return 0, nil, err
+	}
+
+	expired := 0
+	var ready []models.Hold
+	for _, hold := range due {
+		var ended bool
+		var next *models.Hold
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Skip holds picked up or cancelled since they were read
+			result := tx.Model(&models.Hold{}).Where("id = ? AND status = ?", hold.ID, models.HoldReady).Update("status", models.HoldExpired)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			ended = true
+			if hold.CopyID == nil {
+				return nil
+			}
+			var bookCopy models.Copy
+			if err := tx.First(&bookCopy, *hold.CopyID).Error; err != nil {
+				return err
+			}
+			var err error
+			next, err = TrapForNextHold(tx, bookCopy)
+			return err
+		})
+		if err != nil {
+			return expired, ready, err
+		}
+		if ended {
+			expired++
+		}
+		if next != nil {
+			ready = append(ready, *next)
+		}
+	}
+	return expired, ready, nil
+}
+
+// NotifyHoldReady tells the student that their hold can be picked up.
+// Failed sends are logged and retried from the outbox; the hold stays
+// ready either way.
+func NotifyHoldReady(db *gorm.DB, hold models.Hold) {
+	var student models.Student
+	if err := db.First(&student, "usn = ?", hold.StudentUSN).Error; err != nil {
+		log.Println("Error fetching student with USN:", hold.StudentUSN, err)
+		return
+	}
+	var book models.Book
+	if err := db.Select("id", "title").First(&book, hold.BookID).Error; err != nil {
+		log.Println("Error fetching book:", hold.BookID, err)
+		return
+	}
+
+	data := notify.DataFor(db, student, nil)
+	data.BookTitle = book.Title
+	if hold.ExpiresAt != nil {
+		data.PickupBy = hold.ExpiresAt.Format("2006-01-02")
+	}
+	_, err := notify.SendTemplate(context.Background(), db, student, notify.Notification{
+		Template: models.TemplateHoldReady,
+		DedupKey: fmt.Sprintf("hold_ready:%d", hold.ID),
+	}, data)
+	if err != nil {
+		log.Println("Error sending hold notification:", err)
+	}
+}
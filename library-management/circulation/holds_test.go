@@ -0,0 +1,229 @@
+package circulation_test
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+// holdLibrary is a book whose only copy is out, and students to queue for it
+type holdLibrary struct {
+	db       *gorm.DB
+	book     models.Book
+	students []models.Student
+}
+
+func newHoldLibrary(t *testing.T) *holdLibrary {
+	t.Helper()
+	l := &holdLibrary{db: databasetest.Open(t)}
+	l.book = models.Book{Title: "Go", Author: "Donovan", Edition: 1, Copies: []models.Copy{{SerialNumber: "S-1", Status: models.CopyIssued}}}
+	if err := l.db.Create(&l.book).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, usn := range []string{"1AB21CS001", "1AB21CS002", "1AB21CS003"} {
+		student := models.Student{USN: usn, Name: usn}
+		if err := l.db.Create(&student).Error; err != nil {
+			t.Fatal(err)
+		}
+		l.students = append(l.students, student)
+	}
+	return l
+}
+
+func (l *holdLibrary) place(t *testing.T, student int) models.Hold {
+	t.Helper()
+	hold, err := circulation.PlaceHold(l.db, l.students[student], l.book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hold
+}
+
+func (l *holdLibrary) hold(t *testing.T, id uint) models.Hold {
+	t.Helper()
+	var hold models.Hold
+	if err := l.db.First(&hold, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return hold
+}
+
+func (l *holdLibrary) copyStatus(t *testing.T) string {
+	t.Helper()
+	var bookCopy models.Copy
+	if err := l.db.First(&bookCopy, l.book.Copies[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return bookCopy.Status
+}
+
+func TestTrapForNextHold(t *testing.T) {
+	l := newHoldLibrary(t)
+	first, second := l.place(t, 0), l.place(t, 1)
+
+	ready, err := circulation.TrapForNextHold(l.db, l.book.Copies[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready == nil || ready.ID != first.ID || ready.ExpiresAt == nil {
+		t.Fatalf("ready hold = %+v", ready)
+	}
+	if hold := l.hold(t, first.ID); hold.Status != models.HoldReady || *hold.CopyID != l.book.Copies[0].ID {
+		t.Errorf("first hold = %+v", hold)
+	}
+	if hold := l.hold(t, second.ID); hold.Status != models.HoldWaiting {
+		t.Errorf("second hold is %s", hold.Status)
+	}
+	if status := l.copyStatus(t); status != models.CopyOnHold {
+		t.Errorf("copy is %s", status)
+	}
+}
+
+func TestCancelReadyHoldPassesCopyOn(t *testing.T) {
+	l := newHoldLibrary(t)
+	first, second := l.place(t, 0), l.place(t, 1)
+	circulation.TrapForNextHold(l.db, l.book.Copies[0])
+
+	hold := l.hold(t, first.ID)
+	next, err := circulation.CancelHold(l.db, &hold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.ID != second.ID {
+		t.Errorf("next hold = %+v", next)
+	}
+	if hold := l.hold(t, first.ID); hold.Status != models.HoldCancelled {
+		t.Errorf("cancelled hold is %s", hold.Status)
+	}
+}
+
+func TestCancelHoldIsConditional(t *testing.T) {
+	l := newHoldLibrary(t)
+	first := l.place(t, 0)
+	stale := l.hold(t, first.ID) // Read while waiting
+
+	// The hold is trapped, then picked up, before the cancel is saved
+	circulation.TrapForNextHold(l.db, l.book.Copies[0])
+	ready := l.hold(t, first.ID)
+	if err := circulation.FulfillHold(l.db, &ready, l.book.Copies[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := circulation.CancelHold(l.db, &stale); err != circulation.ErrHoldNotActive {
+		t.Errorf("cancelling a fulfilled hold gave %v", err)
+	}
+	if hold := l.hold(t, first.ID); hold.Status != models.HoldFulfilled {
+		t.Errorf("the hold was changed to %s", hold.Status)
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	l := newHoldLibrary(t)
+	first, second := l.place(t, 0), l.place(t, 1)
+	circulation.TrapForNextHold(l.db, l.book.Copies[0])
+
+	if expired, ready, err := circulation.ExpireHolds(l.db); err != nil || expired != 0 || len(ready) != 0 {
+		t.Fatalf("expired %d holds early, %d ready: %v", expired, len(ready), err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	l.db.Model(&models.Hold{}).Where("id = ?", first.ID).Update("expires_at", past)
+	expired, ready, err := circulation.ExpireHolds(l.db)
+	if err != nil || expired != 1 {
+		t.Fatalf("expired %d holds: %v", expired, err)
+	}
+	if len(ready) != 1 || ready[0].ID != second.ID {
+		t.Errorf("ready holds = %+v", ready)
+	}
+	if hold := l.hold(t, first.ID); hold.Status != models.HoldExpired {
+		t.Errorf("first hold is %s", hold.Status)
+	}
+	if status := l.copyStatus(t); status != models.CopyOnHold {
+		t.Errorf("copy is %s", status)
+	}
+
+	// Once the queue is empty the copy goes back on the shelf
+	l.db.Model(&models.Hold{}).Where("id = ?", second.ID).Update("expires_at", past)
+	if expired, ready, _ := circulation.ExpireHolds(l.db); expired != 1 || len(ready) != 0 {
+		t.Errorf("expired %d holds, %d ready", expired, len(ready))
+	}
+	if status := l.copyStatus(t); status != models.CopyAvailable {
+		t.Errorf("copy is %s with nobody waiting", status)
+	}
+}
+
+func TestHoldForCheckoutNeedsReadyHold(t *testing.T) {
+	l := newHoldLibrary(t)
+	bookCopy := l.book.Copies[0]
+	bookCopy.Status = models.CopyOnHold
+	l.db.Model(&bookCopy).Update("status", models.CopyOnHold)
+
+	// A copy on the hold shelf that no hold points at is not lent out
+	if _, err := circulation.HoldForCheckout(l.db, l.students[0], bookCopy); err != circulation.ErrHeldForOther {
+		t.Errorf("borrowing a stray held copy gave %v", err)
+	}
+
+	first := l.place(t, 0)
+	circulation.TrapForNextHold(l.db, bookCopy)
+	if _, err := circulation.HoldForCheckout(l.db, l.students[1], bookCopy); err != circulation.ErrHeldForOther {
+		t.Errorf("borrowing another student's copy gave %v", err)
+	}
+	if hold, err := circulation.HoldForCheckout(l.db, l.students[0], bookCopy); err != nil || hold == nil || hold.ID != first.ID {
+		t.Errorf("the hold's student got %+v, %v", hold, err)
+	}
+}
+
+func TestReleaseHeldCopy(t *testing.T) {
+	l := newHoldLibrary(t)
+	spare := models.Copy{BookID: l.book.ID, SerialNumber: "S-2", Status: models.CopyIssued}
+	if err := l.db.Create(&spare).Error; err != nil {
+		t.Fatal(err)
+	}
+	first, second := l.place(t, 0), l.place(t, 1)
+	circulation.TrapForNextHold(l.db, l.book.Copies[0])
+
+	// The held copy is lost while nothing else is on the shelf
+	lost := l.book.Copies[0]
+	l.db.Model(&lost).Update("status", models.CopyLost)
+	ready, err := circulation.ReleaseHeldCopy(l.db, lost)
+	if err != nil || ready != nil {
+		t.Fatalf("released with %+v: %v", ready, err)
+	}
+	if hold := l.hold(t, first.ID); hold.Status != models.HoldWaiting || hold.CopyID != nil || hold.ExpiresAt != nil {
+		t.Errorf("first hold = %+v", hold)
+	}
+
+	// The hold keeps its place and gets the next copy that comes back
+	ready, err = circulation.TrapForNextHold(l.db, spare)
+	if err != nil || ready == nil || ready.ID != first.ID {
+		t.Errorf("the returned copy went to %+v: %v", ready, err)
+	}
+	if hold := l.hold(t, second.ID); hold.Status != models.HoldWaiting {
+		t.Errorf("second hold is %s", hold.Status)
+	}
+}
+
+func TestReleaseHeldCopyTrapsAnother(t *testing.T) {
+	l := newHoldLibrary(t)
+	first := l.place(t, 0)
+	circulation.TrapForNextHold(l.db, l.book.Copies[0])
+	spare := models.Copy{BookID: l.book.ID, SerialNumber: "S-2", Status: models.CopyAvailable}
+	if err := l.db.Create(&spare).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	damaged := l.book.Copies[0]
+	l.db.Model(&damaged).Update("status", models.CopyDamaged)
+	ready, err := circulation.ReleaseHeldCopy(l.db, damaged)
+	if err != nil || ready == nil || ready.ID != first.ID || *ready.CopyID != spare.ID {
+		t.Fatalf("ready hold = %+v: %v", ready, err)
+	}
+	l.db.First(&spare, spare.ID)
+	if spare.Status != models.CopyOnHold {
+		t.Errorf("the spare copy is %s", spare.Status)
+	}
+}
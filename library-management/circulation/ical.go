@@ -0,0 +1,124 @@
+package circulation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"library-management/models"
+)
+
+// ErrNoEvents is returned when an iCalendar file has no usable events
+var ErrNoEvents = errors.New("no events found in calendar")
+
+// ParseICSHolidays reads the VEVENTs of an iCalendar (.ics) file as
+// holidays. Multi-day events become one holiday per day; DTEND is
+// exclusive as the format specifies. Recurrence rules are not expanded.
+func ParseICSHolidays(r io.Reader, source string) ([]models.Holiday, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var holidays []models.Holiday
+	var inEvent bool
+	var summary, start, end string
+	for n, line := range lines {
+		name, value := splitICSLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent, summary, start, end = true, "", "", ""
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start == "" {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", n+1, summary)
+			}
+			days, err := eventDays(start, end)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			for _, day := range days {
+				holidays = append(holidays, models.Holiday{Date: day, Name: summary, Source: source})
+			}
+		case !inEvent:
+		case name == "SUMMARY":
+			summary = unescapeICSText(value)
+		case name == "DTSTART":
+			start = icsDate(value)
+		case name == "DTEND":
+			end = icsDate(value)
+		}
+	}
+
+	if len(holidays) == 0 {
+		return nil, ErrNoEvents
+	}
+	return holidays, nil
+}
+
+// unfoldICSLines joins continuation lines, which start with a space or tab
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// splitICSLine splits "NAME;PARAM=x:VALUE" into its name and value.
+// Parameters such as VALUE=DATE and TZID are dropped.
+func splitICSLine(line string) (string, string) {
+	head, value, _ := strings.Cut(line, ":")
+	name, _, _ := strings.Cut(head, ";")
+	return strings.ToUpper(name), value
+}
+
+// icsDate reduces a DATE or DATE-TIME value to its YYYYMMDD date
+func icsDate(value string) string {
+	if len(value) >= 8 {
+		return value[:8]
+	}
+	return value
+}
+
+// eventDays lists the dates covered by an event
+func eventDays(start, end string) ([]string, error) {
+	first, err := time.Parse("20060102", start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART %q", start)
+	}
+	last := first
+	if end != "" {
+		endDate, err := time.Parse("20060102", end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DTEND %q", end)
+		}
+		// DTEND is exclusive for all-day events
+		if endDate.After(first) {
+			last = endDate.AddDate(0, 0, -1)
+		}
+	}
+	if last.Sub(first) > 366*24*time.Hour {
+		return nil, fmt.Errorf("event from %s to %s is longer than a year", start, end)
+	}
+
+	var days []string
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(dateLayout))
+	}
+	return days, nil
+}
+
+// unescapeICSText undoes iCalendar TEXT escaping
+func unescapeICSText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
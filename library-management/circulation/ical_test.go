@@ -0,0 +1,75 @@
+package circulation_test
+
+import (
+	"strings"
+	"testing"
+
+	"library-management/circulation"
+	"library-management/models"
+)
+
+func TestParseICSHolidays(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:Republic Day",
+		"DTSTART;VALUE=DATE:20250126",
+		"DTEND;VALUE=DATE:20250127",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Diwali\\, break",
+		"DTSTART;VALUE=DATE:20251020",
+		"DTEND;VALUE=DATE:20251023",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Founders",
+		" Day",
+		"DTSTART;TZID=Asia/Kolkata:20250301T100000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Weekly closure",
+		"DTSTART;VALUE=DATE:20250105",
+		"DTEND;VALUE=DATE:20250106",
+		"RRULE:FREQ=WEEKLY;BYDAY=SU",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	holidays, err := circulation.ParseICSHolidays(strings.NewReader(ics), "college.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Holiday{
+		{Date: "2025-01-26", Name: "Republic Day"},
+		// DTEND is exclusive
+		{Date: "2025-10-20", Name: "Diwali, break"},
+		{Date: "2025-10-21", Name: "Diwali, break"},
+		{Date: "2025-10-22", Name: "Diwali, break"},
+		// Without DTEND a date-time event covers its own day
+		{Date: "2025-03-01", Name: "FoundersDay"},
+		// Recurrence rules are not expanded
+		{Date: "2025-01-05", Name: "Weekly closure"},
+	}
+	if len(holidays) != len(want) {
+		t.Fatalf("got %d holidays: %+v", len(holidays), holidays)
+	}
+	for i, holiday := range holidays {
+		if holiday.Date != want[i].Date || holiday.Name != want[i].Name || holiday.Source != "college.ics" {
+			t.Errorf("holiday %d = %+v, want %s %q", i, holiday, want[i].Date, want[i].Name)
+		}
+	}
+}
+
+func TestParseICSHolidaysRejectsBadEvents(t *testing.T) {
+	for name, ics := range map[string]string{
+		"no events":      "BEGIN:VCALENDAR\nEND:VCALENDAR\n",
+		"no start":       "BEGIN:VEVENT\nSUMMARY:Holiday\nEND:VEVENT\n",
+		"bad start":      "BEGIN:VEVENT\nDTSTART:2025-01-26\nEND:VEVENT\n",
+		"over a year":    "BEGIN:VEVENT\nDTSTART:20250101\nDTEND:20260301\nEND:VEVENT\n",
+		"not ics at all": "date,name\n2025-01-26,Republic Day\n",
+	} {
+		if _, err := circulation.ParseICSHolidays(strings.NewReader(ics), "test"); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
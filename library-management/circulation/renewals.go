@@ -0,0 +1,86 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+var (
+	ErrAlreadyReturned = errors.New("the book has already been returned")
+	ErrRenewalLimit    = errors.New("the loan has reached the maximum number of renewals")
+	ErrTooOverdue      = errors.New("the loan is too far overdue to be renewed")
+	ErrHeldForRenewal  = errors.New("another student is waiting for this book")
+)
+
+// Renew extends a loan by the rule's loan period, counted from the later of
+// now and the current due date. The renewal is recorded in the history.
+func Renew(db *gorm.DB, cal *Calendar, transaction *models.Transaction, bookCopy models.Copy, rule models.CirculationRule, userID uint) error {
+	now := time.Now()
+	if err := CheckRenewal(*transaction, rule, now); err != nil {
+		return err
+	}
+
+	// Anyone else in the queue for the title gets the book back on time
+	var waiting int64
+	if err := db.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ? AND student_usn <> ?", bookCopy.BookID, models.HoldWaiting, transaction.StudentUSN).
+		Count(&waiting).Error; err != nil {
+		return err
+	}
+	if waiting > 0 {
+		return ErrHeldForRenewal
+	}
+
+	oldDueDate := transaction.DueDate
+	newDueDate := RenewedDueDate(cal, rule, oldDueDate, now)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		transaction.DueDate = newDueDate
+		transaction.RenewalCount++
+		if err := tx.Model(transaction).Select("due_date", "renewal_count").Updates(transaction).Error; err != nil {
+			return err
+		}
+		return RecordEvent(tx, transaction.ID, models.EventRenewed, &oldDueDate, &newDueDate, userID)
+	})
+}
+
+// CheckRenewal checks that the rule lets the loan be renewed at now, not
+// counting holds
+func CheckRenewal(transaction models.Transaction, rule models.CirculationRule, now time.Time) error {
+	if transaction.ReturnDate != nil {
+		return ErrAlreadyReturned
+	}
+	if rule.LoanPeriodDays <= 0 {
+		return ErrNotLoanable
+	}
+	if transaction.RenewalCount >= rule.MaxRenewals {
+		return ErrRenewalLimit
+	}
+	if now.After(transaction.DueDate.AddDate(0, 0, rule.RenewalOverdueLimitDays)) {
+		return ErrTooOverdue
+	}
+	return nil
+}
+
+// RenewedDueDate is the due date of a loan due at due and renewed at now
+func RenewedDueDate(cal *Calendar, rule models.CirculationRule, due, now time.Time) time.Time {
+	from := now
+	if due.After(from) {
+		from = due
+	}
+	return DueDate(cal, rule, from)
+}
+
+// RecordEvent appends an entry to a loan's history
+func RecordEvent(tx *gorm.DB, transactionID uint, eventType string, oldDueDate, newDueDate *time.Time, userID uint) error {
+	return tx.Create(&models.TransactionEvent{
+		TransactionID: transactionID,
+		Type:          eventType,
+		OldDueDate:    oldDueDate,
+		NewDueDate:    newDueDate,
+		UserID:        userID,
+	}).Error
+}
@@ -0,0 +1,110 @@
+package circulation
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/models"
+)
+
+// DefaultRule applies when no configured rule matches a loan
+var DefaultRule = models.CirculationRule{
+	LoanPeriodDays:  14,
+	MaxLoans:        5,
+	MaxRenewals:     2,
+	FinePerDayMinor: 1000,
+}
+
+// Configure sets the default rule and the hold and fine limits from the
+// server settings
+func Configure(settings config.CirculationConfig) {
+	DefaultRule = models.CirculationRule{
+		LoanPeriodDays:  settings.LoanPeriodDays,
+		MaxLoans:        settings.MaxLoans,
+		MaxRenewals:     settings.MaxRenewals,
+		FinePerDayMinor: settings.FinePerDayMinor,
+		FineCapMinor:    settings.FineCapMinor,
+	}
+	PickupWindow = time.Duration(settings.HoldPickupWindow)
+	MaxOutstandingBalance = settings.MaxOutstandingBalanceMinor
+}
+
+var (
+	ErrNotLoanable      = errors.New("this item type cannot be borrowed")
+	ErrLoanLimitReached = errors.New("the student has reached the maximum number of open loans")
+)
+
+// FindRule returns the most specific rule matching the student and copy.
+// Ties go to the rule created first.
+func FindRule(db *gorm.DB, student models.Student, bookCopy models.Copy) (models.CirculationRule, error) {
+	var rules []models.CirculationRule
+	if err := db.Order("id").Find(&rules).Error; err != nil {
+		return models.CirculationRule{}, err
+	}
+	return BestRule(rules, student, bookCopy), nil
+}
+
+// BestRule picks the most specific of the rules, given in the order they
+// were created, or DefaultRule if none matches
+func BestRule(rules []models.CirculationRule, student models.Student, bookCopy models.Copy) models.CirculationRule {
+	best, bestScore := DefaultRule, -1
+	for _, rule := range rules {
+		score, ok := matchScore(rule, student, bookCopy)
+		if ok && score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best
+}
+
+// matchScore reports whether the rule applies and how specific it is
+func matchScore(rule models.CirculationRule, student models.Student, bookCopy models.Copy) (int, bool) {
+	score := 0
+	fields := []struct{ want, have string }{
+		{rule.MemberCategory, student.Category},
+		{rule.Department, student.Department},
+		{rule.ItemType, itemType(bookCopy)},
+	}
+	for _, field := range fields {
+		if field.want == "" {
+			continue
+		}
+		if field.want != field.have {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// itemType treats copies created before item types existed as general
+func itemType(bookCopy models.Copy) string {
+	if bookCopy.ItemType == "" {
+		return models.ItemGeneral
+	}
+	return bookCopy.ItemType
+}
+
+// DueDate returns the due date for a loan issued at the given time. A due
+// date on a closed day rolls forward to the closing time of the next open day.
+func DueDate(cal *Calendar, rule models.CirculationRule, issued time.Time) time.Time {
+	due := cal.NextOpenDay(issued.AddDate(0, 0, rule.LoanPeriodDays))
+	return cal.AtClosingTime(due)
+}
+
+// LateFee charges the rule's daily fine for every open day past the due
+// date, limited to the fine cap when one is set. Days the library is closed
+// are not charged.
+func LateFee(cal *Calendar, rule models.CirculationRule, due, returned time.Time) int64 {
+	if !returned.After(due) {
+		return 0
+	}
+	daysLate := int64(cal.OpenDaysBetween(due, returned))
+	fee := daysLate * rule.FinePerDayMinor
+	if rule.FineCapMinor > 0 && fee > rule.FineCapMinor {
+		fee = rule.FineCapMinor
+	}
+	return fee
+}
@@ -0,0 +1,126 @@
+// Package cli holds the administration commands. They are run by the
+// libraryctl tool and, for compatibility, by the server binary when it is
+// given a command name.
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/database"
+	"library-management/migrations"
+	"library-management/storage"
+)
+
+// Run runs the named command with its arguments. It exits the program on
+// failure.
+func Run(name string, args []string) {
+	switch name {
+	case "create-user":
+		createUser(args)
+	case "reset-password":
+		resetPassword(args)
+	case "migrate":
+		migrate(args)
+	case "send-reminders":
+		sendReminders(args)
+	case "import-students":
+		importStudents(args)
+	case "import-books":
+		importBooks(args)
+	case "export":
+		export(args)
+	case "recompute-fines":
+		recomputeFines(args)
+	case "move-ebooks":
+		moveEBooks(args)
+	case "fix-phones":
+		fixPhones(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(Usage())
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", name, Usage())
+		os.Exit(2)
+	}
+}
+
+// Usage lists the commands
+func Usage() string {
+	return fmt.Sprintf(`usage: %s <command> [arguments]
+  create-user -username name [-roles admin,librarian] [-student-usn usn]
+  reset-password -username name
+  migrate [up | down [steps] | status | to <version>]
+  send-reminders
+  import-students [-dry-run] <file.csv | file.xlsx>
+  import-books [-format csv|marc|marcxml] [-serial-prefix IMP-] [-rack R] [-vendor id] [-item-type t] <file>
+  export <students | books | transactions | ledger> [-out file.csv]
+  recompute-fines [-apply]
+  fix-phones [-apply] [-region IN]
+  move-ebooks
+
+Settings are read from the same file and environment as the server.
+`, filepath.Base(os.Args[0]))
+}
+
+// loadConfig reads the settings as the server does, except that the
+// settings only the server uses may be left out
+func loadConfig() *config.Config {
+	cfg, err := config.LoadForCommand(config.Path())
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Apply()
+	circulation.Configure(cfg.Circulation)
+	return cfg
+}
+
+// openDatabase connects to the configured database without checking its
+// schema
+func openDatabase(cfg *config.Config) *gorm.DB {
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+
+	// Lookups that find nothing are expected here, so only log real problems
+	db.Logger = logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+		SlowThreshold:             time.Second,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+	return db
+}
+
+// connect loads the settings and opens a database whose schema is up to
+// date, as every command but migrate needs
+func connect() (*config.Config, *gorm.DB) {
+	cfg := loadConfig()
+	db := openDatabase(cfg)
+	if err := migrations.CheckSchema(db); err != nil {
+		log.Fatal(err)
+	}
+	return cfg, db
+}
+
+// openStore opens the configured file storage
+func openStore(cfg *config.Config) storage.Store {
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to set up file storage: %v", err)
+	}
+	return store
+}
+
+// usageError reports wrong arguments for a command and exits
+func usageError(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	fmt.Fprint(os.Stderr, Usage())
+	os.Exit(2)
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"encoding/csv"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/importer"
+	"library-management/models"
+	"library-management/repository"
+	"library-management/utils"
+)
+
+// exporters write one kind of record as CSV. Students and books are written
+// in the format the import commands read.
+var exporters = map[string]func(db *gorm.DB, w *csv.Writer) error{
+	"students":     exportStudents,
+	"books":        exportBooks,
+	"transactions": exportTransactions,
+	"ledger":       exportLedger,
+}
+
+// export writes students, books, transactions or ledger entries as CSV
+func export(args []string) {
+	if len(args) == 0 {
+		usageError("export needs what to export")
+	}
+	write, ok := exporters[args[0]]
+	if !ok {
+		usageError("cannot export %q", args[0])
+	}
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	out := flags.String("out", "", "file to write; standard output when omitted")
+	flags.Parse(args[1:])
+
+	_, db := connect()
+	var output io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	w := csv.NewWriter(output)
+	if err := write(db, w); err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+}
+
+func exportStudents(db *gorm.DB, w *csv.Writer) error {
+	students, err := repository.NewGormStore(db).Students().List()
+	if err != nil {
+		return err
+	}
+	w.Write(importer.StudentColumns)
+	for _, student := range students {
+		w.Write(importer.StudentRecord(student))
+	}
+	return nil
+}
+
+func exportBooks(db *gorm.DB, w *csv.Writer) error {
+	books, err := repository.NewGormStore(db).Books().List()
+	if err != nil {
+		return err
+	}
+	w.Write(importer.BookColumns)
+	for _, book := range books {
+		for _, bookCopy := range book.Copies {
+			w.Write(importer.BookRecord(book, bookCopy))
+		}
+	}
+	return nil
+}
+
+func exportTransactions(db *gorm.DB, w *csv.Writer) error {
+	transactions, err := repository.NewGormStore(db).Transactions().Find(repository.TransactionFilter{})
+	if err != nil {
+		return err
+	}
+	w.Write([]string{"id", "student_usn", "serial_number", "title", "issue_date", "due_date", "return_date", "late_fee", "renewal_count"})
+	for _, t := range transactions {
+		title := ""
+		if t.Copy.Book != nil {
+			title = t.Copy.Book.Title
+		}
+		returned := ""
+		if t.ReturnDate != nil {
+			returned = t.ReturnDate.Format(time.RFC3339)
+		}
+		w.Write([]string{
+			strconv.FormatUint(uint64(t.ID), 10), t.StudentUSN, t.Copy.SerialNumber, title,
+			t.IssueDate.Format(time.RFC3339), t.DueDate.Format(time.RFC3339), returned,
+			utils.FormatMinor(t.LateFeeMinor), strconv.Itoa(t.RenewalCount),
+		})
+	}
+	return nil
+}
+
+func exportLedger(db *gorm.DB, w *csv.Writer) error {
+	var entries []models.LedgerEntry
+	if err := db.Order("id").Find(&entries).Error; err != nil {
+		return err
+	}
+	w.Write([]string{"id", "student_usn", "transaction_id", "type", "amount", "reason", "payment_method", "reference", "receipt_number", "created_at"})
+	for _, e := range entries {
+		transactionID := ""
+		if e.TransactionID != nil {
+			transactionID = strconv.FormatUint(uint64(*e.TransactionID), 10)
+		}
+		w.Write([]string{
+			strconv.FormatUint(uint64(e.ID), 10), e.StudentUSN, transactionID, e.Type,
+			utils.FormatMinor(e.AmountMinor), e.Reason, e.PaymentMethod, e.Reference,
+			e.ReceiptNumber, e.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"library-management/circulation"
+	"library-management/utils"
+)
+
+// recomputeFines reports returned loans whose late fee no longer matches
+// the circulation rules and calendar and, with -apply, corrects them
+func recomputeFines(args []string) {
+	flags := flag.NewFlagSet("recompute-fines", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "charge or waive the differences instead of only reporting them")
+	flags.Parse(args)
+
+	_, db := connect()
+	corrections, err := circulation.RecomputeLateFees(db, *apply)
+	for _, c := range corrections {
+		fmt.Printf("%-6d %-15s charged %10s, fee %10s\n", c.TransactionID, c.StudentUSN, utils.FormatMinor(c.ChargedMinor), utils.FormatMinor(c.FeeMinor))
+	}
+	if err != nil {
+		log.Fatalf("Failed to recompute late fees: %v", err)
+	}
+
+	verb := "to correct"
+	if *apply {
+		verb = "corrected"
+	}
+	fmt.Printf("%d late fees %s\n", len(corrections), verb)
+	if !*apply && len(corrections) > 0 {
+		fmt.Println("Run again with -apply to correct them")
+	}
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"library-management/importer"
+	"library-management/models"
+)
+
+// openInput opens the file named by a command's only argument; "-" reads
+// standard input
+func openInput(command string, args []string) io.ReadCloser {
+	if len(args) != 1 {
+		usageError("%s needs the file to import", command)
+	}
+	if args[0] == "-" {
+		return os.Stdin
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return file
+}
+
+// importStudents adds or updates students from a CSV or XLSX file
+func importStudents(args []string) {
+	flags := flag.NewFlagSet("import-students", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "check the file and report what would change without storing anything")
+	flags.Parse(args)
+
+	input := openInput("import-students", flags.Args())
+	defer input.Close()
+	_, db := connect()
+
+	var rows []importer.StudentRow
+	var err error
+	if strings.EqualFold(filepath.Ext(flags.Arg(0)), ".xlsx") {
+		var data []byte
+		if data, err = io.ReadAll(input); err == nil {
+			rows, err = importer.ReadStudentsXLSX(bytes.NewReader(data), int64(len(data)))
+		}
+	} else {
+		rows, err = importer.ReadStudentsCSV(input)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read the students: %v", err)
+	}
+
+	result, err := importer.ImportStudents(db, rows, *dryRun)
+	for _, problem := range result.Errors {
+		fmt.Printf("line %d: %s\n", problem.Line, strings.Join(problem.Errors, "; "))
+	}
+	if err != nil {
+		log.Fatalf("Nothing was imported: %v", err)
+	}
+
+	verb := ""
+	if *dryRun {
+		verb = "would be "
+	}
+	fmt.Printf("%d students %screated, %d %supdated, %d unchanged, %d invalid\n",
+		result.Created, verb, result.Updated, verb, result.Skipped, len(result.Errors))
+}
+
+// importBooks adds books and copies from a CSV, MARC21 or MARCXML file. It
+// runs the same import as the API, recording it as an import job, but
+// waits for it to finish.
+func importBooks(args []string) {
+	flags := flag.NewFlagSet("import-books", flag.ExitOnError)
+	format := flags.String("format", "", "csv, marc or marcxml; taken from the file name when omitted")
+	serialPrefix := flags.String("serial-prefix", importer.DefaultSerialPrefix, "prefix of serial numbers given to copies without one")
+	rack := flags.String("rack", "", "rack number of copies without one")
+	vendorID := flags.Uint("vendor", 0, "vendor ID of copies without one")
+	itemType := flags.String("item-type", "", "item type of copies without one")
+	flags.Parse(args)
+	switch *itemType {
+	case "", models.ItemGeneral, models.ItemReference, models.ItemReserve:
+	default:
+		usageError("item type %q is not general, reference or reserve", *itemType)
+	}
+
+	input := openInput("import-books", flags.Args())
+	data, err := io.ReadAll(input)
+	input.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format == "" {
+		if *format, err = importer.DetectFormat(flags.Arg(0), data); err != nil {
+			log.Fatal(err)
+		}
+	}
+	_, db := connect()
+
+	job, err := importer.NewCatalogJob(db, filepath.Base(flags.Arg(0)), *format, nil)
+	if err != nil {
+		log.Fatalf("Failed to start the import: %v", err)
+	}
+	importer.RunCatalogImport(db, job, data, importer.CatalogOptions{
+		SerialPrefix: *serialPrefix,
+		RackNumber:   *rack,
+		VendorID:     uint(*vendorID),
+		ItemType:     *itemType,
+	})
+
+	var failures []models.ImportError
+	db.Where("import_job_id = ?", job.ID).Order("id").Find(&failures)
+	for _, failure := range failures {
+		fmt.Printf("%s: %s\n", failure.Record, failure.Message)
+	}
+	if job.Status == models.JobFailed {
+		log.Fatalf("Import %d failed: %s", job.ID, job.Message)
+	}
+	fmt.Printf("Import %d: %s; %d books and %d copies added, %d copies already present\n",
+		job.ID, job.Message, job.Books, job.Copies, job.Skipped)
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"gorm.io/gorm"
+	"library-management/migrations"
+	"library-management/storage"
+)
+
+// migrate applies, rolls back or lists the versioned schema migrations
+func migrate(args []string) {
+	cfg := loadConfig()
+	db := openDatabase(cfg)
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	var ran []migrations.Migration
+	var err error
+	switch {
+	case action == "up" && len(args) <= 1:
+		ran, err = migrations.Up(db)
+	case action == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps %q", args[1])
+			}
+		}
+		ran, err = migrations.Down(db, steps)
+	case action == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil || version < 0 {
+			log.Fatalf("Invalid version %q", args[1])
+		}
+		ran, err = migrations.To(db, version)
+	case action == "status" && len(args) == 1:
+		printMigrationStatus(db)
+		return
+	default:
+		usageError("unknown migrate action %q", action)
+	}
+
+	for _, m := range ran {
+		fmt.Printf("%s %04d_%s\n", action, m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if len(ran) == 0 {
+		fmt.Println("Nothing to do")
+	}
+
+	// Ebook files move out of the database once the schema has room for
+	// their metadata
+	if action == "up" {
+		moveEBooksToStore(db, openStore(cfg))
+	}
+}
+
+// moveEBooks moves ebook files still kept in the database to the
+// configured storage
+func moveEBooks(args []string) {
+	if len(args) > 0 {
+		usageError("move-ebooks takes no arguments")
+	}
+	cfg, db := connect()
+	moveEBooksToStore(db, openStore(cfg))
+}
+
+func moveEBooksToStore(db *gorm.DB, store storage.Store) {
+	moved, err := migrations.MoveEBooksToStore(context.Background(), db, store)
+	if moved > 0 {
+		fmt.Printf("Moved %d ebook files to storage\n", moved)
+	}
+	if err != nil {
+		log.Fatalf("Failed to move ebook files: %v", err)
+	}
+}
+
+// printMigrationStatus lists the migrations and whether they are applied
+func printMigrationStatus(db *gorm.DB) {
+	statuses, err := migrations.Status(db)
+	if err != nil {
+		log.Fatalf("Failed to read the migration status: %v", err)
+	}
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if status.Unknown {
+			state += " (not in this build)"
+		}
+		fmt.Printf("%04d  %-30s %s\n", status.Version, status.Name, state)
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"library-management/config"
+	"library-management/migrations"
+)
+
+// fixPhones reports student phone numbers that are not in E.164 form and,
+// with -apply, rewrites the ones that can be read
+func fixPhones(args []string) {
+	cfg := loadConfig()
+
+	flags := flag.NewFlagSet("fix-phones", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "rewrite the numbers instead of only reporting them")
+	region := flags.String("region", config.DefaultPhoneRegion, "country assumed for numbers without a country code")
+	flags.Parse(args)
+
+	db := openDatabase(cfg)
+	fixes, err := migrations.NormalizeStudentPhones(db, *region, *apply)
+	if err != nil {
+		log.Fatalf("Failed to normalize phone numbers: %v", err)
+	}
+
+	invalid := 0
+	for _, fix := range fixes {
+		if fix.Fixed == "" {
+			invalid++
+			fmt.Printf("%-6d %-15s %-20s INVALID, fix by hand\n", fix.StudentID, fix.USN, fix.Phone)
+		} else {
+			fmt.Printf("%-6d %-15s %-20s -> %s\n", fix.StudentID, fix.USN, fix.Phone, fix.Fixed)
+		}
+	}
+
+	verb := "can be fixed"
+	if *apply {
+		verb = "fixed"
+	}
+	fmt.Printf("%d numbers %s, %d invalid\n", len(fixes)-invalid, verb, invalid)
+	if !*apply && len(fixes) > invalid {
+		fmt.Println("Run again with -apply to rewrite them")
+	}
+}
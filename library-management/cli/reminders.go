@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"library-management/config"
+	"library-management/handlers"
+	"library-management/notify"
+)
+
+// sendReminders sends today's due date reminders once, as the scheduled
+// job does
+func sendReminders(args []string) {
+	if len(args) > 0 {
+		usageError("send-reminders takes no arguments")
+	}
+	_, db := connect()
+
+	// Set up the notification channels (SMS, email, webhook)
+	config.InitTwilio()
+	notify.SetDefault(notify.FromConfig())
+
+	queued := handlers.CheckDueDatesAndSendReminders(context.Background(), db)
+	fmt.Printf("%d reminders queued\n", queued)
+}
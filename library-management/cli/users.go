@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+	"gorm.io/gorm"
+	"library-management/handlers"
+	"library-management/models"
+	"library-management/utils"
+)
+
+// createUser adds a user with the given roles, by default an administrator
+func createUser(args []string) {
+	flags := flag.NewFlagSet("create-user", flag.ExitOnError)
+	username := flags.String("username", "", "login name of the new user")
+	roles := flags.String("roles", models.RoleAdmin, "comma separated roles to grant")
+	studentUSN := flags.String("student-usn", "", "USN of the student the account belongs to")
+	flags.Parse(args)
+	if *username == "" {
+		usageError("create-user needs -username")
+	}
+
+	_, db := connect()
+	password := readPassword()
+
+	// The roles must exist before they can be granted
+	if err := handlers.SeedRoles(db); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
+	var roleNames []string
+	for _, name := range strings.Split(*roles, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			roleNames = append(roleNames, name)
+		}
+	}
+	user, err := handlers.CreateUserWithRoles(db, *username, password, *studentUSN, roleNames)
+	if err != nil {
+		log.Fatalf("Failed to create user: %v", err)
+	}
+	fmt.Printf("Created user %s (id %d) with roles %s\n", user.Username, user.ID, strings.Join(roleNames, ", "))
+}
+
+// resetPassword sets a new password for a user and signs out all of the
+// user's sessions
+func resetPassword(args []string) {
+	flags := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	username := flags.String("username", "", "login name of the user")
+	flags.Parse(args)
+	if *username == "" {
+		usageError("reset-password needs -username")
+	}
+
+	_, db := connect()
+	var user models.User
+	if err := db.Where("username = ?", *username).Limit(1).Find(&user).Error; err != nil {
+		log.Fatal(err)
+	}
+	if user.ID == 0 {
+		log.Fatalf("There is no user %q", *username)
+	}
+	password := readPassword()
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Fatalf("Failed to hash the password: %v", err)
+	}
+
+	var revoked int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now())
+		revoked = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		log.Fatalf("Failed to reset the password: %v", err)
+	}
+	fmt.Printf("Password of %s reset, %d sessions signed out\n", user.Username, revoked)
+}
+
+// readPassword prompts for a password without echoing it. When standard
+// input is not a terminal, as in scripts, the first line of it is read.
+func readPassword() string {
+	var password string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		input, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Fatalf("Failed to read the password: %v", err)
+		}
+		password = string(input)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatal("No password given")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		log.Fatal("The password must not be empty")
+	}
+	return password
+}
@@ -0,0 +1,18 @@
+// Command libraryctl runs administration tasks against the library
+// database, using the same settings as the server.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"library-management/cli"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, cli.Usage())
+		os.Exit(2)
+	}
+	cli.Run(os.Args[1], os.Args[2:])
+}
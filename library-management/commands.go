@@ -0,0 +1,9 @@
+package main
+
+import "library-management/cli"
+
+// runCommand runs a one-off maintenance command instead of the server. The
+// same commands are available from the libraryctl tool.
+func runCommand(name string, args []string) {
+	cli.Run(name, args)
+}
@@ -0,0 +1,590 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyaruka/phonenumbers"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the server. It is read from a YAML or TOML
+// file and then from environment variables, which win over the file. The
+// env tag names the variable for each setting.
+type Config struct {
+	Server        ServerConfig        `yaml:"server" toml:"server"`
+	Database      DatabaseConfig      `yaml:"database" toml:"database"`
+	JWT           JWTConfig           `yaml:"jwt" toml:"jwt"`
+	SMS           SMSConfig           `yaml:"sms" toml:"sms"`
+	SMTP          SMTPConfig          `yaml:"smtp" toml:"smtp"`
+	Webhook       WebhookConfig       `yaml:"webhook" toml:"webhook"`
+	Notifications NotificationsConfig `yaml:"notifications" toml:"notifications"`
+	Scheduler     SchedulerConfig     `yaml:"scheduler" toml:"scheduler"`
+	Circulation   CirculationConfig   `yaml:"circulation" toml:"circulation"`
+	Storage       StorageConfig       `yaml:"storage" toml:"storage"`
+	Downloads     DownloadsConfig     `yaml:"downloads" toml:"downloads"`
+	Uploads       UploadsConfig       `yaml:"uploads" toml:"uploads"`
+}
+
+// ServerConfig is the HTTP listener
+type ServerConfig struct {
+	ListenAddr      string   `yaml:"listen_addr" toml:"listen_addr" env:"LISTEN_ADDR"`
+	AllowedOrigins  []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"` // How long open requests may take to finish on stop
+}
+
+// Database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// DatabaseConfig is the connection and pool of the database
+type DatabaseConfig struct {
+	Driver          string   `yaml:"driver" toml:"driver" env:"DATABASE_DRIVER"` // postgres or sqlite
+	DSN             string   `yaml:"dsn" toml:"dsn" env:"DATABASE_URL"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"` // 0 means unlimited
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"` // 0 keeps connections forever
+}
+
+// JWTConfig signs session tokens
+type JWTConfig struct {
+	Secret     string   `yaml:"secret" toml:"secret" env:"JWT_SECRET"`
+	AccessTTL  Duration `yaml:"access_ttl" toml:"access_ttl" env:"JWT_ACCESS_TTL"`
+	RefreshTTL Duration `yaml:"refresh_ttl" toml:"refresh_ttl" env:"JWT_REFRESH_TTL"`
+}
+
+// SMSConfig holds the Twilio credentials. SMS is disabled when they are empty.
+type SMSConfig struct {
+	AccountSID string `yaml:"account_sid" toml:"account_sid" env:"TWILIO_ACCOUNT_SID"`
+	AuthToken  string `yaml:"auth_token" toml:"auth_token" env:"TWILIO_AUTH_TOKEN"`
+	From       string `yaml:"from" toml:"from" env:"TWILIO_FROM"`
+}
+
+// SMTPConfig is the mail server for email notifications. Email is disabled
+// when Host is empty.
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"SMTP_PASSWORD"`
+	From     string `yaml:"from" toml:"from" env:"SMTP_FROM"`
+}
+
+// WebhookConfig is the endpoint for webhook notifications. Webhooks are
+// disabled when URL is empty.
+type WebhookConfig struct {
+	URL    string `yaml:"url" toml:"url" env:"NOTIFY_WEBHOOK_URL"`
+	Secret string `yaml:"secret" toml:"secret" env:"NOTIFY_WEBHOOK_SECRET"`
+}
+
+// NotificationsConfig covers defaults and retries of student notifications
+type NotificationsConfig struct {
+	DefaultChannels []string `yaml:"default_channels" toml:"default_channels" env:"NOTIFY_DEFAULT_CHANNELS"`
+	DefaultLanguage string   `yaml:"default_language" toml:"default_language" env:"NOTIFY_DEFAULT_LANGUAGE"`
+	PhoneRegion     string   `yaml:"phone_region" toml:"phone_region" env:"PHONE_DEFAULT_REGION"`
+	MaxAttempts     int      `yaml:"max_attempts" toml:"max_attempts" env:"NOTIFY_MAX_ATTEMPTS"`
+	RetryBaseDelay  Duration `yaml:"retry_base_delay" toml:"retry_base_delay" env:"NOTIFY_RETRY_BASE_DELAY"`
+	RetryInterval   Duration `yaml:"retry_interval" toml:"retry_interval" env:"NOTIFY_RETRY_INTERVAL"`
+}
+
+// SchedulerConfig holds the reminder and hold expiry job schedules
+type SchedulerConfig struct {
+	Enabled         bool     `yaml:"enabled" toml:"enabled" env:"SCHEDULER_ENABLED"`
+	DueSoonCron     string   `yaml:"due_soon_cron" toml:"due_soon_cron" env:"REMINDER_DUE_SOON_CRON"`
+	DueTodayCron    string   `yaml:"due_today_cron" toml:"due_today_cron" env:"REMINDER_DUE_TODAY_CRON"`
+	OverdueCron     string   `yaml:"overdue_cron" toml:"overdue_cron" env:"REMINDER_OVERDUE_CRON"`
+	ExpireHoldsCron string   `yaml:"expire_holds_cron" toml:"expire_holds_cron" env:"EXPIRE_HOLDS_CRON"`
+	DueSoonDays     int      `yaml:"due_soon_days" toml:"due_soon_days" env:"REMINDER_DUE_SOON_DAYS"`
+	LockTTL         Duration `yaml:"lock_ttl" toml:"lock_ttl" env:"JOB_LOCK_TTL"`
+}
+
+// CirculationConfig is the loan rule used when no circulation rule matches,
+// plus the hold and fine limits
+type CirculationConfig struct {
+	LoanPeriodDays             int      `yaml:"loan_period_days" toml:"loan_period_days" env:"LOAN_PERIOD_DAYS"`
+	MaxLoans                   int      `yaml:"max_loans" toml:"max_loans" env:"MAX_LOANS"`
+	MaxRenewals                int      `yaml:"max_renewals" toml:"max_renewals" env:"MAX_RENEWALS"`
+	FinePerDayMinor            int64    `yaml:"fine_per_day_minor" toml:"fine_per_day_minor" env:"FINE_PER_DAY_MINOR"`
+	FineCapMinor               int64    `yaml:"fine_cap_minor" toml:"fine_cap_minor" env:"FINE_CAP_MINOR"` // 0 means no cap
+	HoldPickupWindow           Duration `yaml:"hold_pickup_window" toml:"hold_pickup_window" env:"HOLD_PICKUP_WINDOW"`
+	MaxOutstandingBalanceMinor int64    `yaml:"max_outstanding_balance_minor" toml:"max_outstanding_balance_minor" env:"MAX_OUTSTANDING_BALANCE_MINOR"`
+}
+
+// DownloadsConfig covers the signed links students download ebooks with
+// and the stamping of downloaded PDFs
+type DownloadsConfig struct {
+	SigningSecret string   `yaml:"signing_secret" toml:"signing_secret" env:"DOWNLOAD_SIGNING_SECRET"` // Empty uses the JWT secret
+	LinkTTL       Duration `yaml:"link_ttl" toml:"link_ttl" env:"DOWNLOAD_LINK_TTL"`
+	DailyQuota    int      `yaml:"daily_quota" toml:"daily_quota" env:"DOWNLOAD_DAILY_QUOTA"`             // Downloads per student per day, 0 means unlimited
+	StampCacheTTL Duration `yaml:"stamp_cache_ttl" toml:"stamp_cache_ttl" env:"DOWNLOAD_STAMP_CACHE_TTL"` // How long a student's stamped copy is reused, 0 disables the cache
+}
+
+// Storage drivers
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// StorageConfig is where uploaded files such as ebooks are kept
+type StorageConfig struct {
+	Driver    string   `yaml:"driver" toml:"driver" env:"STORAGE_DRIVER"` // local or s3
+	LocalPath string   `yaml:"local_path" toml:"local_path" env:"STORAGE_LOCAL_PATH"`
+	S3        S3Config `yaml:"s3" toml:"s3"`
+}
+
+// S3Config is a bucket on Amazon S3 or an S3-compatible server such as MinIO
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint" env:"S3_ENDPOINT"` // e.g. https://s3.ap-south-1.amazonaws.com
+	Region    string `yaml:"region" toml:"region" env:"S3_REGION"`
+	Bucket    string `yaml:"bucket" toml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" toml:"access_key" env:"S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key" env:"S3_SECRET_KEY"`
+	PathStyle bool   `yaml:"path_style" toml:"path_style" env:"S3_PATH_STYLE"` // Bucket in the path rather than the host name, as MinIO needs
+}
+
+// Malware scanners
+const (
+	ScannerNone  = "none"
+	ScannerClamd = "clamd"
+)
+
+// UploadsConfig limits and checks uploaded ebook files
+type UploadsConfig struct {
+	MaxEBookSizeMB int           `yaml:"max_ebook_size_mb" toml:"max_ebook_size_mb" env:"UPLOAD_MAX_EBOOK_SIZE_MB"`
+	Scanner        ScannerConfig `yaml:"scanner" toml:"scanner"`
+}
+
+// ScannerConfig is the malware scanner uploaded files go through
+type ScannerConfig struct {
+	Driver       string   `yaml:"driver" toml:"driver" env:"SCANNER_DRIVER"`              // none or clamd
+	ClamdAddress string   `yaml:"clamd_address" toml:"clamd_address" env:"CLAMD_ADDRESS"` // e.g. unix:/run/clamav/clamd.ctl or tcp:127.0.0.1:3310
+	Timeout      Duration `yaml:"timeout" toml:"timeout" env:"CLAMD_TIMEOUT"`
+}
+
+// Duration is a time.Duration written as "15m" or "168h" in files and
+// environment variables
+type Duration time.Duration
+
+// UnmarshalText parses a duration such as "90s"
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText writes the duration in time.Duration notation
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default returns the settings used for anything the file and environment
+// leave out
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			ListenAddr:      ":8008",
+			AllowedOrigins:  []string{"http://localhost:3000"},
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Driver:          DriverPostgres,
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+		},
+		JWT: JWTConfig{
+			AccessTTL:  Duration(15 * time.Minute),
+			RefreshTTL: Duration(7 * 24 * time.Hour),
+		},
+		SMTP: SMTPConfig{Port: 587},
+		Notifications: NotificationsConfig{
+			DefaultLanguage: "en",
+			PhoneRegion:     "IN",
+			MaxAttempts:     5,
+			RetryBaseDelay:  Duration(time.Minute),
+			RetryInterval:   Duration(30 * time.Second),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:         true,
+			DueSoonCron:     "0 9 * * *",
+			DueTodayCron:    "0 8 * * *",
+			OverdueCron:     "0 10 * * *",
+			ExpireHoldsCron: "*/15 * * * *",
+			DueSoonDays:     2,
+			LockTTL:         Duration(10 * time.Minute),
+		},
+		Circulation: CirculationConfig{
+			LoanPeriodDays:             14,
+			MaxLoans:                   5,
+			MaxRenewals:                2,
+			FinePerDayMinor:            1000,
+			HoldPickupWindow:           Duration(3 * 24 * time.Hour),
+			MaxOutstandingBalanceMinor: 50000,
+		},
+		Storage: StorageConfig{
+			Driver:    StorageLocal,
+			LocalPath: "data/files",
+			S3:        S3Config{Region: "us-east-1"},
+		},
+		Downloads: DownloadsConfig{
+			LinkTTL:       Duration(15 * time.Minute),
+			DailyQuota:    10,
+			StampCacheTTL: Duration(10 * time.Minute),
+		},
+		Uploads: UploadsConfig{
+			MaxEBookSizeMB: 100,
+			Scanner: ScannerConfig{
+				Driver:       ScannerNone,
+				ClamdAddress: "unix:/run/clamav/clamd.ctl",
+				Timeout:      Duration(time.Minute),
+			},
+		},
+	}
+}
+
+// Path returns the config file to load: LIBRARY_CONFIG if set, otherwise
+// the first of config.yaml, config.yml and config.toml in the working
+// directory. It returns "" when there is no file.
+func Path() string {
+	if path := os.Getenv("LIBRARY_CONFIG"); path != "" {
+		return path
+	}
+	for _, path := range []string{"config.yaml", "config.yml", "config.toml"} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Load reads the defaults, then the file at path (if any), then the
+// environment, and validates the result for the server
+func Load(path string) (*Config, error) {
+	return load(path, true)
+}
+
+// LoadForCommand is Load for the maintenance commands. They never sign
+// session tokens, so the JWT settings are not required.
+func LoadForCommand(path string) (*Config, error) {
+	return load(path, false)
+}
+
+func load(path string, server bool) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+	cfg.defaultChannels()
+	if err := cfg.validate(server); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// readFile decodes a YAML or TOML file, picked by its extension. Unknown
+// keys are rejected so that typos do not go unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(c); err != nil {
+			var strict *toml.StrictMissingError
+			if errors.As(err, &strict) {
+				var keys []string
+				for _, unknown := range strict.Errors {
+					keys = append(keys, strings.Join(unknown.Key(), "."))
+				}
+				return fmt.Errorf("config: %s: unknown settings: %s", path, strings.Join(keys, ", "))
+			}
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config: %s: unsupported file type, use .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// applyEnv overrides every field that has an env tag and an environment
+// variable. A variable set to "" clears the setting: strings and lists
+// become empty, numbers 0 and switches false.
+func applyEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, info := v.Field(i), v.Type().Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := info.Tag.Get("env")
+		value, ok := os.LookupEnv(name)
+		if name == "" || !ok {
+			continue
+		}
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("config: %s=%q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(Duration(0)) {
+		return field.Addr().Interface().(*Duration).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("not a whole number")
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not true or false")
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// defaultChannels fills in the notification channels when none are given:
+// SMS once it is set up, as before other channels existed, and otherwise
+// only the server log
+func (c *Config) defaultChannels() {
+	if len(c.Notifications.DefaultChannels) > 0 {
+		return
+	}
+	c.Notifications.DefaultChannels = []string{"log"}
+	if c.SMS.AccountSID != "" || c.SMS.AuthToken != "" || c.SMS.From != "" {
+		c.Notifications.DefaultChannels = []string{"sms"}
+	}
+}
+
+// Validate checks the settings and reports every problem at once
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// validate checks the settings; the ones only the server uses are checked
+// when server is set
+func (c *Config) validate(server bool) error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.ListenAddr != "", "server.listen_addr (LISTEN_ADDR) must not be empty")
+	for _, origin := range c.Server.AllowedOrigins {
+		u, err := url.Parse(origin)
+		check(err == nil && u.Scheme != "" && u.Host != "",
+			"server.allowed_origins: %q is not an origin such as https://library.example.edu", origin)
+	}
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+
+	check(c.Database.Driver == DriverPostgres || c.Database.Driver == DriverSQLite,
+		"database.driver (DATABASE_DRIVER) must be %s or %s, not %q", DriverPostgres, DriverSQLite, c.Database.Driver)
+	check(c.Database.DSN != "", "database.dsn (DATABASE_URL) is required")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns (DB_MAX_OPEN_CONNS) must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns (DB_MAX_IDLE_CONNS) must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns (%d) must not exceed database.max_open_conns (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime (DB_CONN_MAX_LIFETIME) must not be negative")
+
+	check(!server || c.JWT.Secret != "", "jwt.secret (JWT_SECRET) is required to sign session tokens")
+	check(c.JWT.AccessTTL > 0, "jwt.access_ttl (JWT_ACCESS_TTL) must be positive")
+	check(c.JWT.RefreshTTL > c.JWT.AccessTTL, "jwt.refresh_ttl (JWT_REFRESH_TTL) must be longer than jwt.access_ttl")
+
+	smsSet := c.SMS.AccountSID != "" || c.SMS.AuthToken != "" || c.SMS.From != ""
+	check(!smsSet || (c.SMS.AccountSID != "" && c.SMS.AuthToken != "" && c.SMS.From != ""),
+		"sms: account_sid (TWILIO_ACCOUNT_SID), auth_token (TWILIO_AUTH_TOKEN) and from (TWILIO_FROM) must all be set to enable SMS")
+
+	if c.SMTP.Host != "" {
+		check(c.SMTP.Port > 0 && c.SMTP.Port < 65536, "smtp.port (SMTP_PORT) must be between 1 and 65535")
+		check(c.SMTP.From != "", "smtp.from (SMTP_FROM) is required when smtp.host is set")
+	}
+
+	if c.Webhook.URL != "" {
+		u, err := url.Parse(c.Webhook.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"webhook.url (NOTIFY_WEBHOOK_URL) must be an http or https URL")
+	}
+
+	enabled := map[string]bool{"log": true, "sms": smsSet, "email": c.SMTP.Host != "", "webhook": c.Webhook.URL != ""}
+	for _, channel := range c.Notifications.DefaultChannels {
+		on, known := enabled[channel]
+		check(known, "notifications.default_channels: unknown channel %q, use log, sms, email or webhook", channel)
+		check(!known || on, "notifications.default_channels: %q is not configured; set its %s settings or remove it", channel, channel)
+	}
+	check(isLanguage(c.Notifications.DefaultLanguage),
+		"notifications.default_language (NOTIFY_DEFAULT_LANGUAGE) must be one of en, kn, hi")
+	check(phonenumbers.GetCountryCodeForRegion(strings.ToUpper(c.Notifications.PhoneRegion)) != 0,
+		"notifications.phone_region (PHONE_DEFAULT_REGION): %q is not a known country code such as IN", c.Notifications.PhoneRegion)
+	check(c.Notifications.MaxAttempts > 0, "notifications.max_attempts (NOTIFY_MAX_ATTEMPTS) must be at least 1")
+	check(c.Notifications.RetryBaseDelay > 0, "notifications.retry_base_delay (NOTIFY_RETRY_BASE_DELAY) must be positive")
+	check(c.Notifications.RetryInterval > 0, "notifications.retry_interval (NOTIFY_RETRY_INTERVAL) must be positive")
+
+	for name, spec := range map[string]string{
+		"scheduler.due_soon_cron (REMINDER_DUE_SOON_CRON)":   c.Scheduler.DueSoonCron,
+		"scheduler.due_today_cron (REMINDER_DUE_TODAY_CRON)": c.Scheduler.DueTodayCron,
+		"scheduler.overdue_cron (REMINDER_OVERDUE_CRON)":     c.Scheduler.OverdueCron,
+		"scheduler.expire_holds_cron (EXPIRE_HOLDS_CRON)":    c.Scheduler.ExpireHoldsCron,
+	} {
+		_, err := cron.ParseStandard(spec)
+		check(err == nil, "%s: %q is not a valid cron expression: %v", name, spec, err)
+	}
+	check(c.Scheduler.DueSoonDays > 0, "scheduler.due_soon_days (REMINDER_DUE_SOON_DAYS) must be at least 1")
+	check(c.Scheduler.LockTTL > 0, "scheduler.lock_ttl (JOB_LOCK_TTL) must be positive")
+
+	check(c.Circulation.LoanPeriodDays > 0, "circulation.loan_period_days (LOAN_PERIOD_DAYS) must be at least 1")
+	check(c.Circulation.MaxLoans > 0, "circulation.max_loans (MAX_LOANS) must be at least 1")
+	check(c.Circulation.MaxRenewals >= 0, "circulation.max_renewals (MAX_RENEWALS) must not be negative")
+	check(c.Circulation.FinePerDayMinor >= 0, "circulation.fine_per_day_minor (FINE_PER_DAY_MINOR) must not be negative")
+	check(c.Circulation.FineCapMinor >= 0, "circulation.fine_cap_minor (FINE_CAP_MINOR) must not be negative")
+	check(c.Circulation.HoldPickupWindow > 0, "circulation.hold_pickup_window (HOLD_PICKUP_WINDOW) must be positive")
+	check(c.Circulation.MaxOutstandingBalanceMinor >= 0,
+		"circulation.max_outstanding_balance_minor (MAX_OUTSTANDING_BALANCE_MINOR) must not be negative")
+
+	check(c.Downloads.LinkTTL > 0, "downloads.link_ttl (DOWNLOAD_LINK_TTL) must be positive")
+	check(c.Downloads.DailyQuota >= 0, "downloads.daily_quota (DOWNLOAD_DAILY_QUOTA) must not be negative")
+	check(c.Downloads.StampCacheTTL >= 0, "downloads.stamp_cache_ttl (DOWNLOAD_STAMP_CACHE_TTL) must not be negative")
+
+	switch c.Storage.Driver {
+	case StorageLocal:
+		check(c.Storage.LocalPath != "", "storage.local_path (STORAGE_LOCAL_PATH) is required for local storage")
+	case StorageS3:
+		u, err := url.Parse(c.Storage.S3.Endpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"storage.s3.endpoint (S3_ENDPOINT) must be an http or https URL")
+		check(c.Storage.S3.Region != "", "storage.s3.region (S3_REGION) is required")
+		check(c.Storage.S3.Bucket != "", "storage.s3.bucket (S3_BUCKET) is required")
+		check(c.Storage.S3.AccessKey != "" && c.Storage.S3.SecretKey != "",
+			"storage.s3.access_key (S3_ACCESS_KEY) and storage.s3.secret_key (S3_SECRET_KEY) are required")
+	default:
+		check(false, "storage.driver (STORAGE_DRIVER) must be %s or %s, not %q", StorageLocal, StorageS3, c.Storage.Driver)
+	}
+
+	check(c.Uploads.MaxEBookSizeMB > 0, "uploads.max_ebook_size_mb (UPLOAD_MAX_EBOOK_SIZE_MB) must be positive")
+	switch c.Uploads.Scanner.Driver {
+	case ScannerNone:
+	case ScannerClamd:
+		network, address, _ := strings.Cut(c.Uploads.Scanner.ClamdAddress, ":")
+		check((network == "unix" || network == "tcp") && address != "",
+			"uploads.scanner.clamd_address (CLAMD_ADDRESS) must look like unix:/path/to/clamd.ctl or tcp:host:port")
+		check(c.Uploads.Scanner.Timeout > 0, "uploads.scanner.timeout (CLAMD_TIMEOUT) must be positive")
+	default:
+		check(false, "uploads.scanner.driver (SCANNER_DRIVER) must be %s or %s, not %q", ScannerNone, ScannerClamd, c.Uploads.Scanner.Driver)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Apply makes the settings visible to the packages that read the config
+// variables
+func (c *Config) Apply() {
+	JWTSecret = []byte(c.JWT.Secret)
+	AccessTokenTTL = time.Duration(c.JWT.AccessTTL)
+	RefreshTokenTTL = time.Duration(c.JWT.RefreshTTL)
+
+	TwilioAccountSID = c.SMS.AccountSID
+	TwilioAuthToken = c.SMS.AuthToken
+	TwilioFrom = c.SMS.From
+
+	SMTPHost = c.SMTP.Host
+	SMTPPort = c.SMTP.Port
+	SMTPUsername = c.SMTP.Username
+	SMTPPassword = c.SMTP.Password
+	SMTPFrom = c.SMTP.From
+	WebhookURL = c.Webhook.URL
+	WebhookSecret = c.Webhook.Secret
+
+	DefaultNotifyChannels = c.Notifications.DefaultChannels
+	DefaultLanguage = c.Notifications.DefaultLanguage
+	DefaultPhoneRegion = strings.ToUpper(c.Notifications.PhoneRegion)
+	NotifyMaxAttempts = c.Notifications.MaxAttempts
+	NotifyRetryBaseDelay = time.Duration(c.Notifications.RetryBaseDelay)
+	NotifyRetryInterval = time.Duration(c.Notifications.RetryInterval)
+
+	SchedulerEnabled = c.Scheduler.Enabled
+	DueSoonCron = c.Scheduler.DueSoonCron
+	DueTodayCron = c.Scheduler.DueTodayCron
+	OverdueCron = c.Scheduler.OverdueCron
+	ExpireHoldsCron = c.Scheduler.ExpireHoldsCron
+	DueSoonDays = c.Scheduler.DueSoonDays
+	JobLockTTL = time.Duration(c.Scheduler.LockTTL)
+
+	DownloadSigningSecret = []byte(c.Downloads.SigningSecret)
+	if len(DownloadSigningSecret) == 0 {
+		DownloadSigningSecret = JWTSecret
+	}
+	DownloadLinkTTL = time.Duration(c.Downloads.LinkTTL)
+	DownloadDailyQuota = c.Downloads.DailyQuota
+	DownloadStampCacheTTL = time.Duration(c.Downloads.StampCacheTTL)
+
+	MaxEBookSize = int64(c.Uploads.MaxEBookSizeMB) << 20
+}
+
+func isLanguage(language string) bool {
+	switch language {
+	case "en", "kn", "hi":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExampleFileLoads(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	cfg, err := Load("../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.Notifications.DefaultChannels, ","); got != "log" {
+		t.Errorf("default channels = %s", got)
+	}
+}
+
+func TestCommandsDoNotNeedJWTSecret(t *testing.T) {
+	t.Setenv("DATABASE_URL", "library.db")
+	t.Setenv("JWT_SECRET", "")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Errorf("the server loaded without a JWT secret: %v", err)
+	}
+	if _, err := LoadForCommand(""); err != nil {
+		t.Errorf("a command needed: %v", err)
+	}
+}
+
+func TestEmptyEnvironmentClearsSettings(t *testing.T) {
+	t.Setenv("DATABASE_URL", "library.db")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("MAX_OUTSTANDING_BALANCE_MINOR", "")
+	t.Setenv("SCHEDULER_ENABLED", "")
+	cfg, err := Load("../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Server.AllowedOrigins) != 0 || cfg.Circulation.MaxOutstandingBalanceMinor != 0 || cfg.Scheduler.Enabled {
+		t.Errorf("origins %v, balance limit %d, scheduler %v", cfg.Server.AllowedOrigins, cfg.Circulation.MaxOutstandingBalanceMinor, cfg.Scheduler.Enabled)
+	}
+
+	// A required setting cleared from the environment is reported
+	t.Setenv("DATABASE_URL", "")
+	if _, err := Load("../config.example.yaml"); err == nil || !strings.Contains(err.Error(), "DATABASE_URL") {
+		t.Errorf("loading without a database gave %v", err)
+	}
+}
+
+func TestDefaultChannelsKeepSMS(t *testing.T) {
+	t.Setenv("DATABASE_URL", "library.db")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("TWILIO_ACCOUNT_SID", "AC123")
+	t.Setenv("TWILIO_AUTH_TOKEN", "token")
+	t.Setenv("TWILIO_FROM", "+15005550006")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.Notifications.DefaultChannels, ","); got != "sms" {
+		t.Errorf("default channels with SMS set up = %s", got)
+	}
+
+	// Channels given explicitly are kept
+	t.Setenv("NOTIFY_DEFAULT_CHANNELS", "log")
+	if cfg, _ := Load(""); strings.Join(cfg.Notifications.DefaultChannels, ",") != "log" {
+		t.Errorf("default channels = %v", cfg.Notifications.DefaultChannels)
+	}
+}
+
+func TestDefaultChannelsMustBeConfigured(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "library.db"
+	cfg.JWT.Secret = "secret"
+	cfg.Notifications.DefaultChannels = []string{"log"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("the defaults are invalid: %v", err)
+	}
+	cfg.Notifications.DefaultChannels = []string{"sms"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `"sms" is not configured`) {
+		t.Errorf("sms without settings gave %v", err)
+	}
+}
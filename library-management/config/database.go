@@ -1,20 +0,0 @@
-package config
-
-
-import (
-	"log"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
-
-var DB *gorm.DB
-
-func ConnectDatabase() {
-	dsn := ""//enter your DB Connection String
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	DB = database
-	log.Println("Database connected successfully!")
-}
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// Ebook download link settings
+var (
+	DownloadSigningSecret []byte
+	DownloadLinkTTL       = 15 * time.Minute
+	DownloadDailyQuota    = 10 // Per student per day, 0 means unlimited
+	DownloadStampCacheTTL = 10 * time.Minute
+)
@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+// JWT settings used to sign and verify session tokens
+var (
+	JWTSecret       []byte
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
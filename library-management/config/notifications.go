@@ -0,0 +1,37 @@
+package config
+
+import "time"
+
+// SMTP settings for email notifications
+var (
+	SMTPHost     string
+	SMTPPort     = 587
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+)
+
+// Webhook settings for HTTP notifications
+var (
+	WebhookURL    string
+	WebhookSecret string // Used to sign webhook bodies; optional
+)
+
+// DefaultNotifyChannels are used for students without channel preferences
+var DefaultNotifyChannels = []string{"log"}
+
+// DefaultLanguage is used for students without a language preference
+var DefaultLanguage = "en"
+
+// DefaultPhoneRegion is the country assumed for phone numbers written
+// without a country code
+var DefaultPhoneRegion = "IN"
+
+// Outbox retry settings. A failed message is retried after
+// NotifyRetryBaseDelay, doubling each time to at most a day, up to
+// NotifyMaxAttempts sends.
+var (
+	NotifyMaxAttempts    = 5
+	NotifyRetryBaseDelay = time.Minute
+	NotifyRetryInterval  = 30 * time.Second // How often the worker looks for due retries
+)
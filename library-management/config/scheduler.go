@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// Cron expressions for the reminder and hold expiry jobs (minute hour day
+// month weekday)
+var (
+	SchedulerEnabled = true
+	DueSoonCron      = "0 9 * * *"
+	DueTodayCron     = "0 8 * * *"
+	OverdueCron      = "0 10 * * *"
+	ExpireHoldsCron  = "*/15 * * * *"
+)
+
+// DueSoonDays is how many days ahead due-soon reminders look
+var DueSoonDays = 2
+
+// JobLockTTL is how long a replica holds a job lock while it runs
+var JobLockTTL = 10 * time.Minute
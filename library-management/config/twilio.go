@@ -2,19 +2,30 @@ package config
 
 import (
 	"log"
-	"fmt"
 	"github.com/twilio/twilio-go"
 	api "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
 var client *twilio.RestClient
 
+// Twilio credentials and sending number; SMS is disabled while they are empty
+var (
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string
+)
+
+// SMSEnabled reports whether Twilio credentials are configured
+func SMSEnabled() bool {
+	return TwilioAccountSID != "" && TwilioAuthToken != "" && TwilioFrom != ""
+}
+
 
 // InitTwilio initializes the Twilio client
 func InitTwilio() {
 	client = twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: "", // Your Twilio Account SID
-		Password: "",  // Your Twilio Auth Token
+		Username: TwilioAccountSID,
+		Password: TwilioAuthToken,
 	})
 
 	if client == nil {
@@ -27,34 +38,28 @@ func InitTwilio() {
 
 // SendSMS sends an SMS message using Twilio
 func SendSMS(to, message string) error {
+	_, err := SendSMSWithSID(to, message)
+	return err
+}
+
+// SendSMSWithSID sends an SMS message using Twilio and returns the message SID
+func SendSMSWithSID(to, message string) (string, error) {
 	// Create the message parameters
 	params := &api.CreateMessageParams{}
 	params.SetTo(to)
-	params.SetFrom("") // Your Twilio phone number
+	params.SetFrom(TwilioFrom)
 	params.SetBody(message)
 
 	// Send the message using the Twilio client
-	_, err := client.Api.CreateMessage(params)
+	resp, err := client.Api.CreateMessage(params)
 	if err != nil {
 		log.Println("Error sending SMS:", err)
-		return err
+		return "", err
 	}
 
 	log.Println("SMS sent successfully!")
-	return nil
-}
-// SendDueDateReminder sends an SMS reminder for the due date
-func SendDueDateReminder(phoneNumber, dueDate, studentName string) error {
-	// Craft the reminder message
-	message := fmt.Sprintf("Hello %s, this is a reminder: Your book is due on %s. Please return it on time.", studentName, dueDate)
-
-	// Use the SendSMS function from config to send the message
-	err := SendSMS(phoneNumber, message)
-	if err != nil {
-		log.Println("Error sending reminder:", err)
-		return err
+	if resp.Sid == nil {
+		return "", nil
 	}
-
-	log.Println("Reminder sent successfully!")
-	return nil
-}
\ No newline at end of file
+	return *resp.Sid, nil
+}
@@ -0,0 +1,4 @@
+package config
+
+// MaxEBookSize is the largest ebook file that can be uploaded, in bytes
+var MaxEBookSize int64 = 100 << 20
@@ -0,0 +1,75 @@
+// Package database opens the configured database and holds the few query
+// pieces that differ between the supported backends.
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library-management/config"
+)
+
+// sqlitePragmas are applied to SQLite files unless the DSN sets its own.
+// WAL and the busy timeout let the web server and the scheduler share the
+// file; SQLite leaves foreign keys off unless asked.
+var sqlitePragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
+// Open connects to the database named by the settings and sets up the
+// connection pool
+func Open(settings config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch settings.Driver {
+	case config.DriverPostgres, "":
+		dialector = postgres.Open(settings.DSN)
+	case config.DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(settings.DSN))
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", settings.Driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(settings.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(settings.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(settings.ConnMaxLifetime))
+	return db, nil
+}
+
+// sqliteDSN adds the default pragmas to a SQLite file name
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_pragma=") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	for _, pragma := range sqlitePragmas {
+		dsn += separator + "_pragma=" + pragma
+		separator = "&"
+	}
+	return dsn
+}
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ContainsFold matches rows whose column contains text, ignoring case. It
+// replaces Postgres' ILIKE so that searches give the same results on every
+// backend; wildcards typed by the user match literally.
+func ContainsFold(column, text string) clause.Expr {
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(text)) + "%"
+	return gorm.Expr("LOWER("+column+") LIKE ? ESCAPE '\\'", pattern)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"library-management/config"
+)
+
+func openSQLite(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	db, err := Open(config.DatabaseConfig{Driver: config.DriverSQLite, DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestContainsFold(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "library.db"))
+	type book struct {
+		ID    uint
+		Title string
+	}
+	if err := db.AutoMigrate(&book{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Go Programming", "100% Go", "100 Go Tips", "snake_case", "snakeXcase", `C:\Temp`, `C:Temp`} {
+		if err := db.Create(&book{Title: title}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for text, want := range map[string][]string{
+		"go":          {"100 Go Tips", "100% Go", "Go Programming"},
+		"PROGRAMMING": {"Go Programming"},
+		"%":           {"100% Go"},
+		"100%":        {"100% Go"},
+		"_":           {"snake_case"},
+		"e_c":         {"snake_case"},
+		`\`:           {`C:\Temp`},
+		`:\t`:         {`C:\Temp`},
+		"":            {"100 Go Tips", "100% Go", `C:Temp`, `C:\Temp`, "Go Programming", "snakeXcase", "snake_case"},
+		"missing":     nil,
+	} {
+		var books []book
+		if err := db.Where(ContainsFold("title", text)).Find(&books).Error; err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		var got []string
+		for _, b := range books {
+			got = append(got, b.Title)
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%q matched %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"library.db":                       "library.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+		"file:library.db?mode=rwc":         "file:library.db?mode=rwc&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+		"library.db?_pragma=cache_size(0)": "library.db?_pragma=cache_size(0)",
+	} {
+		if got := sqliteDSN(dsn); got != want {
+			t.Errorf("sqliteDSN(%q) = %q", dsn, got)
+		}
+	}
+
+	// Open applies them
+	db := openSQLite(t, filepath.Join(t.TempDir(), "library.db"))
+	for pragma, want := range map[string]string{
+		"foreign_keys": "1",
+		"busy_timeout": "5000",
+		"journal_mode": "wal",
+	} {
+		var got string
+		if err := db.Raw("PRAGMA " + pragma).Scan(&got).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("PRAGMA %s = %s, want %s", pragma, got, want)
+		}
+	}
+}
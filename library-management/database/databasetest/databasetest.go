@@ -0,0 +1,36 @@
+// Package databasetest opens throwaway databases for tests.
+package databasetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"library-management/config"
+	"library-management/database"
+	"library-management/migrations"
+)
+
+// Open returns a SQLite database in a temporary directory with every
+// migration applied. It is closed when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := database.Open(config.DatabaseConfig{
+		Driver: config.DriverSQLite,
+		DSN:    filepath.Join(t.TempDir(), "library.db"),
+	})
+	if err != nil {
+		t.Fatalf("opening the test database: %v", err)
+	}
+	db.Logger = logger.Discard
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("migrating the test database: %v", err)
+	}
+	return db
+}
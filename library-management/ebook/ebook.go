@@ -0,0 +1,79 @@
+// Package ebook checks uploaded ebook files and reads the details the
+// catalogue keeps about them: page count, title and author.
+package ebook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	"library-management/watermark"
+)
+
+// Accepted formats
+const (
+	PDF  = "application/pdf"
+	EPUB = "application/epub+zip"
+)
+
+// ErrUnsupported is returned for files that are neither PDF nor EPUB
+var ErrUnsupported = errors.New("only PDF and EPUB files are accepted")
+
+// Details are what an ebook file says about itself
+type Details struct {
+	Pages  int // 0 when the file does not say, as most EPUBs don't
+	Title  string
+	Author string
+}
+
+// maxTextLength caps the title and author taken from a file
+const maxTextLength = 500
+
+// DetectType works out the format from the file's first bytes, whatever
+// its name or the browser says
+func DetectType(r io.Reader) (string, error) {
+	detected, err := mimetype.DetectReader(r)
+	if err != nil {
+		return "", err
+	}
+	for _, mimeType := range []string{PDF, EPUB} {
+		if detected.Is(mimeType) {
+			return mimeType, nil
+		}
+	}
+	return "", fmt.Errorf("%w, this looks like %s", ErrUnsupported, detected.String())
+}
+
+// Inspect reads the details of a file of the given type. Files that cannot
+// be read are refused, as students could not open them either.
+func Inspect(r io.ReaderAt, size int64, mimeType string) (Details, error) {
+	var details Details
+	switch mimeType {
+	case PDF:
+		doc, err := watermark.Describe(r, size)
+		if err != nil {
+			return Details{}, err
+		}
+		details = Details{Pages: doc.Pages, Title: doc.Title, Author: doc.Author}
+	case EPUB:
+		var err error
+		if details, err = inspectEPUB(r, size); err != nil {
+			return Details{}, err
+		}
+	default:
+		return Details{}, ErrUnsupported
+	}
+	details.Title = truncate(details.Title)
+	details.Author = truncate(details.Author)
+	return details, nil
+}
+
+func truncate(text string) string {
+	if utf8.RuneCountInString(text) <= maxTextLength {
+		return text
+	}
+	return strings.TrimSpace(string([]rune(text)[:maxTextLength]))
+}
@@ -0,0 +1,162 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPDF is a one-page PDF with a title and author
+func testPDF() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for _, body := range []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 595 842] >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"<< /Title (The Go Programming Language) /Author (Donovan) >>",
+	} {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// testEPUB zips the given files after the mimetype entry every EPUB starts
+// with
+func testEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(EPUB))
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+func TestDetectType(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"pdf":  {testPDF(), PDF},
+		"epub": {testEPUB(t, map[string]string{"META-INF/container.xml": testContainer}), EPUB},
+	} {
+		got, err := DetectType(bytes.NewReader(tc.data))
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"text": []byte("just some notes"),
+		"zip":  zipOf(t, "notes.txt"),
+		"png":  []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+	} {
+		if _, err := DetectType(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func zipOf(t *testing.T, name string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, _ := archive.Create(name)
+	w.Write([]byte("hello"))
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspectPDF(t *testing.T) {
+	data := testPDF()
+	details, err := Inspect(bytes.NewReader(data), int64(len(data)), PDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details != (Details{Pages: 1, Title: "The Go Programming Language", Author: "Donovan"}) {
+		t.Errorf("details = %+v", details)
+	}
+}
+
+func TestInspectEPUB(t *testing.T) {
+	data := testEPUB(t, map[string]string{
+		"META-INF/container.xml": testContainer,
+		"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>  The Go
+      Programming Language </dc:title>
+    <dc:creator>Alan Donovan</dc:creator>
+    <dc:creator> </dc:creator>
+    <dc:creator>Brian Kernighan</dc:creator>
+    <meta property="schema:numberOfPages">380</meta>
+  </metadata>
+</package>`,
+	})
+	details, err := Inspect(bytes.NewReader(data), int64(len(data)), EPUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Details{Pages: 380, Title: "The Go Programming Language", Author: "Alan Donovan, Brian Kernighan"}
+	if details != want {
+		t.Errorf("details = %+v", details)
+	}
+
+	// EPUB 2 files give the page count as a name and content pair
+	data = testEPUB(t, map[string]string{
+		"META-INF/container.xml": testContainer,
+		"OEBPS/content.opf":      `<package><metadata><title>` + strings.Repeat("x", 600) + `</title><meta name="schema:numberOfPages" content="12"/></metadata></package>`,
+	})
+	if details, err := inspectEPUB(bytes.NewReader(data), int64(len(data))); err != nil || details.Pages != 12 {
+		t.Errorf("EPUB 2 details = %+v, %v", details, err)
+	}
+	if details, _ := Inspect(bytes.NewReader(data), int64(len(data)), EPUB); len(details.Title) != maxTextLength {
+		t.Errorf("the title was kept at %d characters", len(details.Title))
+	}
+}
+
+func TestInspectEPUBRefusesBrokenFiles(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"not a zip":       []byte("PK\x03\x04 but not really"),
+		"no container":    testEPUB(t, map[string]string{"OEBPS/content.opf": "<package/>"}),
+		"empty container": testEPUB(t, map[string]string{"META-INF/container.xml": "<container><rootfiles/></container>"}),
+		"no package":      testEPUB(t, map[string]string{"META-INF/container.xml": testContainer}),
+	} {
+		if _, err := inspectEPUB(bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ebook
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxXMLSize limits how much of the container and package documents is
+// read, so a compressed bomb cannot exhaust memory
+const maxXMLSize = 4 << 20
+
+// inspectEPUB reads the title, authors and, if given, the page count from
+// an EPUB's package document
+func inspectEPUB(r io.ReaderAt, size int64) (Details, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return Details{}, fmt.Errorf("the EPUB is not a valid ZIP archive: %w", err)
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := readXML(archive, "META-INF/container.xml", &container); err != nil {
+		return Details{}, err
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return Details{}, fmt.Errorf("the EPUB has no package document")
+	}
+
+	var pkg struct {
+		Titles   []string `xml:"metadata>title"`
+		Creators []string `xml:"metadata>creator"`
+		Meta     []struct {
+			Name     string `xml:"name,attr"`
+			Content  string `xml:"content,attr"`
+			Property string `xml:"property,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"metadata>meta"`
+	}
+	// The first rootfile is the default rendition
+	if err := readXML(archive, path.Clean(strings.TrimPrefix(container.Rootfiles[0].FullPath, "/")), &pkg); err != nil {
+		return Details{}, err
+	}
+
+	var details Details
+	if len(pkg.Titles) > 0 {
+		details.Title = strings.Join(strings.Fields(pkg.Titles[0]), " ")
+	}
+	var authors []string
+	for _, creator := range pkg.Creators {
+		if creator = strings.Join(strings.Fields(creator), " "); creator != "" {
+			authors = append(authors, creator)
+		}
+	}
+	details.Author = strings.Join(authors, ", ")
+	// Page counts are optional; publishers give them as schema.org metadata
+	for _, meta := range pkg.Meta {
+		value := ""
+		switch {
+		case meta.Property == "schema:numberOfPages":
+			value = meta.Value
+		case meta.Name == "schema:numberOfPages":
+			value = meta.Content
+		}
+		if pages, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && pages > 0 {
+			details.Pages = pages
+		}
+	}
+	return details, nil
+}
+
+// readXML decodes the named file in the archive
+func readXML(archive *zip.Reader, name string, v interface{}) error {
+	f, err := archive.Open(name)
+	if err != nil {
+		return fmt.Errorf("the EPUB has no %s", name)
+	}
+	defer f.Close()
+	decoder := xml.NewDecoder(io.LimitReader(f, maxXMLSize))
+	decoder.Strict = false
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("the EPUB's %s cannot be read: %w", name, err)
+	}
+	return nil
+}
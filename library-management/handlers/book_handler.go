@@ -1,24 +1,62 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
 	"net/http"
 	"io"  // Use io instead of ioutil
-    "time" // Add this for time-related functions
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/ebook"
 	"library-management/models"
+	"library-management/repository"
+	"library-management/scanner"
+	"library-management/services"
+	"library-management/storage"
 )
 
-// Get all books
+// BookSummary is a title together with its copy availability
+type BookSummary struct {
+    models.Book
+    TotalCopies     int `json:"total_copies"`
+    AvailableCopies int `json:"available_copies"`
+}
+
+// summarizeBooks counts the total and available copies of each book
+func summarizeBooks(books []models.Book) []BookSummary {
+    summaries := make([]BookSummary, 0, len(books))
+    for _, book := range books {
+        summary := BookSummary{Book: book, TotalCopies: len(book.Copies)}
+        for _, bookCopy := range book.Copies {
+            if bookCopy.Status == models.CopyAvailable {
+                summary.AvailableCopies++
+            }
+        }
+        summaries = append(summaries, summary)
+    }
+    return summaries
+}
+
+// Get all books grouped by title with their copies
 func GetBooks(c *gin.Context, db *gorm.DB) {
-    var books []models.Book
-    if result := db.Find(&books); result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+    books, err := repository.NewGormStore(db).Books().List()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
     // Books already have nil values for nullable fields, so no need to manually check
-    c.JSON(http.StatusOK, books)
+    c.JSON(http.StatusOK, summarizeBooks(books))
 }
 
 
@@ -35,6 +73,7 @@ func CreateBook(c *gin.Context, db *gorm.DB) {
         Copies        int      `json:"copies" binding:"required,min=1"`
         SerialNumbers []string `json:"serial_numbers" binding:"required"`
         RackNumbers   []string `json:"rack_numbers" binding:"required"`
+        ItemType      string   `json:"item_type" binding:"omitempty,oneof=general reference reserve"`
         Note          string   `json:"note"`
     }
 
@@ -50,164 +89,504 @@ func CreateBook(c *gin.Context, db *gorm.DB) {
         return
     }
 
-    // Create books in a loop
-    var createdBooks []models.Book
+    // Create the title once and a copy for each serial number
+    book := models.Book{
+        Title:         bookInput.Title,
+        Subtitle:      bookInput.Subtitle,
+        Author:        bookInput.Author,
+        Edition:       bookInput.Edition,
+        Publisher:     bookInput.Publisher,
+        PublisherYear: bookInput.PublisherYear, // Use direct assignment for int
+        Note:          bookInput.Note,
+    }
     for i := 0; i < bookInput.Copies; i++ {
-        book := models.Book{
-            Title:         bookInput.Title,
-            Subtitle:      bookInput.Subtitle,
-            Author:        bookInput.Author,
-            Edition:       bookInput.Edition,
-            Publisher:     bookInput.Publisher,
-            PublisherYear: bookInput.PublisherYear, // Use direct assignment for int
-            VendorID:      uint(bookInput.VendorID), // Convert int to uint
-            SerialNumber:  bookInput.SerialNumbers[i],
-            RackNumber:    bookInput.RackNumbers[i],
-            Note:          bookInput.Note,
-        }
+        book.Copies = append(book.Copies, models.Copy{
+            SerialNumber: bookInput.SerialNumbers[i],
+            RackNumber:   bookInput.RackNumbers[i],
+            VendorID:     uint(bookInput.VendorID), // Convert int to uint
+            Status:       models.CopyAvailable,
+            ItemType:     itemTypeOrDefault(bookInput.ItemType),
+        })
+    }
 
-        if err := db.Create(&book).Error; err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
-            return
-        }
+    if err := repository.NewGormStore(db).Books().Create(&book); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusCreated, gin.H{"message": "Book created successfully", "book": book})
+}
+
+// AddBookCopies adds physical copies to an existing title
+func AddBookCopies(c *gin.Context, db *gorm.DB) {
+    var copyInput struct {
+        VendorID      uint     `json:"vendor_id" binding:"required"`
+        SerialNumbers []string `json:"serial_numbers" binding:"required,min=1"`
+        RackNumbers   []string `json:"rack_numbers" binding:"required,min=1"`
+        ItemType      string   `json:"item_type" binding:"omitempty,oneof=general reference reserve"`
+    }
+
+    if err := c.ShouldBindJSON(&copyInput); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+        return
+    }
+
+    if len(copyInput.SerialNumbers) != len(copyInput.RackNumbers) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Number of serial numbers and rack numbers must match"})
+        return
+    }
+
+    books := repository.NewGormStore(db).Books()
+    book, err := books.Get(idParam(c, "id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+        return
+    }
 
-        createdBooks = append(createdBooks, book)
+    var copies []models.Copy
+    for i, serialNumber := range copyInput.SerialNumbers {
+        copies = append(copies, models.Copy{
+            BookID:       book.ID,
+            SerialNumber: serialNumber,
+            RackNumber:   copyInput.RackNumbers[i],
+            VendorID:     copyInput.VendorID,
+            Status:       models.CopyAvailable,
+            ItemType:     itemTypeOrDefault(copyInput.ItemType),
+        })
     }
 
-    c.JSON(http.StatusCreated, gin.H{"message": "Books created successfully", "books": createdBooks})
+    if err := books.AddCopies(copies); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusCreated, gin.H{"message": "Copies added successfully", "copies": copies})
 }
 
+// itemTypeOrDefault treats a missing item type as a general loan item
+func itemTypeOrDefault(itemType string) string {
+    if itemType == "" {
+        return models.ItemGeneral
+    }
+    return itemType
+}
 
+// UpdateCopyStatus marks a copy as lost, damaged, withdrawn or back on the shelf,
+// optionally moving it to another rack or changing its item type
+func UpdateCopyStatus(c *gin.Context, db *gorm.DB) {
+    var input struct {
+        Status     string `json:"status" binding:"required,oneof=available lost damaged withdrawn"`
+        RackNumber string `json:"rack_number"`
+        ItemType   string `json:"item_type" binding:"omitempty,oneof=general reference reserve"`
+    }
 
-func UploadBookFile(c *gin.Context, db *gorm.DB) {
-    // Extract book ID from the URL
-    bookID := c.Param("id")
+    if err := c.ShouldBindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+        return
+    }
 
+    books := repository.NewGormStore(db).Books()
+    bookCopy, err := books.GetCopy(idParam(c, "id"))
+    if err != nil {
+        if err == repository.ErrNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Copy not found"})
+        } else {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        }
+        return
+    }
+
+    // Issued copies change status through the return path
+    if bookCopy.Status == models.CopyIssued {
+        c.JSON(http.StatusConflict, gin.H{"error": "Copy is currently issued"})
+        return
+    }
+
+    // A copy coming back into circulation goes to the next student waiting
+    // for the title, like a returned one. A copy on the hold shelf stays
+    // there while its hold is ready, and its hold goes back to the queue if
+    // the copy leaves circulation.
+    wasOnHold := bookCopy.Status == models.CopyOnHold
+    reshelve := false
+    switch {
+    case input.Status != models.CopyAvailable:
+        bookCopy.Status = input.Status
+    case bookCopy.Status != models.CopyAvailable:
+        reshelve = true
+    }
+    if input.RackNumber != "" {
+        bookCopy.RackNumber = input.RackNumber
+    }
+    if input.ItemType != "" {
+        bookCopy.ItemType = input.ItemType
+    }
+
+    var ready *models.Hold
+    err = db.Transaction(func(tx *gorm.DB) error {
+        if wasOnHold && reshelve {
+            var held int64
+            if err := tx.Model(&models.Hold{}).Where("copy_id = ? AND status = ?", bookCopy.ID, models.HoldReady).Count(&held).Error; err != nil {
+                return err
+            }
+            reshelve = held == 0
+        }
+        if err := repository.NewGormStore(tx).Books().SaveCopy(&bookCopy); err != nil {
+            return err
+        }
+        var err error
+        if wasOnHold && bookCopy.Status != models.CopyOnHold {
+            ready, err = circulation.ReleaseHeldCopy(tx, bookCopy)
+            return err
+        }
+        if !reshelve {
+            return nil
+        }
+        if ready, err = circulation.TrapForNextHold(tx, bookCopy); err != nil {
+            return err
+        }
+        bookCopy.Status = models.CopyAvailable
+        if ready != nil {
+            bookCopy.Status = models.CopyOnHold
+        }
+        return nil
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if ready != nil {
+        circulation.NotifyHoldReady(db, *ready)
+    }
+
+    c.JSON(http.StatusOK, bookCopy)
+}
+
+// UploadBookFile stores a PDF or EPUB uploaded in the "ebook_pdf" form
+// field as the book's ebook, once fileScanner has checked it for malware
+// and its page count, title and author have been read. The file is kept in
+// files.
+func UploadBookFile(c *gin.Context, db *gorm.DB, files storage.Store, fileScanner scanner.Scanner) {
     // Find the book in the database
-    var book models.Book
-    if err := db.First(&book, bookID).Error; err != nil {
+    books := repository.NewGormStore(db).Books()
+    book, err := books.Get(idParam(c, "id"))
+    if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
         return
     }
 
-    // Handle the file upload
-    file, _, err := c.Request.FormFile("ebook_pdf")
+    // Handle the file upload, leaving room for the multipart headers
+    tooLarge := fmt.Sprintf("The file is larger than %d MB", config.MaxEBookSize>>20)
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxEBookSize+1<<20)
+    file, header, err := c.Request.FormFile("ebook_pdf")
+    var maxBytesErr *http.MaxBytesError
+    if errors.As(err, &maxBytesErr) {
+        c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+        return
+    }
     if err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "File upload error: " + err.Error()})
         return
     }
+    defer file.Close()
+    if header.Size > config.MaxEBookSize {
+        c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+        return
+    }
+
+    // Work out the type from the first bytes, then go back to the start
+    mimeType, err := ebook.DetectType(file)
+    if errors.Is(err, ebook.ErrUnsupported) {
+        c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading file: " + err.Error()})
+        return
+    }
 
-    // Read the file content into a byte array
-    ebookContent, err := io.ReadAll(file)  // Use io.ReadAll instead of ioutil.ReadAll
+    // Check the file for malware before anything else reads it
+    if _, err := file.Seek(0, io.SeekStart); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading file: " + err.Error()})
+        return
+    }
+    var infected *scanner.InfectedError
+    if err := fileScanner.Scan(c.Request.Context(), file); errors.As(err, &infected) {
+        log.Printf("Rejected the ebook uploaded for book %d by user %d: %v", book.ID, c.GetUint("userID"), err)
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "The file was rejected by the malware scanner", "signature": infected.Signature})
+        return
+    } else if err != nil {
+        log.Printf("Failed to scan the ebook uploaded for book %d: %v", book.ID, err)
+        c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The file could not be scanned for malware, try again later"})
+        return
+    }
+
+    details, err := ebook.Inspect(file, header.Size, mimeType)
     if err != nil {
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid file: " + err.Error()})
+        return
+    }
+    if _, err := file.Seek(0, io.SeekStart); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading file: " + err.Error()})
         return
     }
 
-    // Update the book's EBookPDF field in the database
-    book.EBookPDF = ebookContent
-    if err := db.Save(&book).Error; err != nil {
+    // Stream the file into the store, hashing it on the way
+    key, err := storage.NewKey(fmt.Sprintf("ebooks/%d", book.ID))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    hash := sha256.New()
+    if err := files.Put(c.Request.Context(), key, io.TeeReader(file, hash), header.Size, mimeType); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage error: " + err.Error()})
+        return
+    }
+
+    // Keep only the file's metadata in the database
+    previous := book.EBook
+    uploadedAt := time.Now()
+    book.EBook = models.EBookFile{
+        Key:        key,
+        Size:       header.Size,
+        SHA256:     hex.EncodeToString(hash.Sum(nil)),
+        MIMEType:   mimeType,
+        UploadedAt: &uploadedAt,
+        Pages:      details.Pages,
+        Title:      details.Title,
+        Author:     details.Author,
+    }
+    if err := books.Save(&book); err != nil {
+        files.Delete(c.Request.Context(), key)
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
         return
     }
+    if previous.Present() {
+        if err := files.Delete(c.Request.Context(), previous.Key); err != nil {
+            log.Printf("Failed to delete the replaced ebook %s: %v", previous.Key, err)
+        }
+    }
 
-    c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "book_id": book.ID})
+    c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "book_id": book.ID, "ebook": book.EBook})
 }
 
-func DownloadBookFile(c *gin.Context, db *gorm.DB) {
-    bookID := c.Param("id")
+// DownloadBookFile serves a book's ebook to the student a signed link was
+// issued to (see CreateDownloadLink) from files
+func DownloadBookFile(c *gin.Context, db *gorm.DB, files storage.Store) {
+    bookID := idParam(c, "id")
+    student, status, err := authorizeDownload(c, db, bookID)
+    if err != nil {
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
 
     // Find the book in the database
-    var book models.Book
-    if err := db.First(&book, bookID).Error; err != nil {
+    book, err := repository.NewGormStore(db).Books().Get(bookID)
+    if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
         return
     }
 
-    if book.EBookPDF == nil {
+    if !book.EBook.Present() {
+        c.JSON(http.StatusNotFound, gin.H{"error": "No file found for this book"})
+        return
+    }
+
+    object, err := files.Open(c.Request.Context(), book.EBook.Key)
+    if err == storage.ErrNotFound {
         c.JSON(http.StatusNotFound, gin.H{"error": "No file found for this book"})
         return
     }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage error: " + err.Error()})
+        return
+    }
+    defer object.Close()
+
+    // The digest changes with the content, so it makes a strong validator
+    var content io.ReadSeeker = object
+    etag := book.EBook.SHA256
+    modified := object.ModTime()
+    if book.EBook.UploadedAt != nil {
+        modified = *book.EBook.UploadedAt
+    }
+    mimeType := book.EBook.MIMEType
+    if mimeType == "" {
+        mimeType = "application/octet-stream"
+    }
+
+    // PDFs are stamped with who downloaded them. A copy that cannot be
+    // stamped is not handed out.
+    if mimeType == "application/pdf" {
+        stamped, err := stampedEBook(book, student, object)
+        if err != nil {
+            log.Printf("stamping book %d for student %s: %v", book.ID, student.USN, err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not prepare the file for download"})
+            return
+        }
+        content = stamped.Copy.Open(object)
+        digest := sha256.Sum256([]byte(book.EBook.SHA256 + stamped.Copy.Digest()))
+        etag = hex.EncodeToString(digest[:])
+        modified = stamped.Stamped
+    }
+
+    if status, err := recordDownload(c, db, book.ID, student, etag, modified); err != nil {
+        c.JSON(status, gin.H{"error": err.Error()})
+        return
+    }
+    c.Header("Content-Type", mimeType)
+    c.Header("Content-Disposition", attachmentDisposition(ebookFilename(book)))
+    c.Header("Cache-Control", "private, no-cache")
+    c.Header("X-Content-Type-Options", "nosniff")
+    if etag != "" {
+        c.Header("ETag", `"`+etag+`"`)
+    }
+
+    // Stream the file, answering Range, If-Range, If-None-Match and
+    // If-Modified-Since requests with 206 or 304 as they ask
+    http.ServeContent(c.Writer, c.Request, "", modified, content)
+}
 
-    // Serve the file
-    c.Header("Content-Disposition", "attachment; filename=ebook.pdf")
-    c.Data(http.StatusOK, "application/pdf", book.EBookPDF)
+// ebookExtensions are the file name extensions of the ebook formats
+var ebookExtensions = map[string]string{
+    "application/pdf":      ".pdf",
+    "application/epub+zip": ".epub",
+}
+
+// ebookFilename names a book's ebook file after its title, e.g.
+// "The Go Programming Language.pdf"
+func ebookFilename(book models.Book) string {
+    name := strings.Map(func(r rune) rune {
+        if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+            return ' '
+        }
+        return r
+    }, book.Title)
+    name = strings.Join(strings.Fields(name), " ")
+    if name == "" {
+        name = "ebook"
+    }
+    if utf8.RuneCountInString(name) > 150 {
+        name = string([]rune(name)[:150])
+    }
+
+    ext, ok := ebookExtensions[book.EBook.MIMEType]
+    if !ok {
+        if exts, _ := mime.ExtensionsByType(book.EBook.MIMEType); len(exts) > 0 {
+            ext = exts[0]
+        }
+    }
+    return name + ext
+}
+
+// attachmentDisposition is a Content-Disposition header for downloading a
+// file. Names that are not plain ASCII are also given in RFC 5987 form,
+// with an ASCII fallback for older clients.
+func attachmentDisposition(filename string) string {
+    fallback := strings.Map(func(r rune) rune {
+        if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+            return '_'
+        }
+        return r
+    }, filename)
+    header := `attachment; filename="` + fallback + `"`
+    if fallback != filename {
+        header += "; filename*=UTF-8''" + encodeRFC5987(filename)
+    }
+    return header
+}
+
+// encodeRFC5987 percent-encodes every byte that is not an attr-char
+func encodeRFC5987(s string) string {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+            b.WriteByte(c)
+        } else {
+            fmt.Fprintf(&b, "%%%02X", c)
+        }
+    }
+    return b.String()
 }
 
 // Search books by title
 func SearchBooksByTitle(c *gin.Context, db *gorm.DB) {
 	title := c.Query("title")
-	var books []models.Book
-	if result := db.Where("title ILIKE ?", "%"+title+"%").Find(&books); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	books, err := repository.NewGormStore(db).Books().SearchByTitle(title)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, books)
+	c.JSON(http.StatusOK, summarizeBooks(books))
 }
-// Delete a book
-func DeleteBook(c *gin.Context, db *gorm.DB) {
-	bookID := c.Param("id")
-	if result := db.Delete(&models.Book{}, bookID); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+// Delete a book and its copies, and its ebook from files
+func DeleteBook(c *gin.Context, db *gorm.DB, files storage.Store) {
+	bookID := idParam(c, "id")
+	books := repository.NewGormStore(db).Books()
+
+	// Refuse while any copy is out on loan
+	issued, err := books.CountCopies(bookID, models.CopyIssued)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if issued > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Book has copies that are currently issued"})
 		return
 	}
+
+	book, err := books.Get(bookID)
+	if err != nil && err != repository.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := books.Delete(bookID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if book.EBook.Present() {
+		if err := files.Delete(c.Request.Context(), book.EBook.Key); err != nil {
+			log.Printf("Failed to delete the ebook of book %d: %v", bookID, err)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Book deleted successfully"})
 }
 // Return a book
 func ReturnBook(c *gin.Context, db *gorm.DB) {
-    transactionID := c.Param("id") // Get the transaction ID from the URL
-    var transaction models.Transaction
-
-    // Find the transaction
-    if err := db.First(&transaction, transactionID).Error; err != nil {
-        if err == gorm.ErrRecordNotFound {
-            c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-        } else {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        }
+    // Save the return, charge any late fee and either trap the copy for
+    // the next hold or put it back on the shelf
+    result, err := circulationService(db).Return(idParam(c, "id"), c.GetUint("userID"))
+    switch err {
+    case nil:
+    case services.ErrTransactionNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
         return
-    }
-
-    // Check if the book is already returned
-    if transaction.ReturnDate != nil {
+    case circulation.ErrAlreadyReturned:
         c.JSON(http.StatusBadRequest, gin.H{"error": "Book is already returned"})
         return
-    }
-
-    // Set the return date
-    now := time.Now()
-    transaction.ReturnDate = &now
-
-    // Calculate late fee if the book is returned after the due date
-    if now.After(transaction.DueDate) {
-        daysLate := int(now.Sub(transaction.DueDate).Hours() / 24)
-        lateFeePerDay := 10.0 // Example late fee rate
-        transaction.LateFee = float64(daysLate) * lateFeePerDay
-    } else {
-        transaction.LateFee = 0
-    }
-
-    // Save the updated transaction
-    if err := db.Save(&transaction).Error; err != nil {
+    default:
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    if result.ReadyHold != nil {
+        circulation.NotifyHoldReady(db, *result.ReadyHold)
+    }
+    if result.LateFee != nil {
+        circulation.NotifyCharge(db, *result.LateFee)
+    }
 
     c.JSON(http.StatusOK, gin.H{
         "message":      "Book returned successfully",
-        "transaction":  transaction,
+        "transaction":  result.Transaction,
     })
 }
 
 // GetBookDetails fetches details of a book by its ID
 func GetBookDetails(c *gin.Context, db *gorm.DB) {
-    // Get the book ID from the URL parameter
-    bookID := c.Param("id")
-
-    // Find the book in the database
-    var book models.Book
-    if err := db.First(&book, bookID).Error; err != nil {
-        if err == gorm.ErrRecordNotFound {
+    // Find the book and its copies in the database
+    book, err := repository.NewGormStore(db).Books().Get(idParam(c, "id"))
+    if err != nil {
+        if err == repository.ErrNotFound {
             c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
         } else {
             c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -216,6 +595,6 @@ func GetBookDetails(c *gin.Context, db *gorm.DB) {
     }
 
     // Return the book details in the response
-    c.JSON(http.StatusOK, book)
+    c.JSON(http.StatusOK, summarizeBooks([]models.Book{book})[0])
 }
 
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/database/databasetest"
+	"library-management/models"
+	"library-management/scanner"
+	"library-management/storage"
+	"library-management/utils"
+)
+
+// testEPUB is a small EPUB with the given title
+func testEPUB(t *testing.T, title string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, _ := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	w.Write([]byte("application/epub+zip"))
+	for name, content := range map[string]string{
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`,
+		"content.opf":            `<package><metadata><title>` + title + `</title><creator>Donovan</creator></metadata></package>`,
+	} {
+		w, _ := archive.Create(name)
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// ebookLibrary is a book with an uploaded EPUB and a student allowed to
+// download it
+type ebookLibrary struct {
+	db      *gorm.DB
+	files   storage.Store
+	book    models.Book
+	student models.Student
+}
+
+func newEBookLibrary(t *testing.T, title string) *ebookLibrary {
+	t.Helper()
+	config.DownloadDailyQuota = 0
+	l := &ebookLibrary{db: databasetest.Open(t), files: storage.NewLocal(t.TempDir())}
+	l.book = models.Book{Title: title, Author: "Donovan", Edition: 1}
+	if err := l.db.Create(&l.book).Error; err != nil {
+		t.Fatal(err)
+	}
+	l.student = downloadTestStudent(t, l.db)
+	l.upload(t, testEPUB(t, title))
+	return l
+}
+
+func (l *ebookLibrary) upload(t *testing.T, data []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("ebook_pdf", "book.epub")
+	part.Write(data)
+	form.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(l.book.ID))}}
+	UploadBookFile(c, l.db, l.files, scanner.Nop{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload gave %d: %s", w.Code, w.Body)
+	}
+}
+
+// download requests the ebook through a freshly signed link
+func (l *ebookLibrary) download(header http.Header) *httptest.ResponseRecorder {
+	expires := time.Now().Add(time.Hour)
+	signature := utils.SignDownload(l.book.ID, l.student.ID, expires)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/books/%d/download?student=%d&expires=%d&signature=%s", l.book.ID, l.student.ID, expires.Unix(), signature), nil)
+	for name, values := range header {
+		c.Request.Header[name] = values
+	}
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(l.book.ID))}}
+	DownloadBookFile(c, l.db, l.files)
+	// gin sends the status of a response without a body after the handler
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestDownloadBookFile(t *testing.T) {
+	l := newEBookLibrary(t, "The Go Programming Language")
+	size := len(testEPUB(t, l.book.Title))
+
+	w := l.download(nil)
+	if w.Code != http.StatusOK || w.Body.Len() != size {
+		t.Fatalf("status %d with %d bytes", w.Code, w.Body.Len())
+	}
+	for name, want := range map[string]string{
+		"Content-Type":        "application/epub+zip",
+		"Content-Disposition": `attachment; filename="The Go Programming Language.epub"`,
+		"Accept-Ranges":       "bytes",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+
+	w = l.download(http.Header{"Range": {"bytes=10-19"}})
+	if w.Code != http.StatusPartialContent || w.Body.Len() != 10 {
+		t.Errorf("range request gave %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes 10-19/%d", size); got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+
+	w = l.download(http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match gave %d with %d bytes", w.Code, w.Body.Len())
+	}
+	w = l.download(http.Header{"If-Modified-Since": {time.Now().UTC().Add(time.Minute).Format(http.TimeFormat)}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since gave %d", w.Code)
+	}
+
+	// A new file gets a new ETag, so cached copies of the old one are
+	// not reused
+	l.upload(t, testEPUB(t, "The Go Programming Language, 2nd edition"))
+	w = l.download(http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("after a new upload: status %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestEBookFilename(t *testing.T) {
+	for title, want := range map[string]string{
+		"The Go Programming Language": `attachment; filename="The Go Programming Language.epub"`,
+		"Go: A/B \"testing\"\n":       `attachment; filename="Go A B testing.epub"`,
+		"":                            `attachment; filename="ebook.epub"`,
+		"100% Go":                     `attachment; filename="100_ Go.epub"; filename*=UTF-8''100%25%20Go.epub`,
+		"ಕನ್ನಡ":                       `attachment; filename="_____.epub"; filename*=UTF-8''%E0%B2%95%E0%B2%A8%E0%B3%8D%E0%B2%A8%E0%B2%A1.epub`,
+		"Café":                        `attachment; filename="Caf_.epub"; filename*=UTF-8''Caf%C3%A9.epub`,
+	} {
+		book := models.Book{Title: title, EBook: models.EBookFile{MIMEType: "application/epub+zip"}}
+		if got := attachmentDisposition(ebookFilename(book)); got != want {
+			t.Errorf("%q: got %s, want %s", title, got, want)
+		}
+	}
+
+	book := models.Book{Title: "Notes", EBook: models.EBookFile{MIMEType: "application/pdf"}}
+	if got := ebookFilename(book); got != "Notes.pdf" {
+		t.Errorf("PDF file name = %s", got)
+	}
+}
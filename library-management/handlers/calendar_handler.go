@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library-management/circulation"
+	"library-management/models"
+)
+
+// GetCalendar returns the weekly opening hours and all holidays
+func GetCalendar(c *gin.Context, db *gorm.DB) {
+	var hours []models.OpeningHours
+	if result := db.Order("weekday").Find(&hours); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	var holidays []models.Holiday
+	if result := db.Order("date").Find(&holidays); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"opening_hours": hours, "holidays": holidays})
+}
+
+// SetOpeningHours replaces the opening hours of the weekdays given. A day
+// marked closed is skipped when due dates are set and fines are counted.
+func SetOpeningHours(c *gin.Context, db *gorm.DB) {
+	var input []struct {
+		Weekday int    `json:"weekday" binding:"min=0,max=6"`
+		Opens   string `json:"opens"`
+		Closes  string `json:"closes"`
+		Closed  bool   `json:"closed"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var hours []models.OpeningHours
+	for _, day := range input {
+		if !day.Closed {
+			opens, err1 := time.Parse("15:04", day.Opens)
+			closes, err2 := time.Parse("15:04", day.Closes)
+			if err1 != nil || err2 != nil || !closes.After(opens) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "opens and closes must be HH:MM times with closes after opens"})
+				return
+			}
+		}
+		hours = append(hours, models.OpeningHours{Weekday: day.Weekday, Opens: day.Opens, Closes: day.Closes, Closed: day.Closed})
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "weekday"}},
+		DoUpdates: clause.AssignmentColumns([]string{"opens", "closes", "closed"}),
+	}).Create(&hours).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	GetCalendar(c, db)
+}
+
+// CreateHoliday adds a dated closure
+func CreateHoliday(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		Date string `json:"date" binding:"required"`
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be in YYYY-MM-DD format"})
+		return
+	}
+
+	holiday := models.Holiday{Date: input.Date, Name: input.Name, Source: "manual"}
+	var existing models.Holiday
+	if result := db.Where("date = ?", holiday.Date).First(&existing); result.Error == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A holiday already exists on this date"})
+		return
+	}
+	if result := db.Create(&holiday); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, holiday)
+}
+
+// DeleteHoliday removes a dated closure
+func DeleteHoliday(c *gin.Context, db *gorm.DB) {
+	id := c.Param("id")
+	if result := db.Delete(&models.Holiday{}, id); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted successfully"})
+}
+
+// maxCalendarImportSize limits imported calendars to 1 MB
+const maxCalendarImportSize = 1 << 20
+
+// ImportHolidays loads holidays from an iCalendar (.ics) file uploaded as
+// the "calendar" form field or sent as the request body. Dates that are
+// already holidays are skipped.
+func ImportHolidays(c *gin.Context, db *gorm.DB) {
+	// Limit the body before the form is parsed, leaving room for the
+	// multipart headers
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarImportSize+1<<20)
+	var reader io.Reader = c.Request.Body
+	source := c.DefaultQuery("source", "ics import")
+	file, header, err := c.Request.FormFile("calendar")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The calendar is larger than 1 MB"})
+		return
+	}
+	if err == nil {
+		defer file.Close()
+		if header.Size > maxCalendarImportSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The calendar is larger than 1 MB"})
+			return
+		}
+		reader = file
+		if c.Query("source") == "" {
+			source = header.Filename
+		}
+	}
+
+	holidays, err := circulation.ParseICSHolidays(reader, source)
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The calendar is larger than 1 MB"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar: " + err.Error()})
+		return
+	}
+
+	// Several events can fall on the same day; keep the first
+	seen := make(map[string]bool, len(holidays))
+	unique := holidays[:0]
+	for _, holiday := range holidays {
+		if !seen[holiday.Date] {
+			seen[holiday.Date] = true
+			unique = append(unique, holiday)
+		}
+	}
+
+	result := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "date"}}, DoNothing: true}).Create(&unique)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Holidays imported successfully",
+		"imported": result.RowsAffected,
+		"skipped":  int64(len(unique)) - result.RowsAffected,
+	})
+}
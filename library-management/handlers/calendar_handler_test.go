@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestImportHolidays(t *testing.T) {
+	db := databasetest.Open(t)
+	importHolidays := func(c *gin.Context) { ImportHolidays(c, db) }
+	ics := "BEGIN:VEVENT\nSUMMARY:Pongal\nDTSTART;VALUE=DATE:20250114\nDTEND;VALUE=DATE:20250116\nEND:VEVENT\n"
+
+	if w := call(importHolidays, http.MethodPost, "", ics); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"imported":2`) {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if w := call(importHolidays, http.MethodPost, "", ics); !strings.Contains(w.Body.String(), `"skipped":2`) {
+		t.Errorf("importing again gave %s", w.Body)
+	}
+
+	huge := ics + strings.Repeat("X-PADDING:"+strings.Repeat("x", 1000)+"\n", 3<<10)
+	if w := call(importHolidays, http.MethodPost, "", huge); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("a %d byte calendar gave %d: %s", len(huge), w.Code, w.Body)
+	}
+
+	var count int64
+	db.Model(&models.Holiday{}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d holidays stored", count)
+	}
+}
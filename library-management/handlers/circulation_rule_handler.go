@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+// circulationRuleInput is the request body for creating or updating a rule
+type circulationRuleInput struct {
+	MemberCategory          string `json:"member_category" binding:"omitempty,oneof=UG PG faculty"`
+	Department              string `json:"department"`
+	ItemType                string `json:"item_type" binding:"omitempty,oneof=general reference reserve"`
+	LoanPeriodDays          int    `json:"loan_period_days" binding:"min=0"`
+	MaxLoans                int    `json:"max_loans" binding:"min=0"`
+	MaxRenewals             int    `json:"max_renewals" binding:"min=0"`
+	RenewalOverdueLimitDays int    `json:"renewal_overdue_limit_days" binding:"min=0"`
+	FinePerDayMinor         int64  `json:"fine_per_day_minor" binding:"min=0"`
+	FineCapMinor            int64  `json:"fine_cap_minor" binding:"min=0"`
+	Note                    string `json:"note"`
+}
+
+func (input circulationRuleInput) apply(rule *models.CirculationRule) {
+	rule.MemberCategory = input.MemberCategory
+	rule.Department = input.Department
+	rule.ItemType = input.ItemType
+	rule.LoanPeriodDays = input.LoanPeriodDays
+	rule.MaxLoans = input.MaxLoans
+	rule.MaxRenewals = input.MaxRenewals
+	rule.RenewalOverdueLimitDays = input.RenewalOverdueLimitDays
+	rule.FinePerDayMinor = input.FinePerDayMinor
+	rule.FineCapMinor = input.FineCapMinor
+	rule.Note = input.Note
+}
+
+// Get all circulation rules
+func GetCirculationRules(c *gin.Context, db *gorm.DB) {
+	var rules []models.CirculationRule
+	if result := db.Order("id").Find(&rules); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// Create a new circulation rule
+func CreateCirculationRule(c *gin.Context, db *gorm.DB) {
+	var input circulationRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rule models.CirculationRule
+	input.apply(&rule)
+	if result := db.Create(&rule); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// Update an existing circulation rule
+func UpdateCirculationRule(c *gin.Context, db *gorm.DB) {
+	id := c.Param("id")
+	var rule models.CirculationRule
+	if result := db.First(&rule, id); result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Circulation rule not found"})
+		return
+	}
+
+	var input circulationRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.apply(&rule)
+	if result := db.Save(&rule); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// Delete a circulation rule
+func DeleteCirculationRule(c *gin.Context, db *gorm.DB) {
+	id := c.Param("id")
+	if result := db.Delete(&models.CirculationRule{}, id); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Circulation rule deleted successfully"})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/config"
+	"library-management/models"
+	"library-management/repository"
+	"library-management/storage"
+	"library-management/utils"
+	"library-management/watermark"
+)
+
+var (
+	errNotStudentAccount = errors.New("only student accounts can download ebooks")
+	errMembershipExpired = errors.New("the student's membership has expired")
+	errQuotaReached      = errors.New("the daily ebook download limit has been reached")
+	errInvalidLink       = errors.New("the download link is invalid")
+	errLinkExpired       = errors.New("the download link has expired")
+)
+
+// CreateDownloadLink issues the current student a signed link to a book's
+// ebook. The link works without a session until it expires.
+func CreateDownloadLink(c *gin.Context, db *gorm.DB) {
+	store := repository.NewGormStore(db)
+	book, err := store.Books().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if !book.EBook.Present() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No file found for this book"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if user.StudentUSN == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": errNotStudentAccount.Error()})
+		return
+	}
+	student, err := store.Students().GetByUSN(*user.StudentUSN)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": errNotStudentAccount.Error()})
+		return
+	}
+
+	if status, err := checkDownloadAllowed(db, student); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	used, err := downloadsToday(db, student.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if config.DownloadDailyQuota > 0 && used >= int64(config.DownloadDailyQuota) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": errQuotaReached.Error()})
+		return
+	}
+
+	expires := time.Now().Add(config.DownloadLinkTTL).Truncate(time.Second)
+	link := fmt.Sprintf("/books/%d/download?student=%d&expires=%d&signature=%s",
+		book.ID, student.ID, expires.Unix(), utils.SignDownload(book.ID, student.ID, expires))
+	response := gin.H{"url": link, "expires_at": expires}
+	if config.DownloadDailyQuota > 0 {
+		response["downloads_left"] = int64(config.DownloadDailyQuota) - used
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetEBookDownloads lists recorded ebook downloads, newest first, optionally
+// for one student or book
+func GetEBookDownloads(c *gin.Context, db *gorm.DB) {
+	query := db.Order("downloaded_at DESC, id DESC")
+	if usn := c.Query("student_usn"); usn != "" {
+		query = query.Where("student_usn = ?", usn)
+	}
+	if bookID := c.Query("book_id"); bookID != "" {
+		id, err := strconv.ParseUint(bookID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book_id"})
+			return
+		}
+		query = query.Where("book_id = ?", id)
+	}
+
+	downloads := []models.EBookDownload{}
+	if err := query.Limit(500).Find(&downloads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, downloads)
+}
+
+// stampCache keeps each student's stamped copy of a PDF for a while, so a
+// viewer fetching it in ranges gets the same bytes every time
+var stampCache = watermark.NewCache(64 << 20)
+
+// stampedEBook returns the copy of a book's PDF stamped with the student it
+// is downloaded by
+func stampedEBook(book models.Book, student models.Student, object storage.Object) (watermark.Entry, error) {
+	key := fmt.Sprintf("%s/%d", book.EBook.Key, student.ID)
+	return stampCache.Get(key, config.DownloadStampCacheTTL, func() (watermark.Entry, error) {
+		now := time.Now().UTC().Truncate(time.Second)
+		stamp := watermark.Stamp{
+			Footer: fmt.Sprintf("Downloaded by %s (%s) on %s. Licensed for personal use only.",
+				student.Name, student.USN, now.Format("2006-01-02 15:04 MST")),
+			Info: map[string]string{
+				"DownloadedBy":    student.Name,
+				"DownloadedByUSN": student.USN,
+				"DownloadedAt":    now.Format(time.RFC3339),
+			},
+			Time: now,
+		}
+		stamped, err := watermark.PDF(watermark.NewReaderAt(object, object.Size()), object.Size(), stamp)
+		return watermark.Entry{Copy: stamped, Stamped: now}, err
+	})
+}
+
+// checkDownloadAllowed checks that the student may still borrow: the
+// membership has not expired and unpaid fines are under the limit
+func checkDownloadAllowed(db *gorm.DB, student models.Student) (int, error) {
+	if !student.ExpiryDate.IsZero() && student.ExpiryDate.Before(time.Now()) {
+		return http.StatusForbidden, errMembershipExpired
+	}
+	if err := circulation.CheckBalanceAllowsLoan(db, student); err == circulation.ErrBalanceTooHigh {
+		return http.StatusForbidden, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// downloadsToday counts the student's downloads since local midnight
+func downloadsToday(db *gorm.DB, studentID uint) (int64, error) {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var count int64
+	err := db.Model(&models.EBookDownload{}).
+		Where("student_id = ? AND downloaded_at >= ?", studentID, midnight).
+		Count(&count).Error
+	return count, err
+}
+
+// authorizeDownload checks the signed link of a download request and that
+// its student may still borrow, and returns the student
+func authorizeDownload(c *gin.Context, db *gorm.DB, bookID uint) (models.Student, int, error) {
+	studentID, err1 := strconv.ParseUint(c.Query("student"), 10, 64)
+	unix, err2 := strconv.ParseInt(c.Query("expires"), 10, 64)
+	signature := c.Query("signature")
+	if err1 != nil || err2 != nil || !utils.VerifyDownload(bookID, uint(studentID), time.Unix(unix, 0), signature) {
+		return models.Student{}, http.StatusForbidden, errInvalidLink
+	}
+	if time.Now().Unix() > unix {
+		return models.Student{}, http.StatusForbidden, errLinkExpired
+	}
+
+	student, err := repository.NewGormStore(db).Students().Get(uint(studentID))
+	if err != nil {
+		return models.Student{}, http.StatusForbidden, errInvalidLink
+	}
+	if status, err := checkDownloadAllowed(db, student); err != nil {
+		return student, status, err
+	}
+	return student, http.StatusOK, nil
+}
+
+// recordDownload records the first request through a download link and
+// counts it against the student's quota. Later requests through the same
+// link, such as a viewer fetching more pages, are not counted again, and
+// neither are HEAD requests or conditional requests that will be answered
+// with 304 Not Modified, since they do not send the file.
+func recordDownload(c *gin.Context, db *gorm.DB, bookID uint, student models.Student, etag string, modified time.Time) (int, error) {
+	if c.Request.Method == http.MethodHead || notModified(c.Request, etag, modified) {
+		return http.StatusOK, nil
+	}
+
+	signature := c.Query("signature")
+	download := models.EBookDownload{
+		BookID:       bookID,
+		StudentID:    student.ID,
+		StudentUSN:   student.USN,
+		Signature:    signature,
+		IP:           c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		DownloadedAt: time.Now(),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Writing the student's row first locks it until the transaction
+		// ends, so parallel downloads by one student are counted one at a
+		// time and cannot all slip under the quota
+		if err := tx.Exec("UPDATE students SET id = id WHERE id = ?", student.ID).Error; err != nil {
+			return err
+		}
+
+		var recorded int64
+		if err := tx.Model(&models.EBookDownload{}).Where("signature = ?", signature).Count(&recorded).Error; err != nil {
+			return err
+		}
+		if recorded > 0 {
+			return nil
+		}
+		used, err := downloadsToday(tx, student.ID)
+		if err != nil {
+			return err
+		}
+		if config.DownloadDailyQuota > 0 && used >= int64(config.DownloadDailyQuota) {
+			return errQuotaReached
+		}
+		return tx.Create(&download).Error
+	})
+	if err == errQuotaReached {
+		return http.StatusTooManyRequests, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// notModified reports whether http.ServeContent will answer the request
+// with 304 Not Modified for a file with the ETag and modification time
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == `"`+etag+`"` {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() || modified.Equal(time.Unix(0, 0)) {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// downloadContext is a request through the download link with the signature
+func downloadContext(method, signature string, header http.Header) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, "/books/1/download?signature="+signature, nil)
+	for name, values := range header {
+		c.Request.Header[name] = values
+	}
+	return c
+}
+
+func downloadTestStudent(t *testing.T, db *gorm.DB) models.Student {
+	t.Helper()
+	student := models.Student{USN: "1AB21CS001", Name: "Asha"}
+	if err := db.Create(&student).Error; err != nil {
+		t.Fatal(err)
+	}
+	return student
+}
+
+func countDownloads(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&models.EBookDownload{}).Count(&count)
+	return count
+}
+
+func TestRecordDownloadCountsLinksOnce(t *testing.T) {
+	db := databasetest.Open(t)
+	student := downloadTestStudent(t, db)
+	config.DownloadDailyQuota = 2
+	modified := time.Now().Add(-time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if status, err := recordDownload(downloadContext(http.MethodGet, "link-1", nil), db, 1, student, "tag", modified); err != nil {
+			t.Fatalf("request %d through one link gave %d %v", i, status, err)
+		}
+	}
+	if n := countDownloads(db); n != 1 {
+		t.Errorf("%d downloads were recorded for one link", n)
+	}
+
+	if _, err := recordDownload(downloadContext(http.MethodGet, "link-2", nil), db, 1, student, "tag", modified); err != nil {
+		t.Fatal(err)
+	}
+	status, err := recordDownload(downloadContext(http.MethodGet, "link-3", nil), db, 1, student, "tag", modified)
+	if status != http.StatusTooManyRequests || err != errQuotaReached {
+		t.Errorf("a download over the quota gave %d %v", status, err)
+	}
+}
+
+func TestRecordDownloadSkipsRequestsWithoutTheFile(t *testing.T) {
+	db := databasetest.Open(t)
+	student := downloadTestStudent(t, db)
+	config.DownloadDailyQuota = 1
+	modified := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	requests := map[string]*gin.Context{
+		"HEAD":          downloadContext(http.MethodHead, "link-1", nil),
+		"matching ETag": downloadContext(http.MethodGet, "link-2", http.Header{"If-None-Match": {`"other", W/"tag"`}}),
+		"any ETag":      downloadContext(http.MethodGet, "link-3", http.Header{"If-None-Match": {"*"}}),
+		"not modified":  downloadContext(http.MethodGet, "link-4", http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}),
+	}
+	for name, c := range requests {
+		if status, err := recordDownload(c, db, 1, student, "tag", modified); err != nil {
+			t.Errorf("%s request gave %d %v", name, status, err)
+		}
+	}
+	if n := countDownloads(db); n != 0 {
+		t.Errorf("%d downloads were recorded for requests that do not send the file", n)
+	}
+
+	// These requests get the file
+	for name, header := range map[string]http.Header{
+		"changed ETag": {"If-None-Match": {`"old"`}},
+		"modified":     {"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}},
+	} {
+		if notModified(downloadContext(http.MethodGet, "", header).Request, "tag", modified) {
+			t.Errorf("a request with a %s would not be sent the file", name)
+		}
+	}
+}
+
+func TestRecordDownloadQuotaUnderLoad(t *testing.T) {
+	db := databasetest.Open(t)
+	student := downloadTestStudent(t, db)
+	config.DownloadDailyQuota = 3
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := downloadContext(http.MethodGet, fmt.Sprintf("link-%d", i), nil)
+			status, err := recordDownload(c, db, 1, student, "tag", time.Now())
+			if err != nil && status != http.StatusTooManyRequests {
+				t.Errorf("download %d: %d %v", i, status, err)
+			}
+			statuses <- status
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	allowed := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			allowed++
+		}
+	}
+	if allowed != 3 || countDownloads(db) != 3 {
+		t.Errorf("%d downloads were allowed and %d recorded, want 3", allowed, countDownloads(db))
+	}
+}
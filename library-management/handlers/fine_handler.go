@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/repository"
+	"library-management/utils"
+)
+
+// GetStudentLedger lists every charge and credit on a student's account
+func GetStudentLedger(c *gin.Context, db *gorm.DB) {
+	student, ok := findStudentForFines(c, db)
+	if !ok {
+		return
+	}
+
+	var entries []models.LedgerEntry
+	if result := db.Where("student_usn = ?", student.USN).Order("created_at, id").Find(&entries); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetStudentBalance returns the outstanding fine balance of a student
+func GetStudentBalance(c *gin.Context, db *gorm.DB) {
+	student, ok := findStudentForFines(c, db)
+	if !ok {
+		return
+	}
+
+	balance, err := circulation.Balance(db, student.USN)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"student_usn":     student.USN,
+		"balance_minor":   balance,
+		"balance":         utils.FormatMinor(balance),
+		"can_borrow":      balance <= circulation.MaxOutstandingBalance,
+		"borrowing_limit": utils.FormatMinor(circulation.MaxOutstandingBalance),
+	})
+}
+
+// AddStudentCharge bills a student for a lost or damaged item
+func AddStudentCharge(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		Type          string `json:"type" binding:"required,oneof=lost damaged late_fee"`
+		AmountMinor   int64  `json:"amount_minor" binding:"required,gt=0"`
+		TransactionID *uint  `json:"transaction_id"`
+		Reason        string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	createLedgerEntry(c, db, models.LedgerEntry{
+		Type:          input.Type,
+		AmountMinor:   input.AmountMinor,
+		TransactionID: input.TransactionID,
+		Reason:        input.Reason,
+	})
+}
+
+// RecordPayment credits a payment made by a student
+func RecordPayment(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		AmountMinor   int64  `json:"amount_minor" binding:"required,gt=0"`
+		PaymentMethod string `json:"payment_method" binding:"required"`
+		Reference     string `json:"reference"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	createLedgerEntry(c, db, models.LedgerEntry{
+		Type:          models.CreditPayment,
+		AmountMinor:   input.AmountMinor,
+		PaymentMethod: input.PaymentMethod,
+		Reference:     input.Reference,
+	})
+}
+
+// GrantWaiver forgives part of a student's balance. The current user is
+// recorded as the approver.
+func GrantWaiver(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		AmountMinor   int64  `json:"amount_minor" binding:"required,gt=0"`
+		Reason        string `json:"reason" binding:"required"`
+		TransactionID *uint  `json:"transaction_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	approver := c.GetUint("userID")
+	createLedgerEntry(c, db, models.LedgerEntry{
+		Type:             models.CreditWaiver,
+		AmountMinor:      input.AmountMinor,
+		Reason:           input.Reason,
+		TransactionID:    input.TransactionID,
+		ApprovedByUserID: &approver,
+	})
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt {{.Entry.ReceiptNumber}}</title>
+<style>
+body { font-family: sans-serif; max-width: 32em; margin: 2em auto; }
+table { width: 100%; border-collapse: collapse; }
+td { padding: 0.3em 0; border-bottom: 1px solid #ddd; }
+td:first-child { color: #555; width: 40%; }
+@media print { button { display: none; } }
+</style>
+</head>
+<body>
+<h2>College Library &mdash; Receipt</h2>
+<table>
+<tr><td>Receipt number</td><td>{{.Entry.ReceiptNumber}}</td></tr>
+<tr><td>Date</td><td>{{.Entry.CreatedAt.Format "2006-01-02 15:04"}}</td></tr>
+<tr><td>Student</td><td>{{.Student.Name}} ({{.Student.USN}})</td></tr>
+<tr><td>Type</td><td>{{.Entry.Type}}</td></tr>
+<tr><td>Amount</td><td>{{.Amount}}</td></tr>
+{{if .Entry.PaymentMethod}}<tr><td>Payment method</td><td>{{.Entry.PaymentMethod}}</td></tr>{{end}}
+{{if .Entry.Reference}}<tr><td>Reference</td><td>{{.Entry.Reference}}</td></tr>{{end}}
+{{if .Entry.Reason}}<tr><td>Reason</td><td>{{.Entry.Reason}}</td></tr>{{end}}
+{{if .Approver}}<tr><td>Approved by</td><td>{{.Approver}}</td></tr>{{end}}
+<tr><td>Outstanding balance</td><td>{{.Balance}}</td></tr>
+</table>
+<p><button onclick="window.print()">Print</button></p>
+</body>
+</html>
+`))
+
+// GetLedgerReceipt renders a printable HTML receipt for a ledger entry
+func GetLedgerReceipt(c *gin.Context, db *gorm.DB) {
+	entryID := c.Param("id")
+	var entry models.LedgerEntry
+	if err := db.First(&entry, entryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ledger entry not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	student, err := repository.NewGormStore(db).Students().GetByUSN(entry.StudentUSN)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if !canActForStudent(c, db, student, models.PermStudentsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own receipts"})
+		return
+	}
+
+	balance, err := circulation.Balance(db, student.USN)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	approver := ""
+	if entry.ApprovedByUserID != nil {
+		var user models.User
+		if err := db.First(&user, *entry.ApprovedByUserID).Error; err == nil {
+			approver = user.Username
+		}
+	}
+
+	amount := entry.AmountMinor
+	if amount < 0 {
+		amount = -amount
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := receiptTemplate.Execute(c.Writer, gin.H{
+		"Entry":    entry,
+		"Student":  student,
+		"Amount":   utils.FormatMinor(amount),
+		"Approver": approver,
+		"Balance":  utils.FormatMinor(balance),
+	}); err != nil {
+		log.Println("Error rendering receipt:", err)
+	}
+}
+
+// createLedgerEntry saves an entry for the student in the URL and responds
+// with it and the new balance
+func createLedgerEntry(c *gin.Context, db *gorm.DB, entry models.LedgerEntry) {
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+
+	entry.StudentUSN = student.USN
+	entry.RecordedByUserID = c.GetUint("userID")
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if entry.Type == models.CreditWaiver {
+			return circulation.GrantWaiver(tx, &entry)
+		}
+		return circulation.AddLedgerEntry(tx, &entry)
+	}); err != nil {
+		if err == circulation.ErrInvalidAmount || err == circulation.ErrWaiverTooLarge {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	circulation.NotifyCharge(db, entry)
+
+	balance, err := circulation.Balance(db, student.USN)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"entry":         entry,
+		"balance_minor": balance,
+		"balance":       utils.FormatMinor(balance),
+	})
+}
+
+// findStudentForFines loads the student in the URL and checks the current
+// user may see their account: staff who can read student records, or the
+// student themselves. It writes the error response itself.
+func findStudentForFines(c *gin.Context, db *gorm.DB) (models.Student, bool) {
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return student, false
+	}
+	if !canActForStudent(c, db, student, models.PermStudentsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own account"})
+		return student, false
+	}
+	return student, true
+}
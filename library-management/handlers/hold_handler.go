@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/repository"
+)
+
+// PlaceHold queues a student for the next available copy of a book
+func PlaceHold(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		StudentUSN string `json:"student_usn" binding:"required"`
+		BookID     uint   `json:"book_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	store := repository.NewGormStore(db)
+	student, err := store.Students().GetByUSN(input.StudentUSN)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if !canActForStudent(c, db, student, models.PermHoldsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only place holds for yourself"})
+		return
+	}
+
+	book, err := store.Books().Get(input.BookID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	hold, err := circulation.PlaceHold(db, student, book)
+	if err != nil {
+		if err == circulation.ErrCopiesAvailable || err == circulation.ErrAlreadyHolding {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+// CancelHold withdraws a hold; a copy trapped for it goes to the next in line
+func CancelHold(c *gin.Context, db *gorm.DB) {
+	holdID := c.Param("id")
+	var hold models.Hold
+	if err := db.Preload("Student").First(&hold, holdID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hold not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if !canActForStudent(c, db, hold.Student, models.PermHoldsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only cancel your own holds"})
+		return
+	}
+
+	next, err := circulation.CancelHold(db, &hold)
+	if err != nil {
+		if err == circulation.ErrHoldNotActive {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if next != nil {
+		circulation.NotifyHoldReady(db, *next)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold cancelled successfully", "hold": hold})
+}
+
+// GetStudentHolds lists a student's holds, newest first
+func GetStudentHolds(c *gin.Context, db *gorm.DB) {
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if !canActForStudent(c, db, student, models.PermStudentsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own holds"})
+		return
+	}
+
+	var holds []models.Hold
+	if result := db.Preload("Book").Where("student_usn = ?", student.USN).Order("placed_at DESC").Find(&holds); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, holds)
+}
+
+// GetBookHolds lists the active hold queue of a book in pickup order
+func GetBookHolds(c *gin.Context, db *gorm.DB) {
+	bookID := c.Param("id")
+
+	var holds []models.Hold
+	if result := db.Preload("Student").
+		Where("book_id = ? AND status IN ?", bookID, []string{models.HoldWaiting, models.HoldReady}).
+		Order("placed_at, id").Find(&holds); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, holds)
+}
+
+// ExpireHolds ends holds that were not picked up in time and notifies the
+// students whose holds became ready as a result. The scheduler runs it, so
+// listing holds never changes them. It returns how many holds expired.
+func ExpireHolds(db *gorm.DB) (int, error) {
+	expired, ready, err := circulation.ExpireHolds(db)
+	for _, hold := range ready {
+		circulation.NotifyHoldReady(db, hold)
+	}
+	return expired, err
+}
+
+// canActForStudent reports whether the current user may act on the
+// student's records. Staff holding staffPermission can act for anyone;
+// accounts linked to a student can only act for themselves.
+func canActForStudent(c *gin.Context, db *gorm.DB, student models.Student, staffPermission string) bool {
+	if middleware.HasPermission(c, db, staffPermission) {
+		return true
+	}
+
+	var user models.User
+	if err := db.First(&user, c.GetUint("userID")).Error; err != nil {
+		return false
+	}
+	return user.StudentUSN != nil && *user.StudentUSN == student.USN
+}
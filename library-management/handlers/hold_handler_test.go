@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+// heldBook is a book with one copy in the given status and a student
+// waiting for it
+func heldBook(t *testing.T, status string) (*gorm.DB, models.Copy, models.Hold) {
+	t.Helper()
+	db := databasetest.Open(t)
+	book := models.Book{Title: "Go", Author: "Donovan", Edition: 1, Copies: []models.Copy{{SerialNumber: "S-1", Status: status}}}
+	student := models.Student{USN: "1AB21CS001", Name: "Asha"}
+	for _, record := range []interface{}{&book, &student} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	hold := models.Hold{StudentUSN: student.USN, BookID: book.ID, Status: models.HoldWaiting, PlacedAt: time.Now()}
+	if err := db.Create(&hold).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db, book.Copies[0], hold
+}
+
+// call runs a handler on a request with the id parameter and returns the
+// response
+func call(handler func(*gin.Context), method, id, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	handler(c)
+	return w
+}
+
+func TestUpdateCopyStatusTrapsReturningCopies(t *testing.T) {
+	db, bookCopy, hold := heldBook(t, models.CopyDamaged)
+
+	w := call(func(c *gin.Context) { UpdateCopyStatus(c, db) }, http.MethodPut, strconv.Itoa(int(bookCopy.ID)), `{"status":"available"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var updated models.Copy
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if updated.Status != models.CopyOnHold {
+		t.Errorf("the response says the copy is %s", updated.Status)
+	}
+	db.First(&hold, hold.ID)
+	if hold.Status != models.HoldReady || hold.CopyID == nil || *hold.CopyID != bookCopy.ID {
+		t.Errorf("hold = %+v", hold)
+	}
+
+	// Marking the trapped copy available again leaves it for the hold
+	w = call(func(c *gin.Context) { UpdateCopyStatus(c, db) }, http.MethodPut, strconv.Itoa(int(bookCopy.ID)), `{"status":"available"}`)
+	db.First(&bookCopy, bookCopy.ID)
+	if w.Code != http.StatusOK || bookCopy.Status != models.CopyOnHold {
+		t.Errorf("status %d, copy %s", w.Code, bookCopy.Status)
+	}
+}
+
+func TestUpdateCopyStatusWithoutHolds(t *testing.T) {
+	db, bookCopy, hold := heldBook(t, models.CopyLost)
+	db.Model(&hold).Update("status", models.HoldCancelled)
+
+	call(func(c *gin.Context) { UpdateCopyStatus(c, db) }, http.MethodPut, strconv.Itoa(int(bookCopy.ID)), `{"status":"available"}`)
+	db.First(&bookCopy, bookCopy.ID)
+	if bookCopy.Status != models.CopyAvailable {
+		t.Errorf("copy is %s", bookCopy.Status)
+	}
+	call(func(c *gin.Context) { UpdateCopyStatus(c, db) }, http.MethodPut, strconv.Itoa(int(bookCopy.ID)), `{"status":"withdrawn"}`)
+	db.First(&bookCopy, bookCopy.ID)
+	if bookCopy.Status != models.CopyWithdrawn {
+		t.Errorf("copy is %s", bookCopy.Status)
+	}
+}
+
+func TestDeleteTransactionTrapsCopy(t *testing.T) {
+	db, bookCopy, hold := heldBook(t, models.CopyIssued)
+	transaction := models.Transaction{StudentUSN: hold.StudentUSN, CopyID: bookCopy.ID, IssueDate: time.Now(), DueDate: time.Now().AddDate(0, 0, 14)}
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := call(func(c *gin.Context) { DeleteTransaction(c, db) }, http.MethodDelete, strconv.Itoa(int(transaction.ID)), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	db.First(&bookCopy, bookCopy.ID)
+	db.First(&hold, hold.ID)
+	if bookCopy.Status != models.CopyOnHold || hold.Status != models.HoldReady {
+		t.Errorf("copy %s, hold %s", bookCopy.Status, hold.Status)
+	}
+}
+
+func TestListingHoldsDoesNotExpireThem(t *testing.T) {
+	db, bookCopy, hold := heldBook(t, models.CopyIssued)
+	if _, err := circulation.TrapForNextHold(db, bookCopy); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&hold).Update("expires_at", time.Now().Add(-time.Hour))
+
+	w := call(func(c *gin.Context) { GetBookHolds(c, db) }, http.MethodGet, strconv.Itoa(int(bookCopy.BookID)), "")
+	db.First(&hold, hold.ID)
+	if w.Code != http.StatusOK || hold.Status != models.HoldReady {
+		t.Errorf("status %d, hold %s after listing", w.Code, hold.Status)
+	}
+
+	if expired, err := ExpireHolds(db); err != nil || expired != 1 {
+		t.Errorf("expired %d: %v", expired, err)
+	}
+	db.First(&hold, hold.ID)
+	db.First(&bookCopy, bookCopy.ID)
+	if hold.Status != models.HoldExpired || bookCopy.Status != models.CopyAvailable {
+		t.Errorf("hold %s, copy %s after expiry", hold.Status, bookCopy.Status)
+	}
+}
+
+func TestUpdateCopyStatusReleasesHeldCopy(t *testing.T) {
+	db, bookCopy, hold := heldBook(t, models.CopyIssued)
+	if _, err := circulation.TrapForNextHold(db, bookCopy); err != nil {
+		t.Fatal(err)
+	}
+
+	w := call(func(c *gin.Context) { UpdateCopyStatus(c, db) }, http.MethodPut, strconv.Itoa(int(bookCopy.ID)), `{"status":"lost"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	db.First(&hold, hold.ID)
+	if hold.Status != models.HoldWaiting || hold.CopyID != nil {
+		t.Errorf("the hold still points at the lost copy: %+v", hold)
+	}
+}
+
+func TestUpdateCopyStatusReshelvesStrayHeldCopy(t *testing.T) {
+	db, bookCopy, hold := heldBook(t, models.CopyOnHold)
+	db.Model(&hold).Update("status", models.HoldCancelled)
+
+	call(func(c *gin.Context) { UpdateCopyStatus(c, db) }, http.MethodPut, strconv.Itoa(int(bookCopy.ID)), `{"status":"available"}`)
+	db.First(&bookCopy, bookCopy.ID)
+	if bookCopy.Status != models.CopyAvailable {
+		t.Errorf("a copy held for nobody stayed %s", bookCopy.Status)
+	}
+}
+
+func TestAuditorsCanListStudentHolds(t *testing.T) {
+	db, _, hold := heldBook(t, models.CopyIssued)
+	var student models.Student
+	db.First(&student, "usn = ?", hold.StudentUSN)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(student.ID))}}
+	permissions := map[string]bool{}
+	for _, permission := range models.DefaultRoles[models.RoleAuditor] {
+		permissions[permission] = true
+	}
+	c.Set("permissions", permissions)
+	GetStudentHolds(c, db)
+
+	var holds []models.Hold
+	json.Unmarshal(w.Body.Bytes(), &holds)
+	if w.Code != http.StatusOK || len(holds) != 1 {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+}
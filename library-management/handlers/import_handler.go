@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/importer"
+	"library-management/models"
+)
+
+// maxCatalogImportSize limits uploaded catalog files to 100 MB
+const maxCatalogImportSize = 100 << 20
+
+// ImportCatalog starts a background import of a CSV, MARC21 (ISO 2709) or
+// MARCXML file uploaded in the "file" form field. Copies without serial
+// numbers are numbered from serial_prefix; rack_number, vendor_id and
+// item_type fill in what the file leaves out. The import record is
+// returned at once so its progress can be followed.
+func ImportCatalog(c *gin.Context, db *gorm.DB) {
+	var options struct {
+		Format       string `form:"format" binding:"omitempty,oneof=csv marc marcxml"`
+		SerialPrefix string `form:"serial_prefix"`
+		RackNumber   string `form:"rack_number"`
+		VendorID     uint   `form:"vendor_id"`
+		ItemType     string `form:"item_type" binding:"omitempty,oneof=general reference reserve"`
+	}
+	// Binding parses the whole form, so the body is limited first, leaving
+	// room for the multipart headers
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCatalogImportSize+1<<20)
+	var maxBytesErr *http.MaxBytesError
+	if err := c.ShouldBind(&options); err != nil {
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is larger than 100 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is larger than 100 MB"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the catalog as the \"file\" form field"})
+		return
+	}
+	defer file.Close()
+	if header.Size > maxCatalogImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is larger than 100 MB"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxCatalogImportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the file"})
+		return
+	}
+
+	format := options.Format
+	if format == "" {
+		if format, err = importer.DetectFormat(header.Filename, data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID := c.GetUint("userID")
+	job, err := importer.NewCatalogJob(db, header.Filename, format, &userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the import"})
+		return
+	}
+	queued := *job // The import updates job as it runs
+	go importer.RunCatalogImport(db, job, data, importer.CatalogOptions{
+		SerialPrefix: options.SerialPrefix,
+		RackNumber:   options.RackNumber,
+		VendorID:     options.VendorID,
+		ItemType:     options.ItemType,
+	})
+	c.JSON(http.StatusAccepted, queued)
+}
+
+// GetCatalogImports lists the catalog imports, newest first
+func GetCatalogImports(c *gin.Context, db *gorm.DB) {
+	var jobs []models.ImportJob
+	if err := db.Order("id DESC").Limit(100).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetCatalogImport returns an import with its progress
+func GetCatalogImport(c *gin.Context, db *gorm.DB) {
+	var job models.ImportJob
+	if err := db.First(&job, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// GetCatalogImportErrors returns the records an import could not import,
+// as JSON or, with format=csv, as a CSV report
+func GetCatalogImportErrors(c *gin.Context, db *gorm.DB) {
+	var job models.ImportJob
+	if err := db.First(&job, idParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	var failures []models.ImportError
+	if err := db.Where("import_job_id = ?", job.ID).Order("id").Find(&failures).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, failures)
+		return
+	}
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=\"import-"+strconv.FormatUint(uint64(job.ID), 10)+"-errors.csv\"")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"record", "error"})
+	for _, failure := range failures {
+		w.Write([]string{failure.Record, failure.Message})
+	}
+	w.Flush()
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"library-management/scheduler"
+)
+
+// GetJobs lists the scheduled jobs with their next and last runs
+func GetJobs(c *gin.Context, sched *scheduler.Scheduler) {
+	c.JSON(http.StatusOK, sched.Jobs())
+}
+
+// GetJobRuns lists the recent runs of one job, newest first
+func GetJobRuns(c *gin.Context, sched *scheduler.Scheduler) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	runs, err := sched.Runs(c.Param("name"), limit)
+	if errors.Is(err, scheduler.ErrUnknownJob) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job runs"})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// RunJob starts a job now. The run continues in the background; its record
+// is returned so the caller can follow it through GetJobRuns.
+func RunJob(c *gin.Context, sched *scheduler.Scheduler) {
+	run, err := sched.Trigger(c.Param("name"), c.GetUint("userID"))
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, scheduler.ErrJobLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start job"})
+	default:
+		c.JSON(http.StatusAccepted, run)
+	}
+}
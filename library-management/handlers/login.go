@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"net/http"
+	"time"
+	"library-management/config"
 	"library-management/models"
 	"library-management/utils"
 	"github.com/gin-gonic/gin"
@@ -39,6 +41,125 @@ func Login(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
+	// Start a new session for the user
+	tokenID, err := utils.NewTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	session := models.Session{
+		UserID:    user.ID,
+		TokenID:   tokenID,
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
 	// Successful login
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	respondWithTokens(c, user, session)
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair. The
+// refresh token is rotated, so each one can only be used once.
+func RefreshToken(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	claims, err := utils.ParseToken(input.RefreshToken, utils.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// The session must still be active and the token must be its latest one
+	var session models.Session
+	if err := db.Preload("User").First(&session, claims.SessionID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+		return
+	}
+	if session.TokenID != claims.Id {
+		// A rotated token was replayed, so end the session altogether
+		now := time.Now()
+		db.Model(&session).Update("revoked_at", &now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+		return
+	}
+
+	tokenID, err := utils.NewTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	// Only the request that still holds the latest token can rotate it, so
+	// the same token used twice at once is caught as reuse
+	expiresAt := time.Now().Add(config.RefreshTokenTTL)
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND token_id = ? AND revoked_at IS NULL", session.ID, claims.Id).
+		Updates(map[string]interface{}{"token_id": tokenID, "expires_at": expiresAt})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		now := time.Now()
+		db.Model(&models.Session{}).Where("id = ?", session.ID).Update("revoked_at", &now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+		return
+	}
+	session.TokenID = tokenID
+	session.ExpiresAt = expiresAt
+
+	respondWithTokens(c, session.User, session)
+}
+
+// Logout revokes the session of the current access token
+func Logout(c *gin.Context, db *gorm.DB) {
+	sessionID := c.GetUint("sessionID")
+
+	now := time.Now()
+	if err := db.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", &now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// respondWithTokens signs an access and refresh token for the session
+func respondWithTokens(c *gin.Context, user models.User, session models.Session) {
+	accessID, err := utils.NewTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+	accessToken, accessExpiry, err := utils.GenerateToken(utils.AccessToken, user.ID, user.Username, session.ID, accessID, config.AccessTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+	refreshToken, _, err := utils.GenerateToken(utils.RefreshToken, user.ID, user.Username, session.ID, session.TokenID, time.Until(session.ExpiresAt))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_at":    accessExpiry,
+	})
 }
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"library-management/config"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestRefreshTokenRotatesOnce(t *testing.T) {
+	config.JWTSecret = []byte("test secret")
+	db := databasetest.Open(t)
+	if err := SeedRoles(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateUserWithRoles(db, "admin", "pw", "", []string{models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	w := call(func(c *gin.Context) { Login(c, db) }, http.MethodPost, "", `{"username":"admin","password":"pw"}`)
+	var tokens struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil || tokens.RefreshToken == "" {
+		t.Fatalf("login gave %d: %s", w.Code, w.Body)
+	}
+
+	// The same refresh token sent many times at once is only honoured once
+	body := `{"refresh_token":"` + tokens.RefreshToken + `"}`
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- call(func(c *gin.Context) { RefreshToken(c, db) }, http.MethodPost, "", body).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	refreshed := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			refreshed++
+		}
+	}
+	if refreshed != 1 {
+		t.Errorf("the token was refreshed %d times", refreshed)
+	}
+
+	// Reuse ends the session, so even the newest token no longer works
+	var session models.Session
+	db.First(&session)
+	if session.RevokedAt == nil {
+		t.Error("the session survived a reused refresh token")
+	}
+}
+
+func TestCreateFirstUserOnlyOnce(t *testing.T) {
+	db := databasetest.Open(t)
+	if err := SeedRoles(db); err != nil {
+		t.Fatal(err)
+	}
+
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := `{"username":"admin` + string(rune('a'+i)) + `","password":"pw"}`
+			codes <- call(func(c *gin.Context) { CreateFirstUser(c, db) }, http.MethodPost, "", body).Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			created++
+		case http.StatusForbidden:
+		default:
+			t.Errorf("setup gave %d", code)
+		}
+	}
+
+	var users int64
+	db.Model(&models.User{}).Count(&users)
+	if created != 1 || users != 1 {
+		t.Errorf("%d setups succeeded, %d users exist", created, users)
+	}
+}
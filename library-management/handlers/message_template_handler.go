@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/notify"
+	"library-management/repository"
+)
+
+// SeedMessageTemplates stores the built-in notification texts that are not
+// in the database yet. Edited templates are left as they are.
+func SeedMessageTemplates(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range models.DefaultTemplates {
+			template := t
+			if err := tx.Where("key = ? AND language = ?", t.Key, t.Language).FirstOrCreate(&template).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMessageTemplates lists the notification templates, optionally
+// filtered by key and language
+func GetMessageTemplates(c *gin.Context, db *gorm.DB) {
+	query := db.Order("key, language")
+	if key := c.Query("key"); key != "" {
+		query = query.Where("key = ?", key)
+	}
+	if language := c.Query("language"); language != "" {
+		query = query.Where("language = ?", language)
+	}
+
+	templates := []models.MessageTemplate{}
+	if result := query.Find(&templates); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// UpdateMessageTemplate replaces the text of a template in one language
+func UpdateMessageTemplate(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		Subject string `json:"subject"`
+		Body    string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, language, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	template := models.MessageTemplate{Key: key, Language: language, Subject: input.Subject, Body: input.Body}
+	if err := notify.ValidateTemplate(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+
+	saveMessageTemplate(c, db, template)
+}
+
+// ResetMessageTemplate puts a template back to its built-in text
+func ResetMessageTemplate(c *gin.Context, db *gorm.DB) {
+	key, language, ok := templateParams(c)
+	if !ok {
+		return
+	}
+	template, ok := models.DefaultTemplate(key, language)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No built-in text for this template and language"})
+		return
+	}
+
+	saveMessageTemplate(c, db, template)
+}
+
+// PreviewMessageTemplate renders a template without sending it. The
+// subject and body may be given to preview unsaved changes; otherwise the
+// stored template is used. Data comes from the given loan or, without one,
+// from sample values.
+func PreviewMessageTemplate(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		Key           string  `json:"key" binding:"required"`
+		Language      string  `json:"language"`
+		Subject       *string `json:"subject"`
+		Body          *string `json:"body"`
+		TransactionID *uint   `json:"transaction_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := notify.FindTemplate(db, input.Key, input.Language)
+	if errors.Is(err, notify.ErrUnknownTemplate) && input.Body != nil {
+		err = nil
+		template = models.MessageTemplate{Key: input.Key, Language: input.Language}
+	}
+	if errors.Is(err, notify.ErrUnknownTemplate) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Subject != nil {
+		template.Subject = *input.Subject
+	}
+	if input.Body != nil {
+		template.Body = *input.Body
+	}
+
+	data := notify.SampleData()
+	if input.TransactionID != nil {
+		transaction, err := repository.NewGormStore(db).Transactions().Get(*input.TransactionID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		if transaction.Student.ID == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+			return
+		}
+		loanData := notify.DataFor(db, transaction.Student, &transaction)
+		loanData.PickupBy, loanData.Fine, loanData.Balance = data.PickupBy, data.Fine, data.Balance
+		data = loanData
+	}
+
+	subject, body, err := notify.RenderTemplate(template, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"key":      template.Key,
+		"language": template.Language,
+		"subject":  subject,
+		"body":     body,
+	})
+}
+
+// templateParams reads and checks the key and language in the URL. It
+// writes the error response itself.
+func templateParams(c *gin.Context) (key, language string, ok bool) {
+	key, language = c.Param("key"), c.Param("language")
+	if _, known := models.DefaultTemplate(key, models.LanguageEnglish); !known {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown template: " + key})
+		return "", "", false
+	}
+	if !models.IsLanguage(language) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported language: " + language})
+		return "", "", false
+	}
+	return key, language, true
+}
+
+// saveMessageTemplate creates or replaces the template for its key and
+// language and responds with it
+func saveMessageTemplate(c *gin.Context, db *gorm.DB, template models.MessageTemplate) {
+	userID := c.GetUint("userID")
+	var existing models.MessageTemplate
+	err := db.Where("key = ? AND language = ?", template.Key, template.Language).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing.Key = template.Key
+	existing.Language = template.Language
+	existing.Subject = template.Subject
+	existing.Body = template.Body
+	existing.UpdatedByUserID = &userID
+	if result := db.Save(&existing); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, existing)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+// templateCall runs a template handler for the key and language in the URL
+func templateCall(handler func(*gin.Context), method, key, language, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "key", Value: key}, {Key: "language", Value: language}}
+	handler(c)
+	return w
+}
+
+func TestUpdateMessageTemplate(t *testing.T) {
+	db := databasetest.Open(t)
+	if err := SeedMessageTemplates(db); err != nil {
+		t.Fatal(err)
+	}
+	update := func(c *gin.Context) { UpdateMessageTemplate(c, db) }
+	reset := func(c *gin.Context) { ResetMessageTemplate(c, db) }
+	preview := func(c *gin.Context) { PreviewMessageTemplate(c, db) }
+	previewSubject := func(language string) string {
+		w := templateCall(preview, http.MethodPost, "", "", `{"key":"`+models.TemplateFine+`","language":"`+language+`"}`)
+		var response struct{ Subject string }
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Subject
+	}
+
+	for name, body := range map[string]string{
+		"unparsable":          `{"subject":"Fine","body":"{{.Fine"}`,
+		"unknown placeholder": `{"subject":"Fine","body":"{{.Amount}}"}`,
+		"no body":             `{"subject":"Fine"}`,
+	} {
+		if w := templateCall(update, http.MethodPut, models.TemplateFine, models.LanguageHindi, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s template gave %d: %s", name, w.Code, w.Body)
+		}
+	}
+	if w := templateCall(update, http.MethodPut, "no_such_template", models.LanguageHindi, `{"body":"Hi"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown key gave %d", w.Code)
+	}
+	if w := templateCall(update, http.MethodPut, models.TemplateFine, "fr", `{"body":"Hi"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported language gave %d", w.Code)
+	}
+
+	// An edited text is used from then on
+	w := templateCall(update, http.MethodPut, models.TemplateFine, models.LanguageHindi, `{"subject":"Jurmana {{.Fine}}","body":"{{.StudentName}}"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if subject := previewSubject(models.LanguageHindi); subject != "Jurmana 30.00" {
+		t.Errorf("edited subject previews as %q", subject)
+	}
+
+	// Resetting restores the built-in text
+	if w := templateCall(reset, http.MethodPost, models.TemplateFine, models.LanguageHindi, ""); w.Code != http.StatusOK {
+		t.Fatalf("reset gave %d: %s", w.Code, w.Body)
+	}
+	if subject := previewSubject(models.LanguageHindi); subject != "पुस्तकालय जुर्माना रु. 30.00" {
+		t.Errorf("reset subject previews as %q", subject)
+	}
+	// Unsupported languages fall back to English
+	if subject := previewSubject("fr"); subject != "Library fine of Rs. 30.00" {
+		t.Errorf("French subject previews as %q", subject)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/notify"
+	"library-management/repository"
+)
+
+// GetNotificationChannels lists the channels this server can send on
+func GetNotificationChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"channels": notify.Default().Channels()})
+}
+
+// GetNotificationPreferences returns the channels a student is notified on
+func GetNotificationPreferences(c *gin.Context, db *gorm.DB) {
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if !canActForStudent(c, db, student, models.PermStudentsWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"student_usn": student.USN,
+		"channels":    notify.Default().ChannelsFor(student),
+		"is_default":  student.NotifyChannels == "",
+		"language":    student.Language,
+	})
+}
+
+// SetNotificationPreferences chooses the channels and language a student is
+// notified in. An empty list or language goes back to the server defaults;
+// a missing language is left unchanged.
+func SetNotificationPreferences(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		Channels []string `json:"channels"`
+		Language *string  `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if !canActForStudent(c, db, student, models.PermStudentsWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only change your own preferences"})
+		return
+	}
+
+	channels := notify.ParseChannels(strings.Join(input.Channels, ","))
+	for _, channel := range channels {
+		if _, ok := notify.Default().Notifier(channel); !ok || channel == notify.ChannelLog {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or disabled channel: " + channel})
+			return
+		}
+	}
+
+	updates := map[string]interface{}{"notify_channels": strings.Join(channels, ",")}
+	if input.Language != nil {
+		if *input.Language != "" && !models.IsLanguage(*input.Language) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported language: " + *input.Language})
+			return
+		}
+		updates["language"] = *input.Language
+	}
+
+	if result := db.Model(&student).Updates(updates); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	GetNotificationPreferences(c, db)
+}
+
+// GetNotificationHistory lists the notifications sent or queued for a
+// student, newest first. Optional filters: status, template and limit.
+func GetNotificationHistory(c *gin.Context, db *gorm.DB) {
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if !canActForStudent(c, db, student, models.PermStudentsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own notifications"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	query := db.Where("student_usn = ?", student.USN)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if template := c.Query("template"); template != "" {
+		query = query.Where("template = ?", template)
+	}
+
+	messages := []models.OutboxMessage{}
+	if err := query.Order("created_at desc, id desc").Limit(limit).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"library-management/database/databasetest"
+	"library-management/models"
+	"library-management/notify"
+)
+
+func TestStudentsCannotChooseTheLogChannel(t *testing.T) {
+	db := databasetest.Open(t)
+	student := models.Student{USN: "1AB21CS001", Name: "Asha"}
+	if err := db.Create(&student).Error; err != nil {
+		t.Fatal(err)
+	}
+	previous := notify.Default()
+	notify.SetDefault(notify.NewDispatcher([]string{notify.ChannelLog}, notify.Log{}, &notify.Recorder{}))
+	t.Cleanup(func() { notify.SetDefault(previous) })
+
+	for body, want := range map[string]int{
+		`{"channels":["log"]}`: http.StatusBadRequest,
+		`{"channels":["sms"]}`: http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(int(student.ID))}}
+		c.Set("permissions", map[string]bool{models.PermStudentsWrite: true})
+		SetNotificationPreferences(c, db)
+		if w.Code != want {
+			t.Errorf("%s: status %d: %s", body, w.Code, w.Body)
+		}
+	}
+
+	if channels := notify.Default().Channels(); strings.Join(channels, ",") != "sms" {
+		t.Errorf("channels offered to students = %v", channels)
+	}
+}
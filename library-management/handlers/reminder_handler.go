@@ -1,97 +1,157 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"library-management/config"
 	"library-management/models"
+	"library-management/notify"
+	"library-management/repository"
 	"log"
-	"strings"
+	"strconv"
 	"time"
 	"gorm.io/gorm"
 )
 
-// SendReminderForSpecificStudent sends a reminder for a specific student based on student_id or usn
-func SendReminderForSpecificStudent(db *gorm.DB, studentID, usn string) error {
-	var transactions []models.Transaction
+// SendReminderForSpecificStudent sends a reminder for each open loan of a
+// specific student based on student_id or usn. Reminders already sent today
+// are skipped. It returns how many reminders were queued.
+func SendReminderForSpecificStudent(db *gorm.DB, studentID, usn string) (int, error) {
+	store := repository.NewGormStore(db)
 
 	// Query by student_id or usn (whichever is provided)
-	query := db.Model(&models.Transaction{})
-
 	if studentID != "" {
-		query = query.Where("student_id = ?", studentID)
-	}
-	if usn != "" {
-		query = query.Where("student_usn = ?", usn)
+		id, err := strconv.ParseUint(studentID, 10, 64)
+		if err != nil {
+			return 0, repository.ErrNotFound
+		}
+		student, err := store.Students().Get(uint(id))
+		if err != nil {
+			return 0, err
+		}
+		usn = student.USN
 	}
 
-	if err := query.Find(&transactions).Error; err != nil {
-		return err
+	transactions, err := store.Transactions().Find(repository.TransactionFilter{StudentUSN: usn, OpenOnly: true})
+	if err != nil {
+		return 0, err
 	}
 
-	// For each transaction, send a reminder
+	// For each transaction, send the reminder that fits its due date
+	now := time.Now()
+	queued := 0
 	for _, transaction := range transactions {
-		var student models.Student
-		if err := db.First(&student, "usn = ?", transaction.StudentUSN).Error; err != nil {
-			log.Println("Error fetching student:", err)
+		if transaction.Student.ID == 0 {
+			log.Println("Error fetching student with USN:", transaction.StudentUSN)
 			continue
 		}
 
-		// Validate phone number before sending the reminder
-		if student.Phone == "" {
-			log.Println("Error: Invalid phone number for student:", student.Name)
-			continue
+		// Failed sends stay in the outbox and are retried by the worker
+		sent, err := sendReminder(context.Background(), db, transaction.Student, transaction, reminderKindFor(transaction, now), now)
+		if err != nil {
+			log.Println("Error sending reminder:", err)
 		}
-
-		// Ensure the phone number is in E.164 format (e.g., +1234567890)
-		if !strings.HasPrefix(student.Phone, "+") {
-			student.Phone = "+91" + student.Phone // Example for US, adjust as necessary
+		if sent {
+			queued++
 		}
+	}
+
+	return queued, nil
+}
+
+// Reminder kinds sent by the scheduled reminder jobs
+const (
+	ReminderDueSoon  = "due_soon"
+	ReminderDueToday = "due_today"
+	ReminderOverdue  = "overdue"
+)
 
-		dueDate := transaction.DueDate.Format("2006-01-02")
-		// Call the SendDueDateReminder function from config
-		err := config.SendDueDateReminder(student.Phone, dueDate, student.Name)
+// CheckDueDatesAndSendReminders checks all due dates within the next 2 days
+// and sends reminders until ctx is cancelled. It returns how many reminders
+// were queued.
+func CheckDueDatesAndSendReminders(ctx context.Context, db *gorm.DB) int {
+	queued := 0
+	for _, kind := range []string{ReminderDueToday, ReminderDueSoon} {
+		n, err := SendScheduledReminders(ctx, db, kind, time.Now())
 		if err != nil {
-			log.Println("Error sending reminder:", err)
+			log.Println("Error sending reminders:", err)
 		}
+		queued += n
 	}
-
-	return nil
+	return queued
 }
 
-// CheckDueDatesAndSendReminders checks all due dates within the next 2 days and sends reminders
-func CheckDueDatesAndSendReminders(db *gorm.DB) {
-	transactions := []models.Transaction{}
-	// Fetch transactions where due date is within the next 2 days
-	if err := db.Where("due_date <= ?", time.Now().AddDate(0, 0, 2)).Find(&transactions).Error; err != nil {
-		log.Println("Error fetching transactions:", err)
-		return
+// SendScheduledReminders notifies every student with an open loan that is
+// due soon, due today or overdue, depending on kind. It returns how many
+// reminders were queued; loans already reminded today are skipped. It stops
+// with the context's error once ctx is cancelled.
+func SendScheduledReminders(ctx context.Context, db *gorm.DB, kind string, now time.Time) (int, error) {
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfTomorrow := startOfToday.AddDate(0, 0, 1)
+
+	filter := repository.TransactionFilter{OpenOnly: true}
+	switch kind {
+	case ReminderDueSoon:
+		filter.DueFrom, filter.DueBefore = startOfTomorrow, startOfTomorrow.AddDate(0, 0, config.DueSoonDays)
+	case ReminderDueToday:
+		filter.DueFrom, filter.DueBefore = startOfToday, startOfTomorrow
+	case ReminderOverdue:
+		filter.DueBefore = startOfToday
+	default:
+		return 0, fmt.Errorf("unknown reminder kind %q", kind)
+	}
+
+	transactions, err := repository.NewGormStore(db.WithContext(ctx)).Transactions().Find(filter)
+	if err != nil {
+		return 0, err
 	}
 
+	queued := 0
 	for _, transaction := range transactions {
-		// Ensure the student is preloaded
-		var student models.Student
-		if err := db.First(&student, "usn = ?", transaction.StudentUSN).Error; err != nil {
-			log.Println("Error fetching student with USN:", transaction.StudentUSN, err)
-			continue
+		if err := ctx.Err(); err != nil {
+			return queued, err
 		}
 
-		// Validate phone number before sending the reminder
-		if student.Phone == "" {
-			log.Println("Error: Invalid phone number for student:", student.Name)
+		// Loans come with their student; skip any whose student is gone
+		if transaction.Student.ID == 0 {
+			log.Println("Error fetching student with USN:", transaction.StudentUSN)
 			continue
 		}
 
-		// Ensure the phone number is in E.164 format (e.g., +1234567890)
-		if !strings.HasPrefix(student.Phone, "+") {
-			student.Phone = "+1" + student.Phone // Example for US, adjust as necessary
-		}
-
-		// Format the due date for the message
-		dueDate := transaction.DueDate.Format("2006-01-02")
-
-		// Send the reminder message
-		err := config.SendDueDateReminder(student.Phone, dueDate, student.Name)
+		// Send the reminder message; failed sends stay in the outbox for retry
+		sent, err := sendReminder(ctx, db, transaction.Student, transaction, kind, now)
 		if err != nil {
 			log.Println("Error sending reminder:", err)
 		}
+		if sent {
+			queued++
+		}
 	}
+	return queued, nil
+}
+
+// reminderKindFor picks the reminder that fits a loan's due date
+func reminderKindFor(transaction models.Transaction, now time.Time) string {
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch {
+	case transaction.DueDate.Before(startOfToday):
+		return ReminderOverdue
+	case transaction.DueDate.Before(startOfToday.AddDate(0, 0, 1)):
+		return ReminderDueToday
+	default:
+		return ReminderDueSoon
+	}
+}
+
+// sendReminder queues a reminder of the given kind for one loan in the
+// notification outbox and makes the first delivery attempt. The same kind
+// of reminder is sent at most once per loan and day; queued reports
+// whether this call queued it.
+func sendReminder(ctx context.Context, db *gorm.DB, student models.Student, transaction models.Transaction, kind string, now time.Time) (queued bool, err error) {
+	messages, err := notify.SendTemplate(ctx, db, student, notify.Notification{
+		Template:      "reminder_" + kind,
+		TransactionID: &transaction.ID,
+		DedupKey:      fmt.Sprintf("reminder:%s:%d:%s", kind, transaction.ID, now.Format("2006-01-02")),
+	}, notify.DataFor(db, student, &transaction))
+	return len(messages) > 0, err
 }
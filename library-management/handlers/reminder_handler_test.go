@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/database/databasetest"
+	"library-management/models"
+	"library-management/notify"
+)
+
+// reminderLibrary is a database with templates, two students and a loan
+// each, and a dispatcher that records what it would send by SMS
+type reminderLibrary struct {
+	db       *gorm.DB
+	recorder *notify.Recorder
+	asha     models.Student
+	ravi     models.Student
+}
+
+func newReminderLibrary(t *testing.T, now time.Time) *reminderLibrary {
+	t.Helper()
+	l := &reminderLibrary{db: databasetest.Open(t), recorder: &notify.Recorder{}}
+	previous := notify.Default()
+	notify.SetDefault(notify.NewDispatcher([]string{notify.ChannelSMS}, l.recorder))
+	t.Cleanup(func() { notify.SetDefault(previous) })
+
+	if err := SeedMessageTemplates(l.db); err != nil {
+		t.Fatal(err)
+	}
+	l.asha = models.Student{USN: "1AB21CS001", Name: "Asha", Phone: "+919800000001"}
+	l.ravi = models.Student{USN: "1AB21CS002", Name: "Ravi", Phone: "+919800000002"}
+	book := models.Book{Title: "The Go Programming Language", Author: "Donovan", Edition: 1, Copies: []models.Copy{
+		{SerialNumber: "S-1", Status: models.CopyIssued},
+		{SerialNumber: "S-2", Status: models.CopyIssued},
+		{SerialNumber: "S-3", Status: models.CopyIssued},
+	}}
+	for _, record := range []interface{}{&l.asha, &l.ravi, &book} {
+		if err := l.db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Asha's loan is due tomorrow and Ravi's is overdue; Ravi's other
+	// loan has been returned
+	returned := now.AddDate(0, 0, -1)
+	for _, loan := range []models.Transaction{
+		{StudentUSN: l.asha.USN, CopyID: book.Copies[0].ID, IssueDate: now.AddDate(0, 0, -13), DueDate: now.AddDate(0, 0, 1)},
+		{StudentUSN: l.ravi.USN, CopyID: book.Copies[1].ID, IssueDate: now.AddDate(0, 0, -20), DueDate: now.AddDate(0, 0, -6)},
+		{StudentUSN: l.ravi.USN, CopyID: book.Copies[2].ID, IssueDate: now.AddDate(0, 0, -20), DueDate: now.AddDate(0, 0, -6), ReturnDate: &returned},
+	} {
+		if err := l.db.Create(&loan).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+// sentTo returns the phone numbers messages were sent to
+func (l *reminderLibrary) sentTo() []string {
+	var phones []string
+	for _, msg := range l.recorder.Sent() {
+		phones = append(phones, msg.To.Phone)
+	}
+	return phones
+}
+
+func TestSendReminderForSpecificStudent(t *testing.T) {
+	l := newReminderLibrary(t, time.Now())
+
+	queued, err := SendReminderForSpecificStudent(l.db, "", l.ravi.USN)
+	if err != nil || queued != 1 {
+		t.Fatalf("queued %d reminders: %v", queued, err)
+	}
+	sent := l.recorder.Sent()
+	if len(sent) != 1 || sent[0].To.Phone != l.ravi.Phone || sent[0].To.StudentUSN != l.ravi.USN {
+		t.Fatalf("sent %+v", sent)
+	}
+	if !strings.Contains(sent[0].Body, "The Go Programming Language") {
+		t.Errorf("the reminder does not name the book: %q", sent[0].Body)
+	}
+	var message models.OutboxMessage
+	if err := l.db.First(&message).Error; err != nil || message.Template != models.TemplateOverdue || message.Status != models.OutboxSent {
+		t.Errorf("outbox message = %+v, %v", message, err)
+	}
+
+	// A second request on the same day is not sent again
+	if queued, err := SendReminderForSpecificStudent(l.db, strconv.Itoa(int(l.ravi.ID)), ""); err != nil || queued != 0 {
+		t.Errorf("a repeated reminder queued %d: %v", queued, err)
+	}
+	if len(l.recorder.Sent()) != 1 {
+		t.Errorf("a repeated reminder was sent again")
+	}
+
+	// By id the other student gets a due-soon reminder
+	if queued, err := SendReminderForSpecificStudent(l.db, strconv.Itoa(int(l.asha.ID)), ""); err != nil || queued != 1 {
+		t.Errorf("queued %d reminders for Asha: %v", queued, err)
+	}
+	if phones := l.sentTo(); len(phones) != 2 || phones[1] != l.asha.Phone {
+		t.Errorf("sent to %v", phones)
+	}
+
+	if _, err := SendReminderForSpecificStudent(l.db, "999", ""); err == nil {
+		t.Error("an unknown student was reminded")
+	}
+}
+
+func TestSendScheduledReminders(t *testing.T) {
+	now := time.Now()
+	l := newReminderLibrary(t, now)
+
+	queued, err := SendScheduledReminders(context.Background(), l.db, ReminderOverdue, now)
+	if err != nil || queued != 1 {
+		t.Fatalf("queued %d overdue reminders: %v", queued, err)
+	}
+	if phones := l.sentTo(); len(phones) != 1 || phones[0] != l.ravi.Phone {
+		t.Errorf("overdue reminders went to %v", phones)
+	}
+
+	l.recorder.Reset()
+	if queued := CheckDueDatesAndSendReminders(context.Background(), l.db); queued != 1 {
+		t.Errorf("queued %d due reminders", queued)
+	}
+	if phones := l.sentTo(); len(phones) != 1 || phones[0] != l.asha.Phone {
+		t.Errorf("due reminders went to %v", phones)
+	}
+
+	// The jobs may run again the same day without reminding twice
+	l.recorder.Reset()
+	if queued, _ := SendScheduledReminders(context.Background(), l.db, ReminderOverdue, now); queued != 0 || CheckDueDatesAndSendReminders(context.Background(), l.db) != 0 {
+		t.Error("reminders were queued twice on one day")
+	}
+	if sent := l.recorder.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d reminders again", len(sent))
+	}
+
+	if _, err := SendScheduledReminders(context.Background(), l.db, "weekly", now); err == nil {
+		t.Error("an unknown reminder kind was accepted")
+	}
+}
+
+func TestRemindersStayQueuedWhenSendingFails(t *testing.T) {
+	now := time.Now()
+	l := newReminderLibrary(t, now)
+	l.recorder.Err = errors.New("the SMS provider is down")
+
+	if queued, _ := SendScheduledReminders(context.Background(), l.db, ReminderOverdue, now); queued != 1 {
+		t.Fatalf("queued %d reminders", queued)
+	}
+	var message models.OutboxMessage
+	if err := l.db.First(&message).Error; err != nil || message.Status != models.OutboxPending || message.Attempts != 1 {
+		t.Errorf("outbox message = %+v, %v", message, err)
+	}
+}
+
+func TestScheduledRemindersStopWhenCancelled(t *testing.T) {
+	now := time.Now()
+	l := newReminderLibrary(t, now)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	queued, err := SendScheduledReminders(ctx, l.db, ReminderOverdue, now)
+	if !errors.Is(err, context.Canceled) || queued != 0 {
+		t.Errorf("queued %d reminders after cancelling: %v", queued, err)
+	}
+	if sent := l.recorder.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d reminders after cancelling", len(sent))
+	}
+}
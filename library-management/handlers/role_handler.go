@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// SeedRoles makes sure the built-in roles exist and hold at least their
+// default permissions. Permissions granted by hand are left in place.
+func SeedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for roleName, permissionNames := range models.DefaultRoles {
+			role := models.Role{Name: roleName}
+			if err := tx.Where("name = ?", roleName).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+
+			var permissions []models.Permission
+			for _, name := range permissionNames {
+				permission := models.Permission{Name: name}
+				if err := tx.Where("name = ?", name).FirstOrCreate(&permission).Error; err != nil {
+					return err
+				}
+				permissions = append(permissions, permission)
+			}
+
+			if err := tx.Model(&role).Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRoles lists all roles with their permissions
+func GetRoles(c *gin.Context, db *gorm.DB) {
+	var roles []models.Role
+	if result := db.Preload("Permissions").Order("name").Find(&roles); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// findRoles loads roles by name and fails if any of them does not exist
+func findRoles(db *gorm.DB, names []string) ([]models.Role, error) {
+	roles := []models.Role{}
+	if len(names) == 0 {
+		return roles, nil
+	}
+	if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRole, name)
+		}
+	}
+	return roles, nil
+}
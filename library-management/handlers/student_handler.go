@@ -1,19 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/importer"
 	"library-management/models"
+	"library-management/repository"
+	"library-management/utils"
 )
 
+// idParam reads a numeric ID from the URL. Malformed IDs read as 0, which
+// matches no record.
+func idParam(c *gin.Context, name string) uint {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
 // Get all students
 func GetStudents(c *gin.Context, db *gorm.DB) {
-	var students []models.Student
-	if result := db.Find(&students); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	students, err := repository.NewGormStore(db).Students().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, students)
@@ -21,9 +39,8 @@ func GetStudents(c *gin.Context, db *gorm.DB) {
 
 // Get a single student by ID
 func GetStudentByID(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-	var student models.Student
-	if result := db.First(&student, id); result.Error != nil {
+	student, err := repository.NewGormStore(db).Students().Get(idParam(c, "id"))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
 		return
 	}
@@ -39,18 +56,26 @@ func CreateStudent(c *gin.Context, db *gorm.DB) {
 	}
 
 	// Check if the student with the same USN already exists
-	var existingStudent models.Student
-	if result := db.Where("usn = ?", student.USN).First(&existingStudent); result.Error == nil {
+	students := repository.NewGormStore(db).Students()
+	if _, err := students.GetByUSN(student.USN); err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Student with the same USN already exists"})
 		return
 	}
 
+	// Store the phone number in E.164 form
+	phone, err := utils.NormalizePhone(student.Phone, config.DefaultPhoneRegion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number: " + student.Phone})
+		return
+	}
+	student.Phone = phone
+
 	// Set registered and expiry dates
 	student.RegisteredAt = time.Now()
 	student.ExpiryDate = student.RegisteredAt.AddDate(4, 0, 0)
 
-	if result := db.Create(&student); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	if err := students.Create(&student); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, student)
@@ -59,29 +84,111 @@ func CreateStudent(c *gin.Context, db *gorm.DB) {
 
 // Update an existing student
 func UpdateStudent(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-	var student models.Student
-	if result := db.First(&student, id); result.Error != nil {
+	students := repository.NewGormStore(db).Students()
+	student, err := students.Get(idParam(c, "id"))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
 		return
 	}
 
-	var updatedData models.Student
-	if err := c.ShouldBindJSON(&updatedData); err != nil {
+	// Only the fields given are changed. The phone is read separately so
+	// that an empty one clears the stored number.
+	var input struct {
+		models.Student
+		Phone *string `json:"phone"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	updatedData := input.Student
 
-	db.Model(&student).Updates(updatedData)
+	// Store a new phone number in E.164 form
+	var phone string
+	if input.Phone != nil {
+		phone, err = utils.NormalizePhone(*input.Phone, config.DefaultPhoneRegion)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number: " + *input.Phone})
+			return
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewGormStore(tx).Students().Update(&student, updatedData); err != nil {
+			return err
+		}
+		if input.Phone == nil {
+			return nil
+		}
+		student.Phone = phone
+		return tx.Model(&student).Update("phone", phone).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, student)
 }
 
 // Delete a student
 func DeleteStudent(c *gin.Context, db *gorm.DB) {
-	id := c.Param("id")
-	if result := db.Delete(&models.Student{}, id); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	if err := repository.NewGormStore(db).Students().Delete(idParam(c, "id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Student deleted successfully"})
 }
+
+// maxStudentImportSize limits uploaded student files to 10 MB
+const maxStudentImportSize = 10 << 20
+
+// Import students from an uploaded CSV or XLSX file. With dry_run=true the
+// file is checked and the counts reported without storing anything.
+func ImportStudents(c *gin.Context, db *gorm.DB) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	// Limit the body before the form is parsed, leaving room for the
+	// multipart headers
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStudentImportSize+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is larger than 10 MB"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the students as the \"file\" form field"})
+		return
+	}
+	defer file.Close()
+	if header.Size > maxStudentImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The file is larger than 10 MB"})
+		return
+	}
+
+	var rows []importer.StudentRow
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".csv":
+		rows, err = importer.ReadStudentsCSV(file)
+	case ".xlsx":
+		rows, err = importer.ReadStudentsXLSX(file, header.Size)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .csv and .xlsx files can be imported"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file: " + err.Error()})
+		return
+	}
+
+	result, err := importer.ImportStudents(db, rows, dryRun)
+	if errors.Is(err, importer.ErrInvalidRows) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some rows are invalid, nothing was imported", "errors": result.Errors})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"library-management/config"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestUpdateStudentPhone(t *testing.T) {
+	db := databasetest.Open(t)
+	config.DefaultPhoneRegion = "IN"
+	student := models.Student{USN: "1AB21CS001", Name: "Asha", Phone: "+919876543210", Department: "CSE"}
+	if err := db.Create(&student).Error; err != nil {
+		t.Fatal(err)
+	}
+	updateStudent := func(c *gin.Context) { UpdateStudent(c, db) }
+	id := strconv.Itoa(int(student.ID))
+
+	for _, tc := range []struct {
+		body, phone string
+	}{
+		// Other fields leave the phone alone
+		{`{"department":"ECE"}`, "+919876543210"},
+		{`{"phone":"98765 43211"}`, "+919876543211"},
+		{`{"phone":""}`, ""},
+	} {
+		w := call(updateStudent, http.MethodPut, id, tc.body)
+		var response models.Student
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var stored models.Student
+		db.First(&stored, student.ID)
+		if w.Code != http.StatusOK || response.Phone != tc.phone || stored.Phone != tc.phone {
+			t.Errorf("%s: status %d, phone %q, stored %q, want %q", tc.body, w.Code, response.Phone, stored.Phone, tc.phone)
+		}
+	}
+
+	if w := call(updateStudent, http.MethodPut, id, `{"phone":"12345"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("an invalid phone gave %d", w.Code)
+	}
+	var stored models.Student
+	db.First(&stored, student.ID)
+	if stored.Department != "ECE" || stored.Name != "Asha" {
+		t.Errorf("student = %+v", stored)
+	}
+}
@@ -1,19 +1,21 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"time"
-    "fmt"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"library-management/circulation"
 	"library-management/models"
+	"library-management/repository"
+	"library-management/services"
 )
 
 func GetTransactions(c *gin.Context, db *gorm.DB) {
-    var transactions []models.Transaction
-    result := db.Preload("Student").Preload("Book").Find(&transactions)
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+    transactions, err := repository.NewGormStore(db).Transactions().Find(repository.TransactionFilter{})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
@@ -27,6 +29,11 @@ func GetTransactions(c *gin.Context, db *gorm.DB) {
 
 
 
+// circulationService returns the checkout and return rules over db
+func circulationService(db *gorm.DB) *services.Circulation {
+    return services.NewGormCirculation(db)
+}
+
 func CreateTransaction(c *gin.Context, db *gorm.DB) {
     var input struct {
         StudentUSN  string `json:"student_usn"`
@@ -39,86 +46,118 @@ func CreateTransaction(c *gin.Context, db *gorm.DB) {
         return
     }
 
-    // Debugging log to check incoming USN
-    fmt.Printf("Received USN: '%s'\n", input.StudentUSN)
+    // Pass on copies whose holds were not picked up in time, so they do
+    // not wait for the scheduler to become available
+    if _, err := ExpireHolds(db); err != nil {
+        log.Println("Error expiring holds:", err)
+    }
 
-    // Check if the student exists with case-insensitive search and trim spaces
-    var student models.Student
-    if err := db.Where("LOWER(TRIM(usn)) = LOWER(TRIM(?))", input.StudentUSN).First(&student).Error; err != nil {
-        fmt.Printf("Student not found, error: %v\n", err)
+    transaction, err := circulationService(db).Checkout(input.StudentUSN, input.SerialNumber, c.GetUint("userID"))
+    var unavailable services.CopyUnavailableError
+    switch {
+    case err == nil:
+        c.JSON(http.StatusCreated, transaction)
+    case err == services.ErrStudentNotFound:
         c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
-        return
+    case err == services.ErrCopyNotFound:
+        c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+    case errors.As(err, &unavailable):
+        c.JSON(http.StatusConflict, gin.H{
+            "error": "This copy is not available for issue (status: " + unavailable.Status + ")",
+        })
+    case err == services.ErrCopyJustIssued:
+        c.JSON(http.StatusConflict, gin.H{"error": "This copy has just been issued"})
+    case err == circulation.ErrHeldForOther:
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case err == circulation.ErrNotLoanable, err == circulation.ErrLoanLimitReached, err == circulation.ErrBalanceTooHigh:
+        c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
     }
+}
 
-    // Find the book by its serial number
-    var book models.Book
-    if err := db.Where("serial_number = ?", input.SerialNumber).First(&book).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+
+
+// RenewTransaction extends the due date of an open loan
+func RenewTransaction(c *gin.Context, db *gorm.DB) {
+    transaction, err := repository.NewGormStore(db).Transactions().Get(idParam(c, "id"))
+    if err != nil {
+        if err == repository.ErrNotFound {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+        } else {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        }
+        return
+    }
+    if !canActForStudent(c, db, transaction.Student, models.PermCirculationIssue) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You can only renew your own loans"})
         return
     }
 
-    // Check if a transaction already exists for the given student and book
-    var existingTransaction models.Transaction
-    if err := db.Where("student_usn = ? AND book_id = ?", input.StudentUSN, book.ID).First(&existingTransaction).Error; err == nil {
-        c.JSON(http.StatusConflict, gin.H{
-            "error": "A transaction already exists for this student and book",
-        })
+    if err := circulationService(db).Renew(&transaction, c.GetUint("userID")); err != nil {
+        switch err {
+        case circulation.ErrAlreadyReturned, circulation.ErrRenewalLimit, circulation.ErrTooOverdue,
+            circulation.ErrHeldForRenewal, circulation.ErrNotLoanable:
+            c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        }
         return
     }
 
-    // Create a new transaction
-    transaction := models.Transaction{
-        StudentUSN: input.StudentUSN,
-        BookID:     book.ID,  // Use the book ID from the found book
-        IssueDate:  time.Now(),
-        DueDate:    time.Now().AddDate(0, 0, 14), // Default 2-week due date
+    c.JSON(http.StatusOK, gin.H{
+        "message":     "Loan renewed successfully",
+        "transaction": transaction,
+    })
+}
+
+// GetTransactionHistory lists the issue, renewal and return events of a loan
+func GetTransactionHistory(c *gin.Context, db *gorm.DB) {
+    transaction, err := repository.NewGormStore(db).Transactions().Get(idParam(c, "id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+        return
+    }
+    if !canActForStudent(c, db, transaction.Student, models.PermTransactionsRead) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own loans"})
+        return
     }
 
-    if err := db.Create(&transaction).Error; err != nil {
+    var events []models.TransactionEvent
+    if err := db.Where("transaction_id = ?", transaction.ID).Order("created_at, id").Find(&events).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusCreated, transaction)
+    c.JSON(http.StatusOK, events)
 }
 
-
-
 // Delete a transaction
 func DeleteTransaction(c *gin.Context, db *gorm.DB) {
-	transactionID := c.Param("id")
-	if result := db.Delete(&models.Transaction{}, transactionID); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	// Deleting an open loan reshelves its copy, perhaps for a hold
+	ready, err := circulationService(db).Delete(idParam(c, "id"))
+	if err == services.ErrTransactionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if ready != nil {
+		circulation.NotifyHoldReady(db, *ready)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Transaction deleted successfully"})
 }
 
 func SearchTransactions(c *gin.Context, db *gorm.DB) {
-    var transactions []models.Transaction
-
-    // Get query parameters
-    serialNumber := c.Query("serial_number")
-    studentUSN := c.Query("student_usn")
-
-    // Base query
-    query := db.Preload("Student").Preload("Book")
-
-    // If serial number is provided, find matching transactions by serial number
-    if serialNumber != "" {
-        query = query.Joins("JOIN books ON books.id = transactions.book_id").
-            Where("books.serial_number = ?", serialNumber)
-    }
-
-    // If student USN is provided, filter by student USN
-    if studentUSN != "" {
-        query = query.Where("student_usn = ?", studentUSN)
-    }
-
-    // Execute the query
-    result := query.Find(&transactions)
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+    // Filter by serial number and student USN when they are given
+    transactions, err := repository.NewGormStore(db).Transactions().Find(repository.TransactionFilter{
+        SerialNumber: c.Query("serial_number"),
+        StudentUSN:   c.Query("student_usn"),
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"library-management/models"
 	"library-management/utils"
@@ -8,11 +9,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// errSetupDone is returned when the first user has already been created
+var errSetupDone = errors.New("setup has already been completed")
+
 // CreateUser handles user registration by hashing the password
 func CreateUser(c *gin.Context, db *gorm.DB) {
 	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username   string   `json:"username" binding:"required"`
+		Password   string   `json:"password" binding:"required"`
+		StudentUSN string   `json:"student_usn"`
+		Roles      []string `json:"roles"`
 	}
 
 	// Bind JSON input to the struct
@@ -21,25 +27,149 @@ func CreateUser(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	// Hash the password before saving
-	hashedPassword, err := utils.HashPassword(input.Password)
+	// Accounts without explicit roles default to the student role when linked to a student
+	roles := input.Roles
+	if len(roles) == 0 && input.StudentUSN != "" {
+		roles = []string{models.RoleStudent}
+	}
+
+	user, err := CreateUserWithRoles(db, input.Username, input.Password, input.StudentUSN, roles)
+	if err != nil {
+		if errors.Is(err, ErrUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// Respond with success message
+	c.JSON(http.StatusOK, gin.H{"message": "User created successfully", "user": user})
+}
+
+// CreateFirstUser bootstraps the first admin account on a fresh install. It
+// is reachable without a token and refuses once any user exists.
+func CreateFirstUser(c *gin.Context, db *gorm.DB) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	// Concurrent setup requests queue on the admin role row, so only the
+	// first of them can find no users. A write takes the row lock on every
+	// database.
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE roles SET id = id WHERE name = ?", models.RoleAdmin).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errSetupDone
+		}
+
+		var err error
+		user, err = CreateUserWithRoles(tx, input.Username, input.Password, "", []string{models.RoleAdmin})
+		return err
+	})
+	if err == errSetupDone {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Setup has already been completed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Admin user created successfully", "user": user})
+}
+
+// GetUsers lists all user accounts with their roles
+func GetUsers(c *gin.Context, db *gorm.DB) {
+	var users []models.User
+	if result := db.Preload("Roles").Find(&users); result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// AssignUserRoles replaces the roles held by a user
+func AssignUserRoles(c *gin.Context, db *gorm.DB) {
+	userID := c.Param("id")
+	var input struct {
+		Roles []string `json:"roles" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	roles, err := findRoles(db, input.Roles)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		if errors.Is(err, ErrUnknownRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
+	if err := db.Model(&user).Association("Roles").Replace(roles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user.Roles = roles
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUserWithRoles hashes the password and saves the user with the named roles
+func CreateUserWithRoles(db *gorm.DB, username, password, studentUSN string, roleNames []string) (models.User, error) {
+	// Hash the password before saving
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	roles, err := findRoles(db, roleNames)
+	if err != nil {
+		return models.User{}, err
+	}
+
 	// Create the user object
 	user := models.User{
-		Username: input.Username,
+		Username: username,
 		Password: hashedPassword,
+		Roles:    roles,
+	}
+	if studentUSN != "" {
+		user.StudentUSN = &studentUSN
 	}
 
 	// Save the user to the database
 	if err := db.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
+		return models.User{}, err
 	}
-
-	// Respond with success message
-	c.JSON(http.StatusOK, gin.H{"message": "User created successfully"})
+	return user, nil
 }
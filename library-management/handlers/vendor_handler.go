@@ -6,13 +6,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"library-management/models"
+	"library-management/repository"
 )
 
 // Get all vendors
 func GetVendors(c *gin.Context, db *gorm.DB) {
-	var vendors []models.Vendor
-	if result := db.Find(&vendors); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	vendors, err := repository.NewGormStore(db).Vendors().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, vendors)
@@ -26,32 +27,29 @@ func CreateVendor(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	if result := db.Create(&vendor); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	if err := repository.NewGormStore(db).Vendors().Create(&vendor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, vendor)
 }
 // Delete a vendor
 func DeleteVendor(c *gin.Context, db *gorm.DB) {
-	vendorID := c.Param("id")
-	if result := db.Delete(&models.Vendor{}, vendorID); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	if err := repository.NewGormStore(db).Vendors().Delete(idParam(c, "id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Vendor deleted successfully"})
 }
 // GetVendorByID retrieves the details of a vendor by their ID
 func GetVendorByID(c *gin.Context, db *gorm.DB) {
-    vendorID := c.Param("id") // Retrieve the vendor ID from the URL parameter
-    var vendor models.Vendor
-
     // Attempt to find the vendor in the database
-    if result := db.First(&vendor, vendorID); result.Error != nil {
-        if result.Error == gorm.ErrRecordNotFound {
+    vendor, err := repository.NewGormStore(db).Vendors().Get(idParam(c, "id"))
+    if err != nil {
+        if err == repository.ErrNotFound {
             c.JSON(http.StatusNotFound, gin.H{"error": "Vendor not found"})
         } else {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         }
         return
     }
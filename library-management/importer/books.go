@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"library-management/models"
+)
+
+// BookColumns are the columns of a catalog CSV file. Each row is one copy;
+// rows of the same book share its ISBN, or else its title, author, edition
+// and publisher. A copy without a serial number is given one on import.
+var BookColumns = []string{
+	"title", "subtitle", "author", "edition", "publisher", "publisher_year",
+	"isbn", "note", "serial_number", "rack_number", "vendor_id", "item_type",
+}
+
+// ReadBooksCSV reads a catalog CSV file with a header row into one record
+// per book. Rows that cannot be read become records with errors.
+func ReadBooksCSV(r io.Reader) ([]CatalogRecord, error) {
+	t, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range []string{"title", "author"} {
+		if !t.has(column) {
+			return nil, fmt.Errorf("the file has no %s column", column)
+		}
+	}
+
+	var records []CatalogRecord
+	byKey := map[bookKey]int{}
+	for i := range t.rows {
+		book, bookCopy, err := readBookRow(t, i)
+		if err != nil {
+			records = append(records, CatalogRecord{
+				Ref:    fmt.Sprintf("line %d", t.lines[i]),
+				Errors: []string{err.Error()},
+			})
+			continue
+		}
+
+		key := keyOf(book)
+		if index, ok := byKey[key]; ok {
+			records[index].Copies = append(records[index].Copies, bookCopy)
+			continue
+		}
+		byKey[key] = len(records)
+		records = append(records, CatalogRecord{
+			Ref:    fmt.Sprintf("line %d", t.lines[i]),
+			Book:   book,
+			Copies: []models.Copy{bookCopy},
+		})
+	}
+	return records, nil
+}
+
+func readBookRow(t *table, i int) (models.Book, models.Copy, error) {
+	book := models.Book{
+		Title:     t.get(i, "title"),
+		Subtitle:  t.get(i, "subtitle"),
+		Author:    t.get(i, "author"),
+		Publisher: t.get(i, "publisher"),
+		ISBN:      normalizeISBN(t.get(i, "isbn")),
+		Note:      t.get(i, "note"),
+	}
+	bookCopy := models.Copy{
+		SerialNumber: t.get(i, "serial_number"),
+		RackNumber:   t.get(i, "rack_number"),
+		ItemType:     strings.ToLower(t.get(i, "item_type")),
+	}
+	if book.Title == "" || book.Author == "" {
+		return book, bookCopy, fmt.Errorf("title and author are required")
+	}
+
+	var err error
+	if book.Edition, err = t.getInt(i, "edition", 1); err != nil {
+		return book, bookCopy, err
+	}
+	if book.PublisherYear, err = t.getInt(i, "publisher_year", 0); err != nil {
+		return book, bookCopy, err
+	}
+	vendorID, err := t.getInt(i, "vendor_id", 0)
+	if err != nil || vendorID < 0 {
+		return book, bookCopy, fmt.Errorf("vendor_id %q is not a vendor ID", t.get(i, "vendor_id"))
+	}
+	bookCopy.VendorID = uint(vendorID)
+
+	switch bookCopy.ItemType {
+	case "", models.ItemGeneral, models.ItemReference, models.ItemReserve:
+	default:
+		return book, bookCopy, fmt.Errorf("item_type %q is not general, reference or reserve", bookCopy.ItemType)
+	}
+	return book, bookCopy, nil
+}
+
+// BookRecord returns a copy and its book in the order of BookColumns
+func BookRecord(book models.Book, bookCopy models.Copy) []string {
+	return []string{
+		book.Title, book.Subtitle, book.Author, strconv.Itoa(book.Edition),
+		book.Publisher, strconv.Itoa(book.PublisherYear), book.ISBN, book.Note,
+		bookCopy.SerialNumber, bookCopy.RackNumber,
+		strconv.FormatUint(uint64(bookCopy.VendorID), 10), bookCopy.ItemType,
+	}
+}
+
+// bookKey identifies a title for matching rows to each other and to the
+// catalog: by ISBN when there is one, otherwise by title, author, edition
+// and publisher
+type bookKey struct {
+	isbn, title, author, publisher string
+	edition                        int
+}
+
+func keyOf(book models.Book) bookKey {
+	if book.ISBN != "" {
+		return bookKey{isbn: book.ISBN}
+	}
+	fold := func(s string) string { return strings.ToLower(strings.TrimSpace(s)) }
+	return bookKey{title: fold(book.Title), author: fold(book.Author), publisher: fold(book.Publisher), edition: book.Edition}
+}
+
+// normalizeISBN keeps the digits and check character of an ISBN, dropping
+// hyphens and qualifiers such as "(pbk.)"
+func normalizeISBN(raw string) string {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return ""
+	}
+	var isbn strings.Builder
+	for _, r := range fields[0] {
+		switch {
+		case r >= '0' && r <= '9':
+			isbn.WriteRune(r)
+		case r == 'x' || r == 'X':
+			isbn.WriteRune('X')
+		}
+	}
+	return isbn.String()
+}
@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadBooksCSV(t *testing.T) {
+	data := "\ufeffTitle,Author,Edition,ISBN,Serial Number,Rack Number,Item Type,Vendor ID\n" +
+		"The Go Programming Language,Donovan,1,978-0-13-419044-0,GO-1,R1,General,\n" +
+		"The Go Programming Language,Donovan,1,9780134190440,GO-2,R1,,\n" +
+		"\n" +
+		"  Dune , Herbert ,,,D-1,,reserve,3\n" +
+		"dune,herbert,,,D-2,,,\n" +
+		",No title,,,,,,\n" +
+		"Bad edition,Someone,first,,,,,\n" +
+		"Bad type,Someone,,,,,loanable,\n" +
+		"Bad vendor,Someone,,,,,,-1\n"
+
+	records, err := ReadBooksCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("read %d records, want 6: %+v", len(records), records)
+	}
+
+	golang := records[0]
+	if golang.Ref != "line 2" || golang.Book.ISBN != "9780134190440" || golang.Book.Edition != 1 || len(golang.Copies) != 2 {
+		t.Errorf("rows of the same ISBN were not grouped: %+v", golang)
+	}
+	if golang.Copies[0].ItemType != "general" || golang.Copies[1].SerialNumber != "GO-2" {
+		t.Errorf("copies = %+v", golang.Copies)
+	}
+
+	dune := records[1]
+	if dune.Ref != "line 5" || dune.Book.Title != "Dune" || len(dune.Copies) != 2 {
+		t.Errorf("rows of the same title and author were not grouped: %+v", dune)
+	}
+	if dune.Copies[0].VendorID != 3 || dune.Copies[0].ItemType != "reserve" {
+		t.Errorf("copy = %+v", dune.Copies[0])
+	}
+
+	for i, ref := range []string{"line 7", "line 8", "line 9", "line 10"} {
+		if r := records[2+i]; r.Ref != ref || len(r.Errors) == 0 {
+			t.Errorf("record %d = %+v, want an error for %s", 2+i, r, ref)
+		}
+	}
+}
+
+func TestReadBooksCSVNeedsTitleAndAuthor(t *testing.T) {
+	for _, data := range []string{"", "title,isbn\nGo,123\n", "author\nSomeone\n", "title,author\n\"unclosed\n"} {
+		if _, err := ReadBooksCSV(strings.NewReader(data)); err == nil {
+			t.Errorf("ReadBooksCSV(%q) succeeded", data)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	marc := isoRecord(nil, testField{"245", "10", "aTitle"})
+	tests := []struct {
+		filename, data, want string
+	}{
+		{"books.CSV", "title,author", "csv"},
+		{"export.mrc", "", "marc"},
+		{"export.xml", "", "marcxml"},
+		{"upload", "  <?xml version=\"1.0\"?><collection/>", "marcxml"},
+		{"upload", marc, "marc"},
+	}
+	for _, tt := range tests {
+		if got, err := DetectFormat(tt.filename, []byte(tt.data)); err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%q) = %q, %v, want %q", tt.filename, got, err, tt.want)
+		}
+	}
+	if _, err := DetectFormat("upload", []byte("title,author")); err == nil {
+		t.Error("an unnamed CSV file was given a format")
+	}
+}
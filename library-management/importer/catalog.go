@@ -0,0 +1,381 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/repository"
+)
+
+// CatalogRecord is a book and its copies read from a catalog file
+type CatalogRecord struct {
+	Ref    string // Where the record is in the file, for the error report
+	Book   models.Book
+	Copies []models.Copy
+	Errors []string // Problems reading the record; it is not imported
+}
+
+// CatalogOptions fill in what a catalog file leaves out
+type CatalogOptions struct {
+	SerialPrefix string // Prefix of generated serial numbers
+	RackNumber   string
+	VendorID     uint
+	ItemType     string
+}
+
+// DefaultSerialPrefix starts the serial numbers given to imported copies
+// that have none
+const DefaultSerialPrefix = "IMP-"
+
+// progressEvery is how many records are imported between progress updates
+const progressEvery = 25
+
+// heartbeatEvery is how often a running import reports that its server is
+// still working on it
+const heartbeatEvery = 30 * time.Second
+
+// StaleImportAfter is how long an unfinished import can go without a
+// heartbeat before it counts as interrupted
+const StaleImportAfter = 5 * time.Minute
+
+// errImportAbandoned stops an import that was marked failed while it ran,
+// for instance by a server that took it for interrupted
+var errImportAbandoned = errors.New("the import was marked failed while it ran")
+
+// DetectFormat works out a catalog file's format from its name, or from
+// its first bytes when the name does not tell
+func DetectFormat(filename string, data []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return models.FormatCSV, nil
+	case ".mrc", ".marc", ".iso", ".dat":
+		return models.FormatMARC, nil
+	case ".xml", ".marcxml":
+		return models.FormatMARCXML, nil
+	}
+
+	start := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(start, []byte("<")):
+		return models.FormatMARCXML, nil
+	case len(start) >= marcLeaderSize && isDigits(start[:5]) && bytes.IndexByte(start, marcRecordEnd) > 0:
+		return models.FormatMARC, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %q; use a .csv, .mrc or .xml file", filename)
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadCatalog reads a catalog file in the given format
+func ReadCatalog(format string, data []byte) ([]CatalogRecord, error) {
+	switch format {
+	case models.FormatCSV:
+		return ReadBooksCSV(bytes.NewReader(data))
+	case models.FormatMARC:
+		return ReadMARC(bytes.NewReader(data))
+	case models.FormatMARCXML:
+		return ReadMARCXML(bytes.NewReader(data))
+	}
+	return nil, fmt.Errorf("unknown catalog format %q", format)
+}
+
+// NewCatalogJob records a queued import of a catalog file
+func NewCatalogJob(db *gorm.DB, filename, format string, userID *uint) (*models.ImportJob, error) {
+	now := time.Now()
+	job := &models.ImportJob{
+		Filename:    filename,
+		Format:      format,
+		Status:      models.ImportQueued,
+		UserID:      userID,
+		HeartbeatAt: &now,
+	}
+	return job, db.Create(job).Error
+}
+
+// RunCatalogImport reads the file and imports its records, keeping the
+// job's progress and heartbeat up to date. Each record is imported in its
+// own transaction; records that fail are listed as import errors and the
+// rest carry on. It is meant to run in the background, so failures are
+// stored on the job rather than returned. A job that is marked failed
+// meanwhile is left as it is.
+func RunCatalogImport(db *gorm.DB, job *models.ImportJob, data []byte, opts CatalogOptions) {
+	started := time.Now()
+	job.Status = models.JobRunning
+	job.StartedAt = &started
+	job.HeartbeatAt = &started
+	result := db.Model(job).Where("status = ?", models.ImportQueued).Select("status", "started_at", "heartbeat_at").Updates(job)
+	if result.Error != nil {
+		log.Printf("Import %d: %v", job.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("Import %d is no longer queued", job.ID)
+		return
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		keepAlive(db, job.ID, stop)
+	}()
+	err := importCatalogSafely(db, job, data, opts)
+	close(stop)
+	<-stopped
+
+	if errors.Is(err, errImportAbandoned) {
+		log.Printf("Import %d was marked failed while it ran; stopping", job.ID)
+		return
+	}
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Status = models.JobSucceeded
+	job.Message = fmt.Sprintf("%d of %d records imported", job.Processed-job.Failed, job.Total)
+	if err != nil {
+		job.Status = models.JobFailed
+		job.Message = err.Error()
+		log.Printf("Import %d failed: %v", job.ID, err)
+	}
+	if err := saveProgress(db, job, "status", "message", "finished_at"); err != nil {
+		log.Printf("Import %d: error saving the outcome: %v", job.ID, err)
+	}
+}
+
+// saveProgress stores the job's counts, heartbeat and the other columns
+// given, as long as the job is still running
+func saveProgress(db *gorm.DB, job *models.ImportJob, columns ...string) error {
+	now := time.Now()
+	job.HeartbeatAt = &now
+	columns = append(columns, "total", "processed", "books", "copies", "skipped", "failed", "heartbeat_at")
+	result := db.Model(job).Where("status = ?", models.JobRunning).Select(columns).Updates(job)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errImportAbandoned
+	}
+	return nil
+}
+
+// keepAlive updates the job's heartbeat until stop is closed, so that an
+// import held up by slow records is not taken for interrupted
+func keepAlive(db *gorm.DB, jobID uint, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			err := db.Model(&models.ImportJob{}).
+				Where("id = ? AND status = ?", jobID, models.JobRunning).
+				Update("heartbeat_at", now).Error
+			if err != nil {
+				log.Printf("Import %d: error updating the heartbeat: %v", jobID, err)
+			}
+		}
+	}
+}
+
+// importCatalogSafely imports the catalog, turning a panic into an error
+// so the job is still marked failed
+func importCatalogSafely(db *gorm.DB, job *models.ImportJob, data []byte, opts CatalogOptions) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Import %d panicked: %v\n%s", job.ID, r, debug.Stack())
+			err = fmt.Errorf("the import stopped unexpectedly: %v", r)
+		}
+	}()
+	return importCatalog(db, job, data, opts)
+}
+
+// FailInterruptedImports marks queued or running imports as failed once
+// they have gone StaleImportAfter without a heartbeat, since the server
+// that ran them has stopped and nothing will finish them. Imports other
+// servers are still running are left alone.
+func FailInterruptedImports(db *gorm.DB) (int64, error) {
+	now := time.Now()
+	result := db.Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportQueued, models.JobRunning}).
+		Where("COALESCE(heartbeat_at, started_at, created_at) < ?", now.Add(-StaleImportAfter)).
+		Updates(map[string]interface{}{
+			"status":      models.JobFailed,
+			"message":     "The import was interrupted when its server stopped",
+			"finished_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func importCatalog(db *gorm.DB, job *models.ImportJob, data []byte, opts CatalogOptions) error {
+	records, err := ReadCatalog(job.Format, data)
+	if err != nil {
+		return err
+	}
+	job.Total = len(records)
+	if err := saveProgress(db, job); err != nil {
+		return err
+	}
+
+	serials := &serialGenerator{prefix: opts.SerialPrefix}
+	if serials.prefix == "" {
+		serials.prefix = DefaultSerialPrefix
+	}
+	for i, record := range records {
+		problems := record.Errors
+		if len(problems) == 0 {
+			if err := importRecord(db, job, record, opts, serials); err != nil {
+				problems = []string{err.Error()}
+			}
+		}
+		if len(problems) > 0 {
+			job.Failed++
+			failure := models.ImportError{ImportJobID: job.ID, Record: record.Ref, Message: strings.Join(problems, "; ")}
+			if err := db.Create(&failure).Error; err != nil {
+				return err
+			}
+		}
+
+		job.Processed = i + 1
+		if job.Processed%progressEvery == 0 {
+			if err := saveProgress(db, job); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// importRecord stores a record's book, unless the catalog already has it,
+// and the copies whose serial numbers are not yet in use. Copies without
+// serial numbers are only added with a new book, so importing the same
+// file twice does not add them twice.
+func importRecord(db *gorm.DB, job *models.ImportJob, record CatalogRecord, opts CatalogOptions, serials *serialGenerator) error {
+	var books, copies, skipped int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		store := repository.NewGormStore(tx).Books()
+		book, err := findBook(tx, keyOf(record.Book))
+		if err != nil {
+			return err
+		}
+
+		var newCopies []models.Copy
+		seen := map[string]bool{}
+		for _, bookCopy := range record.Copies {
+			if seen[bookCopy.SerialNumber] {
+				skipped++
+				continue
+			}
+			if bookCopy.SerialNumber == "" && book != nil {
+				skipped++
+				continue
+			}
+			if bookCopy.SerialNumber == "" {
+				serial, err := serials.next(store)
+				if err != nil {
+					return err
+				}
+				bookCopy.SerialNumber = serial
+			} else if _, err := store.GetCopyBySerial(bookCopy.SerialNumber); err == nil {
+				skipped++
+				continue
+			} else if err != repository.ErrNotFound {
+				return err
+			}
+
+			if bookCopy.RackNumber == "" {
+				bookCopy.RackNumber = opts.RackNumber
+			}
+			if bookCopy.VendorID == 0 {
+				bookCopy.VendorID = opts.VendorID
+			}
+			if bookCopy.ItemType == "" {
+				bookCopy.ItemType = opts.ItemType
+			}
+			if bookCopy.ItemType == "" {
+				bookCopy.ItemType = models.ItemGeneral
+			}
+			bookCopy.Status = models.CopyAvailable
+			seen[bookCopy.SerialNumber] = true
+			newCopies = append(newCopies, bookCopy)
+		}
+		if len(newCopies) == 0 {
+			return nil
+		}
+
+		if book == nil {
+			book = &record.Book
+			book.Copies = nil
+			if err := store.Create(book); err != nil {
+				return err
+			}
+			books++
+		}
+		for i := range newCopies {
+			newCopies[i].BookID = book.ID
+		}
+		if err := store.AddCopies(newCopies); err != nil {
+			return err
+		}
+		copies += len(newCopies)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	job.Books += books
+	job.Copies += copies
+	job.Skipped += skipped
+	return nil
+}
+
+// findBook returns the catalog's book for a key, or nil if there is none
+func findBook(db *gorm.DB, key bookKey) (*models.Book, error) {
+	query := db.Order("id").Limit(1)
+	if key.isbn != "" {
+		query = query.Where("isbn = ?", key.isbn)
+	} else {
+		query = query.Where("LOWER(TRIM(title)) = ? AND LOWER(TRIM(author)) = ? AND LOWER(TRIM(COALESCE(publisher, ''))) = ? AND edition = ?",
+			key.title, key.author, key.publisher, key.edition)
+	}
+	var books []models.Book
+	if err := query.Find(&books).Error; err != nil || len(books) == 0 {
+		return nil, err
+	}
+	return &books[0], nil
+}
+
+// serialGenerator hands out serial numbers such as IMP-000042 that are not
+// yet in use
+type serialGenerator struct {
+	prefix string
+	last   int
+}
+
+func (g *serialGenerator) next(books repository.BookRepository) (string, error) {
+	for {
+		g.last++
+		serial := fmt.Sprintf("%s%06d", g.prefix, g.last)
+		_, err := books.GetCopyBySerial(serial)
+		if err == repository.ErrNotFound {
+			return serial, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestRunCatalogImport(t *testing.T) {
+	db := databasetest.Open(t)
+	data := []byte("title,author,isbn,serial_number\n" +
+		"Go,Donovan,9780134190440,GO-1\n" +
+		"Go,Donovan,9780134190440,\n" +
+		"Dune,Herbert,,\n" +
+		",Missing title,,\n")
+
+	job, err := NewCatalogJob(db, "books.csv", models.FormatCSV, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RunCatalogImport(db, job, data, CatalogOptions{SerialPrefix: "T-", RackNumber: "R9"})
+
+	var stored models.ImportJob
+	db.First(&stored, job.ID)
+	if stored.Status != models.JobSucceeded || stored.Total != 3 || stored.Processed != 3 ||
+		stored.Books != 2 || stored.Copies != 3 || stored.Failed != 1 || stored.FinishedAt == nil {
+		t.Errorf("job = %+v", stored)
+	}
+	var copies []models.Copy
+	db.Order("serial_number").Find(&copies)
+	serials := []string{}
+	for _, c := range copies {
+		serials = append(serials, c.SerialNumber)
+		if c.RackNumber != "R9" || c.Status != models.CopyAvailable {
+			t.Errorf("copy = %+v", c)
+		}
+	}
+	if len(serials) != 3 || serials[0] != "GO-1" || serials[1] != "T-000001" || serials[2] != "T-000002" {
+		t.Errorf("serial numbers = %v", serials)
+	}
+
+	// Importing the file again adds nothing
+	again, _ := NewCatalogJob(db, "books.csv", models.FormatCSV, nil)
+	RunCatalogImport(db, again, data, CatalogOptions{SerialPrefix: "T-"})
+	if again.Books != 0 || again.Copies != 0 || again.Skipped != 3 {
+		t.Errorf("second import = %+v", again)
+	}
+}
+
+func TestRunCatalogImportFailsUnreadableFiles(t *testing.T) {
+	db := databasetest.Open(t)
+	job, _ := NewCatalogJob(db, "books.csv", models.FormatCSV, nil)
+	RunCatalogImport(db, job, []byte("isbn\n123\n"), CatalogOptions{})
+
+	var stored models.ImportJob
+	db.First(&stored, job.ID)
+	if stored.Status != models.JobFailed || stored.Message == "" {
+		t.Errorf("job = %+v", stored)
+	}
+}
+
+func TestRunCatalogImportRecoversFromPanics(t *testing.T) {
+	db := databasetest.Open(t)
+	job, _ := NewCatalogJob(db, "books.mrc", models.FormatMARC, nil)
+
+	// Stop the import partway with a panic
+	db.Callback().Create().Before("gorm:create").Register("test:panic", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*models.Book); ok {
+			panic("boom")
+		}
+	})
+	data := isoRecord(nil, testField{"100", "1 ", "aAuthor"}, testField{"245", "10", "aTitle"})
+	RunCatalogImport(db, job, []byte(data), CatalogOptions{})
+
+	var stored models.ImportJob
+	db.First(&stored, job.ID)
+	if stored.Status != models.JobFailed || stored.FinishedAt == nil {
+		t.Errorf("job = %+v", stored)
+	}
+}
+
+func TestFailInterruptedImports(t *testing.T) {
+	db := databasetest.Open(t)
+	now := time.Now()
+	stale := now.Add(-StaleImportAfter - time.Minute)
+	jobs := []models.ImportJob{
+		{Filename: "stale queued", Status: models.ImportQueued, HeartbeatAt: &stale},
+		{Filename: "stale running", Status: models.JobRunning, HeartbeatAt: &stale},
+		{Filename: "running elsewhere", Status: models.JobRunning, HeartbeatAt: &now},
+		{Filename: "just queued", Status: models.ImportQueued, HeartbeatAt: &now},
+		{Filename: "old succeeded", Status: models.JobSucceeded, HeartbeatAt: &stale},
+		{Filename: "old failed", Status: models.JobFailed, HeartbeatAt: &stale},
+	}
+	for i := range jobs {
+		jobs[i].Format = models.FormatCSV
+		if err := db.Create(&jobs[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := FailInterruptedImports(db)
+	if err != nil || failed != 2 {
+		t.Fatalf("FailInterruptedImports = %d, %v, want 2", failed, err)
+	}
+	for i, job := range jobs {
+		want := job.Status
+		if i < 2 {
+			want = models.JobFailed
+		}
+		var stored models.ImportJob
+		db.First(&stored, job.ID)
+		if stored.Status != want {
+			t.Errorf("%s import is now %s, want %s", job.Filename, stored.Status, want)
+		}
+		if i < 2 && (stored.FinishedAt == nil || stored.Message == "") {
+			t.Errorf("interrupted job = %+v", stored)
+		}
+	}
+}
+
+func TestRunCatalogImportLeavesFailedJobs(t *testing.T) {
+	db := databasetest.Open(t)
+	data := "title,author\n"
+	for i := 0; i < 3*progressEvery; i++ {
+		data += fmt.Sprintf("Book %d,Author\n", i)
+	}
+
+	// Another server fails the job as interrupted partway through. The
+	// progress saved before then is kept.
+	job, _ := NewCatalogJob(db, "books.csv", models.FormatCSV, nil)
+	db.Callback().Create().After("gorm:create").Register("test:fail_job", func(tx *gorm.DB) {
+		if book, ok := tx.Statement.Dest.(*models.Book); ok && book.Title == "Book 30" {
+			tx.Session(&gorm.Session{NewDB: true}).Model(&models.ImportJob{}).Where("id = ?", job.ID).
+				Updates(map[string]interface{}{"status": models.JobFailed, "message": "interrupted"})
+		}
+	})
+	RunCatalogImport(db, job, []byte(data), CatalogOptions{})
+
+	var stored models.ImportJob
+	db.First(&stored, job.ID)
+	if stored.Status != models.JobFailed || stored.Message != "interrupted" || stored.Processed != progressEvery {
+		t.Errorf("job = %+v", stored)
+	}
+	var books int64
+	db.Model(&models.Book{}).Count(&books)
+	if books >= 3*progressEvery {
+		t.Errorf("the import carried on after it was failed: %d books", books)
+	}
+
+	// A job failed before it starts is not run
+	queued, _ := NewCatalogJob(db, "books.csv", models.FormatCSV, nil)
+	db.Model(queued).Update("status", models.JobFailed)
+	RunCatalogImport(db, queued, []byte(data), CatalogOptions{})
+	var notRun models.ImportJob
+	db.First(&notRun, queued.ID)
+	if notRun.Status != models.JobFailed || notRun.StartedAt != nil {
+		t.Errorf("job failed while queued = %+v", notRun)
+	}
+}
@@ -0,0 +1,108 @@
+// Package importer reads students and catalog records from files and
+// stores them in bulk.
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// table is a file read into rows with named columns
+type table struct {
+	columns map[string]int
+	rows    [][]string
+	lines   []int // File line of each row
+}
+
+// readCSV reads a CSV file whose first row names the columns
+func readCSV(r io.Reader) (*table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return newTable(records, lines)
+}
+
+// newTable makes a table of records whose first one names the columns.
+// Column names are matched without regard to case, surrounding spaces or
+// spaces versus underscores. Blank rows are dropped.
+func newTable(records [][]string, lines []int) (*table, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+
+	t := &table{columns: map[string]int{}}
+	for i, name := range records[0] {
+		if key := columnKey(name); key != "" {
+			t.columns[key] = i
+		}
+	}
+	for i, record := range records[1:] {
+		if isBlank(record) {
+			continue
+		}
+		t.rows = append(t.rows, record)
+		t.lines = append(t.lines, lines[i+1])
+	}
+	return t, nil
+}
+
+func columnKey(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff") // Excel writes a byte order mark
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// has reports whether the file has the column
+func (t *table) has(column string) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+// get returns the trimmed value of a column in row i, or "" if the file
+// does not have the column
+func (t *table) get(i int, column string) string {
+	index, ok := t.columns[column]
+	if !ok || index >= len(t.rows[i]) {
+		return ""
+	}
+	return strings.TrimSpace(t.rows[i][index])
+}
+
+// getInt reads a whole number column, using fallback when it is empty
+func (t *table) getInt(i int, column string, fallback int) (int, error) {
+	value := t.get(i, column)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q is not a whole number", column, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,328 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"library-management/models"
+)
+
+// MARC21 delimiters in the ISO 2709 transmission format
+const (
+	marcSubfield     = 0x1F
+	marcRecordEnd    = 0x1D
+	marcLeaderSize   = 24
+	marcDirEntrySize = 12
+)
+
+// marcRecord is a MARC21 bibliographic record
+type marcRecord struct {
+	leader  string
+	control map[string]string // 001-009
+	fields  []marcField
+}
+
+type marcField struct {
+	tag        string
+	ind1, ind2 byte
+	subfields  []marcSubfieldValue
+}
+
+type marcSubfieldValue struct {
+	code  byte
+	value string
+}
+
+// first returns the first subfield with the code in the first field with
+// the tag
+func (r marcRecord) first(tag string, code byte) string {
+	for _, f := range r.fields {
+		if f.tag == tag {
+			return f.get(code)
+		}
+	}
+	return ""
+}
+
+func (f marcField) get(code byte) string {
+	for _, sf := range f.subfields {
+		if sf.code == code {
+			return sf.value
+		}
+	}
+	return ""
+}
+
+// ReadMARC reads MARC21 records in the ISO 2709 transmission format
+func ReadMARC(r io.Reader) ([]CatalogRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []CatalogRecord
+	for n := 1; len(bytes.TrimSpace(data)) > 0; n++ {
+		// Records end with a terminator; the length in the leader is only
+		// used to check it
+		end := bytes.IndexByte(data, marcRecordEnd)
+		if end < 0 {
+			end = len(data) - 1
+		}
+		raw := bytes.TrimLeft(data[:end+1], "\r\n ")
+		data = data[end+1:]
+
+		record, err := parseISO2709(raw)
+		if err != nil {
+			records = append(records, CatalogRecord{Ref: fmt.Sprintf("record %d", n), Errors: []string{err.Error()}})
+			continue
+		}
+		records = append(records, catalogRecordFromMARC(n, record))
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file has no MARC records")
+	}
+	return records, nil
+}
+
+func parseISO2709(raw []byte) (marcRecord, error) {
+	if len(raw) < marcLeaderSize {
+		return marcRecord{}, fmt.Errorf("the record is too short")
+	}
+	leader := string(raw[:marcLeaderSize])
+	length, err1 := strconv.Atoi(leader[0:5])
+	base, err2 := strconv.Atoi(leader[12:17])
+	if err1 != nil || err2 != nil || base <= marcLeaderSize || base > len(raw) {
+		return marcRecord{}, fmt.Errorf("the record has an invalid leader %q", leader)
+	}
+	if length != len(raw) {
+		return marcRecord{}, fmt.Errorf("the leader gives a length of %d but the record is %d bytes", length, len(raw))
+	}
+
+	// Fields are in MARC-8 unless leader position 9 says UTF-8; plain ASCII
+	// reads the same either way
+	if leader[9] != 'a' && !isASCII(raw) {
+		return marcRecord{}, fmt.Errorf("the record is MARC-8 encoded; export the file as UTF-8")
+	}
+	if !utf8.Valid(raw) {
+		return marcRecord{}, fmt.Errorf("the record is not valid UTF-8")
+	}
+
+	record := marcRecord{leader: leader, control: map[string]string{}}
+	directory := raw[marcLeaderSize : base-1]
+	for len(directory) >= marcDirEntrySize {
+		entry := directory[:marcDirEntrySize]
+		directory = directory[marcDirEntrySize:]
+
+		tag := string(entry[0:3])
+		size, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || base+start+size > len(raw) || size < 1 {
+			return marcRecord{}, fmt.Errorf("field %s has an invalid directory entry", tag)
+		}
+		body := raw[base+start : base+start+size-1] // Without the field terminator
+		if strings.HasPrefix(tag, "00") {
+			record.control[tag] = string(body)
+			continue
+		}
+
+		field := marcField{tag: tag}
+		if len(body) >= 2 {
+			field.ind1, field.ind2 = body[0], body[1]
+			body = body[2:]
+		}
+		for _, part := range bytes.Split(body, []byte{marcSubfield})[1:] {
+			if len(part) > 0 {
+				field.subfields = append(field.subfields, marcSubfieldValue{code: part[0], value: string(part[1:])})
+			}
+		}
+		record.fields = append(record.fields, field)
+	}
+	return record, nil
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadMARCXML reads MARC21 records from a MARCXML collection or a single
+// record document
+func ReadMARCXML(r io.Reader) ([]CatalogRecord, error) {
+	type xmlSubfield struct {
+		Code  string `xml:"code,attr"`
+		Value string `xml:",chardata"`
+	}
+	type xmlRecord struct {
+		Leader        string `xml:"leader"`
+		ControlFields []struct {
+			Tag   string `xml:"tag,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"controlfield"`
+		DataFields []struct {
+			Tag       string        `xml:"tag,attr"`
+			Ind1      string        `xml:"ind1,attr"`
+			Ind2      string        `xml:"ind2,attr"`
+			Subfields []xmlSubfield `xml:"subfield"`
+		} `xml:"datafield"`
+	}
+
+	var records []CatalogRecord
+	decoder := xml.NewDecoder(r)
+	for n := 1; ; {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid MARCXML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var x xmlRecord
+		if err := decoder.DecodeElement(&x, &start); err != nil {
+			return nil, fmt.Errorf("invalid MARCXML in record %d: %w", n, err)
+		}
+		record := marcRecord{leader: x.Leader, control: map[string]string{}}
+		for _, cf := range x.ControlFields {
+			record.control[cf.Tag] = cf.Value
+		}
+		for _, df := range x.DataFields {
+			field := marcField{tag: df.Tag, ind1: indicator(df.Ind1), ind2: indicator(df.Ind2)}
+			for _, sf := range df.Subfields {
+				if sf.Code != "" {
+					field.subfields = append(field.subfields, marcSubfieldValue{code: sf.Code[0], value: sf.Value})
+				}
+			}
+			record.fields = append(record.fields, field)
+		}
+		records = append(records, catalogRecordFromMARC(n, record))
+		n++
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file has no MARCXML records")
+	}
+	return records, nil
+}
+
+func indicator(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
+
+var (
+	firstNumber = regexp.MustCompile(`\d+`)
+	firstYear   = regexp.MustCompile(`\d{4}`)
+)
+
+// ordinals reads editions written out in words, as in "Second edition"
+var ordinals = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+	"sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+}
+
+// catalogRecordFromMARC maps a bibliographic record onto a book:
+//
+//	245 $a title, $b subtitle
+//	100 $a author (110, 111, 700 or 245 $c when there is no 100)
+//	250 $a edition
+//	260 $b publisher, $c year (264 for RDA records)
+//	020 $a ISBN
+//
+// Koha item fields (952 $p barcode, $o call number) become copies with
+// those serial and rack numbers; records without them get one copy.
+func catalogRecordFromMARC(n int, r marcRecord) CatalogRecord {
+	record := CatalogRecord{Ref: fmt.Sprintf("record %d", n)}
+	if id := strings.TrimSpace(r.control["001"]); id != "" {
+		record.Ref += " (001 " + id + ")"
+	}
+
+	book := models.Book{
+		Title:    trimMARC(r.first("245", 'a')),
+		Subtitle: trimMARC(r.first("245", 'b')),
+		ISBN:     normalizeISBN(r.first("020", 'a')),
+		Edition:  1,
+	}
+	for _, tag := range []string{"100", "110", "111", "700"} {
+		if book.Author = trimMARC(r.first(tag, 'a')); book.Author != "" {
+			break
+		}
+	}
+	if book.Author == "" {
+		book.Author = trimMARC(r.first("245", 'c'))
+	}
+
+	// Blank editions, or ones of only spaces, keep the default
+	if words := strings.Fields(strings.ToLower(r.first("250", 'a'))); len(words) > 0 {
+		if number := firstNumber.FindString(strings.Join(words, " ")); number != "" {
+			book.Edition, _ = strconv.Atoi(number)
+		} else if word, ok := ordinals[words[0]]; ok {
+			book.Edition = word
+		}
+	}
+
+	publisher, date := r.first("260", 'b'), r.first("260", 'c')
+	if publisher == "" && date == "" {
+		for _, f := range r.fields {
+			if f.tag == "264" && f.ind2 == '1' {
+				publisher, date = f.get('b'), f.get('c')
+				break
+			}
+		}
+	}
+	book.Publisher = trimMARC(publisher)
+	year := firstYear.FindString(date)
+	if year == "" && len(r.control["008"]) >= 11 {
+		year = r.control["008"][7:11] // Date 1 of the fixed fields
+	}
+	book.PublisherYear, _ = strconv.Atoi(year)
+
+	if book.Title == "" {
+		record.Errors = append(record.Errors, "no title (245 $a)")
+	}
+	if book.Author == "" {
+		record.Errors = append(record.Errors, "no author (100 $a)")
+	}
+	record.Book = book
+
+	for _, f := range r.fields {
+		if f.tag == "952" {
+			record.Copies = append(record.Copies, models.Copy{
+				SerialNumber: strings.TrimSpace(f.get('p')),
+				RackNumber:   strings.TrimSpace(f.get('o')),
+			})
+		}
+	}
+	if len(record.Copies) == 0 {
+		record.Copies = []models.Copy{{}}
+	}
+	return record
+}
+
+// trimMARC removes the ISBD punctuation that MARC fields end with, as in
+// "Go programming /" or "Addison-Wesley,". A final period is kept after
+// an initial, as in "Kernighan, Brian W."
+func trimMARC(s string) string {
+	s = strings.TrimRight(strings.TrimSpace(s), " /:;,=")
+	if strings.HasSuffix(s, ".") {
+		words := strings.Fields(s)
+		if last := words[len(words)-1]; utf8.RuneCountInString(last) > 2 {
+			s = strings.TrimSuffix(s, ".")
+		}
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testField is a data field for isoRecord, e.g. {"245", "10", "aGo /"},
+// where subfields are separated by '|'
+type testField struct {
+	tag, indicators, subfields string
+}
+
+// isoRecord encodes fields as an ISO 2709 record
+func isoRecord(control map[string]string, fields ...testField) string {
+	var directory, body strings.Builder
+	add := func(tag, data string) {
+		data += "\x1e"
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(data), body.Len())
+		body.WriteString(data)
+	}
+	for _, tag := range []string{"001", "008"} {
+		if value, ok := control[tag]; ok {
+			add(tag, value)
+		}
+	}
+	for _, f := range fields {
+		add(f.tag, f.indicators+"\x1f"+strings.ReplaceAll(f.subfields, "|", "\x1f"))
+	}
+	base := marcLeaderSize + directory.Len() + 1
+	length := base + body.Len() + 1
+	return fmt.Sprintf("%05dnam a22%05d   4500", length, base) + directory.String() + "\x1e" + body.String() + "\x1d"
+}
+
+func TestReadMARC(t *testing.T) {
+	data := isoRecord(map[string]string{"001": "ocm123", "008": "850101s1978    nyu"},
+		testField{"020", "  ", "a0131103628 (pbk.)"},
+		testField{"100", "1 ", "aKernighan, Brian W."},
+		testField{"245", "14", "aThe C programming language /|cBrian W. Kernighan."},
+		testField{"250", "  ", "aSecond edition."},
+		testField{"260", "  ", "bPrentice Hall,"},
+		testField{"952", "  ", "pB-001|oQA76.73"},
+		testField{"952", "  ", "pB-002"},
+	) + "\n" + isoRecord(nil,
+		testField{"245", "00", "aAnonymous works :|bvolume 2"},
+		testField{"700", "1 ", "aEditor, Some,"},
+		testField{"250", "  ", "a   "},
+		testField{"264", " 1", "bSelf,|c[2021]"},
+	)
+
+	records, err := ReadMARC(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("read %d records, want 2", len(records))
+	}
+
+	first := records[0]
+	if len(first.Errors) > 0 {
+		t.Fatalf("record 1 errors: %v", first.Errors)
+	}
+	book := first.Book
+	if book.Title != "The C programming language" || book.Author != "Kernighan, Brian W." ||
+		book.Edition != 2 || book.Publisher != "Prentice Hall" || book.PublisherYear != 1978 || book.ISBN != "0131103628" {
+		t.Errorf("record 1 book = %+v", book)
+	}
+	if first.Ref != "record 1 (001 ocm123)" {
+		t.Errorf("record 1 ref = %q", first.Ref)
+	}
+	if len(first.Copies) != 2 || first.Copies[0].SerialNumber != "B-001" || first.Copies[0].RackNumber != "QA76.73" {
+		t.Errorf("record 1 copies = %+v", first.Copies)
+	}
+
+	second := records[1].Book
+	if second.Title != "Anonymous works" || second.Subtitle != "volume 2" || second.Author != "Editor, Some" ||
+		second.Edition != 1 || second.Publisher != "Self" || second.PublisherYear != 2021 {
+		t.Errorf("record 2 book = %+v", second)
+	}
+	if len(records[1].Copies) != 1 || records[1].Copies[0].SerialNumber != "" {
+		t.Errorf("record 2 copies = %+v", records[1].Copies)
+	}
+}
+
+func TestReadMARCEditions(t *testing.T) {
+	for edition, want := range map[string]int{
+		"2nd ed.":       2,
+		"Rev. 3rd ed.":  3,
+		"Third edition": 3,
+		"":              1,
+		"   ":           1,
+		"\t\n":          1,
+		"Revised":       1,
+	} {
+		data := isoRecord(nil,
+			testField{"100", "1 ", "aAuthor"},
+			testField{"245", "10", "aTitle"},
+			testField{"250", "  ", "a" + edition},
+		)
+		records, err := ReadMARC(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("edition %q: %v", edition, err)
+		}
+		if got := records[0].Book.Edition; got != want {
+			t.Errorf("edition %q read as %d, want %d", edition, got, want)
+		}
+	}
+}
+
+func TestReadMARCReportsBadRecords(t *testing.T) {
+	good := isoRecord(nil, testField{"100", "1 ", "aAuthor"}, testField{"245", "10", "aTitle"})
+	tests := map[string]string{
+		"short":          "00010nam\x1d",
+		"bad leader":     "abcdenam a2200000   4500\x1e\x1d",
+		"wrong length":   "99999" + good[5:],
+		"bad directory":  good[:24] + "245999900000" + good[36:],
+		"no title":       isoRecord(nil, testField{"100", "1 ", "aAuthor"}),
+		"MARC-8 encoded": strings.Replace(isoRecord(nil, testField{"100", "1 ", "aAuth\xe8or"}, testField{"245", "10", "aTitle"}), "nam a22", "nam  22", 1),
+	}
+	for name, data := range tests {
+		records, err := ReadMARC(strings.NewReader(data + good))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(records) != 2 || len(records[0].Errors) == 0 || len(records[1].Errors) != 0 {
+			t.Errorf("%s: records = %+v", name, records)
+		}
+	}
+
+	if _, err := ReadMARC(strings.NewReader(" \n")); err == nil {
+		t.Error("an empty file was accepted")
+	}
+}
+
+func TestReadMARCXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a2200000 a 4500</leader>
+    <controlfield tag="001">42</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">978-0-13-419044-0</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Donovan, Alan A. A.,</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="4"><subfield code="a">The Go programming language /</subfield></datafield>
+    <datafield tag="250" ind1=" " ind2=" "><subfield code="a"> </subfield></datafield>
+    <datafield tag="260" ind1=" " ind2=" "><subfield code="b">Addison-Wesley,</subfield><subfield code="c">c2016.</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="245" ind1="0" ind2="0"><subfield code="a">No author</subfield></datafield>
+  </record>
+</collection>`
+	records, err := ReadMARCXML(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("read %d records, want 2", len(records))
+	}
+	book := records[0].Book
+	if book.Title != "The Go programming language" || book.Author != "Donovan, Alan A. A." || book.ISBN != "9780134190440" ||
+		book.Edition != 1 || book.Publisher != "Addison-Wesley" || book.PublisherYear != 2016 {
+		t.Errorf("record 1 book = %+v", book)
+	}
+	if len(records[1].Errors) == 0 {
+		t.Error("a record without an author was accepted")
+	}
+
+	if _, err := ReadMARCXML(strings.NewReader("<collection><record>")); err == nil {
+		t.Error("truncated MARCXML was accepted")
+	}
+}
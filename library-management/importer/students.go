@@ -0,0 +1,236 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/models"
+	"library-management/repository"
+	"library-management/utils"
+)
+
+// StudentColumns are the columns of a student file, named after the
+// student's JSON fields. Only usn is required.
+var StudentColumns = []string{
+	"usn", "name", "email", "phone", "address", "admission_year",
+	"department", "category", "notify_channels", "language", "remark",
+}
+
+// StudentRow is a student read from a file, the line it was on and any
+// values that could not be read
+type StudentRow struct {
+	Line    int
+	Student models.Student
+	Errors  []string
+}
+
+// RowError lists the problems with one row of a file
+type RowError struct {
+	Line   int      `json:"line"`
+	USN    string   `json:"usn,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// StudentResult counts what an import did, or would do in a dry run
+type StudentResult struct {
+	DryRun  bool       `json:"dry_run"`
+	Rows    int        `json:"rows"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"` // Existing students the row would not change
+	Errors  []RowError `json:"errors,omitempty"`
+}
+
+// ErrInvalidRows is returned when rows fail validation, in which case
+// nothing is imported
+var ErrInvalidRows = errors.New("some rows are invalid")
+
+// errDryRun rolls back a dry run's transaction
+var errDryRun = errors.New("dry run")
+
+// ReadStudentsCSV reads one student per row of a CSV file with a header row
+func ReadStudentsCSV(r io.Reader) ([]StudentRow, error) {
+	t, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	return studentRows(t)
+}
+
+// ReadStudentsXLSX reads one student per row of the first worksheet of an
+// Excel workbook with a header row
+func ReadStudentsXLSX(r io.ReaderAt, size int64) ([]StudentRow, error) {
+	t, err := readXLSX(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return studentRows(t)
+}
+
+func studentRows(t *table) ([]StudentRow, error) {
+	if !t.has("usn") {
+		return nil, fmt.Errorf("the file has no usn column")
+	}
+
+	rows := make([]StudentRow, 0, len(t.rows))
+	for i := range t.rows {
+		row := StudentRow{
+			Line: t.lines[i],
+			Student: models.Student{
+				USN:            t.get(i, "usn"),
+				Name:           t.get(i, "name"),
+				Email:          t.get(i, "email"),
+				Phone:          t.get(i, "phone"),
+				Address:        t.get(i, "address"),
+				Department:     t.get(i, "department"),
+				Category:       t.get(i, "category"),
+				NotifyChannels: t.get(i, "notify_channels"),
+				Language:       t.get(i, "language"),
+				Remark:         t.get(i, "remark"),
+			},
+		}
+		year, err := t.getInt(i, "admission_year", 0)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		row.Student.AdmissionYear = year
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// StudentRecord returns a student's fields in the order of StudentColumns
+func StudentRecord(s models.Student) []string {
+	year := ""
+	if s.AdmissionYear != 0 {
+		year = strconv.Itoa(s.AdmissionYear)
+	}
+	return []string{
+		s.USN, s.Name, s.Email, s.Phone, s.Address, year,
+		s.Department, s.Category, s.NotifyChannels, s.Language, s.Remark,
+	}
+}
+
+// validateStudents checks every row and stores phone numbers in E.164
+// form. USNs must be unique within the file; a USN that is already
+// registered updates that student.
+func validateStudents(rows []StudentRow) []RowError {
+	var problems []RowError
+	firstLine := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		errs := append([]string(nil), row.Errors...)
+		student := &row.Student
+
+		key := strings.ToLower(student.USN)
+		switch line, seen := firstLine[key]; {
+		case student.USN == "":
+			errs = append(errs, "usn is required")
+		case seen:
+			errs = append(errs, fmt.Sprintf("usn %s is also on line %d", student.USN, line))
+		default:
+			firstLine[key] = row.Line
+		}
+
+		if student.Email != "" {
+			if address, err := mail.ParseAddress(student.Email); err != nil || address.Address != student.Email {
+				errs = append(errs, fmt.Sprintf("invalid email %q", student.Email))
+			}
+		}
+		if student.Phone != "" {
+			phone, err := utils.NormalizePhone(student.Phone, config.DefaultPhoneRegion)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid phone number %q", student.Phone))
+			} else {
+				student.Phone = phone
+			}
+		}
+
+		if len(errs) > 0 {
+			problems = append(problems, RowError{Line: row.Line, USN: student.USN, Errors: errs})
+		}
+	}
+	return problems
+}
+
+// ImportStudents validates the rows, then creates the students that are
+// new and updates the ones whose USN already exists, all in one
+// transaction. Empty fields leave the stored value unchanged. If any row
+// is invalid nothing is stored and ErrInvalidRows is returned with the
+// problems in the result. A dry run does all the work and rolls it back,
+// so its counts are what a real import would do.
+func ImportStudents(db *gorm.DB, rows []StudentRow, dryRun bool) (StudentResult, error) {
+	result := StudentResult{DryRun: dryRun, Rows: len(rows), Errors: validateStudents(rows)}
+	if len(result.Errors) > 0 && !dryRun {
+		return result, ErrInvalidRows
+	}
+	invalid := map[int]bool{}
+	for _, problem := range result.Errors {
+		invalid[problem.Line] = true
+	}
+
+	err := repository.NewGormStore(db).Atomic(func(store repository.Store) error {
+		students := store.Students()
+		for _, row := range rows {
+			if invalid[row.Line] {
+				continue
+			}
+			student := row.Student
+
+			existing, err := students.GetByUSN(student.USN)
+			if err == repository.ErrNotFound {
+				student.RegisteredAt = time.Now()
+				student.ExpiryDate = student.RegisteredAt.AddDate(4, 0, 0)
+				if err := students.Create(&student); err != nil {
+					return fmt.Errorf("line %d: %w", row.Line, err)
+				}
+				result.Created++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			student.USN = "" // Keep the stored spelling
+			if unchanged(existing, student) {
+				result.Skipped++
+				continue
+			}
+			if err := students.Update(&existing, student); err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+			result.Updated++
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return StudentResult{}, err
+	}
+	return result, nil
+}
+
+// unchanged reports whether updating the student with the changes would
+// leave it as it is
+func unchanged(student, changes models.Student) bool {
+	same := func(current, change string) bool { return change == "" || change == current }
+	return same(student.Name, changes.Name) &&
+		same(student.Email, changes.Email) &&
+		same(student.Phone, changes.Phone) &&
+		same(student.Address, changes.Address) &&
+		(changes.AdmissionYear == 0 || changes.AdmissionYear == student.AdmissionYear) &&
+		same(student.Department, changes.Department) &&
+		same(student.Category, changes.Category) &&
+		same(student.NotifyChannels, changes.NotifyChannels) &&
+		same(student.Language, changes.Language) &&
+		same(student.Remark, changes.Remark)
+}
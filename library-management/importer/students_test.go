@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestImportStudents(t *testing.T) {
+	db := databasetest.Open(t)
+	db.Create(&models.Student{USN: "1AB21CS001", Name: "Asha", Phone: "+919876543210"})
+
+	read := func(data string) []StudentRow {
+		t.Helper()
+		rows, err := ReadStudentsCSV(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	data := "usn,name,phone,admission_year\n" +
+		"1ab21cs001,,98765 43210,\n" + // Unchanged apart from the spelling
+		"1AB21CS002,Ravi,,2021\n" +
+		"1AB21CS003,Meera,,\n"
+
+	dryRun, err := ImportStudents(db, read(data), true)
+	if err != nil || dryRun.Created != 2 || dryRun.Skipped != 1 || !dryRun.DryRun {
+		t.Fatalf("dry run = %+v, %v", dryRun, err)
+	}
+	var count int64
+	if db.Model(&models.Student{}).Count(&count); count != 1 {
+		t.Errorf("the dry run stored %d students", count-1)
+	}
+
+	result, err := ImportStudents(db, read(data), false)
+	if err != nil || result.Created != 2 || result.Skipped != 1 || result.Updated != 0 {
+		t.Fatalf("import = %+v, %v", result, err)
+	}
+	var ravi models.Student
+	db.Where("usn = ?", "1AB21CS002").First(&ravi)
+	if ravi.Name != "Ravi" || ravi.AdmissionYear != 2021 || ravi.ExpiryDate.IsZero() {
+		t.Errorf("created student = %+v", ravi)
+	}
+
+	result, err = ImportStudents(db, read("usn,email\n1AB21CS003,meera@example.com\n"), false)
+	if err != nil || result.Updated != 1 {
+		t.Errorf("update = %+v, %v", result, err)
+	}
+}
+
+func TestImportStudentsRejectsInvalidRows(t *testing.T) {
+	db := databasetest.Open(t)
+	rows, err := ReadStudentsCSV(strings.NewReader("usn,email,phone,admission_year\n" +
+		"1AB21CS001,,,\n" +
+		",,,\n" +
+		"1AB21CS002,not an email,,\n" +
+		"1ab21cs001,,,\n" +
+		"1AB21CS004,,12,\n" +
+		"1AB21CS005,,,soon\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ImportStudents(db, rows, false)
+	if err != ErrInvalidRows {
+		t.Fatalf("ImportStudents = %v, want ErrInvalidRows", err)
+	}
+	lines := []int{}
+	for _, problem := range result.Errors {
+		lines = append(lines, problem.Line)
+	}
+	if len(lines) != 4 || lines[0] != 4 || lines[1] != 5 || lines[2] != 6 || lines[3] != 7 {
+		t.Errorf("rows with errors are on lines %v, want 4 to 7", lines)
+	}
+	var count int64
+	if db.Model(&models.Student{}).Count(&count); count != 0 {
+		t.Errorf("%d students were stored from an invalid file", count)
+	}
+
+	if _, err := ReadStudentsCSV(strings.NewReader("name\nAsha\n")); err == nil {
+		t.Error("a file without a usn column was accepted")
+	}
+}
@@ -0,0 +1,222 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Limits on what is read from a workbook, so a small upload cannot make
+// the server allocate without bound. Excel sheets end at column XFD.
+const (
+	maxXLSXColumns = 16384
+	maxXLSXCells   = 1 << 22 // Across all rows, blanks included
+	maxXLSXXMLSize = 64 << 20
+)
+
+// readXLSX reads the first worksheet of an Excel workbook whose first row
+// names the columns. Only cell values are read; formulas contribute their
+// cached result.
+func readXLSX(r io.ReaderAt, size int64) (*table, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an Excel workbook: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var strs []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if strs, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("the workbook has no worksheet %s", sheetPath)
+	}
+	var sheet struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeXML(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	var lines []int
+	cells := 0
+	for i, row := range sheet.Rows {
+		var record []string
+		for j, cell := range row.Cells {
+			column := j
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if column >= maxXLSXColumns {
+				return nil, fmt.Errorf("cell %s is past the last column", cell.Ref)
+			}
+			// Cells to the right of the header row have no column name
+			if len(records) > 0 && column >= len(records[0]) {
+				continue
+			}
+			if grow := column + 1 - len(record); grow > 0 {
+				if cells += grow; cells > maxXLSXCells {
+					return nil, fmt.Errorf("the worksheet has more than %d cells", maxXLSXCells)
+				}
+				record = append(record, make([]string, grow)...)
+			}
+			record[column], err = cellValue(cell.Type, cell.Value, cell.Inline, strs)
+			if err != nil {
+				return nil, fmt.Errorf("cell %s: %w", cell.Ref, err)
+			}
+		}
+		line := row.Number
+		if line == 0 {
+			line = i + 1
+		}
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return newTable(records, lines)
+}
+
+// firstSheet finds the file of the workbook's first worksheet
+func firstSheet(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	wb, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", fmt.Errorf("not an Excel workbook: xl/workbook.xml is missing")
+	}
+	if err := decodeXML(wb, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("the workbook has no worksheets")
+	}
+	if f, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodeXML(f, &rels); err != nil {
+			return "", err
+		}
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].ID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+// readSharedStrings reads the workbook's table of strings, joining the runs
+// of rich text
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []struct {
+			Text string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"si"`
+	}
+	if err := decodeXML(f, &sst); err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		strs[i] = item.Text + strings.Join(item.Runs, "")
+	}
+	return strs, nil
+}
+
+func cellValue(cellType, value, inline string, strs []string) (string, error) {
+	switch cellType {
+	case "s":
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(strs) {
+			return "", fmt.Errorf("bad shared string %q", value)
+		}
+		return strs[i], nil
+	case "inlineStr":
+		return inline, nil
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		// Numbers may be stored in exponent form or with a fraction of
+		// zero; phone numbers and years must read as plain digits
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+		return value, nil
+	default: // str (formula result) and e (error)
+		return value, nil
+	}
+}
+
+// columnIndex turns a cell reference such as "AB12" into a zero based
+// column number
+func columnIndex(ref string) (int, error) {
+	column := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		column = column*26 + int(ref[i]-'A') + 1
+		if column > maxXLSXColumns {
+			return 0, fmt.Errorf("cell %s is past the last column", ref)
+		}
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("bad cell reference %q", ref)
+	}
+	return column - 1, nil
+}
+
+// decodeXML decodes a file of the workbook, reading no more than
+// maxXLSXXMLSize of it however well it compresses
+func decodeXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	r := &io.LimitedReader{R: rc, N: maxXLSXXMLSize + 1}
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		if r.N == 0 {
+			return fmt.Errorf("%s is larger than %d MB", f.Name, maxXLSXXMLSize>>20)
+		}
+		return fmt.Errorf("reading %s: %w", f.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// workbook zips a minimal XLSX file around a worksheet's sheetData
+func workbook(t *testing.T, sheetData string, sharedStrings ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	add := func(name, content string) {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	add("xl/workbook.xml", `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Students" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	add("xl/_rels/workbook.xml.rels", `<Relationships><Relationship Id="rId1" Target="worksheets/students.xml"/></Relationships>`)
+	add("xl/worksheets/students.xml", `<worksheet><sheetData>`+sheetData+`</sheetData></worksheet>`)
+	if len(sharedStrings) > 0 {
+		var sst strings.Builder
+		sst.WriteString("<sst>")
+		for _, s := range sharedStrings {
+			fmt.Fprintf(&sst, "<si><t>%s</t></si>", s)
+		}
+		sst.WriteString("</sst>")
+		add("xl/sharedStrings.xml", sst.String())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readStudentsWorkbook(data []byte) ([]StudentRow, error) {
+	return ReadStudentsXLSX(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestReadStudentsXLSX(t *testing.T) {
+	data := workbook(t, `
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>Admission Year</t></is></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="inlineStr"><is><t>Asha</t></is></c><c r="C2"><v>99</v></c><c r="D2"><v>2.021E3</v></c></row>
+<row r="4"><c r="D4" t="str"><v>twenty</v></c><c r="A4" t="inlineStr"><is><t>1AB21CS002</t></is></c></row>
+<row r="5"></row>`, "USN", "name", "1AB21CS001")
+
+	rows, err := readStudentsWorkbook(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("read %d rows, want 2: %+v", len(rows), rows)
+	}
+	first := rows[0]
+	if first.Line != 2 || first.Student.USN != "1AB21CS001" || first.Student.Name != "Asha" ||
+		first.Student.AdmissionYear != 2021 || len(first.Errors) != 0 {
+		t.Errorf("row 1 = %+v", first)
+	}
+	second := rows[1]
+	if second.Line != 4 || second.Student.USN != "1AB21CS002" || len(second.Errors) == 0 {
+		t.Errorf("row 2 = %+v", second)
+	}
+}
+
+func TestReadXLSXLimits(t *testing.T) {
+	header := `<row r="1"><c r="A1" t="inlineStr"><is><t>usn</t></is></c></row>`
+	tests := map[string][]byte{
+		"column past XFD":       workbook(t, `<row r="1"><c r="XFE1" t="inlineStr"><is><t>usn</t></is></c></row>`),
+		"overflowing column":    workbook(t, header+`<row r="2"><c r="ZZZZZZZZZZZZZZZZZZZZ2"><v>1</v></c></row>`),
+		"bad reference":         workbook(t, header+`<row r="2"><c r="12"><v>1</v></c></row>`),
+		"bad shared string":     workbook(t, header+`<row r="2"><c r="A2" t="s"><v>7</v></c></row>`, "usn"),
+		"missing workbook part": []byte("PK\x05\x06" + strings.Repeat("\x00", 18)),
+		"not a zip":             []byte("usn\n1AB21CS001\n"),
+	}
+	// A sheet that compresses far below its size
+	var wide strings.Builder
+	wide.WriteString(`<row r="1"><c r="XFD1" t="inlineStr"><is><t>usn</t></is></c></row>`)
+	for i := 2; i < 300; i++ {
+		fmt.Fprintf(&wide, `<row r="%d"><c r="XFD%d"><v>1</v></c></row>`, i, i)
+	}
+	tests["too many cells"] = workbook(t, wide.String())
+
+	for name, data := range tests {
+		if _, err := readStudentsWorkbook(data); err == nil {
+			t.Errorf("%s: the workbook was accepted", name)
+		}
+	}
+
+	huge := workbook(t, header+"<!--"+strings.Repeat(" ", maxXLSXXMLSize)+"-->")
+	if _, err := readStudentsWorkbook(huge); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("a sheet past the size limit gave %v", err)
+	}
+}
+
+func TestReadXLSXIgnoresCellsRightOfTheHeader(t *testing.T) {
+	data := workbook(t, `<row r="1"><c r="A1" t="inlineStr"><is><t>usn</t></is></c></row>
+<row r="2"><c r="A2" t="inlineStr"><is><t>1AB21CS001</t></is></c><c r="XFD2"><v>1</v></c></row>`)
+	table, err := readXLSX(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.rows) != 1 || len(table.rows[0]) != 1 {
+		t.Errorf("rows = %v", table.rows)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AB12": 27, "XFD1048576": 16383} {
+		if got, err := columnIndex(ref); err != nil || got != want {
+			t.Errorf("columnIndex(%q) = %d, %v, want %d", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"", "1", "a1", "XFE1", "AAAAAAAAAAAAAAAA1"} {
+		if _, err := columnIndex(ref); err == nil {
+			t.Errorf("columnIndex(%q) succeeded", ref)
+		}
+	}
+}
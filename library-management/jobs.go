@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/handlers"
+	"library-management/scheduler"
+)
+
+// reminderJob sends one kind of due date reminder
+func reminderJob(db *gorm.DB, kind string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		queued, err := handlers.SendScheduledReminders(ctx, db, kind, time.Now())
+		return fmt.Sprintf("%d reminders queued", queued), err
+	}
+}
+
+// expireHoldsJob ends holds that were not picked up in time and tells the
+// students next in line
+func expireHoldsJob(db *gorm.DB) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		expired, err := handlers.ExpireHolds(db.WithContext(ctx))
+		return fmt.Sprintf("%d holds expired", expired), err
+	}
+}
+
+// newScheduler registers the reminder and hold expiry jobs on their
+// configured schedules
+func newScheduler(db *gorm.DB) (*scheduler.Scheduler, error) {
+	sched := scheduler.New(db, config.JobLockTTL)
+	jobs := []scheduler.Job{
+		{Name: "due_soon_reminders", Spec: config.DueSoonCron, Run: reminderJob(db, handlers.ReminderDueSoon)},
+		{Name: "due_today_reminders", Spec: config.DueTodayCron, Run: reminderJob(db, handlers.ReminderDueToday)},
+		{Name: "overdue_reminders", Spec: config.OverdueCron, Run: reminderJob(db, handlers.ReminderOverdue)},
+		{Name: "expire_holds", Spec: config.ExpireHoldsCron, Run: expireHoldsJob(db)},
+	}
+	for _, job := range jobs {
+		if err := sched.Add(job); err != nil {
+			return nil, err
+		}
+	}
+	return sched, nil
+}
@@ -1,102 +1,263 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"library-management/circulation"
 	"library-management/models"
 	"library-management/handlers" // Ensure you import the handlers package
 	"library-management/config"    // Add this import for the config package
+	"library-management/database"
+	"library-management/middleware"
+	"library-management/migrations"
+	"library-management/importer"
+	"library-management/notify"
+	"library-management/scanner"
+	"library-management/storage"
 )
 
 var DB *gorm.DB
 
-// ConnectDatabase establishes a connection to the PostgreSQL database
-func ConnectDatabase() {
-	dsn := ""
+// ConnectDatabase establishes a connection to the configured database
+func ConnectDatabase(settings config.DatabaseConfig) {
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = database.Open(settings)
 	if err != nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
-	log.Println("Connected to the database successfully!")
+	log.Printf("Connected to the %s database successfully!", settings.Driver)
+}
+
+// loadConfig reads the settings file and environment and makes the
+// settings visible to the other packages. It stops the program with the
+// list of problems if the settings are invalid.
+func loadConfig() *config.Config {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Apply()
+	circulation.Configure(cfg.Circulation)
+	return cfg
 }
 
 func main() {
+	// One-off maintenance commands, e.g. "library-management fix-phones"
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Load and check the settings before anything else
+	cfg := loadConfig()
+
 	// Initialize the router
 	r := gin.Default()
 
 	// Enable CORS
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowOrigins:     cfg.Server.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		AllowCredentials: true,
 	}))
 
 	// Connect to the database
-	ConnectDatabase()
+	ConnectDatabase(cfg.Database)
 
 	// Initialize the Twilio client
 	config.InitTwilio()
 
-	// Auto-migrate models
-	DB.AutoMigrate(
-		&models.Student{},
-		&models.Book{},
-		&models.Vendor{},
-		&models.Transaction{},
-		&models.User{},
-	)
+	// Set up the notification channels (SMS, email, webhook)
+	notify.SetDefault(notify.FromConfig())
+
+	// Refuse to run against a schema this build does not understand
+	if err := migrations.CheckSchema(DB); err != nil {
+		log.Fatalf("Cannot start: %v", err)
+	}
+
+	// Keep ebook files on disk or in an S3 bucket
+	fileStore, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to set up file storage: %v", err)
+	}
+	if pending, err := migrations.PendingEBooks(DB); err != nil {
+		log.Printf("Failed to check for ebooks kept in the database: %v", err)
+	} else if pending > 0 {
+		log.Printf("Warning: %d ebook files are still kept in the database; run \"libraryctl move-ebooks\" to move them to storage", pending)
+	}
+
+	// Check uploaded ebooks for malware
+	fileScanner, err := scanner.New(cfg.Uploads.Scanner)
+	if err != nil {
+		log.Fatalf("Failed to set up the malware scanner: %v", err)
+	}
+
+	// Make sure the built-in roles and their permissions exist
+	if err := handlers.SeedRoles(DB); err != nil {
+		log.Fatalf("Failed to seed roles: %v", err)
+	}
+
+	// Store the built-in notification texts so they can be edited
+	if err := handlers.SeedMessageTemplates(DB); err != nil {
+		log.Fatalf("Failed to seed notification templates: %v", err)
+	}
+
+	// Imports whose server stopped without finishing them never will
+	if failed, err := importer.FailInterruptedImports(DB); err != nil {
+		log.Printf("Failed to check for interrupted imports: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted catalog imports as failed", failed)
+	}
+
+	// Run the reminder jobs on their schedules; with the scheduler disabled
+	// they can still be triggered from the admin endpoint
+	sched, err := newScheduler(DB)
+	if err != nil {
+		log.Fatalf("Failed to set up the scheduler: %v", err)
+	}
+	if config.SchedulerEnabled {
+		sched.Start()
+	}
+
+	// Run until interrupted or told to stop by the service manager
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Retry failed notifications from the outbox in the background
+	go notify.RunRetryWorker(ctx, DB)
+
+	// can builds the permission check for a route
+	can := func(permission string) gin.HandlerFunc { return middleware.RequirePermission(DB, permission) }
+	canAny := func(permissions ...string) gin.HandlerFunc { return middleware.RequireAnyPermission(DB, permissions...) }
+
+	// Every route registered on api requires a valid access token
+	api := r.Group("/")
+	api.Use(middleware.AuthRequired(DB))
 
 	// Register routes for students
-	r.GET("/students", func(c *gin.Context) { handlers.GetStudents(c, DB) })
-	r.GET("/students/:id", func(c *gin.Context) { handlers.GetStudentByID(c, DB) })
-	r.POST("/students", func(c *gin.Context) { handlers.CreateStudent(c, DB) })
-	r.PUT("/students/:id", func(c *gin.Context) { handlers.UpdateStudent(c, DB) })
-	r.DELETE("/students/:id", func(c *gin.Context) { handlers.DeleteStudent(c, DB) })
+	api.GET("/students", can(models.PermStudentsRead), func(c *gin.Context) { handlers.GetStudents(c, DB) })
+	api.GET("/students/:id", can(models.PermStudentsRead), func(c *gin.Context) { handlers.GetStudentByID(c, DB) })
+	api.POST("/students", can(models.PermStudentsWrite), func(c *gin.Context) { handlers.CreateStudent(c, DB) })
+	api.POST("/students/import", can(models.PermStudentsWrite), func(c *gin.Context) { handlers.ImportStudents(c, DB) })
+	api.PUT("/students/:id", can(models.PermStudentsWrite), func(c *gin.Context) { handlers.UpdateStudent(c, DB) })
+	api.DELETE("/students/:id", can(models.PermStudentsDelete), func(c *gin.Context) { handlers.DeleteStudent(c, DB) })
 
 	// Register routes for books
-	r.GET("/books", func(c *gin.Context) { handlers.GetBooks(c, DB) })
-	r.POST("/books", func(c *gin.Context) { handlers.CreateBook(c, DB) })
-	r.GET("/books/search", func(c *gin.Context) { handlers.SearchBooksByTitle(c, DB) })
-	r.POST("/books/:id/upload", func(c *gin.Context) { handlers.UploadBookFile(c, DB) })
-	r.GET("/books/:id/download", func(c *gin.Context) { handlers.DownloadBookFile(c, DB) })
-	r.DELETE("/books/:id", func(c *gin.Context) { handlers.DeleteBook(c, DB) }) // Added delete route for books
-	r.PUT("/transactions/:id/return", func(c *gin.Context) { handlers.ReturnBook(c, DB) }) // Fixed closing parenthesis here
-	r.GET("/books/:id", func(c *gin.Context) {
+	api.GET("/books", can(models.PermBooksRead), func(c *gin.Context) { handlers.GetBooks(c, DB) })
+	api.POST("/books", can(models.PermBooksWrite), func(c *gin.Context) { handlers.CreateBook(c, DB) })
+	api.POST("/books/:id/copies", can(models.PermBooksWrite), func(c *gin.Context) { handlers.AddBookCopies(c, DB) })
+	api.PUT("/copies/:id/status", can(models.PermBooksWrite), func(c *gin.Context) { handlers.UpdateCopyStatus(c, DB) })
+	api.GET("/books/search", can(models.PermBooksRead), func(c *gin.Context) { handlers.SearchBooksByTitle(c, DB) })
+	api.POST("/books/:id/upload", can(models.PermEbooksUpload), func(c *gin.Context) { handlers.UploadBookFile(c, DB, fileStore, fileScanner) })
+	api.POST("/books/:id/download-link", can(models.PermEbooksDownload), func(c *gin.Context) { handlers.CreateDownloadLink(c, DB) })
+	api.GET("/ebook-downloads", can(models.PermTransactionsRead), func(c *gin.Context) { handlers.GetEBookDownloads(c, DB) })
+	api.DELETE("/books/:id", can(models.PermBooksDelete), func(c *gin.Context) { handlers.DeleteBook(c, DB, fileStore) }) // Added delete route for books
+	api.PUT("/transactions/:id/return", can(models.PermCirculationReturn), func(c *gin.Context) { handlers.ReturnBook(c, DB) }) // Fixed closing parenthesis here
+	api.GET("/books/:id", can(models.PermBooksRead), func(c *gin.Context) {
 		handlers.GetBookDetails(c, DB)
 	})
 	
 
+	// Register routes for catalog imports
+	api.POST("/catalog/imports", can(models.PermBooksWrite), func(c *gin.Context) { handlers.ImportCatalog(c, DB) })
+	api.GET("/catalog/imports", can(models.PermBooksWrite), func(c *gin.Context) { handlers.GetCatalogImports(c, DB) })
+	api.GET("/catalog/imports/:id", can(models.PermBooksWrite), func(c *gin.Context) { handlers.GetCatalogImport(c, DB) })
+	api.GET("/catalog/imports/:id/errors", can(models.PermBooksWrite), func(c *gin.Context) { handlers.GetCatalogImportErrors(c, DB) })
+
 	// Register routes for vendors
-	r.GET("/vendors", func(c *gin.Context) { handlers.GetVendors(c, DB) })
-	r.POST("/vendors", func(c *gin.Context) { handlers.CreateVendor(c, DB) })
-	r.DELETE("/vendors/:id", func(c *gin.Context) { handlers.DeleteVendor(c, DB) }) // Added delete route for vendors
-	r.GET("/vendors/:id", func(c *gin.Context) { handlers.GetVendorByID(c, DB) }) // Fixed this line to use handlers.GetVendorByID
+	api.GET("/vendors", can(models.PermVendorsRead), func(c *gin.Context) { handlers.GetVendors(c, DB) })
+	api.POST("/vendors", can(models.PermVendorsWrite), func(c *gin.Context) { handlers.CreateVendor(c, DB) })
+	api.DELETE("/vendors/:id", can(models.PermVendorsDelete), func(c *gin.Context) { handlers.DeleteVendor(c, DB) }) // Added delete route for vendors
+	api.GET("/vendors/:id", can(models.PermVendorsRead), func(c *gin.Context) { handlers.GetVendorByID(c, DB) }) // Fixed this line to use handlers.GetVendorByID
 
 	// Register routes for transactions
-	r.GET("/transactions", func(c *gin.Context) { handlers.GetTransactions(c, DB) })
-	r.POST("/transactions", func(c *gin.Context) { handlers.CreateTransaction(c, DB) })
-	r.DELETE("/transactions/:id", func(c *gin.Context) { handlers.DeleteTransaction(c, DB) }) // Added delete route for transactions
-	r.GET("/transactions/search", func(c *gin.Context) { handlers.SearchTransactions(c, DB) })
+	api.GET("/transactions", can(models.PermTransactionsRead), func(c *gin.Context) { handlers.GetTransactions(c, DB) })
+	api.POST("/transactions", can(models.PermCirculationIssue), func(c *gin.Context) { handlers.CreateTransaction(c, DB) })
+	api.POST("/transactions/:id/renew", can(models.PermCirculationRenew), func(c *gin.Context) { handlers.RenewTransaction(c, DB) })
+	api.GET("/transactions/:id/history", canAny(models.PermTransactionsRead, models.PermLoansReadOwn), func(c *gin.Context) { handlers.GetTransactionHistory(c, DB) }) // Students with loans:read-own see only their own loans, checked in the handler
+	api.DELETE("/transactions/:id", can(models.PermTransactionsDelete), func(c *gin.Context) { handlers.DeleteTransaction(c, DB) }) // Added delete route for transactions
+	api.GET("/transactions/search", can(models.PermTransactionsRead), func(c *gin.Context) { handlers.SearchTransactions(c, DB) })
+
+	// Register routes for holds
+	api.POST("/holds", can(models.PermHoldsPlace), func(c *gin.Context) { handlers.PlaceHold(c, DB) })
+	api.DELETE("/holds/:id", can(models.PermHoldsPlace), func(c *gin.Context) { handlers.CancelHold(c, DB) })
+	api.GET("/students/:id/holds", can(models.PermHoldsRead), func(c *gin.Context) { handlers.GetStudentHolds(c, DB) })
+	api.GET("/books/:id/holds", can(models.PermTransactionsRead), func(c *gin.Context) { handlers.GetBookHolds(c, DB) })
+
+	// Register routes for fines
+	api.GET("/students/:id/ledger", can(models.PermFinesRead), func(c *gin.Context) { handlers.GetStudentLedger(c, DB) })
+	api.GET("/students/:id/balance", can(models.PermFinesRead), func(c *gin.Context) { handlers.GetStudentBalance(c, DB) })
+	api.POST("/students/:id/charges", can(models.PermFinesCharge), func(c *gin.Context) { handlers.AddStudentCharge(c, DB) })
+	api.POST("/students/:id/payments", can(models.PermFinesCollect), func(c *gin.Context) { handlers.RecordPayment(c, DB) })
+	api.POST("/students/:id/waivers", can(models.PermFinesWaive), func(c *gin.Context) { handlers.GrantWaiver(c, DB) })
+	api.GET("/ledger/:id/receipt", can(models.PermFinesRead), func(c *gin.Context) { handlers.GetLedgerReceipt(c, DB) })
+
+	// Register routes for notification preferences and history
+	api.GET("/notifications/channels", func(c *gin.Context) { handlers.GetNotificationChannels(c) })
+	api.GET("/students/:id/notification-preferences", can(models.PermNotifyPreferences), func(c *gin.Context) { handlers.GetNotificationPreferences(c, DB) })
+	api.PUT("/students/:id/notification-preferences", can(models.PermNotifyPreferences), func(c *gin.Context) { handlers.SetNotificationPreferences(c, DB) })
+	api.GET("/students/:id/notifications", can(models.PermNotifyHistory), func(c *gin.Context) { handlers.GetNotificationHistory(c, DB) })
+
+	// Register routes for notification templates
+	api.GET("/notification-templates", can(models.PermTemplatesManage), func(c *gin.Context) { handlers.GetMessageTemplates(c, DB) })
+	api.PUT("/notification-templates/:key/:language", can(models.PermTemplatesManage), func(c *gin.Context) { handlers.UpdateMessageTemplate(c, DB) })
+	api.DELETE("/notification-templates/:key/:language", can(models.PermTemplatesManage), func(c *gin.Context) { handlers.ResetMessageTemplate(c, DB) })
+	api.POST("/notification-templates/preview", can(models.PermTemplatesManage), func(c *gin.Context) { handlers.PreviewMessageTemplate(c, DB) })
+
+	// Register routes for circulation rules
+	api.GET("/circulation-rules", can(models.PermRulesRead), func(c *gin.Context) { handlers.GetCirculationRules(c, DB) })
+	api.POST("/circulation-rules", can(models.PermRulesManage), func(c *gin.Context) { handlers.CreateCirculationRule(c, DB) })
+	api.PUT("/circulation-rules/:id", can(models.PermRulesManage), func(c *gin.Context) { handlers.UpdateCirculationRule(c, DB) })
+	api.DELETE("/circulation-rules/:id", can(models.PermRulesManage), func(c *gin.Context) { handlers.DeleteCirculationRule(c, DB) })
+
+	// Register routes for the library calendar
+	api.GET("/calendar", can(models.PermBooksRead), func(c *gin.Context) { handlers.GetCalendar(c, DB) })
+	api.PUT("/calendar/hours", can(models.PermCalendarManage), func(c *gin.Context) { handlers.SetOpeningHours(c, DB) })
+	api.POST("/calendar/holidays", can(models.PermCalendarManage), func(c *gin.Context) { handlers.CreateHoliday(c, DB) })
+	api.DELETE("/calendar/holidays/:id", can(models.PermCalendarManage), func(c *gin.Context) { handlers.DeleteHoliday(c, DB) })
+	api.POST("/calendar/holidays/import", can(models.PermCalendarManage), func(c *gin.Context) { handlers.ImportHolidays(c, DB) })
 
 	// Basic health check endpoint
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "Server is running"})
 	})
 
-	// Register login route
+	// Ebook downloads are authorized by the signed link, so viewers can
+	// fetch them without the access token
+	r.GET("/books/:id/download", func(c *gin.Context) { handlers.DownloadBookFile(c, DB, fileStore) })
+	r.HEAD("/books/:id/download", func(c *gin.Context) { handlers.DownloadBookFile(c, DB, fileStore) })
+
+	// Register login and session routes
 	r.POST("/login", func(c *gin.Context) { handlers.Login(c, DB) })
+	r.POST("/refresh", func(c *gin.Context) { handlers.RefreshToken(c, DB) })
+	api.POST("/logout", func(c *gin.Context) { handlers.Logout(c, DB) })
 
-	// Register user creation route
-	r.POST("/register", func(c *gin.Context) { handlers.CreateUser(c, DB) })
+	// Register user creation routes; /setup creates the first admin and only works until a user exists
+	r.POST("/setup", func(c *gin.Context) { handlers.CreateFirstUser(c, DB) })
+	api.POST("/register", can(models.PermUsersManage), func(c *gin.Context) { handlers.CreateUser(c, DB) })
+
+	// Register user and role administration routes
+	api.GET("/users", can(models.PermUsersRead), func(c *gin.Context) { handlers.GetUsers(c, DB) })
+	api.PUT("/users/:id/roles", can(models.PermUsersManage), func(c *gin.Context) { handlers.AssignUserRoles(c, DB) })
+	api.GET("/roles", can(models.PermUsersRead), func(c *gin.Context) { handlers.GetRoles(c, DB) })
+
+	// Register scheduled job administration routes
+	api.GET("/admin/jobs", can(models.PermJobsRead), func(c *gin.Context) { handlers.GetJobs(c, sched) })
+	api.GET("/admin/jobs/:name/runs", can(models.PermJobsRead), func(c *gin.Context) { handlers.GetJobRuns(c, sched) })
+	api.POST("/admin/jobs/:name/run", can(models.PermJobsRun), func(c *gin.Context) { handlers.RunJob(c, sched) })
 
 	// Trigger reminder check (e.g., via HTTP request or scheduled task)
-	r.GET("/send-reminders", func(c *gin.Context) {
+	api.GET("/send-reminders", can(models.PermRemindersSend), func(c *gin.Context) {
 		// Retrieve query parameters (student_id or usn)
 		studentID := c.DefaultQuery("student_id", "")
 		usn := c.DefaultQuery("usn", "")
@@ -110,7 +271,7 @@ func main() {
 		}
 	
 		// Call the function to send reminders to the specific student
-		err := handlers.SendReminderForSpecificStudent(DB, studentID, usn)
+		queued, err := handlers.SendReminderForSpecificStudent(DB, studentID, usn)
 	
 		if err != nil {
 			// Handle error response
@@ -119,12 +280,33 @@ func main() {
 				"error":   err.Error(),
 			})
 		} else {
-			// Success response
-			c.JSON(200, gin.H{"message": "Reminder check triggered"})
+			// Success response; reminders already sent today are not counted
+			c.JSON(200, gin.H{"message": "Reminder check triggered", "queued": queued})
 		}
 	})
 	
 
-	// Start the server on port 8008
-	r.Run(":8008")
+	// Start the server on the configured address (":8008" by default)
+	server := &http.Server{Addr: cfg.Server.ListenAddr, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", cfg.Server.ListenAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Server stopped: %v", err)
+	case <-ctx.Done():
+	}
+
+	// Let open requests and running jobs finish before exiting
+	stop()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Open requests did not finish: %v", err)
+	}
+	sched.Stop()
+	log.Println("Server stopped")
 }
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/utils"
+)
+
+// AuthRequired rejects requests without a valid access token in the
+// Authorization header. The session behind the token is checked on every
+// request so that logout takes effect immediately.
+func AuthRequired(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		claims, err := utils.ParseToken(tokenString, utils.AccessToken)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		var session models.Session
+		if err := db.First(&session, claims.SessionID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session not found"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return
+		}
+		if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) || session.UserID != claims.UserID {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has ended"})
+			return
+		}
+
+		c.Set("userID", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("sessionID", claims.SessionID)
+		c.Next()
+	}
+}
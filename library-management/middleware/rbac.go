@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RequirePermission allows the request through only if one of the current
+// user's roles grants the permission. It must run after AuthRequired.
+func RequirePermission(db *gorm.DB, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, err := Permissions(c, db)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !permissions[permission] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAnyPermission allows the request through if the current user
+// holds at least one of the permissions. It must run after AuthRequired.
+func RequireAnyPermission(db *gorm.DB, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, err := Permissions(c, db)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		for _, permission := range permissions {
+			if granted[permission] {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to perform this action"})
+	}
+}
+
+// Permissions returns the set of permissions granted to the current user.
+// The result is cached on the request context.
+func Permissions(c *gin.Context, db *gorm.DB) (map[string]bool, error) {
+	if cached, ok := c.Get("permissions"); ok {
+		return cached.(map[string]bool), nil
+	}
+
+	var names []string
+	err := db.Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", c.GetUint("userID")).
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+	c.Set("permissions", permissions)
+	return permissions, nil
+}
+
+// HasPermission reports whether the current user holds the permission
+func HasPermission(c *gin.Context, db *gorm.DB, permission string) bool {
+	permissions, err := Permissions(c, db)
+	return err == nil && permissions[permission]
+}
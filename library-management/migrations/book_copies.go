@@ -0,0 +1,137 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+// legacyBook is the shape of the books table before copies were split out,
+// when every physical copy had its own row.
+type legacyBook struct {
+	ID            uint
+	Title         string
+	Subtitle      string
+	Author        string
+	Edition       int
+	Publisher     string
+	PublisherYear int
+	VendorID      uint
+	SerialNumber  string
+	RackNumber    string
+	EBookPDF      []byte
+}
+
+func (legacyBook) TableName() string { return "books" }
+
+// bibliographicKey identifies rows that describe the same title
+type bibliographicKey struct {
+	Title         string
+	Subtitle      string
+	Author        string
+	Edition       int
+	Publisher     string
+	PublisherYear int
+}
+
+// SplitBookCopies converts one-row-per-copy books into a single Book per
+// title with a Copy for each former row. Transactions are re-pointed from
+// the old book rows to the new copies. It does nothing on databases that
+// have already been converted or were created after the split.
+func SplitBookCopies(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable("books") || !migrator.HasColumn(&legacyBook{}, "SerialNumber") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.Copy{}); err != nil {
+			return err
+		}
+
+		hasTransactions := tx.Migrator().HasTable("transactions")
+		if hasTransactions {
+			// Transactions still reference book rows that are about to be merged
+			if err := tx.Exec("ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transactions_book").Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("ALTER TABLE transactions ADD COLUMN IF NOT EXISTS copy_id bigint").Error; err != nil {
+				return err
+			}
+		}
+
+		var rows []legacyBook
+		if err := tx.Order("id").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		canonical := make(map[bibliographicKey]legacyBook)
+		merged := 0
+		for _, row := range rows {
+			key := bibliographicKey{row.Title, row.Subtitle, row.Author, row.Edition, row.Publisher, row.PublisherYear}
+			book, seen := canonical[key]
+			if !seen {
+				book = row
+				canonical[key] = row
+			}
+
+			item := models.Copy{
+				BookID:       book.ID,
+				SerialNumber: row.SerialNumber,
+				RackNumber:   row.RackNumber,
+				VendorID:     row.VendorID,
+				Status:       models.CopyAvailable,
+			}
+
+			if hasTransactions {
+				var openLoans int64
+				if err := tx.Table("transactions").Where("book_id = ? AND return_date IS NULL", row.ID).Count(&openLoans).Error; err != nil {
+					return err
+				}
+				if openLoans > 0 {
+					item.Status = models.CopyIssued
+				}
+			}
+
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+
+			if hasTransactions {
+				if err := tx.Table("transactions").Where("book_id = ?", row.ID).Update("copy_id", item.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			if seen {
+				// Keep an ebook uploaded against a duplicate row
+				if book.EBookPDF == nil && row.EBookPDF != nil {
+					if err := tx.Model(&legacyBook{ID: book.ID}).Updates(legacyBook{EBookPDF: row.EBookPDF}).Error; err != nil {
+						return err
+					}
+					book.EBookPDF = row.EBookPDF
+					canonical[key] = book
+				}
+				if err := tx.Delete(&legacyBook{}, row.ID).Error; err != nil {
+					return err
+				}
+				merged++
+			}
+		}
+
+		for _, column := range []string{"SerialNumber", "RackNumber", "VendorID"} {
+			if err := tx.Migrator().DropColumn(&legacyBook{}, column); err != nil {
+				return err
+			}
+		}
+		if hasTransactions {
+			if err := tx.Exec("ALTER TABLE transactions DROP COLUMN IF EXISTS book_id").Error; err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Split %d book rows into %d titles (%d duplicates merged)", len(rows), len(canonical), merged)
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/storage"
+)
+
+// legacyEBook is a book's ebook as it was kept in the books table before
+// version 3
+type legacyEBook struct {
+	ID       uint
+	EBookKey string `gorm:"column:ebook_key"`
+	EBookPDF []byte `gorm:"column:e_book_pdf"`
+}
+
+// PendingEBooks counts the ebook files still kept in the books table
+func PendingEBooks(db *gorm.DB) (int64, error) {
+	var count int64
+	if !db.Migrator().HasColumn("books", "e_book_pdf") {
+		return 0, nil
+	}
+	err := db.Table("books").Where("e_book_pdf IS NOT NULL").Count(&count).Error
+	return count, err
+}
+
+// MoveEBooksToStore copies the ebook files still kept in the books table
+// into the blob store, records their size, digest and type, and empties the
+// old column. Books that were given a new file since keep it and only lose
+// the old one. It does nothing before migration 3. It returns the number of
+// files moved.
+func MoveEBooksToStore(ctx context.Context, db *gorm.DB, store storage.Store) (int, error) {
+	migrator := db.Migrator()
+	if !migrator.HasColumn("books", "e_book_pdf") || !migrator.HasColumn("books", "ebook_key") {
+		return 0, nil
+	}
+
+	// One at a time, so only one file is in memory
+	var ids []uint
+	if err := db.Table("books").Where("e_book_pdf IS NOT NULL").Order("id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, id := range ids {
+		var book legacyEBook
+		if err := db.Table("books").Select("id", "ebook_key", "e_book_pdf").Where("id = ?", id).Take(&book).Error; err != nil {
+			return moved, err
+		}
+		if book.EBookKey != "" || len(book.EBookPDF) == 0 {
+			if err := db.Table("books").Where("id = ?", id).Update("e_book_pdf", nil).Error; err != nil {
+				return moved, err
+			}
+			continue
+		}
+		if err := moveEBook(ctx, db, store, book); err != nil {
+			return moved, fmt.Errorf("book %d: %w", id, err)
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+func moveEBook(ctx context.Context, db *gorm.DB, store storage.Store, book legacyEBook) error {
+	key, err := storage.NewKey(fmt.Sprintf("ebooks/%d", book.ID))
+	if err != nil {
+		return err
+	}
+	mimeType := http.DetectContentType(book.EBookPDF)
+	if err := store.Put(ctx, key, bytes.NewReader(book.EBookPDF), int64(len(book.EBookPDF)), mimeType); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(book.EBookPDF)
+	err = db.Table("books").Where("id = ?", book.ID).Updates(map[string]interface{}{
+		"ebook_key":         key,
+		"ebook_size":        len(book.EBookPDF),
+		"ebook_sha256":      hex.EncodeToString(sum[:]),
+		"ebook_mime_type":   mimeType,
+		"ebook_uploaded_at": time.Now(),
+		"e_book_pdf":        nil,
+	}).Error
+	if err != nil {
+		store.Delete(ctx, key)
+		return err
+	}
+	return nil
+}
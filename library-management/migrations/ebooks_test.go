@@ -0,0 +1,113 @@
+package migrations_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"gorm.io/gorm"
+	"library-management/database/databasetest"
+	"library-management/migrations"
+	"library-management/models"
+	"library-management/storage"
+)
+
+// legacyBook adds a book whose ebook is kept in the books table
+func legacyBook(t *testing.T, db *gorm.DB, title string, pdf []byte) uint {
+	t.Helper()
+	book := models.Book{Title: title, Author: "Donovan", Edition: 1}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Table("books").Where("id = ?", book.ID).Update("e_book_pdf", pdf).Error; err != nil {
+		t.Fatal(err)
+	}
+	return book.ID
+}
+
+func TestMoveEBooksToStore(t *testing.T) {
+	db := databasetest.Open(t)
+	store := storage.NewLocal(t.TempDir())
+	ctx := context.Background()
+
+	pdf := []byte("%PDF-1.4\n% the go programming language\n")
+	moving := legacyBook(t, db, "Go", pdf)
+	// A book given a new file since keeps it and only loses the old one
+	replaced := legacyBook(t, db, "C", []byte("%PDF-1.4\n% old\n"))
+	if err := db.Table("books").Where("id = ?", replaced).Update("ebook_key", "ebooks/2/new").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if pending, err := migrations.PendingEBooks(db); err != nil || pending != 2 {
+		t.Fatalf("%d ebooks pending: %v", pending, err)
+	}
+	moved, err := migrations.MoveEBooksToStore(ctx, db, store)
+	if err != nil || moved != 1 {
+		t.Fatalf("moved %d ebooks: %v", moved, err)
+	}
+	if pending, _ := migrations.PendingEBooks(db); pending != 0 {
+		t.Errorf("%d ebooks are still pending", pending)
+	}
+
+	var book models.Book
+	if err := db.First(&book, moving).Error; err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(pdf)
+	if book.EBook.Size != int64(len(pdf)) || book.EBook.SHA256 != hex.EncodeToString(sum[:]) ||
+		book.EBook.MIMEType != "application/pdf" || book.EBook.UploadedAt == nil {
+		t.Errorf("moved ebook = %+v", book.EBook)
+	}
+	object, err := store.Open(ctx, book.EBook.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer object.Close()
+	if data, _ := io.ReadAll(object); string(data) != string(pdf) {
+		t.Errorf("stored %q", data)
+	}
+
+	var other models.Book
+	if err := db.First(&other, replaced).Error; err != nil || other.EBook.Key != "ebooks/2/new" {
+		t.Errorf("the replaced ebook = %+v, %v", other.EBook, err)
+	}
+
+	// Running it again finds nothing to do
+	if moved, err := migrations.MoveEBooksToStore(ctx, db, store); moved != 0 || err != nil {
+		t.Errorf("a second run moved %d: %v", moved, err)
+	}
+}
+
+// failingStore refuses every file
+type failingStore struct{ storage.Store }
+
+func (failingStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	return errors.New("the bucket is full")
+}
+
+func TestMoveEBooksToStoreKeepsFilesItCannotMove(t *testing.T) {
+	db := databasetest.Open(t)
+	id := legacyBook(t, db, "Go", []byte("%PDF-1.4\n"))
+
+	if _, err := migrations.MoveEBooksToStore(context.Background(), db, failingStore{}); err == nil {
+		t.Fatal("a failed move was not reported")
+	}
+	var legacy struct{ EBookPDF []byte }
+	db.Table("books").Select("e_book_pdf AS e_book_pdf").Where("id = ?", id).Take(&legacy)
+	if pending, _ := migrations.PendingEBooks(db); pending != 1 || len(legacy.EBookPDF) == 0 {
+		t.Errorf("the file was lost: %d pending, %q", pending, legacy.EBookPDF)
+	}
+}
+
+func TestMoveEBooksToStoreBeforeMigration3(t *testing.T) {
+	db := databasetest.Open(t)
+	if _, err := migrations.To(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := migrations.MoveEBooksToStore(context.Background(), db, failingStore{}); moved != 0 || err != nil {
+		t.Errorf("moved %d before migration 3: %v", moved, err)
+	}
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+// ConvertMoneyToMinorUnits moves the float money columns to integer minor
+// units and opens a ledger charge for every late fee recorded so far. It
+// must run after AutoMigrate has added the new columns, and does nothing
+// once the old columns are gone.
+func ConvertMoneyToMinorUnits(db *gorm.DB) error {
+	migrator := db.Migrator()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if migrator.HasColumn("transactions", "late_fee") {
+			if err := tx.Exec("UPDATE transactions SET late_fee_minor = ROUND(late_fee * 100) WHERE late_fee IS NOT NULL").Error; err != nil {
+				return err
+			}
+
+			var charged []models.Transaction
+			if err := tx.Where("late_fee_minor > 0").Find(&charged).Error; err != nil {
+				return err
+			}
+			for _, transaction := range charged {
+				createdAt := transaction.IssueDate
+				if transaction.ReturnDate != nil {
+					createdAt = *transaction.ReturnDate
+				}
+				entry := models.LedgerEntry{
+					StudentUSN:    transaction.StudentUSN,
+					TransactionID: &transaction.ID,
+					Type:          models.ChargeLateFee,
+					AmountMinor:   transaction.LateFeeMinor,
+					Reason:        "Late fee recorded before the fine ledger",
+					CreatedAt:     createdAt,
+				}
+				if err := tx.Omit("ReceiptNumber").Create(&entry).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().DropColumn("transactions", "late_fee"); err != nil {
+				return err
+			}
+			log.Printf("Moved %d late fees into the fine ledger", len(charged))
+		}
+
+		if migrator.HasColumn("circulation_rules", "fine_per_day") {
+			if err := tx.Exec("UPDATE circulation_rules SET fine_per_day_minor = ROUND(fine_per_day * 100), fine_cap_minor = ROUND(COALESCE(fine_cap, 0) * 100)").Error; err != nil {
+				return err
+			}
+			for _, column := range []string{"fine_per_day", "fine_cap"} {
+				if err := tx.Migrator().DropColumn("circulation_rules", column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+	"library-management/models"
+	"library-management/utils"
+)
+
+// PhoneFix reports a stored phone number that is not in E.164 form. Fixed
+// is empty when the number could not be read.
+type PhoneFix struct {
+	StudentID uint
+	USN       string
+	Phone     string
+	Fixed     string
+}
+
+// NormalizeStudentPhones finds student phone numbers that are not in E.164
+// form, reading numbers without a country code as numbers of region. With
+// apply set, numbers that can be read are rewritten; unreadable ones are
+// only reported.
+func NormalizeStudentPhones(db *gorm.DB, region string, apply bool) ([]PhoneFix, error) {
+	var students []models.Student
+	if err := db.Select("id", "usn", "phone").Where("phone <> ''").Order("id").Find(&students).Error; err != nil {
+		return nil, err
+	}
+
+	var fixes []PhoneFix
+	for _, student := range students {
+		fixed, err := utils.NormalizePhone(student.Phone, region)
+		if err == nil && fixed == student.Phone {
+			continue
+		}
+		fixes = append(fixes, PhoneFix{StudentID: student.ID, USN: student.USN, Phone: student.Phone, Fixed: fixed})
+		if apply && err == nil {
+			if err := db.Model(&models.Student{}).Where("id = ?", student.ID).Update("phone", fixed).Error; err != nil {
+				return fixes, err
+			}
+		}
+	}
+	return fixes, nil
+}
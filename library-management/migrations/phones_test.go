@@ -0,0 +1,63 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"library-management/database/databasetest"
+	"library-management/migrations"
+	"library-management/models"
+)
+
+func TestNormalizeStudentPhones(t *testing.T) {
+	db := databasetest.Open(t)
+	phones := map[string]string{
+		"1AB21CS001": "+919876543210",
+		"1AB21CS002": "98765 43211",
+		"1AB21CS003": "12345",
+		"1AB21CS004": "",
+	}
+	for usn, phone := range phones {
+		if err := db.Create(&models.Student{USN: usn, Name: usn, Phone: phone}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := func(usn string) string {
+		var student models.Student
+		db.First(&student, "usn = ?", usn)
+		return student.Phone
+	}
+
+	fixes, err := migrations.NormalizeStudentPhones(db, "IN", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"1AB21CS002": "+919876543211", "1AB21CS003": ""}
+	if len(fixes) != len(want) {
+		t.Fatalf("fixes = %+v", fixes)
+	}
+	for _, fix := range fixes {
+		if fixed, ok := want[fix.USN]; !ok || fix.Fixed != fixed || fix.Phone != phones[fix.USN] {
+			t.Errorf("fix = %+v", fix)
+		}
+	}
+	if phone := stored("1AB21CS002"); phone != "98765 43211" {
+		t.Errorf("a dry run stored %q", phone)
+	}
+
+	if _, err := migrations.NormalizeStudentPhones(db, "IN", true); err != nil {
+		t.Fatal(err)
+	}
+	for usn, want := range map[string]string{
+		"1AB21CS001": "+919876543210",
+		"1AB21CS002": "+919876543211",
+		"1AB21CS003": "12345", // Unreadable numbers are only reported
+		"1AB21CS004": "",
+	} {
+		if phone := stored(usn); phone != want {
+			t.Errorf("%s: phone %q, want %q", usn, phone, want)
+		}
+	}
+	if fixes, _ := migrations.NormalizeStudentPhones(db, "IN", false); len(fixes) != 1 || fixes[0].USN != "1AB21CS003" {
+		t.Errorf("after applying: %+v", fixes)
+	}
+}
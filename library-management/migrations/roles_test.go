@@ -0,0 +1,89 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"library-management/database/databasetest"
+	"library-management/migrations"
+	"library-management/models"
+)
+
+// rolesOf returns the names of the roles each user holds
+func rolesOf(t *testing.T, db *gorm.DB) map[string][]string {
+	t.Helper()
+	var users []models.User
+	if err := db.Preload("Roles").Order("id").Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	roles := map[string][]string{}
+	for _, user := range users {
+		roles[user.Username] = []string{}
+		for _, role := range user.Roles {
+			roles[user.Username] = append(roles[user.Username], role.Name)
+		}
+	}
+	return roles
+}
+
+func TestUsersFromBeforeRolesAreGivenRoles(t *testing.T) {
+	db := databasetest.Open(t)
+	if _, err := migrations.To(db, 5); err != nil {
+		t.Fatal(err)
+	}
+	usn := "1AB21CS001"
+	for _, user := range []models.User{
+		{Username: "librarian", Password: "hash"},
+		{Username: "asha", Password: "hash", StudentUSN: &usn},
+	} {
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	roles := rolesOf(t, db)
+	if len(roles["librarian"]) != 1 || roles["librarian"][0] != models.RoleAdmin {
+		t.Errorf("librarian has roles %v, want admin", roles["librarian"])
+	}
+	if len(roles["asha"]) != 1 || roles["asha"][0] != models.RoleStudent {
+		t.Errorf("asha has roles %v, want student", roles["asha"])
+	}
+}
+
+func TestInstallsUsingRolesAreLeftAlone(t *testing.T) {
+	db := databasetest.Open(t)
+	if _, err := migrations.To(db, 5); err != nil {
+		t.Fatal(err)
+	}
+	auditor := models.Role{Name: models.RoleAuditor}
+	if err := db.Create(&auditor).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []models.User{
+		{Username: "audit", Password: "hash", Roles: []models.Role{auditor}},
+		{Username: "disabled", Password: "hash"},
+	} {
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+	roles := rolesOf(t, db)
+	if len(roles["audit"]) != 1 || roles["audit"][0] != models.RoleAuditor || len(roles["disabled"]) != 0 {
+		t.Errorf("roles changed to %v", roles)
+	}
+
+	// The migration can be rolled back and applied again
+	if _, err := migrations.To(db, 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+}
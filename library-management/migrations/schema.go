@@ -0,0 +1,368 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/models"
+)
+
+// Schema changes live in sql/<dialect>/NNNN_name.up.sql with a matching
+// .down.sql that undoes them. Versions must only ever be added, never
+// edited once released.
+//
+//go:embed sql
+var migrationFiles embed.FS
+
+// ErrSchemaBehind is returned when the database is missing migrations that
+// this build needs
+var ErrSchemaBehind = errors.New("database schema is out of date")
+
+// Migration is one versioned schema change and its rollback
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationStatus is a migration and when it was applied, if it was
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool // Applied to the database but not part of this build
+}
+
+// Available returns the migrations for the database's dialect, oldest first
+func Available(db *gorm.DB) ([]Migration, error) {
+	dir := path.Join("sql", db.Dialector.Name())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for the %s database: %w", db.Dialector.Name(), err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		number, label, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(number, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s is not named NNNN_name.%s.sql", name, direction)
+		}
+		body, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if m.Name != label {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// applied returns the migrations recorded in the database by version
+func applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[int64]SchemaMigration{}, nil
+	}
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// prepare creates the schema_migrations table. Databases that were set up
+// by AutoMigrate before versioned migrations existed are brought up to the
+// baseline and recorded as version 1, so their data is kept.
+func prepare(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	legacy := migrator.HasTable("students") || migrator.HasTable("books")
+	if err := migrator.CreateTable(&SchemaMigration{}); err != nil {
+		return err
+	}
+	if !legacy {
+		return nil
+	}
+	return adoptLegacySchema(db)
+}
+
+// adoptLegacySchema runs the upgrades the server used to apply at startup
+// and marks the baseline as applied
+func adoptLegacySchema(db *gorm.DB) error {
+	if err := SplitBookCopies(db); err != nil {
+		return fmt.Errorf("splitting book copies: %w", err)
+	}
+	if err := db.AutoMigrate(baselineModels...); err != nil {
+		return err
+	}
+	if err := ConvertMoneyToMinorUnits(db); err != nil {
+		return fmt.Errorf("converting money columns: %w", err)
+	}
+	return db.Create(&SchemaMigration{Version: 1, Name: "initial_schema", AppliedAt: time.Now()}).Error
+}
+
+// baselineModels are the tables of migration 1. Models that later
+// migrations change are frozen below as they were at version 1, so an
+// adopted database can still take those migrations.
+var baselineModels = []interface{}{
+	&models.Student{},
+	&baselineBook{},
+	&models.Copy{},
+	&models.Vendor{},
+	&models.Transaction{},
+	&models.User{},
+	&models.Session{},
+	&models.Role{},
+	&models.Permission{},
+	&models.CirculationRule{},
+	&models.Hold{},
+	&models.TransactionEvent{},
+	&models.LedgerEntry{},
+	&models.OpeningHours{},
+	&models.Holiday{},
+	&models.JobLock{},
+	&models.JobRun{},
+	&models.OutboxMessage{},
+	&models.MessageTemplate{},
+}
+
+// baselineBook is models.Book at version 1, before ISBNs and stored ebook
+// files
+type baselineBook struct {
+	ID            uint   `gorm:"primaryKey"`
+	Title         string `gorm:"not null"`
+	Subtitle      string
+	Author        string `gorm:"not null"`
+	Edition       int    `gorm:"not null"`
+	Publisher     string
+	PublisherYear int `gorm:"not null"`
+	Note          string
+	EBookPDF      []byte
+
+	Copies []models.Copy `gorm:"foreignKey:BookID;references:ID"`
+}
+
+func (baselineBook) TableName() string { return "books" }
+
+// Status lists every migration of this build and any unknown ones found in
+// the database, by version
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Available(db)
+	if err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(done, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range done {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Up applies every pending migration. It returns the migrations it applied.
+func Up(db *gorm.DB) ([]Migration, error) {
+	migrations, err := Available(db)
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+	return To(db, migrations[len(migrations)-1].Version)
+}
+
+// Down rolls back the given number of most recent migrations
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := prepare(db); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(done))
+	for version := range done {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if steps > len(versions) {
+		steps = len(versions)
+	}
+	if steps <= 0 {
+		return nil, nil
+	}
+
+	// Roll back to just below the oldest migration being undone
+	target := int64(0)
+	if steps < len(versions) {
+		target = versions[steps]
+	}
+	return To(db, target)
+}
+
+// To applies or rolls back migrations until exactly those up to version are
+// applied. Each migration runs in its own transaction. It returns the
+// migrations it ran, in the order it ran them.
+func To(db *gorm.DB, version int64) ([]Migration, error) {
+	migrations, err := Available(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepare(db); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int64]Migration{}
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	if _, ok := known[version]; !ok && version != 0 {
+		return nil, fmt.Errorf("there is no migration %d", version)
+	}
+	for v := range done {
+		if _, ok := known[v]; !ok && v > version {
+			return nil, fmt.Errorf("migration %d is applied but not part of this build; roll it back with the build that added it", v)
+		}
+	}
+
+	var ran []Migration
+
+	// Roll back newer migrations first, newest first
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= version {
+			break
+		}
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return ran, fmt.Errorf("migration %04d_%s cannot be rolled back: it has no down file", m.Version, m.Name)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("rolling back %04d_%s: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+
+	// Then apply missing ones, oldest first
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("applying %04d_%s: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// CheckSchema returns ErrSchemaBehind if any migration of this build has not
+// been applied to the database
+func CheckSchema(db *gorm.DB) error {
+	statuses, err := Status(db)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s not applied; run \"library-management migrate up\"", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS "message_templates";
+DROP TABLE IF EXISTS "notification_outbox";
+DROP TABLE IF EXISTS "job_runs";
+DROP TABLE IF EXISTS "job_locks";
+DROP TABLE IF EXISTS "holidays";
+DROP TABLE IF EXISTS "opening_hours";
+DROP TABLE IF EXISTS "ledger_entries";
+DROP TABLE IF EXISTS "transaction_events";
+DROP TABLE IF EXISTS "holds";
+DROP TABLE IF EXISTS "circulation_rules";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "permissions";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "user_roles";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "transactions";
+DROP TABLE IF EXISTS "vendors";
+DROP TABLE IF EXISTS "copies";
+DROP TABLE IF EXISTS "books";
+DROP TABLE IF EXISTS "students";
@@ -0,0 +1,266 @@
+-- Baseline schema: the tables the server created with AutoMigrate
+-- before versioned migrations were introduced.
+
+CREATE TABLE "students" (
+    "id" bigserial,
+    "name" text,
+    "usn" text,
+    "email" text,
+    "phone" text,
+    "address" text,
+    "admission_year" bigint,
+    "department" text,
+    "category" text,
+    "notify_channels" text,
+    "language" text,
+    "registered_at" timestamptz,
+    "expiry_date" timestamptz,
+    "remark" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_students_usn" UNIQUE ("usn")
+);
+
+CREATE TABLE "books" (
+    "id" bigserial,
+    "title" text NOT NULL,
+    "subtitle" text,
+    "author" text NOT NULL,
+    "edition" bigint NOT NULL,
+    "publisher" text,
+    "publisher_year" bigint NOT NULL,
+    "note" text,
+    "e_book_pdf" bytea,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "copies" (
+    "id" bigserial,
+    "book_id" bigint NOT NULL,
+    "serial_number" text NOT NULL,
+    "rack_number" text NOT NULL,
+    "vendor_id" bigint NOT NULL,
+    "status" text NOT NULL DEFAULT 'available',
+    "item_type" text NOT NULL DEFAULT 'general',
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_books_copies" FOREIGN KEY ("book_id") REFERENCES "books"("id"),
+    CONSTRAINT "uni_copies_serial_number" UNIQUE ("serial_number")
+);
+CREATE INDEX IF NOT EXISTS "idx_copies_book_id" ON "copies" ("book_id");
+
+CREATE TABLE "vendors" (
+    "id" bigserial,
+    "vendor_name" text,
+    "contact" text,
+    "email" text,
+    "address" text,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "transactions" (
+    "id" bigserial,
+    "student_usn" text NOT NULL,
+    "copy_id" bigint NOT NULL,
+    "issue_date" timestamptz,
+    "due_date" timestamptz,
+    "return_date" timestamptz,
+    "late_fee_minor" bigint NOT NULL DEFAULT 0,
+    "renewal_count" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transactions_copy" FOREIGN KEY ("copy_id") REFERENCES "copies"("id"),
+    CONSTRAINT "fk_transactions_student" FOREIGN KEY ("student_usn") REFERENCES "students"("usn")
+);
+
+CREATE TABLE "users" (
+    "id" bigserial,
+    "username" text NOT NULL,
+    "password" text NOT NULL,
+    "student_usn" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_username" UNIQUE ("username")
+);
+
+CREATE TABLE "roles" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "description" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_roles_name" UNIQUE ("name")
+);
+
+CREATE TABLE "user_roles" (
+    "user_id" bigint,
+    "role_id" bigint,
+    PRIMARY KEY ("user_id","role_id"),
+    CONSTRAINT "fk_user_roles_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_user_roles_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id")
+);
+
+CREATE TABLE "sessions" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_id" text NOT NULL,
+    "expires_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "uni_sessions_token_id" UNIQUE ("token_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE TABLE "permissions" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_permissions_name" UNIQUE ("name")
+);
+
+CREATE TABLE "role_permissions" (
+    "role_id" bigint,
+    "permission_id" bigint,
+    PRIMARY KEY ("role_id","permission_id"),
+    CONSTRAINT "fk_role_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions"("id"),
+    CONSTRAINT "fk_role_permissions_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id")
+);
+
+CREATE TABLE "circulation_rules" (
+    "id" bigserial,
+    "member_category" text,
+    "department" text,
+    "item_type" text,
+    "loan_period_days" bigint NOT NULL,
+    "max_loans" bigint NOT NULL,
+    "max_renewals" bigint NOT NULL,
+    "renewal_overdue_limit_days" bigint NOT NULL DEFAULT 0,
+    "fine_per_day_minor" bigint NOT NULL DEFAULT 0,
+    "fine_cap_minor" bigint NOT NULL DEFAULT 0,
+    "note" text,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "holds" (
+    "id" bigserial,
+    "student_usn" text NOT NULL,
+    "book_id" bigint NOT NULL,
+    "status" text NOT NULL,
+    "copy_id" bigint,
+    "placed_at" timestamptz,
+    "ready_at" timestamptz,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_holds_student" FOREIGN KEY ("student_usn") REFERENCES "students"("usn"),
+    CONSTRAINT "fk_holds_book" FOREIGN KEY ("book_id") REFERENCES "books"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_holds_status" ON "holds" ("status");
+CREATE INDEX IF NOT EXISTS "idx_holds_book_id" ON "holds" ("book_id");
+CREATE INDEX IF NOT EXISTS "idx_holds_student_usn" ON "holds" ("student_usn");
+
+CREATE TABLE "transaction_events" (
+    "id" bigserial,
+    "transaction_id" bigint NOT NULL,
+    "type" text NOT NULL,
+    "old_due_date" timestamptz,
+    "new_due_date" timestamptz,
+    "user_id" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transaction_events_transaction_id" ON "transaction_events" ("transaction_id");
+
+CREATE TABLE "ledger_entries" (
+    "id" bigserial,
+    "student_usn" text NOT NULL,
+    "transaction_id" bigint,
+    "type" text NOT NULL,
+    "amount_minor" bigint NOT NULL,
+    "reason" text,
+    "payment_method" text,
+    "reference" text,
+    "approved_by_user_id" bigint,
+    "recorded_by_user_id" bigint,
+    "receipt_number" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_ledger_entries_receipt_number" UNIQUE ("receipt_number")
+);
+CREATE INDEX IF NOT EXISTS "idx_ledger_entries_transaction_id" ON "ledger_entries" ("transaction_id");
+CREATE INDEX IF NOT EXISTS "idx_ledger_entries_student_usn" ON "ledger_entries" ("student_usn");
+
+CREATE TABLE "opening_hours" (
+    "id" bigserial,
+    "weekday" bigint NOT NULL,
+    "opens" text,
+    "closes" text,
+    "closed" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_opening_hours_weekday" UNIQUE ("weekday")
+);
+
+CREATE TABLE "holidays" (
+    "id" bigserial,
+    "date" varchar(10) NOT NULL,
+    "name" text,
+    "source" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_holidays_date" UNIQUE ("date")
+);
+
+CREATE TABLE "job_locks" (
+    "name" text,
+    "owner" text,
+    "locked_until" timestamptz,
+    PRIMARY KEY ("name")
+);
+
+CREATE TABLE "job_runs" (
+    "id" bigserial,
+    "job_name" text NOT NULL,
+    "trigger" text NOT NULL,
+    "status" text NOT NULL,
+    "message" text,
+    "owner" text,
+    "user_id" bigint,
+    "started_at" timestamptz,
+    "finished_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_job_runs_job_name" ON "job_runs" ("job_name");
+
+CREATE TABLE "notification_outbox" (
+    "id" bigserial,
+    "student_usn" text NOT NULL,
+    "recipient_name" text,
+    "phone" text,
+    "email" text,
+    "channel" text NOT NULL,
+    "template" text NOT NULL,
+    "transaction_id" bigint,
+    "subject" text,
+    "body" text,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz,
+    "last_error" text,
+    "provider_message_id" text,
+    "dedup_key" text,
+    "created_at" timestamptz,
+    "sent_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_notification_outbox_dedup_key" UNIQUE ("dedup_key")
+);
+CREATE INDEX IF NOT EXISTS "idx_notification_outbox_student_usn" ON "notification_outbox" ("student_usn");
+CREATE INDEX IF NOT EXISTS "idx_notification_outbox_next_attempt_at" ON "notification_outbox" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_notification_outbox_status" ON "notification_outbox" ("status");
+CREATE INDEX IF NOT EXISTS "idx_notification_outbox_transaction_id" ON "notification_outbox" ("transaction_id");
+
+CREATE TABLE "message_templates" (
+    "id" bigserial,
+    "key" text NOT NULL,
+    "language" text NOT NULL,
+    "subject" text,
+    "body" text NOT NULL,
+    "updated_by_user_id" bigint,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_message_template_key_language" ON "message_templates" ("key","language");
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "import_errors";
+DROP TABLE IF EXISTS "import_jobs";
+DROP INDEX IF EXISTS "idx_books_isbn";
+ALTER TABLE "books" DROP COLUMN IF EXISTS "isbn";
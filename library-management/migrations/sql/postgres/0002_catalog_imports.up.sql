@@ -0,0 +1,33 @@
+-- Catalog imports: ISBNs on books, and background import jobs with the
+-- records they could not import.
+
+ALTER TABLE "books" ADD COLUMN "isbn" text;
+CREATE INDEX IF NOT EXISTS "idx_books_isbn" ON "books" ("isbn");
+
+CREATE TABLE "import_jobs" (
+    "id" bigserial,
+    "filename" text,
+    "format" text NOT NULL,
+    "status" text NOT NULL,
+    "message" text,
+    "total" bigint NOT NULL DEFAULT 0,
+    "processed" bigint NOT NULL DEFAULT 0,
+    "books" bigint NOT NULL DEFAULT 0,
+    "copies" bigint NOT NULL DEFAULT 0,
+    "skipped" bigint NOT NULL DEFAULT 0,
+    "failed" bigint NOT NULL DEFAULT 0,
+    "user_id" bigint,
+    "created_at" timestamptz,
+    "started_at" timestamptz,
+    "finished_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE "import_errors" (
+    "id" bigserial,
+    "import_job_id" bigint NOT NULL,
+    "record" text NOT NULL,
+    "message" text NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_import_errors_import_job_id" ON "import_errors" ("import_job_id");
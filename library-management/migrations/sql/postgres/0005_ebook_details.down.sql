@@ -0,0 +1,3 @@
+ALTER TABLE "books" DROP COLUMN IF EXISTS "ebook_author";
+ALTER TABLE "books" DROP COLUMN IF EXISTS "ebook_title";
+ALTER TABLE "books" DROP COLUMN IF EXISTS "ebook_pages";
@@ -0,0 +1,6 @@
+-- The page count, title and author read from uploaded ebook files. Files
+-- uploaded before this have none.
+
+ALTER TABLE "books" ADD COLUMN "ebook_pages" integer;
+ALTER TABLE "books" ADD COLUMN "ebook_title" text;
+ALTER TABLE "books" ADD COLUMN "ebook_author" text;
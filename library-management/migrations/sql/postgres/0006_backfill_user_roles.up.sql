@@ -0,0 +1,18 @@
+-- Accounts created before roles existed have none, and /setup refuses once
+-- any account exists, so an upgraded install would be locked out. If no
+-- account holds a role yet, accounts linked to a student become students
+-- and the others admins. Installs that already use roles are left alone.
+-- The roles get their permissions when the server starts.
+
+INSERT INTO "roles" ("name")
+SELECT 'admin' WHERE NOT EXISTS (SELECT 1 FROM "roles" WHERE "name" = 'admin');
+INSERT INTO "roles" ("name")
+SELECT 'student' WHERE NOT EXISTS (SELECT 1 FROM "roles" WHERE "name" = 'student');
+
+INSERT INTO "user_roles" ("user_id", "role_id")
+SELECT "users"."id", "roles"."id"
+FROM "users" JOIN "roles" ON "roles"."name" = CASE
+    WHEN COALESCE("users"."student_usn", '') = '' THEN 'admin'
+    ELSE 'student'
+END
+WHERE NOT EXISTS (SELECT 1 FROM "user_roles");
@@ -0,0 +1,248 @@
+-- Baseline schema: the tables the server created with AutoMigrate
+-- before versioned migrations were introduced.
+
+CREATE TABLE `students` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text,
+    `usn` text,
+    `email` text,
+    `phone` text,
+    `address` text,
+    `admission_year` integer,
+    `department` text,
+    `category` text,
+    `notify_channels` text,
+    `language` text,
+    `registered_at` datetime,
+    `expiry_date` datetime,
+    `remark` text,
+    CONSTRAINT `uni_students_usn` UNIQUE (`usn`)
+);
+
+CREATE TABLE `books` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `title` text NOT NULL,
+    `subtitle` text,
+    `author` text NOT NULL,
+    `edition` integer NOT NULL,
+    `publisher` text,
+    `publisher_year` integer NOT NULL,
+    `note` text,
+    `e_book_pdf` blob
+);
+
+CREATE TABLE `copies` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `book_id` integer NOT NULL,
+    `serial_number` text NOT NULL,
+    `rack_number` text NOT NULL,
+    `vendor_id` integer NOT NULL,
+    `status` text NOT NULL DEFAULT 'available',
+    `item_type` text NOT NULL DEFAULT 'general',
+    CONSTRAINT `fk_books_copies` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`),
+    CONSTRAINT `uni_copies_serial_number` UNIQUE (`serial_number`)
+);
+CREATE INDEX `idx_copies_book_id` ON `copies`(`book_id`);
+
+CREATE TABLE `vendors` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `vendor_name` text,
+    `contact` text,
+    `email` text,
+    `address` text
+);
+
+CREATE TABLE `transactions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `student_usn` text NOT NULL,
+    `copy_id` integer NOT NULL,
+    `issue_date` datetime,
+    `due_date` datetime,
+    `return_date` datetime,
+    `late_fee_minor` integer NOT NULL DEFAULT 0,
+    `renewal_count` integer NOT NULL DEFAULT 0,
+    CONSTRAINT `fk_transactions_student` FOREIGN KEY (`student_usn`) REFERENCES `students`(`usn`),
+    CONSTRAINT `fk_transactions_copy` FOREIGN KEY (`copy_id`) REFERENCES `copies`(`id`)
+);
+
+CREATE TABLE `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `username` text NOT NULL,
+    `password` text NOT NULL,
+    `student_usn` text,
+    CONSTRAINT `uni_users_username` UNIQUE (`username`)
+);
+
+CREATE TABLE `roles` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    `description` text,
+    CONSTRAINT `uni_roles_name` UNIQUE (`name`)
+);
+
+CREATE TABLE `user_roles` (
+    `user_id` integer,
+    `role_id` integer,
+    PRIMARY KEY (`user_id`,`role_id`),
+    CONSTRAINT `fk_user_roles_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+    CONSTRAINT `fk_user_roles_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`)
+);
+
+CREATE TABLE `sessions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `token_id` text NOT NULL,
+    `expires_at` datetime,
+    `revoked_at` datetime,
+    `created_at` datetime,
+    CONSTRAINT `fk_sessions_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),
+    CONSTRAINT `uni_sessions_token_id` UNIQUE (`token_id`)
+);
+CREATE INDEX `idx_sessions_user_id` ON `sessions`(`user_id`);
+
+CREATE TABLE `permissions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    CONSTRAINT `uni_permissions_name` UNIQUE (`name`)
+);
+
+CREATE TABLE `role_permissions` (
+    `role_id` integer,
+    `permission_id` integer,
+    PRIMARY KEY (`role_id`,`permission_id`),
+    CONSTRAINT `fk_role_permissions_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`),
+    CONSTRAINT `fk_role_permissions_permission` FOREIGN KEY (`permission_id`) REFERENCES `permissions`(`id`)
+);
+
+CREATE TABLE `circulation_rules` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `member_category` text,
+    `department` text,
+    `item_type` text,
+    `loan_period_days` integer NOT NULL,
+    `max_loans` integer NOT NULL,
+    `max_renewals` integer NOT NULL,
+    `renewal_overdue_limit_days` integer NOT NULL DEFAULT 0,
+    `fine_per_day_minor` integer NOT NULL DEFAULT 0,
+    `fine_cap_minor` integer NOT NULL DEFAULT 0,
+    `note` text
+);
+
+CREATE TABLE `holds` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `student_usn` text NOT NULL,
+    `book_id` integer NOT NULL,
+    `status` text NOT NULL,
+    `copy_id` integer,
+    `placed_at` datetime,
+    `ready_at` datetime,
+    `expires_at` datetime,
+    CONSTRAINT `fk_holds_student` FOREIGN KEY (`student_usn`) REFERENCES `students`(`usn`),
+    CONSTRAINT `fk_holds_book` FOREIGN KEY (`book_id`) REFERENCES `books`(`id`)
+);
+CREATE INDEX `idx_holds_book_id` ON `holds`(`book_id`);
+CREATE INDEX `idx_holds_student_usn` ON `holds`(`student_usn`);
+CREATE INDEX `idx_holds_status` ON `holds`(`status`);
+
+CREATE TABLE `transaction_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `transaction_id` integer NOT NULL,
+    `type` text NOT NULL,
+    `old_due_date` datetime,
+    `new_due_date` datetime,
+    `user_id` integer,
+    `created_at` datetime
+);
+CREATE INDEX `idx_transaction_events_transaction_id` ON `transaction_events`(`transaction_id`);
+
+CREATE TABLE `ledger_entries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `student_usn` text NOT NULL,
+    `transaction_id` integer,
+    `type` text NOT NULL,
+    `amount_minor` integer NOT NULL,
+    `reason` text,
+    `payment_method` text,
+    `reference` text,
+    `approved_by_user_id` integer,
+    `recorded_by_user_id` integer,
+    `receipt_number` text,
+    `created_at` datetime,
+    CONSTRAINT `uni_ledger_entries_receipt_number` UNIQUE (`receipt_number`)
+);
+CREATE INDEX `idx_ledger_entries_transaction_id` ON `ledger_entries`(`transaction_id`);
+CREATE INDEX `idx_ledger_entries_student_usn` ON `ledger_entries`(`student_usn`);
+
+CREATE TABLE `opening_hours` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `weekday` integer NOT NULL,
+    `opens` text,
+    `closes` text,
+    `closed` numeric NOT NULL DEFAULT false,
+    CONSTRAINT `uni_opening_hours_weekday` UNIQUE (`weekday`)
+);
+
+CREATE TABLE `holidays` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `date` text NOT NULL,
+    `name` text,
+    `source` text,
+    CONSTRAINT `uni_holidays_date` UNIQUE (`date`)
+);
+
+CREATE TABLE `job_locks` (
+    `name` text,
+    `owner` text,
+    `locked_until` datetime,
+    PRIMARY KEY (`name`)
+);
+
+CREATE TABLE `job_runs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `job_name` text NOT NULL,
+    `trigger` text NOT NULL,
+    `status` text NOT NULL,
+    `message` text,
+    `owner` text,
+    `user_id` integer,
+    `started_at` datetime,
+    `finished_at` datetime
+);
+CREATE INDEX `idx_job_runs_job_name` ON `job_runs`(`job_name`);
+
+CREATE TABLE `notification_outbox` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `student_usn` text NOT NULL,
+    `recipient_name` text,
+    `phone` text,
+    `email` text,
+    `channel` text NOT NULL,
+    `template` text NOT NULL,
+    `transaction_id` integer,
+    `subject` text,
+    `body` text,
+    `status` text NOT NULL DEFAULT 'pending',
+    `attempts` integer NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime,
+    `last_error` text,
+    `provider_message_id` text,
+    `dedup_key` text,
+    `created_at` datetime,
+    `sent_at` datetime,
+    CONSTRAINT `uni_notification_outbox_dedup_key` UNIQUE (`dedup_key`)
+);
+CREATE INDEX `idx_notification_outbox_transaction_id` ON `notification_outbox`(`transaction_id`);
+CREATE INDEX `idx_notification_outbox_student_usn` ON `notification_outbox`(`student_usn`);
+CREATE INDEX `idx_notification_outbox_next_attempt_at` ON `notification_outbox`(`next_attempt_at`);
+CREATE INDEX `idx_notification_outbox_status` ON `notification_outbox`(`status`);
+
+CREATE TABLE `message_templates` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `key` text NOT NULL,
+    `language` text NOT NULL,
+    `subject` text,
+    `body` text NOT NULL,
+    `updated_by_user_id` integer,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_message_template_key_language` ON `message_templates`(`key`,`language`);
@@ -0,0 +1,31 @@
+-- Catalog imports: ISBNs on books, and background import jobs with the
+-- records they could not import.
+
+ALTER TABLE `books` ADD COLUMN `isbn` text;
+CREATE INDEX `idx_books_isbn` ON `books`(`isbn`);
+
+CREATE TABLE `import_jobs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `filename` text,
+    `format` text NOT NULL,
+    `status` text NOT NULL,
+    `message` text,
+    `total` integer NOT NULL DEFAULT 0,
+    `processed` integer NOT NULL DEFAULT 0,
+    `books` integer NOT NULL DEFAULT 0,
+    `copies` integer NOT NULL DEFAULT 0,
+    `skipped` integer NOT NULL DEFAULT 0,
+    `failed` integer NOT NULL DEFAULT 0,
+    `user_id` integer,
+    `created_at` datetime,
+    `started_at` datetime,
+    `finished_at` datetime
+);
+
+CREATE TABLE `import_errors` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `import_job_id` integer NOT NULL,
+    `record` text NOT NULL,
+    `message` text NOT NULL
+);
+CREATE INDEX `idx_import_errors_import_job_id` ON `import_errors`(`import_job_id`);
@@ -0,0 +1,7 @@
+-- Files already in the blob store are not copied back; books lose track of
+-- them.
+ALTER TABLE `books` DROP COLUMN `ebook_uploaded_at`;
+ALTER TABLE `books` DROP COLUMN `ebook_mime_type`;
+ALTER TABLE `books` DROP COLUMN `ebook_sha256`;
+ALTER TABLE `books` DROP COLUMN `ebook_size`;
+ALTER TABLE `books` DROP COLUMN `ebook_key`;
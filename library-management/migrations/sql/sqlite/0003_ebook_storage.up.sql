@@ -0,0 +1,9 @@
+-- Ebook files move from the books table into the blob store; the table
+-- keeps their metadata. e_book_pdf stays until "libraryctl migrate up" or
+-- "libraryctl move-ebooks" has copied the old files out and emptied it.
+
+ALTER TABLE `books` ADD COLUMN `ebook_key` text;
+ALTER TABLE `books` ADD COLUMN `ebook_size` integer;
+ALTER TABLE `books` ADD COLUMN `ebook_sha256` text;
+ALTER TABLE `books` ADD COLUMN `ebook_mime_type` text;
+ALTER TABLE `books` ADD COLUMN `ebook_uploaded_at` datetime;
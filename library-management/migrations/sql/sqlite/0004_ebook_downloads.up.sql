@@ -0,0 +1,16 @@
+-- Log of ebook downloads through signed links, which also counts them
+-- against each student's daily quota.
+
+CREATE TABLE `ebook_downloads` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `book_id` integer NOT NULL,
+    `student_id` integer NOT NULL,
+    `student_usn` text NOT NULL,
+    `signature` text NOT NULL,
+    `ip` text,
+    `user_agent` text,
+    `downloaded_at` datetime NOT NULL
+);
+CREATE UNIQUE INDEX `idx_ebook_downloads_signature` ON `ebook_downloads`(`signature`);
+CREATE INDEX `idx_ebook_downloads_book_id` ON `ebook_downloads`(`book_id`);
+CREATE INDEX `idx_ebook_downloads_student_day` ON `ebook_downloads`(`student_id`, `downloaded_at`);
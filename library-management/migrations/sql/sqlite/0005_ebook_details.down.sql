@@ -0,0 +1,3 @@
+ALTER TABLE `books` DROP COLUMN `ebook_author`;
+ALTER TABLE `books` DROP COLUMN `ebook_title`;
+ALTER TABLE `books` DROP COLUMN `ebook_pages`;
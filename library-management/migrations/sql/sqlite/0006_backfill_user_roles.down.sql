@@ -0,0 +1,3 @@
+-- The roles given to existing accounts cannot be told apart from roles
+-- given since, so they are kept.
+SELECT 1;
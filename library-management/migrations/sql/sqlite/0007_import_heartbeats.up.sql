@@ -0,0 +1,6 @@
+-- Imports record when their server last reported progress, so that a
+-- server starting up only fails the imports nobody is running any more.
+-- Unfinished imports from before this count from when they started.
+
+ALTER TABLE `import_jobs` ADD COLUMN `heartbeat_at` datetime;
+UPDATE `import_jobs` SET `heartbeat_at` = COALESCE(`started_at`, `created_at`) WHERE `status` IN ('queued', 'running');
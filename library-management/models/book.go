@@ -1,5 +1,7 @@
 package models
 
+// Book is the bibliographic record of a title. The physical items the
+// library owns are tracked separately as Copy rows.
 type Book struct {
     ID            uint   `gorm:"primaryKey"`
     Title         string `gorm:"not null"`
@@ -8,10 +10,10 @@ type Book struct {
     Edition       int    `gorm:"not null"`         // Changed to int
     Publisher     string
     PublisherYear int    `gorm:"not null"`         // Changed to int
-    VendorID      uint   `gorm:"not null"`         // Changed to uint
-    SerialNumber  string `gorm:"unique;not null"`
-    RackNumber    string `gorm:"not null"`
     Note          string
-    EBookPDF      []byte
+    ISBN          string `gorm:"index"`
+    EBook         EBookFile `gorm:"embedded;embeddedPrefix:ebook_"` // The file itself is in the blob store
+
+    Copies []Copy `gorm:"foreignKey:BookID;references:ID"`
 }
 
@@ -0,0 +1,18 @@
+package models
+
+// OpeningHours are the regular hours of the library on one day of the week
+type OpeningHours struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	Weekday int    `gorm:"unique;not null" json:"weekday"` // 0 = Sunday ... 6 = Saturday
+	Opens   string `json:"opens"`                          // "09:00"
+	Closes  string `json:"closes"`                         // "17:30"
+	Closed  bool   `gorm:"not null;default:false" json:"closed"`
+}
+
+// Holiday is a dated closure such as a festival or exam break
+type Holiday struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	Date   string `gorm:"unique;not null;size:10" json:"date"` // YYYY-MM-DD
+	Name   string `json:"name"`
+	Source string `json:"source"` // "manual" or the name of the imported calendar
+}
@@ -0,0 +1,33 @@
+package models
+
+// Member categories a student can belong to
+const (
+	CategoryUG      = "UG"
+	CategoryPG      = "PG"
+	CategoryFaculty = "faculty"
+)
+
+// Item types a copy can have
+const (
+	ItemGeneral   = "general"
+	ItemReference = "reference"
+	ItemReserve   = "reserve"
+)
+
+// CirculationRule sets the loan terms for a combination of member category,
+// department and item type. An empty match field applies to any value, and
+// the most specific matching rule wins.
+type CirculationRule struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	MemberCategory string `json:"member_category"`
+	Department     string `json:"department"`
+	ItemType       string `json:"item_type"`
+	LoanPeriodDays int    `gorm:"not null" json:"loan_period_days"` // 0 means the item cannot be borrowed
+	MaxLoans       int    `gorm:"not null" json:"max_loans"`        // Maximum open loans for the member
+	MaxRenewals    int    `gorm:"not null" json:"max_renewals"`
+	// Days past the due date a loan can still be renewed; 0 refuses overdue renewals
+	RenewalOverdueLimitDays int    `gorm:"not null;default:0" json:"renewal_overdue_limit_days"`
+	FinePerDayMinor         int64  `gorm:"not null;default:0" json:"fine_per_day_minor"` // In minor currency units
+	FineCapMinor            int64  `gorm:"not null;default:0" json:"fine_cap_minor"`     // 0 means no cap
+	Note                    string `json:"note"`
+}
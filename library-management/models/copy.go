@@ -0,0 +1,24 @@
+package models
+
+// Copy statuses
+const (
+	CopyAvailable = "available"
+	CopyIssued    = "issued"
+	CopyOnHold    = "on_hold" // Trapped on the hold shelf for a student
+	CopyLost      = "lost"
+	CopyDamaged   = "damaged"
+	CopyWithdrawn = "withdrawn"
+)
+
+// Copy is a physical item of a Book on the shelves
+type Copy struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	BookID       uint   `gorm:"not null;index" json:"book_id"` // Foreign key to Book.ID
+	SerialNumber string `gorm:"unique;not null" json:"serial_number"`
+	RackNumber   string `gorm:"not null" json:"rack_number"`
+	VendorID     uint   `gorm:"not null" json:"vendor_id"`
+	Status       string `gorm:"not null;default:available" json:"status"`
+	ItemType     string `gorm:"not null;default:general" json:"item_type"` // general, reference or reserve
+
+	Book *Book `gorm:"foreignKey:BookID;references:ID" json:"book,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// EBookFile describes a book's ebook file. The content is kept in the blob
+// store under Key; the database only has what is needed to serve it.
+type EBookFile struct {
+	Key        string     `json:"-"`
+	Size       int64      `json:"size,omitempty"`
+	SHA256     string     `json:"sha256,omitempty"` // Hex digest of the content
+	MIMEType   string     `json:"mime_type,omitempty"`
+	UploadedAt *time.Time `json:"uploaded_at,omitempty"`
+
+	// Read from the file itself when it is uploaded
+	Pages  int    `json:"pages,omitempty"` // 0 when the file does not say
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+// Present reports whether the book has an ebook file
+func (f EBookFile) Present() bool {
+	return f.Key != ""
+}
+
+// EBookDownload records a student downloading an ebook through a signed
+// link. A link is recorded once, however many range requests it serves.
+type EBookDownload struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	BookID       uint      `gorm:"not null;index" json:"book_id"`
+	StudentID    uint      `gorm:"not null" json:"student_id"`
+	StudentUSN   string    `gorm:"not null" json:"student_usn"`
+	Signature    string    `gorm:"not null;uniqueIndex" json:"-"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	DownloadedAt time.Time `gorm:"not null" json:"downloaded_at"`
+}
+
+func (EBookDownload) TableName() string { return "ebook_downloads" }
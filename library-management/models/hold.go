@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Hold statuses
+const (
+	HoldWaiting   = "waiting"   // In the queue for the title
+	HoldReady     = "ready"     // A copy is trapped on the hold shelf for pickup
+	HoldFulfilled = "fulfilled" // The trapped copy was issued to the student
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired" // The student did not pick up the copy in time
+)
+
+// Hold is a student's reservation of a title. Holds on a title are served
+// first in, first out.
+type Hold struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	StudentUSN string     `gorm:"not null;index" json:"student_usn"` // Foreign key to Student.USN
+	BookID     uint       `gorm:"not null;index" json:"book_id"`     // Foreign key to Book.ID (the title held)
+	Status     string     `gorm:"not null;index" json:"status"`
+	CopyID     *uint      `json:"copy_id"` // The trapped copy once the hold is ready
+	PlacedAt   time.Time  `json:"placed_at"`
+	ReadyAt    *time.Time `json:"ready_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // Pickup deadline for a ready hold
+
+	Student Student `gorm:"foreignKey:StudentUSN;references:USN" json:"student"`
+	Book    Book    `gorm:"foreignKey:BookID;references:ID" json:"book"`
+}
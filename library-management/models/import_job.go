@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// ImportQueued is the status of an import that has not started yet; running
+// imports use the job run statuses
+const ImportQueued = "queued"
+
+// Catalog import file formats
+const (
+	FormatCSV     = "csv"
+	FormatMARC    = "marc"    // MARC21 in ISO 2709 transmission format
+	FormatMARCXML = "marcxml" // MARC21 as MARCXML
+)
+
+// ImportJob tracks a catalog import running in the background
+type ImportJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Filename   string     `json:"filename"`
+	Format     string     `gorm:"not null" json:"format"`
+	Status     string     `gorm:"not null" json:"status"`
+	Message    string     `json:"message"`
+	Total      int        `gorm:"not null;default:0" json:"total"`     // Records in the file
+	Processed  int        `gorm:"not null;default:0" json:"processed"` // Records handled so far
+	Books      int        `gorm:"not null;default:0" json:"books"`     // New titles
+	Copies     int        `gorm:"not null;default:0" json:"copies"`    // New copies
+	Skipped    int        `gorm:"not null;default:0" json:"skipped"`   // Copies whose serial number already existed
+	Failed     int        `gorm:"not null;default:0" json:"failed"`    // Records that were not imported
+	UserID     *uint      `json:"user_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Updated while the import is queued or running; an old one means the
+	// server running it has stopped
+	HeartbeatAt *time.Time `json:"heartbeat_at,omitempty"`
+}
+
+// ImportError is a record of an import that could not be imported
+type ImportError struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	ImportJobID uint   `gorm:"not null;index" json:"import_job_id"`
+	Record      string `gorm:"not null" json:"record"` // Where the record is in the file, e.g. "line 12" or "record 7 (001 ocm123)"
+	Message     string `gorm:"not null" json:"message"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Job run statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// JobLock is a lease on a scheduled job. Only the replica holding an
+// unexpired lease runs the job.
+type JobLock struct {
+	Name        string    `gorm:"primaryKey" json:"name"`
+	Owner       string    `json:"owner"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// JobRun records one execution of a scheduled job
+type JobRun struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	JobName    string     `gorm:"index;not null" json:"job_name"`
+	Trigger    string     `gorm:"not null" json:"trigger"`
+	Status     string     `gorm:"not null" json:"status"`
+	Message    string     `json:"message"`
+	Owner      string     `json:"owner"`             // Replica that ran the job
+	UserID     *uint      `json:"user_id,omitempty"` // Set for manual runs
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Ledger entry types. Charges add to what a student owes; credits reduce it.
+const (
+	ChargeLateFee = "late_fee"
+	ChargeLost    = "lost"
+	ChargeDamaged = "damaged"
+	CreditPayment = "payment"
+	CreditWaiver  = "waiver"
+)
+
+// LedgerEntry is a charge or credit on a student's fine account. Amounts are
+// in minor currency units (paise): positive for charges, negative for
+// credits, so a student's balance is the sum of their entries.
+type LedgerEntry struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	StudentUSN       string    `gorm:"not null;index" json:"student_usn"` // Foreign key to Student.USN
+	TransactionID    *uint     `gorm:"index" json:"transaction_id"`       // The loan a charge relates to, if any
+	Type             string    `gorm:"not null" json:"type"`
+	AmountMinor      int64     `gorm:"not null" json:"amount_minor"`
+	Reason           string    `json:"reason"`
+	PaymentMethod    string    `json:"payment_method"`      // cash, upi, card, ... for payments
+	Reference        string    `json:"reference"`           // External receipt or transfer reference
+	ApprovedByUserID *uint     `json:"approved_by_user_id"` // The user who approved a waiver
+	RecordedByUserID uint      `json:"recorded_by_user_id"`
+	ReceiptNumber    string    `gorm:"unique" json:"receipt_number"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// IsCredit reports whether the entry reduces the balance
+func (e LedgerEntry) IsCredit() bool {
+	return e.Type == CreditPayment || e.Type == CreditWaiver
+}
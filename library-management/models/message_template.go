@@ -0,0 +1,107 @@
+package models
+
+import "time"
+
+// Template keys for the notifications the library sends
+const (
+	TemplateDueSoon   = "reminder_due_soon"
+	TemplateDueToday  = "reminder_due_today"
+	TemplateOverdue   = "reminder_overdue"
+	TemplateHoldReady = "hold_ready"
+	TemplateFine      = "fine_notice"
+)
+
+// Languages students can receive notifications in
+const (
+	LanguageEnglish = "en"
+	LanguageKannada = "kn"
+	LanguageHindi   = "hi"
+)
+
+// Languages lists the supported notification languages
+var Languages = []string{LanguageEnglish, LanguageKannada, LanguageHindi}
+
+// MessageTemplate is the editable text of one notification in one language.
+// Subject and Body are text/template sources with placeholders such as
+// {{.StudentName}}, {{.BookTitle}}, {{.SerialNumber}}, {{.DueDate}} and {{.Fine}}.
+type MessageTemplate struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Key             string    `gorm:"not null;uniqueIndex:idx_message_template_key_language" json:"key"`
+	Language        string    `gorm:"not null;uniqueIndex:idx_message_template_key_language" json:"language"`
+	Subject         string    `json:"subject"`
+	Body            string    `gorm:"not null" json:"body"`
+	UpdatedByUserID *uint     `json:"updated_by_user_id,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// DefaultTemplates are the built-in texts. They are seeded at startup and
+// restored when an edited template is reset.
+var DefaultTemplates = []MessageTemplate{
+	{Key: TemplateDueSoon, Language: LanguageEnglish,
+		Subject: "Library book due on {{.DueDate}}",
+		Body:    `Hello {{.StudentName}}, this is a reminder: "{{.BookTitle}}" (copy {{.SerialNumber}}) is due on {{.DueDate}}. Please return it on time.`},
+	{Key: TemplateDueToday, Language: LanguageEnglish,
+		Subject: "Library book due today",
+		Body:    `Hello {{.StudentName}}, "{{.BookTitle}}" (copy {{.SerialNumber}}) is due today ({{.DueDate}}). Please return it before the library closes.`},
+	{Key: TemplateOverdue, Language: LanguageEnglish,
+		Subject: "Library book overdue",
+		Body:    `Hello {{.StudentName}}, "{{.BookTitle}}" (copy {{.SerialNumber}}) was due on {{.DueDate}} and is overdue. Please return it as soon as possible to limit late fees.`},
+	{Key: TemplateHoldReady, Language: LanguageEnglish,
+		Subject: `Your hold on "{{.BookTitle}}" is ready`,
+		Body:    `Hello {{.StudentName}}, your hold on "{{.BookTitle}}" is ready for pickup. Please collect it by {{.PickupBy}}.`},
+	{Key: TemplateFine, Language: LanguageEnglish,
+		Subject: "Library fine of Rs. {{.Fine}}",
+		Body:    `Hello {{.StudentName}}, a fine of Rs. {{.Fine}} has been charged to your library account{{if .BookTitle}} for "{{.BookTitle}}"{{end}}. Your balance is Rs. {{.Balance}}.`},
+
+	{Key: TemplateDueSoon, Language: LanguageKannada,
+		Subject: "ಗ್ರಂಥಾಲಯ ಪುಸ್ತಕವನ್ನು {{.DueDate}} ರಂದು ಹಿಂದಿರುಗಿಸಬೇಕು",
+		Body:    `ನಮಸ್ಕಾರ {{.StudentName}}, ಜ್ಞಾಪನೆ: "{{.BookTitle}}" (ಪ್ರತಿ {{.SerialNumber}}) ಅನ್ನು {{.DueDate}} ರಂದು ಹಿಂದಿರುಗಿಸಬೇಕು. ದಯವಿಟ್ಟು ಸಮಯಕ್ಕೆ ಸರಿಯಾಗಿ ಹಿಂದಿರುಗಿಸಿ.`},
+	{Key: TemplateDueToday, Language: LanguageKannada,
+		Subject: "ಗ್ರಂಥಾಲಯ ಪುಸ್ತಕವನ್ನು ಇಂದು ಹಿಂದಿರುಗಿಸಬೇಕು",
+		Body:    `ನಮಸ್ಕಾರ {{.StudentName}}, "{{.BookTitle}}" (ಪ್ರತಿ {{.SerialNumber}}) ಅನ್ನು ಇಂದು ({{.DueDate}}) ಹಿಂದಿರುಗಿಸಬೇಕು. ಗ್ರಂಥಾಲಯ ಮುಚ್ಚುವ ಮೊದಲು ದಯವಿಟ್ಟು ಹಿಂದಿರುಗಿಸಿ.`},
+	{Key: TemplateOverdue, Language: LanguageKannada,
+		Subject: "ಗ್ರಂಥಾಲಯ ಪುಸ್ತಕದ ಅವಧಿ ಮೀರಿದೆ",
+		Body:    `ನಮಸ್ಕಾರ {{.StudentName}}, "{{.BookTitle}}" (ಪ್ರತಿ {{.SerialNumber}}) ಅನ್ನು {{.DueDate}} ರಂದು ಹಿಂದಿರುಗಿಸಬೇಕಿತ್ತು. ವಿಳಂಬ ಶುಲ್ಕ ಹೆಚ್ಚಾಗದಂತೆ ದಯವಿಟ್ಟು ಶೀಘ್ರವಾಗಿ ಹಿಂದಿರುಗಿಸಿ.`},
+	{Key: TemplateHoldReady, Language: LanguageKannada,
+		Subject: `"{{.BookTitle}}" ಪುಸ್ತಕ ಪಡೆಯಲು ಸಿದ್ಧವಾಗಿದೆ`,
+		Body:    `ನಮಸ್ಕಾರ {{.StudentName}}, ನೀವು ಕಾಯ್ದಿರಿಸಿದ "{{.BookTitle}}" ಪುಸ್ತಕ ಪಡೆಯಲು ಸಿದ್ಧವಾಗಿದೆ. ದಯವಿಟ್ಟು {{.PickupBy}} ರೊಳಗೆ ಪಡೆದುಕೊಳ್ಳಿ.`},
+	{Key: TemplateFine, Language: LanguageKannada,
+		Subject: "ಗ್ರಂಥಾಲಯ ದಂಡ ರೂ. {{.Fine}}",
+		Body:    `ನಮಸ್ಕಾರ {{.StudentName}}, ನಿಮ್ಮ ಗ್ರಂಥಾಲಯ ಖಾತೆಗೆ{{if .BookTitle}} "{{.BookTitle}}" ಪುಸ್ತಕಕ್ಕಾಗಿ{{end}} ರೂ. {{.Fine}} ದಂಡ ವಿಧಿಸಲಾಗಿದೆ. ನಿಮ್ಮ ಬಾಕಿ ರೂ. {{.Balance}}.`},
+
+	{Key: TemplateDueSoon, Language: LanguageHindi,
+		Subject: "पुस्तकालय की पुस्तक {{.DueDate}} को लौटानी है",
+		Body:    `नमस्ते {{.StudentName}}, याद दिला दें कि "{{.BookTitle}}" (प्रति {{.SerialNumber}}) {{.DueDate}} को लौटानी है। कृपया समय पर लौटाएँ।`},
+	{Key: TemplateDueToday, Language: LanguageHindi,
+		Subject: "पुस्तकालय की पुस्तक आज लौटानी है",
+		Body:    `नमस्ते {{.StudentName}}, "{{.BookTitle}}" (प्रति {{.SerialNumber}}) आज ({{.DueDate}}) लौटानी है। कृपया पुस्तकालय बंद होने से पहले लौटाएँ।`},
+	{Key: TemplateOverdue, Language: LanguageHindi,
+		Subject: "पुस्तकालय की पुस्तक की अवधि समाप्त",
+		Body:    `नमस्ते {{.StudentName}}, "{{.BookTitle}}" (प्रति {{.SerialNumber}}) {{.DueDate}} को लौटानी थी और अब अतिदेय है। विलंब शुल्क से बचने के लिए कृपया इसे जल्द से जल्द लौटाएँ।`},
+	{Key: TemplateHoldReady, Language: LanguageHindi,
+		Subject: `"{{.BookTitle}}" लेने के लिए तैयार है`,
+		Body:    `नमस्ते {{.StudentName}}, आपके द्वारा आरक्षित "{{.BookTitle}}" लेने के लिए तैयार है। कृपया {{.PickupBy}} तक ले जाएँ।`},
+	{Key: TemplateFine, Language: LanguageHindi,
+		Subject: "पुस्तकालय जुर्माना रु. {{.Fine}}",
+		Body:    `नमस्ते {{.StudentName}}, आपके पुस्तकालय खाते में{{if .BookTitle}} "{{.BookTitle}}" के लिए{{end}} रु. {{.Fine}} का जुर्माना लगाया गया है। आपकी बकाया राशि रु. {{.Balance}} है।`},
+}
+
+// DefaultTemplate returns the built-in text for a key and language
+func DefaultTemplate(key, language string) (MessageTemplate, bool) {
+	for _, t := range DefaultTemplates {
+		if t.Key == key && t.Language == language {
+			return t, true
+		}
+	}
+	return MessageTemplate{}, false
+}
+
+// IsLanguage reports whether notifications can be sent in the language
+func IsLanguage(language string) bool {
+	for _, l := range Languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Outbox statuses. Pending messages are retried until they are sent or
+// run out of attempts.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxMessage is one notification on one channel, kept as a delivery log
+// and as the retry queue for failed sends
+type OutboxMessage struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	StudentUSN        string     `gorm:"not null;index" json:"student_usn"`
+	RecipientName     string     `json:"recipient_name"`
+	Phone             string     `json:"phone,omitempty"`
+	Email             string     `json:"email,omitempty"`
+	Channel           string     `gorm:"not null" json:"channel"`
+	Template          string     `gorm:"not null" json:"template"`    // e.g. "reminder_due_soon"
+	TransactionID     *uint      `gorm:"index" json:"transaction_id"` // The loan the message is about, if any
+	Subject           string     `json:"subject"`
+	Body              string     `json:"body"`
+	Status            string     `gorm:"not null;default:pending;index" json:"status"`
+	Attempts          int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt     *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastError         string     `json:"last_error,omitempty"`
+	ProviderMessageID string     `json:"provider_message_id,omitempty"`
+	DedupKey          *string    `gorm:"unique" json:"-"` // Stops the same notification being queued twice
+	CreatedAt         time.Time  `json:"created_at"`
+	SentAt            *time.Time `json:"sent_at,omitempty"`
+}
+
+// TableName keeps the outbox under its own name
+func (OutboxMessage) TableName() string {
+	return "notification_outbox"
+}
@@ -0,0 +1,134 @@
+package models
+
+// Role groups a set of permissions that can be granted to users
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"unique;not null" json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+}
+
+// Permission is a single action a route can require, e.g. "books:write"
+type Permission struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"unique;not null" json:"name"`
+}
+
+// Built-in role names
+const (
+	RoleAdmin     = "admin"
+	RoleLibrarian = "librarian"
+	RoleAssistant = "assistant"
+	RoleAuditor   = "auditor"
+	RoleStudent   = "student"
+)
+
+// Permissions checked by the route policies in main.go
+const (
+	PermStudentsRead   = "students:read"
+	PermStudentsWrite  = "students:write"
+	PermStudentsDelete = "students:delete"
+
+	PermBooksRead   = "books:read"
+	PermBooksWrite  = "books:write"
+	PermBooksDelete = "books:delete"
+
+	PermEbooksUpload   = "ebooks:upload"
+	PermEbooksDownload = "ebooks:download"
+
+	PermVendorsRead   = "vendors:read"
+	PermVendorsWrite  = "vendors:write"
+	PermVendorsDelete = "vendors:delete"
+
+	PermTransactionsRead   = "transactions:read"
+	PermLoansReadOwn       = "loans:read-own" // A student's own loans only
+	PermTransactionsDelete = "transactions:delete"
+	PermCirculationIssue   = "circulation:issue"
+	PermCirculationReturn  = "circulation:return"
+	PermCirculationRenew   = "circulation:renew"
+
+	PermRemindersSend     = "reminders:send"
+	PermNotifyPreferences = "notifications:preferences"
+	PermNotifyHistory     = "notifications:history"
+	PermTemplatesManage   = "templates:manage"
+
+	PermHoldsRead   = "holds:read"
+	PermHoldsPlace  = "holds:place"
+	PermHoldsManage = "holds:manage"
+
+	PermFinesRead    = "fines:read"
+	PermFinesCollect = "fines:collect"
+	PermFinesCharge  = "fines:charge"
+	PermFinesWaive   = "fines:waive"
+
+	PermRulesRead   = "rules:read"
+	PermRulesManage = "rules:manage"
+
+	PermCalendarManage = "calendar:manage"
+
+	PermUsersRead   = "users:read"
+	PermUsersManage = "users:manage"
+
+	PermJobsRead = "jobs:read"
+	PermJobsRun  = "jobs:run"
+)
+
+// DefaultRoles lists the permissions each built-in role is seeded with
+var DefaultRoles = map[string][]string{
+	RoleAdmin: {
+		PermStudentsRead, PermStudentsWrite, PermStudentsDelete, PermNotifyPreferences, PermNotifyHistory,
+		PermBooksRead, PermBooksWrite, PermBooksDelete,
+		PermEbooksUpload, PermEbooksDownload,
+		PermVendorsRead, PermVendorsWrite, PermVendorsDelete,
+		PermTransactionsRead, PermTransactionsDelete, PermCirculationIssue, PermCirculationReturn, PermCirculationRenew,
+		PermRemindersSend, PermTemplatesManage,
+		PermHoldsRead, PermHoldsPlace, PermHoldsManage,
+		PermFinesRead, PermFinesCollect, PermFinesCharge, PermFinesWaive,
+		PermRulesRead, PermRulesManage,
+		PermCalendarManage,
+		PermUsersRead, PermUsersManage,
+		PermJobsRead, PermJobsRun,
+	},
+	RoleLibrarian: {
+		PermStudentsRead, PermStudentsWrite, PermStudentsDelete, PermNotifyPreferences, PermNotifyHistory,
+		PermBooksRead, PermBooksWrite, PermBooksDelete,
+		PermEbooksUpload, PermEbooksDownload,
+		PermVendorsRead, PermVendorsWrite,
+		PermTransactionsRead, PermTransactionsDelete, PermCirculationIssue, PermCirculationReturn, PermCirculationRenew,
+		PermRemindersSend, PermTemplatesManage,
+		PermHoldsRead, PermHoldsPlace, PermHoldsManage,
+		PermFinesRead, PermFinesCollect, PermFinesCharge, PermFinesWaive,
+		PermRulesRead, PermRulesManage,
+		PermCalendarManage,
+		PermUsersRead,
+		PermJobsRead, PermJobsRun,
+	},
+	RoleAssistant: {
+		PermStudentsRead, PermNotifyHistory,
+		PermBooksRead, PermEbooksDownload,
+		PermVendorsRead,
+		PermTransactionsRead, PermCirculationIssue, PermCirculationReturn, PermCirculationRenew,
+		PermRemindersSend,
+		PermHoldsRead, PermHoldsPlace, PermHoldsManage,
+		PermFinesRead, PermFinesCollect,
+		PermRulesRead,
+	},
+	RoleAuditor: {
+		PermStudentsRead, PermNotifyHistory,
+		PermBooksRead,
+		PermVendorsRead,
+		PermTransactionsRead,
+		PermHoldsRead,
+		PermFinesRead,
+		PermRulesRead,
+		PermUsersRead,
+		PermJobsRead,
+	},
+	RoleStudent: {
+		PermBooksRead, PermEbooksDownload,
+		PermNotifyPreferences, PermNotifyHistory,
+		PermHoldsRead, PermHoldsPlace,
+		PermCirculationRenew, PermLoansReadOwn,
+		PermFinesRead,
+	},
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is a server-side record of an issued refresh token. Access tokens
+// carry the session ID so that revoking the session invalidates both.
+type Session struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenID   string     `gorm:"unique;not null" json:"-"` // jti of the current refresh token
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"` // Nullable field
+	CreatedAt time.Time  `json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;references:ID" json:"-"`
+}
@@ -11,6 +11,9 @@ type Student struct {
 	Address      string    `json:"address"`
 	AdmissionYear int      `json:"admission_year"`
 	Department   string    `json:"department"`
+	Category     string    `json:"category"` // UG, PG or faculty
+	NotifyChannels string  `json:"notify_channels"` // Comma separated, e.g. "sms,email"; empty uses the defaults
+	Language     string    `json:"language"` // Notification language: en, kn or hi; empty uses the default
 	RegisteredAt time.Time `json:"registered_at"`
 	ExpiryDate   time.Time `json:"expiry_date"`
 	Remark       string    `json:"remark"`
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Transaction event types
+const (
+	EventIssued   = "issued"
+	EventRenewed  = "renewed"
+	EventReturned = "returned"
+)
+
+// TransactionEvent is an entry in the history of a loan
+type TransactionEvent struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	TransactionID uint       `gorm:"not null;index" json:"transaction_id"` // Foreign key to Transaction.ID
+	Type          string     `gorm:"not null" json:"type"`
+	OldDueDate    *time.Time `json:"old_due_date"`
+	NewDueDate    *time.Time `json:"new_due_date"`
+	UserID        uint       `json:"user_id"` // Staff or student account that made the change
+	CreatedAt     time.Time  `json:"created_at"`
+}
@@ -1,7 +1,9 @@
 package models
 
 type User struct {
-	ID       uint   `gorm:"primaryKey"`
-	Username string `gorm:"unique;not null"`
-	Password string `gorm:"not null"` // Hashed password
+	ID         uint    `gorm:"primaryKey" json:"id"`
+	Username   string  `gorm:"unique;not null" json:"username"`
+	Password   string  `gorm:"not null" json:"-"` // Hashed password
+	StudentUSN *string `json:"student_usn"`       // Set for accounts that belong to a student
+	Roles      []Role  `gorm:"many2many:user_roles" json:"roles"`
 }
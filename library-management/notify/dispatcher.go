@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"library-management/config"
+	"library-management/models"
+)
+
+// ErrNoChannels is returned when none of a student's channels is available
+var ErrNoChannels = errors.New("no notification channel is available for the student")
+
+// Result is the outcome of sending on one channel
+type Result struct {
+	Channel   string `json:"channel"`
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Dispatcher routes messages to the channels a student prefers
+type Dispatcher struct {
+	mu        sync.RWMutex
+	notifiers map[string]Notifier
+	defaults  []string
+}
+
+// NewDispatcher creates a dispatcher that falls back to defaultChannels for
+// students without preferences
+func NewDispatcher(defaultChannels []string, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{notifiers: make(map[string]Notifier), defaults: defaultChannels}
+	for _, n := range notifiers {
+		d.Register(n)
+	}
+	return d
+}
+
+// FromConfig builds a dispatcher with the log channel and every channel
+// that has settings
+func FromConfig() *Dispatcher {
+	d := NewDispatcher(config.DefaultNotifyChannels, Log{})
+	if config.SMSEnabled() {
+		d.Register(TwilioSMS{})
+	}
+	if config.SMTPHost != "" {
+		d.Register(SMTPEmail{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+		})
+	}
+	if config.WebhookURL != "" {
+		d.Register(Webhook{URL: config.WebhookURL, Secret: config.WebhookSecret})
+	}
+	return d
+}
+
+// Register adds a notifier, replacing any other for the same channel
+func (d *Dispatcher) Register(n Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers[n.Channel()] = n
+}
+
+// Channels lists the registered channels students can choose. The log
+// channel only serves as a server default.
+func (d *Dispatcher) Channels() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	channels := make([]string, 0, len(d.notifiers))
+	for name := range d.notifiers {
+		if name != ChannelLog {
+			channels = append(channels, name)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Notifier returns the notifier registered for a channel
+func (d *Dispatcher) Notifier(channel string) (Notifier, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	n, ok := d.notifiers[channel]
+	return n, ok
+}
+
+// ChannelsFor returns the channels a student should be notified on
+func (d *Dispatcher) ChannelsFor(student models.Student) []string {
+	if channels := ParseChannels(student.NotifyChannels); len(channels) > 0 {
+		return channels
+	}
+	return d.defaults
+}
+
+// NotifyStudent sends the message on each of the student's channels. It
+// fails only if no channel delivered the message.
+func (d *Dispatcher) NotifyStudent(ctx context.Context, student models.Student, subject, body string) ([]Result, error) {
+	msg := Message{
+		To:      RecipientFor(student),
+		Subject: subject,
+		Body:    body,
+	}
+
+	var results []Result
+	var errs []error
+	delivered := false
+	for _, channel := range d.ChannelsFor(student) {
+		n, ok := d.Notifier(channel)
+		if !ok {
+			continue
+		}
+		id, err := n.Send(ctx, msg)
+		result := Result{Channel: channel, MessageID: id}
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		} else {
+			delivered = true
+		}
+		results = append(results, result)
+	}
+
+	if delivered {
+		return results, nil
+	}
+	if len(errs) == 0 {
+		return results, ErrNoChannels
+	}
+	return results, errors.Join(errs...)
+}
+
+// RecipientFor builds the recipient addresses of a student
+func RecipientFor(student models.Student) Recipient {
+	return Recipient{
+		StudentUSN: student.USN,
+		Name:       student.Name,
+		Phone:      student.Phone,
+		Email:      student.Email,
+	}
+}
+
+// ParseChannels splits a comma separated channel list
+func ParseChannels(list string) []string {
+	var channels []string
+	for _, channel := range strings.Split(list, ",") {
+		if channel = strings.ToLower(strings.TrimSpace(channel)); channel != "" {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+var (
+	defaultMu         sync.RWMutex
+	defaultDispatcher = NewDispatcher([]string{ChannelSMS})
+)
+
+// SetDefault replaces the dispatcher used by the package level functions.
+// Tests can install one built from Recorders.
+func SetDefault(d *Dispatcher) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultDispatcher = d
+}
+
+// Default returns the dispatcher used by the package level functions
+func Default() *Dispatcher {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultDispatcher
+}
+
+// NotifyStudent sends a message through the default dispatcher
+func NotifyStudent(ctx context.Context, student models.Student, subject, body string) ([]Result, error) {
+	return Default().NotifyStudent(ctx, student, subject, body)
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPEmail sends plain-text email through an SMTP server
+type SMTPEmail struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (e SMTPEmail) Channel() string { return ChannelEmail }
+
+func (e SMTPEmail) Send(ctx context.Context, msg Message) (string, error) {
+	if msg.To.Email == "" {
+		return "", ErrNoAddress
+	}
+
+	// Generate our own Message-ID so the send can be traced in mail logs
+	host, _ := os.Hostname()
+	messageID := fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), msg.To.StudentUSN, host)
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", e.From)
+	m.SetAddressHeader("To", msg.To.Email, msg.To.Name)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetHeader("Message-ID", messageID)
+	m.SetBody("text/plain", msg.Body)
+
+	dialer := gomail.NewDialer(e.Host, e.Port, e.Username, e.Password)
+	if err := dialer.DialAndSend(m); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
@@ -0,0 +1,17 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Log is a Notifier that only writes messages to the server log. It is the
+// default channel of a server with no SMS, email or webhook settings.
+type Log struct{}
+
+func (Log) Channel() string { return ChannelLog }
+
+func (Log) Send(ctx context.Context, msg Message) (string, error) {
+	log.Printf("Notification for %s (%s): %s", msg.To.StudentUSN, msg.Subject, msg.Body)
+	return "", nil
+}
@@ -0,0 +1,42 @@
+// Package notify delivers messages to students over SMS, email and
+// webhooks. Each channel is a Notifier; the Dispatcher picks the channels
+// a student has opted into.
+package notify
+
+import (
+	"context"
+	"errors"
+)
+
+// Channel names used in student preferences
+const (
+	ChannelSMS     = "sms"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+)
+
+// ErrNoAddress is returned when a recipient has no address for a channel
+var ErrNoAddress = errors.New("recipient has no address for this channel")
+
+// Recipient is who a message is for, with an address per channel
+type Recipient struct {
+	StudentUSN string
+	Name       string
+	Phone      string
+	Email      string
+}
+
+// Message is a notification to one recipient
+type Message struct {
+	To      Recipient
+	Subject string
+	Body    string
+}
+
+// Notifier sends messages over one channel. Send returns the provider's
+// message ID when the provider reports one.
+type Notifier interface {
+	Channel() string
+	Send(ctx context.Context, msg Message) (string, error)
+}
@@ -0,0 +1,216 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library-management/config"
+	"library-management/models"
+)
+
+// ErrChannelUnavailable is recorded when a queued message's channel is no
+// longer configured
+var ErrChannelUnavailable = errors.New("channel is not available")
+
+// Notification is a message to queue in the outbox for a student
+type Notification struct {
+	Template      string
+	TransactionID *uint
+	// DedupKey identifies the notification, e.g. "reminder:overdue:42:2024-06-01".
+	// A notification whose key was queued before is skipped. Empty never dedups.
+	DedupKey string
+	Subject  string
+	Body     string
+}
+
+// Enqueue stores the notification in the outbox once for each of the
+// student's channels. It returns only the newly queued messages, so a
+// duplicate notification returns none. The messages are claimed for the
+// caller's first attempt; the retry worker only sends them if that attempt
+// never records an outcome.
+func (d *Dispatcher) Enqueue(db *gorm.DB, student models.Student, n Notification) ([]models.OutboxMessage, error) {
+	claimedUntil := time.Now().Add(claimLease())
+	var queued []models.OutboxMessage
+	for _, channel := range d.ChannelsFor(student) {
+		msg := models.OutboxMessage{
+			StudentUSN:    student.USN,
+			RecipientName: student.Name,
+			Phone:         student.Phone,
+			Email:         student.Email,
+			Channel:       channel,
+			Template:      n.Template,
+			TransactionID: n.TransactionID,
+			Subject:       n.Subject,
+			Body:          n.Body,
+			Status:        models.OutboxPending,
+			NextAttemptAt: &claimedUntil,
+		}
+		if n.DedupKey != "" {
+			key := n.DedupKey + ":" + channel
+			msg.DedupKey = &key
+		}
+
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&msg)
+		if result.Error != nil {
+			return queued, result.Error
+		}
+		if result.RowsAffected == 1 {
+			queued = append(queued, msg)
+		}
+	}
+	return queued, nil
+}
+
+// Send queues the notification and makes the first delivery attempt.
+// Failed messages stay in the outbox for the retry worker. It fails only
+// if none of the new messages was delivered.
+func (d *Dispatcher) Send(ctx context.Context, db *gorm.DB, student models.Student, n Notification) ([]models.OutboxMessage, error) {
+	queued, err := d.Enqueue(db, student, n)
+	if err != nil {
+		return queued, err
+	}
+	if len(queued) == 0 {
+		return queued, nil
+	}
+
+	var errs []error
+	delivered := false
+	for i := range queued {
+		if err := d.Deliver(ctx, db, &queued[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", queued[i].Channel, err))
+		} else {
+			delivered = true
+		}
+	}
+	if delivered {
+		return queued, nil
+	}
+	return queued, errors.Join(errs...)
+}
+
+// Deliver makes one attempt to send a queued message and records the
+// outcome. Failures are scheduled for a retry with exponential backoff
+// until config.NotifyMaxAttempts is reached.
+func (d *Dispatcher) Deliver(ctx context.Context, db *gorm.DB, msg *models.OutboxMessage) error {
+	var id string
+	err := ErrChannelUnavailable
+	if n, ok := d.Notifier(msg.Channel); ok {
+		id, err = n.Send(ctx, Message{
+			To: Recipient{
+				StudentUSN: msg.StudentUSN,
+				Name:       msg.RecipientName,
+				Phone:      msg.Phone,
+				Email:      msg.Email,
+			},
+			Subject: msg.Subject,
+			Body:    msg.Body,
+		})
+	}
+
+	now := time.Now()
+	msg.Attempts++
+	if err == nil {
+		msg.Status = models.OutboxSent
+		msg.ProviderMessageID = id
+		msg.SentAt = &now
+		msg.NextAttemptAt = nil
+		msg.LastError = ""
+	} else {
+		msg.LastError = err.Error()
+		if errors.Is(err, ErrNoAddress) || msg.Attempts >= config.NotifyMaxAttempts {
+			// A missing address will not fix itself, so it is not retried
+			msg.Status = models.OutboxFailed
+			msg.NextAttemptAt = nil
+		} else {
+			next := now.Add(retryDelay(msg.Attempts))
+			msg.NextAttemptAt = &next
+		}
+	}
+
+	if saveErr := db.Save(msg).Error; saveErr != nil {
+		log.Printf("Error saving outbox message %d: %v", msg.ID, saveErr)
+	}
+	return err
+}
+
+// RetryPending delivers the pending messages that are due, oldest first,
+// and returns how many were sent. Each message is claimed before it is
+// sent so that replicas running the worker do not send it twice.
+func (d *Dispatcher) RetryPending(ctx context.Context, db *gorm.DB, limit int) (int, error) {
+	now := time.Now()
+	due := []models.OutboxMessage{}
+	err := db.Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		msg := &due[i]
+		claimedUntil := now.Add(claimLease())
+		claim := db.Model(&models.OutboxMessage{}).
+			Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at <= ?", msg.ID, models.OutboxPending, msg.Attempts, now).
+			Update("next_attempt_at", claimedUntil)
+		if claim.Error != nil {
+			return sent, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue // Another replica took it or holds its lease
+		}
+		if err := d.Deliver(ctx, db, msg); err == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// maxRetryDelay caps the wait between sends of a failed message
+const maxRetryDelay = 24 * time.Hour
+
+// retryDelay is how long to wait after a message's attempts-th failed send:
+// the base delay, doubled for each earlier failure, but no longer than
+// maxRetryDelay or the base delay if that is longer
+func retryDelay(attempts int) time.Duration {
+	limit := max(config.NotifyRetryBaseDelay, maxRetryDelay)
+	delay := config.NotifyRetryBaseDelay
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// claimLease is how long a message is reserved for the sender that
+// claimed it
+func claimLease() time.Duration {
+	return 2 * config.NotifyRetryInterval
+}
+
+// RunRetryWorker retries pending messages through the default dispatcher
+// every config.NotifyRetryInterval until ctx is cancelled
+func RunRetryWorker(ctx context.Context, db *gorm.DB) {
+	ticker := time.NewTicker(config.NotifyRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if sent, err := Default().RetryPending(ctx, db, 100); err != nil {
+				log.Println("Error retrying notifications:", err)
+			} else if sent > 0 {
+				log.Printf("Retried %d notifications", sent)
+			}
+		}
+	}
+}
+
+// Send queues and sends a notification through the default dispatcher
+func Send(ctx context.Context, db *gorm.DB, student models.Student, n Notification) ([]models.OutboxMessage, error) {
+	return Default().Send(ctx, db, student, n)
+}
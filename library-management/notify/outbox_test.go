@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestRetryDelay(t *testing.T) {
+	defer func(base time.Duration) { config.NotifyRetryBaseDelay = base }(config.NotifyRetryBaseDelay)
+	config.NotifyRetryBaseDelay = time.Minute
+
+	for attempts, want := range map[int]time.Duration{
+		1:       time.Minute,
+		2:       2 * time.Minute,
+		5:       16 * time.Minute,
+		11:      1024 * time.Minute,
+		12:      maxRetryDelay, // 2048 minutes would be over the cap
+		64:      maxRetryDelay, // Shifting this far would overflow
+		1 << 20: maxRetryDelay,
+	} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+
+	// A base delay longer than the cap is kept as it is
+	config.NotifyRetryBaseDelay = 48 * time.Hour
+	if got := retryDelay(3); got != 48*time.Hour {
+		t.Errorf("retryDelay with a two day base = %v", got)
+	}
+}
+
+func TestEnqueueSkipsDuplicatesPerChannel(t *testing.T) {
+	db := databasetest.Open(t)
+	d := NewDispatcher([]string{ChannelSMS}, &Recorder{}, &Recorder{Name: ChannelEmail})
+	student := models.Student{USN: "1AB21CS001", Name: "Asha", NotifyChannels: "sms"}
+	n := Notification{Template: models.TemplateOverdue, DedupKey: "reminder:overdue:1:2024-06-01", Body: "Overdue"}
+
+	if queued, err := d.Enqueue(db, student, n); err != nil || len(queued) != 1 {
+		t.Fatalf("first enqueue = %v, %v", queued, err)
+	}
+	if queued, err := d.Enqueue(db, student, n); err != nil || len(queued) != 0 {
+		t.Errorf("the duplicate queued %v, %v", queued, err)
+	}
+
+	// A channel added later still gets the notification, once
+	student.NotifyChannels = "sms,email"
+	queued, err := d.Enqueue(db, student, n)
+	if err != nil || len(queued) != 1 || queued[0].Channel != ChannelEmail {
+		t.Errorf("after adding email: %v, %v", queued, err)
+	}
+	if queued, _ := d.Enqueue(db, student, n); len(queued) != 0 {
+		t.Errorf("the duplicate queued %v", queued)
+	}
+
+	// Without a key nothing is skipped
+	n.DedupKey = ""
+	for i := 0; i < 2; i++ {
+		if queued, _ := d.Enqueue(db, student, n); len(queued) != 2 {
+			t.Errorf("enqueue %d without a key queued %v", i, queued)
+		}
+	}
+
+	var count int64
+	db.Model(&models.OutboxMessage{}).Count(&count)
+	if count != 6 {
+		t.Errorf("%d messages in the outbox, want 6", count)
+	}
+}
+
+// dueMessage queues a message whose next attempt is already due
+func dueMessage(t *testing.T, db *gorm.DB, d *Dispatcher) models.OutboxMessage {
+	t.Helper()
+	queued, err := d.Enqueue(db, models.Student{USN: "1AB21CS001", Name: "Asha", Phone: "+919876543210"}, Notification{Body: "Hello"})
+	if err != nil || len(queued) != 1 {
+		t.Fatalf("enqueue = %v, %v", queued, err)
+	}
+	msg := queued[0]
+	past := time.Now().Add(-time.Second)
+	if err := db.Model(&msg).Update("next_attempt_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestRetryPending(t *testing.T) {
+	db := databasetest.Open(t)
+	recorder := &Recorder{}
+	d := NewDispatcher([]string{ChannelSMS}, recorder)
+	ctx := context.Background()
+
+	// A newly queued message is claimed for its first attempt
+	if _, err := d.Enqueue(db, models.Student{USN: "1AB21CS002"}, Notification{Body: "Claimed"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := dueMessage(t, db, d)
+
+	// A failed send releases the claim until the retry is due
+	recorder.Err = errors.New("provider down")
+	if sent, err := d.RetryPending(ctx, db, 10); err != nil || sent != 0 {
+		t.Fatalf("RetryPending = %d, %v", sent, err)
+	}
+	var stored models.OutboxMessage
+	db.First(&stored, msg.ID)
+	if stored.Status != models.OutboxPending || stored.Attempts != 1 || stored.LastError != "provider down" ||
+		stored.NextAttemptAt == nil || time.Until(*stored.NextAttemptAt) < config.NotifyRetryBaseDelay-time.Second {
+		t.Fatalf("after a failed send: %+v", stored)
+	}
+	if sent, _ := d.RetryPending(ctx, db, 10); sent != 0 {
+		t.Errorf("a message was retried before it was due")
+	}
+
+	recorder.Err = nil
+	db.Model(&stored).Update("next_attempt_at", time.Now().Add(-time.Second))
+	if sent, err := d.RetryPending(ctx, db, 10); err != nil || sent != 1 {
+		t.Fatalf("RetryPending = %d, %v", sent, err)
+	}
+	db.First(&stored, msg.ID)
+	if stored.Status != models.OutboxSent || stored.Attempts != 2 || stored.SentAt == nil {
+		t.Errorf("after sending: %+v", stored)
+	}
+	if sent := recorder.Sent(); len(sent) != 1 || sent[0].Body != "Hello" {
+		t.Errorf("sent %+v", sent)
+	}
+}
+
+func TestRetryPendingSendsEachMessageOnce(t *testing.T) {
+	db := databasetest.Open(t)
+	recorder := &Recorder{}
+	d := NewDispatcher([]string{ChannelSMS}, recorder)
+	for i := 0; i < 5; i++ {
+		dueMessage(t, db, d)
+	}
+
+	// Replicas running the worker at the same time claim each message once
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent, err := d.RetryPending(context.Background(), db, 10)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			total += sent
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if sent := len(recorder.Sent()); total != 5 || sent != 5 {
+		t.Errorf("%d sends reported, %d made, want 5", total, sent)
+	}
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Recorder is a fake Notifier that keeps every message instead of sending
+// it. Set Err to make sends fail. It is safe for concurrent use.
+type Recorder struct {
+	Name string // Channel to report; defaults to sms
+	Err  error
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (r *Recorder) Channel() string {
+	if r.Name == "" {
+		return ChannelSMS
+	}
+	return r.Name
+}
+
+func (r *Recorder) Send(ctx context.Context, msg Message) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Err != nil {
+		return "", r.Err
+	}
+	r.sent = append(r.sent, msg)
+	return fmt.Sprintf("recorded-%d", len(r.sent)), nil
+}
+
+// Sent returns a copy of the messages recorded so far
+func (r *Recorder) Sent() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.sent...)
+}
+
+// Reset forgets the recorded messages
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+}
@@ -0,0 +1,19 @@
+package notify
+
+import (
+	"context"
+
+	"library-management/config"
+)
+
+// TwilioSMS sends text messages through the Twilio client in config
+type TwilioSMS struct{}
+
+func (TwilioSMS) Channel() string { return ChannelSMS }
+
+func (TwilioSMS) Send(ctx context.Context, msg Message) (string, error) {
+	if msg.To.Phone == "" {
+		return "", ErrNoAddress
+	}
+	return config.SendSMSWithSID(msg.To.Phone, msg.Body)
+}
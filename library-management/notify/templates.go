@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"gorm.io/gorm"
+	"library-management/config"
+	"library-management/models"
+)
+
+// ErrUnknownTemplate is returned for a template key with no text in any
+// language
+var ErrUnknownTemplate = errors.New("unknown notification template")
+
+// TemplateData holds the values templates can use as placeholders
+type TemplateData struct {
+	StudentName  string
+	StudentUSN   string
+	BookTitle    string
+	SerialNumber string
+	DueDate      string // YYYY-MM-DD
+	PickupBy     string // YYYY-MM-DD, for holds
+	Fine         string // Amount of the charge, e.g. "12.50"
+	Balance      string // Outstanding balance after the charge
+}
+
+// SampleData is used to check and preview templates
+func SampleData() TemplateData {
+	return TemplateData{
+		StudentName:  "Asha Rao",
+		StudentUSN:   "1XX21CS001",
+		BookTitle:    "Introduction to Algorithms",
+		SerialNumber: "CS-0042",
+		DueDate:      "2024-06-01",
+		PickupBy:     "2024-06-04",
+		Fine:         "30.00",
+		Balance:      "45.00",
+	}
+}
+
+// DataFor fills in the student and, when a loan is given, its book and due date
+func DataFor(db *gorm.DB, student models.Student, transaction *models.Transaction) TemplateData {
+	data := TemplateData{StudentName: student.Name, StudentUSN: student.USN}
+	if transaction == nil {
+		return data
+	}
+	data.DueDate = transaction.DueDate.Format("2006-01-02")
+	var item models.Copy
+	if err := db.Preload("Book").First(&item, transaction.CopyID).Error; err == nil {
+		data.SerialNumber = item.SerialNumber
+		if item.Book != nil {
+			data.BookTitle = item.Book.Title
+		}
+	}
+	return data
+}
+
+// FindTemplate picks the text for key in the student's language, falling
+// back to the default language and then English. Stored templates win over
+// the built-in ones.
+func FindTemplate(db *gorm.DB, key, language string) (models.MessageTemplate, error) {
+	candidates := []string{language, config.DefaultLanguage, models.LanguageEnglish}
+	for _, lang := range candidates {
+		if lang == "" {
+			continue
+		}
+		var t models.MessageTemplate
+		err := db.Where("key = ? AND language = ?", key, lang).First(&t).Error
+		if err == nil {
+			return t, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return t, err
+		}
+		if t, ok := models.DefaultTemplate(key, lang); ok {
+			return t, nil
+		}
+	}
+	return models.MessageTemplate{}, ErrUnknownTemplate
+}
+
+// RenderTemplate fills in the template's subject and body
+func RenderTemplate(t models.MessageTemplate, data TemplateData) (subject, body string, err error) {
+	if subject, err = execute(t.Key+".subject", t.Subject, data); err != nil {
+		return "", "", err
+	}
+	if body, err = execute(t.Key+".body", t.Body, data); err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+// ValidateTemplate checks that a subject and body parse and only use
+// known placeholders
+func ValidateTemplate(t models.MessageTemplate) error {
+	_, _, err := RenderTemplate(t, SampleData())
+	return err
+}
+
+// Render finds and fills in a template
+func Render(db *gorm.DB, key, language string, data TemplateData) (subject, body string, err error) {
+	t, err := FindTemplate(db, key, language)
+	if err != nil {
+		return "", "", err
+	}
+	return RenderTemplate(t, data)
+}
+
+// SendTemplate renders the notification's template in the student's
+// language and sends it through the default dispatcher
+func SendTemplate(ctx context.Context, db *gorm.DB, student models.Student, n Notification, data TemplateData) ([]models.OutboxMessage, error) {
+	subject, body, err := Render(db, n.Template, student.Language, data)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", n.Template, err)
+	}
+	n.Subject, n.Body = subject, body
+	return Send(ctx, db, student, n)
+}
+
+func execute(name, text string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
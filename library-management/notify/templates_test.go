@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	"library-management/config"
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestFindTemplate(t *testing.T) {
+	db := databasetest.Open(t)
+	defer func(language string) { config.DefaultLanguage = language }(config.DefaultLanguage)
+	config.DefaultLanguage = models.LanguageEnglish
+	data := SampleData()
+
+	// Without stored templates the built-in text in the language is used
+	subject, _, err := Render(db, models.TemplateOverdue, models.LanguageKannada, data)
+	if want, _ := models.DefaultTemplate(models.TemplateOverdue, models.LanguageKannada); err != nil || subject != want.Subject {
+		t.Errorf("built-in Kannada subject = %q, %v", subject, err)
+	}
+
+	// A stored template wins over the built-in one
+	stored := models.MessageTemplate{Key: models.TemplateOverdue, Language: models.LanguageKannada, Subject: "Overdue: {{.BookTitle}}", Body: "{{.StudentName}}"}
+	if err := db.Create(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	subject, body, err := Render(db, models.TemplateOverdue, models.LanguageKannada, data)
+	if err != nil || subject != "Overdue: Introduction to Algorithms" || body != "Asha Rao" {
+		t.Errorf("stored template rendered %q %q, %v", subject, body, err)
+	}
+
+	// Texts missing in a language fall back to the default language, then
+	// English
+	custom := models.MessageTemplate{Key: "custom_notice", Language: models.LanguageEnglish, Body: "Hello {{.StudentName}}"}
+	if err := db.Create(&custom).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, language := range []string{models.LanguageKannada, models.LanguageHindi, "fr", ""} {
+		if found, err := FindTemplate(db, "custom_notice", language); err != nil || found.Language != models.LanguageEnglish {
+			t.Errorf("%q: found %+v, %v", language, found, err)
+		}
+	}
+	config.DefaultLanguage = models.LanguageHindi
+	if found, err := FindTemplate(db, models.TemplateFine, ""); err != nil || found.Language != models.LanguageHindi {
+		t.Errorf("without a language: found %+v, %v", found, err)
+	}
+
+	if _, err := FindTemplate(db, "no_such_template", models.LanguageEnglish); err != ErrUnknownTemplate {
+		t.Errorf("unknown template gave %v", err)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	for _, template := range models.DefaultTemplates {
+		if err := ValidateTemplate(template); err != nil {
+			t.Errorf("built-in %s/%s: %v", template.Key, template.Language, err)
+		}
+	}
+
+	for name, template := range map[string]models.MessageTemplate{
+		"unclosed action":     {Key: "k", Body: "Hello {{.StudentName"},
+		"unknown placeholder": {Key: "k", Body: "Hello {{.Nickname}}"},
+		"bad subject":         {Key: "k", Subject: "{{if .Fine}}", Body: "Hello"},
+		"unknown function":    {Key: "k", Body: "{{shout .StudentName}}"},
+	} {
+		if err := ValidateTemplate(template); err == nil {
+			t.Errorf("%s: no error", name)
+		} else if !strings.Contains(err.Error(), "k.") {
+			t.Errorf("%s: the error %q does not name the template", name, err)
+		}
+	}
+}
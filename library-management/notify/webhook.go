@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Webhook posts messages as JSON to an HTTP endpoint, such as a college
+// messaging gateway. When Secret is set the body is signed with HMAC-SHA256
+// in the X-Signature header.
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// webhookPayload is the JSON body posted to the endpoint
+type webhookPayload struct {
+	StudentUSN string `json:"student_usn"`
+	Name       string `json:"name"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email"`
+	Subject    string `json:"subject"`
+	Body       string `json:"body"`
+}
+
+func (w Webhook) Channel() string { return ChannelWebhook }
+
+func (w Webhook) Send(ctx context.Context, msg Message) (string, error) {
+	body, err := json.Marshal(webhookPayload{
+		StudentUSN: msg.To.StudentUSN,
+		Name:       msg.To.Name,
+		Phone:      msg.To.Phone,
+		Email:      msg.To.Email,
+		Subject:    msg.Subject,
+		Body:       msg.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(snippet))
+	}
+
+	// Use the endpoint's message ID if it returns one
+	var reply struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reply)
+	return reply.ID, nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library-management/database"
+	"library-management/models"
+)
+
+// GormStore keeps the repositories in a SQL database through gorm
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a store backed by db
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// DB returns the database handle, which inside Atomic is the open transaction
+func (s *GormStore) DB() *gorm.DB {
+	return s.db
+}
+
+func (s *GormStore) Students() StudentRepository         { return gormStudents{s.db} }
+func (s *GormStore) Books() BookRepository               { return gormBooks{s.db} }
+func (s *GormStore) Transactions() TransactionRepository { return gormTransactions{s.db} }
+func (s *GormStore) Vendors() VendorRepository           { return gormVendors{s.db} }
+
+func (s *GormStore) Atomic(fn func(Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewGormStore(tx))
+	})
+}
+
+// notFound maps gorm's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormStudents struct{ db *gorm.DB }
+
+func (r gormStudents) List() ([]models.Student, error) {
+	var students []models.Student
+	err := r.db.Find(&students).Error
+	return students, err
+}
+
+func (r gormStudents) Get(id uint) (models.Student, error) {
+	var student models.Student
+	err := r.db.First(&student, id).Error
+	return student, notFound(err)
+}
+
+func (r gormStudents) GetByUSN(usn string) (models.Student, error) {
+	var student models.Student
+	err := r.db.Where("LOWER(TRIM(usn)) = LOWER(TRIM(?))", usn).First(&student).Error
+	return student, notFound(err)
+}
+
+func (r gormStudents) LockByUSN(usn string) error {
+	// A write takes the row lock on every database
+	result := r.db.Exec("UPDATE students SET id = id WHERE LOWER(TRIM(usn)) = LOWER(TRIM(?))", usn)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormStudents) Create(student *models.Student) error {
+	return r.db.Create(student).Error
+}
+
+func (r gormStudents) Update(student *models.Student, changes models.Student) error {
+	return r.db.Model(student).Updates(changes).Error
+}
+
+func (r gormStudents) Delete(id uint) error {
+	return r.db.Delete(&models.Student{}, id).Error
+}
+
+type gormBooks struct{ db *gorm.DB }
+
+func (r gormBooks) List() ([]models.Book, error) {
+	var books []models.Book
+	err := r.db.Preload("Copies").Find(&books).Error
+	return books, err
+}
+
+func (r gormBooks) SearchByTitle(title string) ([]models.Book, error) {
+	var books []models.Book
+	err := r.db.Preload("Copies").Where(database.ContainsFold("title", title)).Find(&books).Error
+	return books, err
+}
+
+func (r gormBooks) Get(id uint) (models.Book, error) {
+	var book models.Book
+	err := r.db.Preload("Copies").First(&book, id).Error
+	return book, notFound(err)
+}
+
+func (r gormBooks) Create(book *models.Book) error {
+	return r.db.Create(book).Error
+}
+
+func (r gormBooks) Save(book *models.Book) error {
+	return r.db.Omit(clause.Associations).Save(book).Error
+}
+
+func (r gormBooks) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("book_id = ?", id).Delete(&models.Copy{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Book{}, id).Error
+	})
+}
+
+func (r gormBooks) GetCopy(id uint) (models.Copy, error) {
+	var bookCopy models.Copy
+	err := r.db.First(&bookCopy, id).Error
+	return bookCopy, notFound(err)
+}
+
+func (r gormBooks) GetCopyBySerial(serialNumber string) (models.Copy, error) {
+	var bookCopy models.Copy
+	err := r.db.Where("serial_number = ?", serialNumber).First(&bookCopy).Error
+	return bookCopy, notFound(err)
+}
+
+func (r gormBooks) AddCopies(copies []models.Copy) error {
+	return r.db.Create(&copies).Error
+}
+
+func (r gormBooks) SaveCopy(bookCopy *models.Copy) error {
+	return r.db.Omit(clause.Associations).Save(bookCopy).Error
+}
+
+func (r gormBooks) SetCopyStatus(id uint, from, to string) error {
+	result := r.db.Model(&models.Copy{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r gormBooks) CountCopies(bookID uint, status string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Copy{}).Where("book_id = ? AND status = ?", bookID, status).Count(&count).Error
+	return count, err
+}
+
+type gormTransactions struct{ db *gorm.DB }
+
+func (r gormTransactions) Find(filter TransactionFilter) ([]models.Transaction, error) {
+	query := r.db.Preload("Student").Preload("Copy.Book")
+	if filter.SerialNumber != "" {
+		query = query.Joins("JOIN copies ON copies.id = transactions.copy_id").
+			Where("copies.serial_number = ?", filter.SerialNumber)
+	}
+	if filter.StudentUSN != "" {
+		query = query.Where("student_usn = ?", filter.StudentUSN)
+	}
+	if filter.OpenOnly {
+		query = query.Where("return_date IS NULL")
+	}
+	if !filter.DueFrom.IsZero() {
+		query = query.Where("due_date >= ?", filter.DueFrom)
+	}
+	if !filter.DueBefore.IsZero() {
+		query = query.Where("due_date < ?", filter.DueBefore)
+	}
+
+	transactions := []models.Transaction{}
+	err := query.Order("transactions.id").Find(&transactions).Error
+	return transactions, err
+}
+
+func (r gormTransactions) Get(id uint) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db.Preload("Student").Preload("Copy.Book").First(&transaction, id).Error
+	return transaction, notFound(err)
+}
+
+func (r gormTransactions) Create(transaction *models.Transaction) error {
+	return r.db.Omit(clause.Associations).Create(transaction).Error
+}
+
+func (r gormTransactions) Save(transaction *models.Transaction) error {
+	return r.db.Omit(clause.Associations).Save(transaction).Error
+}
+
+func (r gormTransactions) MarkReturned(transaction models.Transaction) error {
+	result := r.db.Model(&models.Transaction{}).
+		Where("id = ? AND return_date IS NULL", transaction.ID).
+		Updates(map[string]interface{}{
+			"return_date":    transaction.ReturnDate,
+			"late_fee_minor": transaction.LateFeeMinor,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r gormTransactions) Delete(id uint) error {
+	return r.db.Delete(&models.Transaction{}, id).Error
+}
+
+func (r gormTransactions) CountOpen(studentUSN string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Transaction{}).Where("student_usn = ? AND return_date IS NULL", studentUSN).Count(&count).Error
+	return count, err
+}
+
+type gormVendors struct{ db *gorm.DB }
+
+func (r gormVendors) List() ([]models.Vendor, error) {
+	var vendors []models.Vendor
+	err := r.db.Find(&vendors).Error
+	return vendors, err
+}
+
+func (r gormVendors) Get(id uint) (models.Vendor, error) {
+	var vendor models.Vendor
+	err := r.db.First(&vendor, id).Error
+	return vendor, notFound(err)
+}
+
+func (r gormVendors) Create(vendor *models.Vendor) error {
+	return r.db.Create(vendor).Error
+}
+
+func (r gormVendors) Delete(id uint) error {
+	return r.db.Delete(&models.Vendor{}, id).Error
+}
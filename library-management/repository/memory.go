@@ -0,0 +1,508 @@
+package repository
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"library-management/models"
+)
+
+// MemoryStore keeps the repositories in maps. It is meant for tests and
+// tools. Units of work run one at a time on a copy of the data, so others
+// see none of their changes until they succeed, and all of them after.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data memoryData
+}
+
+type memoryData struct {
+	nextID       uint
+	students     map[uint]models.Student
+	books        map[uint]models.Book
+	copies       map[uint]models.Copy
+	transactions map[uint]models.Transaction
+	vendors      map[uint]models.Vendor
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: memoryData{
+		students:     map[uint]models.Student{},
+		books:        map[uint]models.Book{},
+		copies:       map[uint]models.Copy{},
+		transactions: map[uint]models.Transaction{},
+		vendors:      map[uint]models.Vendor{},
+	}}
+}
+
+func (s *MemoryStore) Students() StudentRepository         { return memoryStudents{s} }
+func (s *MemoryStore) Books() BookRepository               { return memoryBooks{s} }
+func (s *MemoryStore) Transactions() TransactionRepository { return memoryTransactions{s} }
+func (s *MemoryStore) Vendors() VendorRepository           { return memoryVendors{s} }
+
+// Atomic runs fn on a store holding a copy of the data, which replaces the
+// store's data if fn succeeds. The store is locked meanwhile, so fn must
+// only use the store it is given.
+func (s *MemoryStore) Atomic(fn func(Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{data: s.data.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	s.data = tx.data
+	return nil
+}
+
+func (d memoryData) clone() memoryData {
+	return memoryData{
+		nextID:       d.nextID,
+		students:     cloneMap(d.students),
+		books:        cloneMap(d.books),
+		copies:       cloneMap(d.copies),
+		transactions: cloneMap(d.transactions),
+		vendors:      cloneMap(d.vendors),
+	}
+}
+
+func cloneMap[T any](m map[uint]T) map[uint]T {
+	c := make(map[uint]T, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// sortedValues returns the values of m ordered by ID
+func sortedValues[T any](m map[uint]T, keep func(T) bool) []T {
+	ids := make([]uint, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	values := []T{}
+	for _, id := range ids {
+		if keep == nil || keep(m[id]) {
+			values = append(values, m[id])
+		}
+	}
+	return values
+}
+
+func (s *MemoryStore) newID() uint {
+	s.data.nextID++
+	return s.data.nextID
+}
+
+type memoryStudents struct{ s *MemoryStore }
+
+func (r memoryStudents) List() ([]models.Student, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return sortedValues(r.s.data.students, nil), nil
+}
+
+func (r memoryStudents) Get(id uint) (models.Student, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	student, ok := r.s.data.students[id]
+	if !ok {
+		return models.Student{}, ErrNotFound
+	}
+	return student, nil
+}
+
+func (r memoryStudents) GetByUSN(usn string) (models.Student, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.studentByUSN(usn)
+}
+
+// LockByUSN only checks the student exists; a unit of work already has the
+// store to itself
+func (r memoryStudents) LockByUSN(usn string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	_, err := r.s.studentByUSN(usn)
+	return err
+}
+
+func (s *MemoryStore) studentByUSN(usn string) (models.Student, error) {
+	usn = strings.TrimSpace(usn)
+	for _, student := range sortedValues(s.data.students, nil) {
+		if strings.EqualFold(strings.TrimSpace(student.USN), usn) {
+			return student, nil
+		}
+	}
+	return models.Student{}, ErrNotFound
+}
+
+func (r memoryStudents) Create(student *models.Student) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, existing := range r.s.data.students {
+		if existing.USN == student.USN {
+			return ErrConflict
+		}
+	}
+	student.ID = r.s.newID()
+	r.s.data.students[student.ID] = *student
+	return nil
+}
+
+func (r memoryStudents) Update(student *models.Student, changes models.Student) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.students[student.ID]; !ok {
+		return ErrNotFound
+	}
+	id := student.ID
+	copyNonZero(student, changes)
+	student.ID = id
+	r.s.data.students[id] = *student
+	return nil
+}
+
+func (r memoryStudents) Delete(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.students, id)
+	return nil
+}
+
+// copyNonZero sets every field of dst that is non-zero in src, like gorm's
+// Updates with a struct
+func copyNonZero[T any](dst *T, src T) {
+	to := reflect.ValueOf(dst).Elem()
+	from := reflect.ValueOf(src)
+	for i := 0; i < from.NumField(); i++ {
+		if !from.Field(i).IsZero() {
+			to.Field(i).Set(from.Field(i))
+		}
+	}
+}
+
+type memoryBooks struct{ s *MemoryStore }
+
+// withCopies attaches the copies of a stored book
+func (s *MemoryStore) withCopies(book models.Book) models.Book {
+	book.Copies = sortedValues(s.data.copies, func(c models.Copy) bool { return c.BookID == book.ID })
+	return book
+}
+
+func (r memoryBooks) find(keep func(models.Book) bool) []models.Book {
+	books := sortedValues(r.s.data.books, keep)
+	for i := range books {
+		books[i] = r.s.withCopies(books[i])
+	}
+	return books
+}
+
+func (r memoryBooks) List() ([]models.Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.find(nil), nil
+}
+
+func (r memoryBooks) SearchByTitle(title string) ([]models.Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	title = strings.ToLower(title)
+	return r.find(func(b models.Book) bool { return strings.Contains(strings.ToLower(b.Title), title) }), nil
+}
+
+func (r memoryBooks) Get(id uint) (models.Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	book, ok := r.s.data.books[id]
+	if !ok {
+		return models.Book{}, ErrNotFound
+	}
+	return r.s.withCopies(book), nil
+}
+
+func (r memoryBooks) Create(book *models.Book) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.s.checkSerials(book.Copies); err != nil {
+		return err
+	}
+	book.ID = r.s.newID()
+	for i := range book.Copies {
+		book.Copies[i].BookID = book.ID
+		r.s.addCopy(&book.Copies[i])
+	}
+	stored := *book
+	stored.Copies = nil
+	r.s.data.books[book.ID] = stored
+	return nil
+}
+
+func (r memoryBooks) Save(book *models.Book) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if book.ID == 0 {
+		book.ID = r.s.newID()
+	}
+	stored := *book
+	stored.Copies = nil
+	r.s.data.books[book.ID] = stored
+	return nil
+}
+
+func (r memoryBooks) Delete(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for copyID, bookCopy := range r.s.data.copies {
+		if bookCopy.BookID == id {
+			delete(r.s.data.copies, copyID)
+		}
+	}
+	delete(r.s.data.books, id)
+	return nil
+}
+
+func (r memoryBooks) GetCopy(id uint) (models.Copy, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	bookCopy, ok := r.s.data.copies[id]
+	if !ok {
+		return models.Copy{}, ErrNotFound
+	}
+	return bookCopy, nil
+}
+
+func (r memoryBooks) GetCopyBySerial(serialNumber string) (models.Copy, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, bookCopy := range r.s.data.copies {
+		if bookCopy.SerialNumber == serialNumber {
+			return bookCopy, nil
+		}
+	}
+	return models.Copy{}, ErrNotFound
+}
+
+func (r memoryBooks) AddCopies(copies []models.Copy) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if err := r.s.checkSerials(copies); err != nil {
+		return err
+	}
+	for i := range copies {
+		r.s.addCopy(&copies[i])
+	}
+	return nil
+}
+
+// checkSerials rejects serial numbers that are already taken
+func (s *MemoryStore) checkSerials(copies []models.Copy) error {
+	seen := map[string]bool{}
+	for _, existing := range s.data.copies {
+		seen[existing.SerialNumber] = true
+	}
+	for _, bookCopy := range copies {
+		if seen[bookCopy.SerialNumber] {
+			return ErrConflict
+		}
+		seen[bookCopy.SerialNumber] = true
+	}
+	return nil
+}
+
+func (s *MemoryStore) addCopy(bookCopy *models.Copy) {
+	bookCopy.ID = s.newID()
+	if bookCopy.Status == "" {
+		bookCopy.Status = models.CopyAvailable
+	}
+	if bookCopy.ItemType == "" {
+		bookCopy.ItemType = models.ItemGeneral
+	}
+	stored := *bookCopy
+	stored.Book = nil
+	s.data.copies[stored.ID] = stored
+}
+
+func (r memoryBooks) SaveCopy(bookCopy *models.Copy) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if bookCopy.ID == 0 {
+		r.s.addCopy(bookCopy)
+		return nil
+	}
+	stored := *bookCopy
+	stored.Book = nil
+	r.s.data.copies[stored.ID] = stored
+	return nil
+}
+
+func (r memoryBooks) SetCopyStatus(id uint, from, to string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	bookCopy, ok := r.s.data.copies[id]
+	if !ok || bookCopy.Status != from {
+		return ErrConflict
+	}
+	bookCopy.Status = to
+	r.s.data.copies[id] = bookCopy
+	return nil
+}
+
+func (r memoryBooks) CountCopies(bookID uint, status string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, bookCopy := range r.s.data.copies {
+		if bookCopy.BookID == bookID && bookCopy.Status == status {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryTransactions struct{ s *MemoryStore }
+
+// withDetails attaches the student and copy of a stored loan
+func (s *MemoryStore) withDetails(transaction models.Transaction) models.Transaction {
+	for _, student := range s.data.students {
+		if student.USN == transaction.StudentUSN {
+			transaction.Student = student
+		}
+	}
+	if bookCopy, ok := s.data.copies[transaction.CopyID]; ok {
+		if book, ok := s.data.books[bookCopy.BookID]; ok {
+			bookCopy.Book = &book
+		}
+		transaction.Copy = bookCopy
+	}
+	return transaction
+}
+
+func (r memoryTransactions) Find(filter TransactionFilter) ([]models.Transaction, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	transactions := sortedValues(r.s.data.transactions, func(t models.Transaction) bool {
+		switch {
+		case filter.StudentUSN != "" && t.StudentUSN != filter.StudentUSN:
+			return false
+		case filter.SerialNumber != "" && r.s.data.copies[t.CopyID].SerialNumber != filter.SerialNumber:
+			return false
+		case filter.OpenOnly && t.ReturnDate != nil:
+			return false
+		case !filter.DueFrom.IsZero() && t.DueDate.Before(filter.DueFrom):
+			return false
+		case !filter.DueBefore.IsZero() && !t.DueDate.Before(filter.DueBefore):
+			return false
+		}
+		return true
+	})
+	for i := range transactions {
+		transactions[i] = r.s.withDetails(transactions[i])
+	}
+	return transactions, nil
+}
+
+func (r memoryTransactions) Get(id uint) (models.Transaction, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	transaction, ok := r.s.data.transactions[id]
+	if !ok {
+		return models.Transaction{}, ErrNotFound
+	}
+	return r.s.withDetails(transaction), nil
+}
+
+func (r memoryTransactions) Create(transaction *models.Transaction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	transaction.ID = r.s.newID()
+	r.s.storeTransaction(*transaction)
+	return nil
+}
+
+func (r memoryTransactions) Save(transaction *models.Transaction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if transaction.ID == 0 {
+		transaction.ID = r.s.newID()
+	}
+	r.s.storeTransaction(*transaction)
+	return nil
+}
+
+func (r memoryTransactions) MarkReturned(transaction models.Transaction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	stored, ok := r.s.data.transactions[transaction.ID]
+	if !ok || stored.ReturnDate != nil {
+		return ErrConflict
+	}
+	stored.ReturnDate = transaction.ReturnDate
+	stored.LateFeeMinor = transaction.LateFeeMinor
+	r.s.data.transactions[stored.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) storeTransaction(transaction models.Transaction) {
+	transaction.Student = models.Student{}
+	transaction.Copy = models.Copy{}
+	s.data.transactions[transaction.ID] = transaction
+}
+
+func (r memoryTransactions) Delete(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.transactions, id)
+	return nil
+}
+
+func (r memoryTransactions) CountOpen(studentUSN string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var count int64
+	for _, transaction := range r.s.data.transactions {
+		if transaction.StudentUSN == studentUSN && transaction.ReturnDate == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryVendors struct{ s *MemoryStore }
+
+func (r memoryVendors) List() ([]models.Vendor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return sortedValues(r.s.data.vendors, nil), nil
+}
+
+func (r memoryVendors) Get(id uint) (models.Vendor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	vendor, ok := r.s.data.vendors[id]
+	if !ok {
+		return models.Vendor{}, ErrNotFound
+	}
+	return vendor, nil
+}
+
+func (r memoryVendors) Create(vendor *models.Vendor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	vendor.ID = r.s.newID()
+	r.s.data.vendors[vendor.ID] = *vendor
+	return nil
+}
+
+func (r memoryVendors) Delete(id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.data.vendors, id)
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"library-management/models"
+)
+
+func TestAtomicRollsBack(t *testing.T) {
+	s := NewMemoryStore()
+	book := models.Book{Title: "Go", Copies: []models.Copy{{SerialNumber: "S-1"}}}
+	if err := s.Books().Create(&book); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+	err := s.Atomic(func(tx Store) error {
+		if err := tx.Books().SetCopyStatus(book.Copies[0].ID, models.CopyAvailable, models.CopyIssued); err != nil {
+			return err
+		}
+		if err := tx.Students().Create(&models.Student{USN: "1AB21CS001", Name: "Asha"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Atomic returned %v", err)
+	}
+
+	bookCopy, _ := s.Books().GetCopy(book.Copies[0].ID)
+	if bookCopy.Status != models.CopyAvailable {
+		t.Errorf("copy is %s after a rollback", bookCopy.Status)
+	}
+	if _, err := s.Students().GetByUSN("1AB21CS001"); err != ErrNotFound {
+		t.Errorf("the student was kept after a rollback: %v", err)
+	}
+}
+
+func TestAtomicCommits(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.Atomic(func(tx Store) error {
+		return tx.Students().Create(&models.Student{USN: "1AB21CS001", Name: "Asha"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Students().GetByUSN("1ab21cs001"); err != nil {
+		t.Errorf("the student was not committed: %v", err)
+	}
+	// Ids keep counting from the committed data
+	other := models.Student{USN: "1AB21CS002", Name: "Ravi"}
+	if err := s.Students().Create(&other); err != nil {
+		t.Fatal(err)
+	}
+	if other.ID <= 1 {
+		t.Errorf("the next student got id %d", other.ID)
+	}
+}
+
+func TestMarkReturned(t *testing.T) {
+	s := NewMemoryStore()
+	transaction := models.Transaction{StudentUSN: "1AB21CS001", CopyID: 1, DueDate: time.Now()}
+	if err := s.Transactions().Create(&transaction); err != nil {
+		t.Fatal(err)
+	}
+
+	returned := time.Now()
+	transaction.ReturnDate = &returned
+	transaction.LateFeeMinor = 300
+	if err := s.Transactions().MarkReturned(transaction); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.Transactions().Get(transaction.ID)
+	if stored.ReturnDate == nil || stored.LateFeeMinor != 300 {
+		t.Errorf("stored loan = %+v", stored)
+	}
+
+	transaction.LateFeeMinor = 900
+	if err := s.Transactions().MarkReturned(transaction); err != ErrConflict {
+		t.Errorf("returning twice gave %v", err)
+	}
+	if stored, _ := s.Transactions().Get(transaction.ID); stored.LateFeeMinor != 300 {
+		t.Errorf("a second return changed the late fee to %d", stored.LateFeeMinor)
+	}
+}
@@ -0,0 +1,98 @@
+// Package repository hides how students, books, loans and vendors are
+// stored behind small interfaces, so handlers and services do not build
+// queries themselves.
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"library-management/models"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a conditional update finds the record
+	// changed or a unique value already taken
+	ErrConflict = errors.New("record was changed or already exists")
+)
+
+// StudentRepository stores library members
+type StudentRepository interface {
+	List() ([]models.Student, error)
+	Get(id uint) (models.Student, error)
+	// GetByUSN ignores case and surrounding spaces
+	GetByUSN(usn string) (models.Student, error)
+	// LockByUSN holds the student's record until the unit of work ends, so
+	// changes checked against the student's loans are made one at a time
+	LockByUSN(usn string) error
+	Create(student *models.Student) error
+	// Update copies the non-zero fields of changes onto the student
+	Update(student *models.Student, changes models.Student) error
+	Delete(id uint) error
+}
+
+// BookRepository stores titles and their physical copies. Books are
+// returned with their copies.
+type BookRepository interface {
+	List() ([]models.Book, error)
+	SearchByTitle(title string) ([]models.Book, error)
+	Get(id uint) (models.Book, error)
+	// Create stores the book together with its copies
+	Create(book *models.Book) error
+	Save(book *models.Book) error
+	// Delete removes the book and all of its copies
+	Delete(id uint) error
+
+	GetCopy(id uint) (models.Copy, error)
+	GetCopyBySerial(serialNumber string) (models.Copy, error)
+	AddCopies(copies []models.Copy) error
+	SaveCopy(bookCopy *models.Copy) error
+	// SetCopyStatus moves a copy from one status to another and returns
+	// ErrConflict if the copy is no longer in the from status
+	SetCopyStatus(id uint, from, to string) error
+	CountCopies(bookID uint, status string) (int64, error)
+}
+
+// TransactionFilter narrows a loan search. Zero fields do not filter.
+type TransactionFilter struct {
+	StudentUSN   string
+	SerialNumber string
+	OpenOnly     bool
+	DueFrom      time.Time // Due on or after
+	DueBefore    time.Time
+}
+
+// TransactionRepository stores loans. Loans are returned with their
+// student and copy.
+type TransactionRepository interface {
+	Find(filter TransactionFilter) ([]models.Transaction, error)
+	Get(id uint) (models.Transaction, error)
+	Create(transaction *models.Transaction) error
+	Save(transaction *models.Transaction) error
+	// MarkReturned stores the return date and late fee of an open loan and
+	// returns ErrConflict if the loan has already been returned
+	MarkReturned(transaction models.Transaction) error
+	Delete(id uint) error
+	CountOpen(studentUSN string) (int64, error)
+}
+
+// VendorRepository stores the suppliers copies are bought from
+type VendorRepository interface {
+	List() ([]models.Vendor, error)
+	Get(id uint) (models.Vendor, error)
+	Create(vendor *models.Vendor) error
+	Delete(id uint) error
+}
+
+// Store gives access to all repositories of one backend
+type Store interface {
+	Students() StudentRepository
+	Books() BookRepository
+	Transactions() TransactionRepository
+	Vendors() VendorRepository
+
+	// Atomic runs fn against a store whose changes are kept only if fn
+	// returns nil
+	Atomic(fn func(Store) error) error
+}
@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"library-management/config"
+)
+
+// chunkSize is how much of the file is sent to clamd at a time
+const chunkSize = 64 << 10
+
+// Clamd scans files with a ClamAV daemon, streaming them over its socket
+// with the INSTREAM command
+type Clamd struct {
+	network string // unix or tcp
+	address string
+	timeout time.Duration
+}
+
+// NewClamd returns a scanner for the daemon at an address such as
+// "unix:/run/clamav/clamd.ctl" or "tcp:127.0.0.1:3310"
+func NewClamd(cfg config.ScannerConfig) (*Clamd, error) {
+	network, address, _ := strings.Cut(cfg.ClamdAddress, ":")
+	if (network != "unix" && network != "tcp") || address == "" {
+		return nil, fmt.Errorf("invalid clamd address %q", cfg.ClamdAddress)
+	}
+	return &Clamd{network: network, address: address, timeout: time.Duration(cfg.Timeout)}, nil
+}
+
+func (s *Clamd) Scan(ctx context.Context, r io.Reader) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The file goes in chunks, each after its length; an empty chunk ends it
+	sendErr := func() error {
+		if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+			return err
+		}
+		buf := make([]byte, 4+chunkSize)
+		for {
+			n, err := io.ReadFull(r, buf[4:])
+			if n > 0 {
+				binary.BigEndian.PutUint32(buf, uint32(n))
+				if _, err := conn.Write(buf[:4+n]); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err := conn.Write([]byte{0, 0, 0, 0})
+		return err
+	}()
+
+	// clamd may answer early, for instance when the file is over its
+	// StreamMaxLength, so its reply explains a failed write
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if sendErr != nil {
+			return fmt.Errorf("clamd: %w", sendErr)
+		}
+		return fmt.Errorf("clamd: reading the reply: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// parseReply reads a reply such as "stream: OK", "stream: Eicar-Signature
+// FOUND" or "INSTREAM size limit exceeded. ERROR"
+func parseReply(reply string) error {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := reply
+	if i := strings.LastIndex(reply, ": "); i >= 0 {
+		result = reply[i+2:]
+	}
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &InfectedError{Signature: strings.TrimSuffix(result, " FOUND")}
+	}
+	return fmt.Errorf("clamd: %s", reply)
+}
@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"library-management/config"
+)
+
+func TestParseReply(t *testing.T) {
+	if err := parseReply("stream: OK\x00"); err != nil {
+		t.Errorf("OK gave %v", err)
+	}
+
+	var infected *InfectedError
+	if err := parseReply("stream: Eicar-Signature FOUND\x00"); !errors.As(err, &infected) || infected.Signature != "Eicar-Signature" {
+		t.Errorf("FOUND gave %v", err)
+	}
+
+	err := parseReply("INSTREAM size limit exceeded. ERROR\x00")
+	if err == nil || errors.As(err, &infected) || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("ERROR gave %v", err)
+	}
+}
+
+// fakeClamd answers INSTREAM requests on a unix socket. Streams containing
+// "EICAR" are reported infected; limit, if set, makes it stop reading and
+// answer with an error once a stream is longer.
+func fakeClamd(t *testing.T, limit int) string {
+	t.Helper()
+	address := filepath.Join(t.TempDir(), "clamd.ctl")
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var stream bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&stream, r, int64(size)); err != nil {
+						return
+					}
+					if limit > 0 && stream.Len() > limit {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+				}
+				if bytes.Contains(stream.Bytes(), []byte("EICAR")) {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return "unix:" + address
+}
+
+func TestClamdScan(t *testing.T) {
+	s, err := New(config.ScannerConfig{Driver: config.ScannerClamd, ClamdAddress: fakeClamd(t, 0), Timeout: config.Duration(5 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Larger than a chunk, so the file goes in several
+	clean := bytes.Repeat([]byte("clean "), chunkSize/3)
+	if err := s.Scan(context.Background(), bytes.NewReader(clean)); err != nil {
+		t.Errorf("a clean file gave %v", err)
+	}
+
+	infected := append(bytes.Repeat([]byte("x"), chunkSize), []byte("EICAR")...)
+	var infectedErr *InfectedError
+	if err := s.Scan(context.Background(), bytes.NewReader(infected)); !errors.As(err, &infectedErr) {
+		t.Errorf("an infected file gave %v", err)
+	}
+}
+
+func TestClamdScanReportsEarlyReplies(t *testing.T) {
+	s, err := NewClamd(config.ScannerConfig{ClamdAddress: fakeClamd(t, chunkSize), Timeout: config.Duration(5 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := bytes.Repeat([]byte("x"), 64*chunkSize)
+	if err := s.Scan(context.Background(), bytes.NewReader(big)); err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("a file over the limit gave %v", err)
+	}
+}
+
+func TestClamdScanFailsWithoutTheDaemon(t *testing.T) {
+	s, err := NewClamd(config.ScannerConfig{ClamdAddress: "unix:" + filepath.Join(t.TempDir(), "missing.ctl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Scan(context.Background(), strings.NewReader("file")); err == nil {
+		t.Error("scanning without a daemon succeeded")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if s, err := New(config.ScannerConfig{}); err != nil || s != (Nop{}) {
+		t.Errorf("no driver gave %v, %v", s, err)
+	}
+	for _, cfg := range []config.ScannerConfig{
+		{Driver: "antivirus"},
+		{Driver: config.ScannerClamd, ClamdAddress: "127.0.0.1:3310"},
+		{Driver: config.ScannerClamd, ClamdAddress: "tcp:"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%+v: no error", cfg)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package scanner checks uploaded files for malware before they are
+// stored. The scanner is chosen in the settings: none, or a ClamAV daemon.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"library-management/config"
+)
+
+// Scanner checks a file for malware
+type Scanner interface {
+	// Scan reads r to the end. It returns an *InfectedError if malware is
+	// found, or another error if the file could not be scanned.
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+// InfectedError reports malware found in a file
+type InfectedError struct {
+	Signature string // The scanner's name for what it found
+}
+
+func (e *InfectedError) Error() string {
+	return "malware found: " + e.Signature
+}
+
+// New returns the scanner the settings describe
+func New(cfg config.ScannerConfig) (Scanner, error) {
+	switch cfg.Driver {
+	case config.ScannerNone, "":
+		return Nop{}, nil
+	case config.ScannerClamd:
+		return NewClamd(cfg)
+	}
+	return nil, fmt.Errorf("unknown scanner driver %q", cfg.Driver)
+}
+
+// Nop accepts every file without looking at it
+type Nop struct{}
+
+func (Nop) Scan(ctx context.Context, r io.Reader) error {
+	return nil
+}
@@ -0,0 +1,241 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"library-management/models"
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobLocked  = errors.New("job is already running")
+)
+
+// Job is a named task run on a cron schedule. Run returns a short summary
+// that is stored with the run record.
+type Job struct {
+	Name string
+	Spec string // Standard five-field cron expression
+	Run  func(ctx context.Context) (string, error)
+}
+
+// JobInfo describes a registered job for the admin API
+type JobInfo struct {
+	Name    string         `json:"name"`
+	Spec    string         `json:"schedule"`
+	NextRun *time.Time     `json:"next_run,omitempty"`
+	LastRun *models.JobRun `json:"last_run,omitempty"`
+}
+
+// Scheduler runs jobs on their schedules. A lease row in job_locks makes
+// sure only one replica runs a given job at a time.
+type Scheduler struct {
+	db      *gorm.DB
+	cron    *cron.Cron
+	owner   string
+	lockTTL time.Duration
+	jobs    map[string]*Job
+	entries map[string]cron.EntryID
+	started bool
+
+	ctx       context.Context // Cancelled by Stop to end running jobs
+	cancel    context.CancelFunc
+	triggered sync.WaitGroup // Runs started by Trigger
+}
+
+// New creates a scheduler whose locks are held for lockTTL while a job runs
+func New(db *gorm.DB, lockTTL time.Duration) *Scheduler {
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:      db,
+		cron:    cron.New(cron.WithLocation(time.Local)),
+		owner:   fmt.Sprintf("%s:%d", host, os.Getpid()),
+		lockTTL: lockTTL,
+		jobs:    map[string]*Job{},
+		entries: map[string]cron.EntryID{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(job Job) error {
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("job %q is already registered", job.Name)
+	}
+	id, err := s.cron.AddFunc(job.Spec, func() { s.run(&job, models.TriggerSchedule, nil) })
+	if err != nil {
+		return fmt.Errorf("job %q: invalid schedule %q: %w", job.Name, job.Spec, err)
+	}
+	s.jobs[job.Name] = &job
+	s.entries[job.Name] = id
+	return nil
+}
+
+// Start begins running jobs on their schedules
+func (s *Scheduler) Start() {
+	s.cron.Start()
+	s.started = true
+	log.Printf("Scheduler started as %s with %d jobs", s.owner, len(s.jobs))
+}
+
+// Stop stops the schedule, cancels the context of running jobs and waits
+// for them to finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	<-s.cron.Stop().Done()
+	s.triggered.Wait()
+}
+
+// Jobs lists the registered jobs with their next and most recent runs
+func (s *Scheduler) Jobs() []JobInfo {
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for name, job := range s.jobs {
+		info := JobInfo{Name: name, Spec: job.Spec}
+		if s.started {
+			next := s.cron.Entry(s.entries[name]).Next
+			info.NextRun = &next
+		}
+		var last models.JobRun
+		if err := s.db.Where("job_name = ?", name).Order("started_at desc").First(&last).Error; err == nil {
+			info.LastRun = &last
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Runs returns the most recent runs of a job, newest first
+func (s *Scheduler) Runs(name string, limit int) ([]models.JobRun, error) {
+	if _, ok := s.jobs[name]; !ok {
+		return nil, ErrUnknownJob
+	}
+	runs := []models.JobRun{}
+	err := s.db.Where("job_name = ?", name).Order("started_at desc").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// Trigger runs a job now in the background on behalf of a user. It returns
+// the run record, or ErrJobLocked if the job is running on any instance.
+func (s *Scheduler) Trigger(name string, userID uint) (*models.JobRun, error) {
+	job, ok := s.jobs[name]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	run, err := s.begin(job, models.TriggerManual, &userID)
+	if err != nil {
+		return nil, err
+	}
+	s.triggered.Add(1)
+	go func() {
+		defer s.triggered.Done()
+		s.finish(job, run)
+	}()
+	return run, nil
+}
+
+// run is called by cron on the job's schedule
+func (s *Scheduler) run(job *Job, trigger string, userID *uint) {
+	run, err := s.begin(job, trigger, userID)
+	if errors.Is(err, ErrJobLocked) {
+		return // Another replica has it
+	}
+	if err != nil {
+		log.Printf("Job %s: %v", job.Name, err)
+		return
+	}
+	s.finish(job, run)
+}
+
+// begin takes the job's lock and records the start of a run
+func (s *Scheduler) begin(job *Job, trigger string, userID *uint) (*models.JobRun, error) {
+	now := time.Now()
+	acquired, err := s.lock(job.Name, now)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrJobLocked
+	}
+
+	run := models.JobRun{
+		JobName:   job.Name,
+		Trigger:   trigger,
+		Status:    models.JobRunning,
+		Owner:     s.owner,
+		UserID:    userID,
+		StartedAt: now,
+	}
+	if err := s.db.Create(&run).Error; err != nil {
+		s.unlock(job.Name, now)
+		return nil, err
+	}
+	return &run, nil
+}
+
+// finish runs the job and stores its outcome
+func (s *Scheduler) finish(job *Job, run *models.JobRun) {
+	defer func() {
+		// Scheduled runs keep the lease until a minute after they started so
+		// a replica whose clock is a few seconds behind does not run the same
+		// slot again
+		until := time.Now()
+		if run.Trigger == models.TriggerSchedule && until.Before(run.StartedAt.Add(time.Minute)) {
+			until = run.StartedAt.Add(time.Minute)
+		}
+		s.unlock(job.Name, until)
+	}()
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.lockTTL)
+	defer cancel()
+
+	message, err := job.Run(ctx)
+	finished := time.Now()
+	run.Status = models.JobSucceeded
+	run.Message = message
+	if err != nil {
+		run.Status = models.JobFailed
+		run.Message = err.Error()
+		log.Printf("Job %s failed: %v", job.Name, err)
+	}
+	run.FinishedAt = &finished
+	if err := s.db.Save(run).Error; err != nil {
+		log.Printf("Job %s: error saving run: %v", job.Name, err)
+	}
+}
+
+// lock takes the job's lease if it is free or has expired
+func (s *Scheduler) lock(name string, now time.Time) (bool, error) {
+	err := s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.JobLock{Name: name}).Error
+	if err != nil {
+		return false, err
+	}
+
+	result := s.db.Model(&models.JobLock{}).
+		Where("name = ? AND locked_until < ?", name, now).
+		Updates(map[string]interface{}{"owner": s.owner, "locked_until": now.Add(s.lockTTL)})
+	return result.RowsAffected == 1, result.Error
+}
+
+// unlock ends our lease on the job at until
+func (s *Scheduler) unlock(name string, until time.Time) {
+	err := s.db.Model(&models.JobLock{}).
+		Where("name = ? AND owner = ?", name, s.owner).
+		Update("locked_until", until).Error
+	if err != nil {
+		log.Printf("Job %s: error releasing lock: %v", name, err)
+	}
+}
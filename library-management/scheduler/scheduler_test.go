@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"library-management/database/databasetest"
+	"library-management/models"
+)
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	db := databasetest.Open(t)
+	s := New(db, time.Minute)
+	started := make(chan struct{})
+	err := s.Add(Job{Name: "slow", Spec: "0 0 1 1 *", Run: func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+
+	run, err := s.Trigger("slow", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Trigger("slow", 1); err != ErrJobLocked {
+		t.Errorf("a second run while the first was running gave %v", err)
+	}
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not end the running job")
+	}
+
+	// Stop waited for the run to be recorded and its lock released
+	db.First(run, run.ID)
+	if run.Status != models.JobFailed || run.Message != context.Canceled.Error() {
+		t.Errorf("run = %s %q", run.Status, run.Message)
+	}
+	var lock models.JobLock
+	db.First(&lock, "name = ?", "slow")
+	if lock.LockedUntil.After(time.Now()) {
+		t.Errorf("the lock is held until %v", lock.LockedUntil)
+	}
+}
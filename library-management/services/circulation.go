@@ -0,0 +1,274 @@
+// Package services holds the library's business rules on top of the
+// repositories, independent of HTTP and of the storage backend.
+package services
+
+import (
+	"errors"
+	"time"
+
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/repository"
+)
+
+var (
+	ErrStudentNotFound     = errors.New("student not found")
+	ErrCopyNotFound        = errors.New("book not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrCopyJustIssued      = errors.New("this copy has just been issued")
+)
+
+// CopyUnavailableError is returned when a copy's status does not allow it
+// to be issued
+type CopyUnavailableError struct {
+	Status string
+}
+
+func (e CopyUnavailableError) Error() string {
+	return "this copy is not available for issue (status: " + e.Status + ")"
+}
+
+// Policy holds the circulation rules kept outside the repositories: loan
+// rules, the opening calendar, holds, fines and the loan history. Every
+// method is given the store of the unit of work it runs in.
+type Policy interface {
+	Rule(store repository.Store, student models.Student, bookCopy models.Copy) (models.CirculationRule, error)
+	// HoldForCheckout returns the student's hold that a loan of the copy
+	// fulfils, or an error if another student's hold comes first
+	HoldForCheckout(store repository.Store, student models.Student, bookCopy models.Copy) (*models.Hold, error)
+	CheckBalance(store repository.Store, student models.Student) error
+	DueDate(store repository.Store, rule models.CirculationRule, issued time.Time) (time.Time, error)
+	LateFee(store repository.Store, rule models.CirculationRule, due, returned time.Time) (int64, error)
+
+	// Issued records a new loan and fulfils the hold it was issued for
+	Issued(store repository.Store, transaction models.Transaction, hold *models.Hold, userID uint) error
+	// Returned records a return and charges its late fee, if any
+	Returned(store repository.Store, transaction models.Transaction, userID uint) (*models.LedgerEntry, error)
+	// Reshelve traps a returned copy for the next hold or puts it back on
+	// the shelf. It returns the hold that became ready.
+	Reshelve(store repository.Store, bookCopy models.Copy) (*models.Hold, error)
+	Renew(store repository.Store, transaction *models.Transaction, bookCopy models.Copy, rule models.CirculationRule, userID uint) error
+}
+
+// Circulation issues, returns and renews loans
+type Circulation struct {
+	Store  repository.Store
+	Policy Policy
+	Now    func() time.Time
+}
+
+// NewCirculation returns the circulation service for a store
+func NewCirculation(store repository.Store, policy Policy) *Circulation {
+	return &Circulation{Store: store, Policy: policy, Now: time.Now}
+}
+
+// Checkout issues the copy with the serial number to the student
+func (s *Circulation) Checkout(studentUSN, serialNumber string, userID uint) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := s.Store.Atomic(func(store repository.Store) error {
+		// Checkouts for one student run one at a time, so two desks cannot
+		// both pass the loan limit or the hold queue
+		err := store.Students().LockByUSN(studentUSN)
+		if err == repository.ErrNotFound {
+			return ErrStudentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		student, err := store.Students().GetByUSN(studentUSN)
+		if err != nil {
+			return err
+		}
+
+		bookCopy, err := store.Books().GetCopyBySerial(serialNumber)
+		if err == repository.ErrNotFound {
+			return ErrCopyNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Only copies on the shelf or trapped for a hold can be issued
+		if bookCopy.Status != models.CopyAvailable && bookCopy.Status != models.CopyOnHold {
+			return CopyUnavailableError{Status: bookCopy.Status}
+		}
+
+		// Waiting and ready holds reserve the title for the students in the queue
+		hold, err := s.Policy.HoldForCheckout(store, student, bookCopy)
+		if err != nil {
+			return err
+		}
+
+		rule, err := s.Policy.Rule(store, student, bookCopy)
+		if err != nil {
+			return err
+		}
+		if err := checkLoanAllowed(store, rule, student); err != nil {
+			return err
+		}
+
+		// Students with unpaid fines over the limit cannot borrow
+		if err := s.Policy.CheckBalance(store, student); err != nil {
+			return err
+		}
+
+		now := s.Now()
+		dueDate, err := s.Policy.DueDate(store, rule, now)
+		if err != nil {
+			return err
+		}
+		transaction = models.Transaction{
+			StudentUSN: student.USN,
+			CopyID:     bookCopy.ID,
+			IssueDate:  now,
+			DueDate:    dueDate,
+		}
+
+		// The status condition stops two desks issuing the same copy at once
+		err = store.Books().SetCopyStatus(bookCopy.ID, bookCopy.Status, models.CopyIssued)
+		if err == repository.ErrConflict {
+			return ErrCopyJustIssued
+		}
+		if err != nil {
+			return err
+		}
+		if err := store.Transactions().Create(&transaction); err != nil {
+			return err
+		}
+		return s.Policy.Issued(store, transaction, hold, userID)
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return transaction, nil
+}
+
+// checkLoanAllowed verifies that the rule lets the student take another loan
+func checkLoanAllowed(store repository.Store, rule models.CirculationRule, student models.Student) error {
+	if rule.LoanPeriodDays <= 0 {
+		return circulation.ErrNotLoanable
+	}
+	openLoans, err := store.Transactions().CountOpen(student.USN)
+	if err != nil {
+		return err
+	}
+	if rule.MaxLoans > 0 && openLoans >= int64(rule.MaxLoans) {
+		return circulation.ErrLoanLimitReached
+	}
+	return nil
+}
+
+// ReturnResult is a returned loan and what the return set off
+type ReturnResult struct {
+	Transaction models.Transaction
+	LateFee     *models.LedgerEntry // The fine charged for a late return
+	ReadyHold   *models.Hold        // The hold the copy was trapped for
+}
+
+// Return checks a loan back in, charging the late fee and passing the copy
+// on to the next hold
+func (s *Circulation) Return(transactionID uint, userID uint) (ReturnResult, error) {
+	transaction, err := s.Store.Transactions().Get(transactionID)
+	if err == repository.ErrNotFound {
+		return ReturnResult{}, ErrTransactionNotFound
+	}
+	if err != nil {
+		return ReturnResult{}, err
+	}
+	if transaction.ReturnDate != nil {
+		return ReturnResult{}, circulation.ErrAlreadyReturned
+	}
+
+	// Look up the fine rate for this student and item
+	student, err := s.Store.Students().GetByUSN(transaction.StudentUSN)
+	if err != nil && err != repository.ErrNotFound {
+		return ReturnResult{}, err
+	}
+	bookCopy, err := s.Store.Books().GetCopy(transaction.CopyID)
+	if err != nil && err != repository.ErrNotFound {
+		return ReturnResult{}, err
+	}
+	rule, err := s.Policy.Rule(s.Store, student, bookCopy)
+	if err != nil {
+		return ReturnResult{}, err
+	}
+
+	now := s.Now()
+	fee, err := s.Policy.LateFee(s.Store, rule, transaction.DueDate, now)
+	if err != nil {
+		return ReturnResult{}, err
+	}
+	transaction.ReturnDate = &now
+	transaction.LateFeeMinor = fee
+
+	// Only one return of a loan counts, so concurrent returns cannot both
+	// charge the late fee
+	result := ReturnResult{Transaction: transaction}
+	err = s.Store.Atomic(func(store repository.Store) error {
+		err := store.Transactions().MarkReturned(result.Transaction)
+		if err == repository.ErrConflict {
+			return circulation.ErrAlreadyReturned
+		}
+		if err != nil {
+			return err
+		}
+		if result.LateFee, err = s.Policy.Returned(store, result.Transaction, userID); err != nil {
+			return err
+		}
+		result.ReadyHold, err = s.Policy.Reshelve(store, bookCopy)
+		return err
+	})
+	if err != nil {
+		return ReturnResult{}, err
+	}
+	return result, nil
+}
+
+// Renew extends an open loan under the rule for its student and copy
+func (s *Circulation) Renew(transaction *models.Transaction, userID uint) error {
+	rule, err := s.Policy.Rule(s.Store, transaction.Student, transaction.Copy)
+	if err != nil {
+		return err
+	}
+	return s.Policy.Renew(s.Store, transaction, transaction.Copy, rule, userID)
+}
+
+// Delete removes a loan. The copy of an open loan is reshelved like a
+// returned one, so it goes to the next student waiting for the title, whose
+// hold is returned.
+func (s *Circulation) Delete(transactionID uint) (*models.Hold, error) {
+	transaction, err := s.Store.Transactions().Get(transactionID)
+	if err == repository.ErrNotFound {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ready *models.Hold
+	err = s.Store.Atomic(func(store repository.Store) error {
+		if err := store.Transactions().Delete(transaction.ID); err != nil {
+			return err
+		}
+		if transaction.ReturnDate != nil {
+			return nil
+		}
+		// Only a copy still out on the loan is reshelved
+		bookCopy, err := store.Books().GetCopy(transaction.CopyID)
+		if err == repository.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if bookCopy.Status != models.CopyIssued {
+			return nil
+		}
+		ready, err = s.Policy.Reshelve(store, bookCopy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ready, nil
+}
@@ -0,0 +1,409 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/database/databasetest"
+	"library-management/models"
+	"library-management/repository"
+)
+
+// library is a database with two students and a book with two copies, and
+// the circulation service for it at a fixed time
+type library struct {
+	db      *gorm.DB
+	store   repository.Store
+	service *Circulation
+	book    models.Book
+	now     time.Time
+}
+
+func newLibrary(t *testing.T) *library {
+	t.Helper()
+	l := &library{
+		db:  databasetest.Open(t),
+		now: time.Date(2026, 10, 5, 10, 0, 0, 0, time.UTC), // A Monday
+	}
+	l.service = NewGormCirculation(l.db)
+	l.service.Now = func() time.Time { return l.now }
+	l.store = l.service.Store
+
+	l.book = models.Book{Title: "Go", Author: "Donovan", Edition: 1, Copies: []models.Copy{
+		{SerialNumber: "S-1", Status: models.CopyAvailable},
+		{SerialNumber: "S-2", Status: models.CopyAvailable, ItemType: models.ItemReference},
+	}}
+	l.create(t,
+		&models.Student{USN: "1AB21CS001", Name: "Asha", Category: "UG"},
+		&models.Student{USN: "1AB21CS002", Name: "Ravi", Category: "PG"},
+		&l.book,
+	)
+	return l
+}
+
+// create stores records such as rules, holidays and ledger entries
+func (l *library) create(t *testing.T, records ...interface{}) {
+	t.Helper()
+	for _, record := range records {
+		if err := l.db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// placeHold queues the student for the book, whether or not a copy is on
+// the shelf
+func (l *library) placeHold(t *testing.T, usn string) {
+	t.Helper()
+	l.create(t, &models.Hold{StudentUSN: usn, BookID: l.book.ID, Status: models.HoldWaiting, PlacedAt: time.Now()})
+}
+
+func (l *library) holds(t *testing.T) []models.Hold {
+	t.Helper()
+	var holds []models.Hold
+	if err := l.db.Order("id").Find(&holds).Error; err != nil {
+		t.Fatal(err)
+	}
+	return holds
+}
+
+func (l *library) ledger(t *testing.T) []models.LedgerEntry {
+	t.Helper()
+	var entries []models.LedgerEntry
+	if err := l.db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func (l *library) events(t *testing.T, transactionID uint) []models.TransactionEvent {
+	t.Helper()
+	var events []models.TransactionEvent
+	if err := l.db.Where("transaction_id = ?", transactionID).Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func (l *library) copyStatus(t *testing.T, serial string) string {
+	t.Helper()
+	bookCopy, err := l.store.Books().GetCopyBySerial(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bookCopy.Status
+}
+
+func TestCheckout(t *testing.T) {
+	l := newLibrary(t)
+	l.create(t,
+		&models.CirculationRule{MemberCategory: "UG", LoanPeriodDays: 7, MaxLoans: 2},
+		&models.CirculationRule{ItemType: models.ItemReference, LoanPeriodDays: 0},
+		&models.Holiday{Date: "2026-10-12"},
+	)
+
+	transaction, err := l.service.Checkout("1ab21cs001", "S-1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Due a week later, which is a holiday, so the next day
+	if want := time.Date(2026, 10, 13, 10, 0, 0, 0, time.UTC); !transaction.DueDate.Equal(want) {
+		t.Errorf("due %v, want %v", transaction.DueDate, want)
+	}
+	if transaction.StudentUSN != "1AB21CS001" {
+		t.Errorf("issued to %q", transaction.StudentUSN)
+	}
+	if status := l.copyStatus(t, "S-1"); status != models.CopyIssued {
+		t.Errorf("copy is %s after checkout", status)
+	}
+	if events := l.events(t, transaction.ID); len(events) != 1 || events[0].Type != models.EventIssued || events[0].UserID != 9 {
+		t.Errorf("events = %+v", events)
+	}
+
+	if _, err := l.service.Checkout("1AB21CS002", "S-1", 9); !errors.As(err, &CopyUnavailableError{}) {
+		t.Errorf("issuing an issued copy gave %v", err)
+	}
+	if _, err := l.service.Checkout("1AB21CS002", "S-2", 9); err != circulation.ErrNotLoanable {
+		t.Errorf("issuing a reference copy gave %v", err)
+	}
+	if _, err := l.service.Checkout("nobody", "S-1", 9); err != ErrStudentNotFound {
+		t.Errorf("issuing to an unknown student gave %v", err)
+	}
+	if _, err := l.service.Checkout("1AB21CS002", "S-9", 9); err != ErrCopyNotFound {
+		t.Errorf("issuing an unknown copy gave %v", err)
+	}
+}
+
+func TestCheckoutLimits(t *testing.T) {
+	l := newLibrary(t)
+	l.create(t, &models.CirculationRule{LoanPeriodDays: 14, MaxLoans: 1})
+	if _, err := l.service.Checkout("1AB21CS001", "S-1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.service.Checkout("1AB21CS001", "S-2", 1); err != circulation.ErrLoanLimitReached {
+		t.Errorf("a second loan gave %v", err)
+	}
+
+	l.create(t, &models.LedgerEntry{StudentUSN: "1AB21CS002", Type: models.ChargeLateFee, AmountMinor: circulation.MaxOutstandingBalance + 1})
+	if _, err := l.service.Checkout("1AB21CS002", "S-2", 1); err != circulation.ErrBalanceTooHigh {
+		t.Errorf("borrowing with fines over the limit gave %v", err)
+	}
+	if status := l.copyStatus(t, "S-2"); status != models.CopyAvailable {
+		t.Errorf("a refused checkout left the copy %s", status)
+	}
+}
+
+func TestConcurrentCheckoutsKeepTheLoanLimit(t *testing.T) {
+	l := newLibrary(t)
+	l.create(t, &models.CirculationRule{LoanPeriodDays: 14, MaxLoans: 2})
+	serials := []string{"C-1", "C-2", "C-3", "C-4", "C-5", "C-6", "C-7", "C-8"}
+	var copies []models.Copy
+	for _, serial := range serials {
+		copies = append(copies, models.Copy{BookID: l.book.ID, SerialNumber: serial, Status: models.CopyAvailable})
+	}
+	l.create(t, &copies)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(serials))
+	for _, serial := range serials {
+		wg.Add(1)
+		go func(serial string) {
+			defer wg.Done()
+			_, err := l.service.Checkout("1AB21CS001", serial, 1)
+			errs <- err
+		}(serial)
+	}
+	wg.Wait()
+	close(errs)
+
+	issued := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			issued++
+		case circulation.ErrLoanLimitReached:
+		default:
+			t.Errorf("Checkout: %v", err)
+		}
+	}
+	if open, _ := l.store.Transactions().CountOpen("1AB21CS001"); issued != 2 || open != 2 {
+		t.Errorf("%d checkouts succeeded and %d loans are open, want 2", issued, open)
+	}
+}
+
+func TestCheckoutHonoursHolds(t *testing.T) {
+	l := newLibrary(t)
+	l.placeHold(t, "1AB21CS002")
+
+	if _, err := l.service.Checkout("1AB21CS001", "S-1", 1); err != circulation.ErrHeldForOther {
+		t.Errorf("jumping the queue gave %v", err)
+	}
+	if _, err := l.service.Checkout("1AB21CS002", "S-1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if holds := l.holds(t); holds[0].Status != models.HoldFulfilled || *holds[0].CopyID != l.book.Copies[0].ID {
+		t.Errorf("hold = %+v", holds[0])
+	}
+}
+
+func TestReturn(t *testing.T) {
+	l := newLibrary(t)
+	l.create(t, &models.CirculationRule{LoanPeriodDays: 7, MaxLoans: 5, FinePerDayMinor: 500, FineCapMinor: 1200})
+	transaction, err := l.service.Checkout("1AB21CS001", "S-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.placeHold(t, "1AB21CS002")
+
+	// Four days late; the fine is capped
+	l.now = transaction.DueDate.AddDate(0, 0, 4)
+	result, err := l.service.Return(transaction.ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Transaction.ReturnDate == nil || result.Transaction.LateFeeMinor != 1200 {
+		t.Errorf("returned loan = %+v", result.Transaction)
+	}
+	if result.LateFee == nil || result.LateFee.AmountMinor != 1200 || result.LateFee.Type != models.ChargeLateFee {
+		t.Errorf("late fee = %+v", result.LateFee)
+	}
+	if result.ReadyHold == nil || result.ReadyHold.StudentUSN != "1AB21CS002" || result.ReadyHold.Status != models.HoldReady {
+		t.Errorf("ready hold = %+v", result.ReadyHold)
+	}
+	if status := l.copyStatus(t, "S-1"); status != models.CopyOnHold {
+		t.Errorf("copy is %s, want it trapped for the hold", status)
+	}
+	stored, _ := l.store.Transactions().Get(transaction.ID)
+	if stored.ReturnDate == nil || stored.LateFeeMinor != 1200 {
+		t.Errorf("stored loan = %+v", stored)
+	}
+
+	if _, err := l.service.Return(transaction.ID, 2); err != circulation.ErrAlreadyReturned {
+		t.Errorf("returning twice gave %v", err)
+	}
+	if ledger := l.ledger(t); len(ledger) != 1 {
+		t.Errorf("ledger = %+v, want one late fee", ledger)
+	}
+
+	// The copy is now only for the student who held it
+	if _, err := l.service.Checkout("1AB21CS001", "S-1", 1); err != circulation.ErrHeldForOther {
+		t.Errorf("borrowing a copy trapped for another student gave %v", err)
+	}
+	if _, err := l.service.Checkout("1AB21CS002", "S-1", 1); err != nil {
+		t.Errorf("the student who held the copy could not borrow it: %v", err)
+	}
+}
+
+func TestReturnOnTimeReshelves(t *testing.T) {
+	l := newLibrary(t)
+	transaction, _ := l.service.Checkout("1AB21CS001", "S-1", 1)
+	result, err := l.service.Return(transaction.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.LateFee != nil || result.ReadyHold != nil || result.Transaction.LateFeeMinor != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if status := l.copyStatus(t, "S-1"); status != models.CopyAvailable {
+		t.Errorf("copy is %s after an on-time return", status)
+	}
+	if _, err := l.service.Return(999, 1); err != ErrTransactionNotFound {
+		t.Errorf("returning an unknown loan gave %v", err)
+	}
+}
+
+func TestConcurrentReturnsChargeOnce(t *testing.T) {
+	l := newLibrary(t)
+	transaction, _ := l.service.Checkout("1AB21CS001", "S-1", 1)
+	l.now = transaction.DueDate.AddDate(0, 0, 3)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := l.service.Return(transaction.ID, 1)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			succeeded++
+		case circulation.ErrAlreadyReturned:
+		default:
+			t.Errorf("Return: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d returns succeeded, want 1", succeeded)
+	}
+	if ledger := l.ledger(t); len(ledger) != 1 {
+		t.Errorf("%d late fees were charged, want 1", len(ledger))
+	}
+}
+
+func TestRenew(t *testing.T) {
+	l := newLibrary(t)
+	rule := models.CirculationRule{LoanPeriodDays: 7, MaxLoans: 5, MaxRenewals: 1}
+	l.create(t, &rule)
+	l.now = time.Now()
+	issued, _ := l.service.Checkout("1AB21CS001", "S-1", 1)
+	// Loans are renewed as the handlers load them, with their student and copy
+	transaction, err := l.store.Transactions().Get(issued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstDue := transaction.DueDate
+
+	if err := l.service.Renew(&transaction, 1); err != nil {
+		t.Fatal(err)
+	}
+	if transaction.RenewalCount != 1 || !transaction.DueDate.Equal(firstDue.AddDate(0, 0, 7)) {
+		t.Errorf("renewed loan = %+v", transaction)
+	}
+	stored, _ := l.store.Transactions().Get(transaction.ID)
+	if stored.RenewalCount != 1 || !stored.DueDate.Equal(transaction.DueDate) {
+		t.Errorf("stored loan = %+v", stored)
+	}
+	if err := l.service.Renew(&transaction, 1); err != circulation.ErrRenewalLimit {
+		t.Errorf("renewing past the limit gave %v", err)
+	}
+
+	l.db.Model(&rule).Update("max_renewals", 5)
+	l.placeHold(t, "1AB21CS002")
+	if err := l.service.Renew(&transaction, 1); err != circulation.ErrHeldForRenewal {
+		t.Errorf("renewing a held title gave %v", err)
+	}
+}
+
+func TestDeleteOpenLoanReshelves(t *testing.T) {
+	l := newLibrary(t)
+	transaction, _ := l.service.Checkout("1AB21CS001", "S-1", 1)
+	ready, err := l.service.Delete(transaction.ID)
+	if err != nil || ready != nil {
+		t.Fatalf("Delete gave %+v, %v", ready, err)
+	}
+	if status := l.copyStatus(t, "S-1"); status != models.CopyAvailable {
+		t.Errorf("copy is %s after its loan was deleted", status)
+	}
+	if _, err := l.store.Transactions().Get(transaction.ID); err != repository.ErrNotFound {
+		t.Errorf("the loan is still stored: %v", err)
+	}
+	if _, err := l.service.Delete(transaction.ID); err != ErrTransactionNotFound {
+		t.Errorf("deleting a deleted loan gave %v", err)
+	}
+}
+
+func TestDeleteOpenLoanTrapsForHold(t *testing.T) {
+	l := newLibrary(t)
+	transaction, _ := l.service.Checkout("1AB21CS001", "S-1", 1)
+	l.placeHold(t, "1AB21CS002")
+
+	ready, err := l.service.Delete(transaction.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready == nil || ready.StudentUSN != "1AB21CS002" || ready.Status != models.HoldReady {
+		t.Errorf("ready hold = %+v", ready)
+	}
+	if status := l.copyStatus(t, "S-1"); status != models.CopyOnHold {
+		t.Errorf("copy is %s, want it trapped for the hold", status)
+	}
+}
+
+func TestDeleteReturnedLoanLeavesCopy(t *testing.T) {
+	l := newLibrary(t)
+	transaction, _ := l.service.Checkout("1AB21CS001", "S-1", 1)
+	l.service.Return(transaction.ID, 1)
+	// Issued again since the first loan was returned
+	if _, err := l.service.Checkout("1AB21CS002", "S-1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.service.Delete(transaction.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status := l.copyStatus(t, "S-1"); status != models.CopyIssued {
+		t.Errorf("deleting an old loan left the copy %s", status)
+	}
+}
+
+func TestCheckoutRefusesStrayHeldCopy(t *testing.T) {
+	l := newLibrary(t)
+	if err := l.store.Books().SetCopyStatus(l.book.Copies[0].ID, models.CopyAvailable, models.CopyOnHold); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.service.Checkout("1AB21CS001", "S-1", 1); err != circulation.ErrHeldForOther {
+		t.Errorf("issuing a copy held for nobody gave %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"library-management/circulation"
+	"library-management/models"
+	"library-management/repository"
+)
+
+var errNotGormStore = errors.New("the gorm policy needs a gorm store")
+
+// GormPolicy applies the rules of the circulation package to a gorm store
+type GormPolicy struct{}
+
+// NewGormCirculation returns the circulation service for a gorm database
+func NewGormCirculation(db *gorm.DB) *Circulation {
+	return NewCirculation(repository.NewGormStore(db), GormPolicy{})
+}
+
+func gormDB(store repository.Store) (*gorm.DB, error) {
+	gormStore, ok := store.(*repository.GormStore)
+	if !ok {
+		return nil, errNotGormStore
+	}
+	return gormStore.DB(), nil
+}
+
+func (GormPolicy) Rule(store repository.Store, student models.Student, bookCopy models.Copy) (models.CirculationRule, error) {
+	db, err := gormDB(store)
+	if err != nil {
+		return models.CirculationRule{}, err
+	}
+	return circulation.FindRule(db, student, bookCopy)
+}
+
+func (GormPolicy) HoldForCheckout(store repository.Store, student models.Student, bookCopy models.Copy) (*models.Hold, error) {
+	db, err := gormDB(store)
+	if err != nil {
+		return nil, err
+	}
+	return circulation.HoldForCheckout(db, student, bookCopy)
+}
+
+func (GormPolicy) CheckBalance(store repository.Store, student models.Student) error {
+	db, err := gormDB(store)
+	if err != nil {
+		return err
+	}
+	return circulation.CheckBalanceAllowsLoan(db, student)
+}
+
+func (GormPolicy) DueDate(store repository.Store, rule models.CirculationRule, issued time.Time) (time.Time, error) {
+	db, err := gormDB(store)
+	if err != nil {
+		return time.Time{}, err
+	}
+	cal, err := circulation.LoadCalendar(db)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return circulation.DueDate(cal, rule, issued), nil
+}
+
+func (GormPolicy) LateFee(store repository.Store, rule models.CirculationRule, due, returned time.Time) (int64, error) {
+	db, err := gormDB(store)
+	if err != nil {
+		return 0, err
+	}
+	cal, err := circulation.LoadCalendar(db)
+	if err != nil {
+		return 0, err
+	}
+	return circulation.LateFee(cal, rule, due, returned), nil
+}
+
+func (GormPolicy) Issued(store repository.Store, transaction models.Transaction, hold *models.Hold, userID uint) error {
+	tx, err := gormDB(store)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		if err := circulation.FulfillHold(tx, hold, transaction.CopyID); err != nil {
+			return err
+		}
+	}
+	return circulation.RecordEvent(tx, transaction.ID, models.EventIssued, nil, &transaction.DueDate, userID)
+}
+
+func (GormPolicy) Returned(store repository.Store, transaction models.Transaction, userID uint) (*models.LedgerEntry, error) {
+	tx, err := gormDB(store)
+	if err != nil {
+		return nil, err
+	}
+	if err := circulation.RecordEvent(tx, transaction.ID, models.EventReturned, nil, nil, userID); err != nil {
+		return nil, err
+	}
+	if transaction.LateFeeMinor <= 0 {
+		return nil, nil
+	}
+
+	// Charge the late fee to the student's fine account
+	lateFee := &models.LedgerEntry{
+		StudentUSN:       transaction.StudentUSN,
+		TransactionID:    &transaction.ID,
+		Type:             models.ChargeLateFee,
+		AmountMinor:      transaction.LateFeeMinor,
+		RecordedByUserID: userID,
+	}
+	if err := circulation.AddLedgerEntry(tx, lateFee); err != nil {
+		return nil, err
+	}
+	return lateFee, nil
+}
+
+func (GormPolicy) Reshelve(store repository.Store, bookCopy models.Copy) (*models.Hold, error) {
+	tx, err := gormDB(store)
+	if err != nil {
+		return nil, err
+	}
+	return circulation.TrapForNextHold(tx, bookCopy)
+}
+
+func (GormPolicy) Renew(store repository.Store, transaction *models.Transaction, bookCopy models.Copy, rule models.CirculationRule, userID uint) error {
+	db, err := gormDB(store)
+	if err != nil {
+		return err
+	}
+	cal, err := circulation.LoadCalendar(db)
+	if err != nil {
+		return err
+	}
+	return circulation.Renew(db, cal, transaction, bookCopy, rule, userID)
+}
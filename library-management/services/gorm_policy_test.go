@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"library-management/circulation"
+	"library-management/database/databasetest"
+	"library-management/models"
+	"library-management/repository"
+)
+
+// racingPolicy returns the loan through another service while the first
+// return is working out its late fee
+type racingPolicy struct {
+	GormPolicy
+	other *Circulation
+	raced bool
+}
+
+func (p *racingPolicy) LateFee(store repository.Store, rule models.CirculationRule, due, returned time.Time) (int64, error) {
+	if !p.raced {
+		p.raced = true
+		if _, err := p.other.Return(1, 1); err != nil {
+			return 0, err
+		}
+	}
+	return p.GormPolicy.LateFee(store, rule, due, returned)
+}
+
+func TestGormReturnIsConditional(t *testing.T) {
+	db := databasetest.Open(t)
+	if err := db.Create(&models.Student{USN: "1AB21CS001", Name: "Asha"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	book := models.Book{Title: "Go", Author: "Donovan", Edition: 1, Copies: []models.Copy{{SerialNumber: "S-1"}}}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.CirculationRule{LoanPeriodDays: 7, MaxLoans: 5, FinePerDayMinor: 100}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewGormCirculation(db)
+	transaction, err := other.Checkout("1AB21CS001", "S-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.ID != 1 {
+		t.Fatalf("the loan got id %d", transaction.ID)
+	}
+	late := func() time.Time { return transaction.DueDate.AddDate(0, 0, 3) }
+	other.Now = late
+
+	service := NewCirculation(repository.NewGormStore(db), &racingPolicy{other: other})
+	service.Now = late
+	if _, err := service.Return(transaction.ID, 1); err != circulation.ErrAlreadyReturned {
+		t.Errorf("the losing return gave %v", err)
+	}
+
+	var charges int64
+	db.Model(&models.LedgerEntry{}).Where("transaction_id = ?", transaction.ID).Count(&charges)
+	if charges != 1 {
+		t.Errorf("%d late fees were charged, want 1", charges)
+	}
+	var events int64
+	db.Model(&models.TransactionEvent{}).Where("transaction_id = ? AND type = ?", transaction.ID, models.EventReturned).Count(&events)
+	if events != 1 {
+		t.Errorf("the return was recorded %d times", events)
+	}
+}
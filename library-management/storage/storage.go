@@ -0,0 +1,75 @@
+// Package storage keeps uploaded files, such as ebooks, outside the
+// database. Files are stored under keys like "ebooks/12/3f9a..." on the
+// local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"library-management/config"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("object not found")
+
+// Store is a blob store
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// already there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the object stored under key
+	Open(ctx context.Context, key string) (Object, error)
+	// Delete removes the object under key. Deleting a missing object is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a stored file opened for reading. It can seek, so it can be
+// served in ranges.
+type Object interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+// New returns the store the settings describe
+func New(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Driver {
+	case config.StorageLocal, "":
+		return NewLocal(cfg.LocalPath), nil
+	case config.StorageS3:
+		return NewS3(cfg.S3)
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
+
+// checkKey rejects keys that could escape the store's root
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
+
+// NewKey returns a new random key under dir, e.g. "ebooks/12/3f9a...".
+// Replaced files get new keys, so cached copies of the old one are never
+// served in their place.
+func NewKey(dir string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return dir + "/" + hex.EncodeToString(b), nil
+}
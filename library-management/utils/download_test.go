@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"library-management/config"
+)
+
+func TestVerifyDownload(t *testing.T) {
+	config.DownloadSigningSecret = []byte("secret")
+	expires := time.Unix(1790000000, 0)
+	signature := SignDownload(4, 1, expires)
+
+	if !VerifyDownload(4, 1, expires, signature) {
+		t.Error("a signed link was refused")
+	}
+	for name, forged := range map[string]string{
+		"uppercase":     strings.ToUpper(signature),
+		"other book":    SignDownload(5, 1, expires),
+		"other student": SignDownload(4, 2, expires),
+		"other expiry":  SignDownload(4, 1, expires.Add(time.Second)),
+		"truncated":     signature[:len(signature)-2],
+		"empty":         "",
+	} {
+		if VerifyDownload(4, 1, expires, forged) {
+			t.Errorf("a link signed with the %s signature was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package utils
+
+import "fmt"
+
+// FormatMinor renders an amount in minor units (e.g. paise) as a decimal
+// string with two places, such as "-12.50"
+func FormatMinor(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// ErrInvalidPhone is returned for numbers that cannot be dialled
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone returns a phone number in E.164 form, such as
+// "+919876543210". Numbers written without a country code are read as
+// numbers of region, an ISO country code like "IN". An empty number stays
+// empty.
+func NormalizePhone(raw, region string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	number, err := phonenumbers.Parse(raw, strings.ToUpper(region))
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return "", ErrInvalidPhone
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	for _, tc := range []struct {
+		raw, region, want string
+	}{
+		{"9876543210", "IN", "+919876543210"},
+		{"098765 43210", "in", "+919876543210"},
+		{" +91 98765-43210 ", "IN", "+919876543210"},
+		{"+1 (415) 555-2671", "IN", "+14155552671"},
+		{"(415) 555-2671", "US", "+14155552671"},
+		{"", "IN", ""},
+		{"   ", "IN", ""},
+	} {
+		got, err := NormalizePhone(tc.raw, tc.region)
+		if err != nil || got != tc.want {
+			t.Errorf("NormalizePhone(%q, %q) = %q, %v; want %q", tc.raw, tc.region, got, err, tc.want)
+		}
+	}
+
+	for _, raw := range []string{"12345", "not a number", "+91 12345", "98765432101234"} {
+		if got, err := NormalizePhone(raw, "IN"); err != ErrInvalidPhone {
+			t.Errorf("NormalizePhone(%q) = %q, %v", raw, got, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"library-management/config"
+)
+
+// Token types carried in the "typ" claim
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+// Claims are the JWT claims issued for a logged in user
+type Claims struct {
+	UserID    uint   `json:"uid"`
+	Username  string `json:"username"`
+	SessionID uint   `json:"sid"`
+	Type      string `json:"typ"`
+	jwt.StandardClaims
+}
+
+// NewTokenID returns a random identifier for the jti claim
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateToken signs a token of the given type for a user session
+func GenerateToken(tokenType string, userID uint, username string, sessionID uint, tokenID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := Claims{
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
+		Type:      tokenType,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(config.JWTSecret)
+	return signed, expiresAt, err
+}
+
+// ParseToken verifies the signature and expiry of a token and checks its type
+func ParseToken(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return config.JWTSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Type != tokenType {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,113 @@
+package watermark
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Entry is a cached stamped copy
+type Entry struct {
+	Copy    *Copy
+	Stamped time.Time // When the stamp was made, shown in its footer
+}
+
+// Cache keeps recent stamped copies so repeat downloads, such as a viewer
+// fetching a file in ranges, are not stamped again. The copies hold at most
+// maxBytes, the oldest being dropped first.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	entries  map[string]*cacheEntry
+	building map[string]*build
+}
+
+type cacheEntry struct {
+	Entry
+	expires time.Time
+}
+
+// build is a stamping in progress that other requests for the same key
+// wait for
+type build struct {
+	done  chan struct{}
+	entry Entry
+	err   error
+}
+
+// NewCache returns a cache holding up to maxBytes of updates
+func NewCache(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		entries:  map[string]*cacheEntry{},
+		building: map[string]*build{},
+	}
+}
+
+// Get returns the copy cached under key, or makes it with stamp and keeps
+// it for ttl. Concurrent calls for the same key share one stamping.
+func (c *Cache) Get(key string, ttl time.Duration, stamp func() (Entry, error)) (Entry, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		if time.Now().Before(e.expires) {
+			c.mu.Unlock()
+			return e.Entry, nil
+		}
+		c.remove(key)
+	}
+	if b, ok := c.building[key]; ok {
+		c.mu.Unlock()
+		<-b.done
+		return b.entry, b.err
+	}
+	b := &build{done: make(chan struct{})}
+	c.building[key] = b
+	c.mu.Unlock()
+
+	// Waiting callers are released even if stamp panics
+	b.err = errStampFailed
+	defer func() {
+		c.mu.Lock()
+		delete(c.building, key)
+		if b.err == nil && b.entry.Copy != nil && ttl > 0 && b.entry.Copy.memory() <= c.maxBytes {
+			c.entries[key] = &cacheEntry{Entry: b.entry, expires: time.Now().Add(ttl)}
+			c.size += b.entry.Copy.memory()
+			c.evict()
+		}
+		c.mu.Unlock()
+		close(b.done)
+	}()
+	b.entry, b.err = stamp()
+	return b.entry, b.err
+}
+
+// errStampFailed is what callers waiting for a stamping that panicked get
+var errStampFailed = errors.New("watermark: stamping failed")
+
+func (c *Cache) remove(key string) {
+	c.size -= c.entries[key].Copy.memory()
+	delete(c.entries, key)
+}
+
+// evict drops expired entries, then the oldest, until the cache fits
+func (c *Cache) evict() {
+	if c.size <= c.maxBytes {
+		return
+	}
+	now := time.Now()
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			c.remove(key)
+		}
+	}
+	for c.size > c.maxBytes {
+		var oldest string
+		for key, e := range c.entries {
+			if oldest == "" || e.Stamped.Before(c.entries[oldest].Stamped) {
+				oldest = key
+			}
+		}
+		c.remove(oldest)
+	}
+}
@@ -0,0 +1,92 @@
+package watermark
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheSharesAndKeepsStampings(t *testing.T) {
+	cache := NewCache(1 << 20)
+	var calls int32
+	stamp := func() (Entry, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return Entry{Copy: &Copy{size: 1}, Stamped: time.Now()}, nil
+	}
+
+	done := make(chan Entry)
+	for i := 0; i < 4; i++ {
+		go func() {
+			entry, _ := cache.Get("book/1", time.Minute, stamp)
+			done <- entry
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	if _, err := cache.Get("book/1", time.Minute, stamp); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("stamped %d times, want once", calls)
+	}
+}
+
+func TestCacheDoesNotKeepFailures(t *testing.T) {
+	cache := NewCache(1 << 20)
+	failure := errors.New("broken")
+	if _, err := cache.Get("book/1", time.Minute, func() (Entry, error) { return Entry{}, failure }); err != failure {
+		t.Fatalf("Get = %v, want the stamping error", err)
+	}
+	entry, err := cache.Get("book/1", time.Minute, func() (Entry, error) {
+		return Entry{Copy: &Copy{size: 1}}, nil
+	})
+	if err != nil || entry.Copy == nil {
+		t.Errorf("Get after a failure = %v, %v", entry, err)
+	}
+}
+
+func TestCacheRecoversFromAPanickingStamp(t *testing.T) {
+	cache := NewCache(1 << 20)
+	started, release := make(chan struct{}), make(chan struct{})
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		cache.Get("book/1", time.Minute, func() (Entry, error) {
+			close(started)
+			<-release
+			panic("malformed")
+		})
+	}()
+	<-started
+
+	// A caller waiting on the stamping must be released with an error
+	waited := make(chan error)
+	go func() {
+		_, err := cache.Get("book/1", time.Minute, func() (Entry, error) {
+			return Entry{Copy: &Copy{size: 1}}, nil
+		})
+		waited <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if <-panicked == nil {
+		t.Fatal("the panic was not passed on to the stamping caller")
+	}
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a waiting caller hung after the stamping panicked")
+	}
+
+	// Later downloads stamp again
+	entry, err := cache.Get("book/1", time.Minute, func() (Entry, error) {
+		return Entry{Copy: &Copy{size: 1}}, nil
+	})
+	if err != nil || entry.Copy == nil {
+		t.Errorf("Get after a panic = %v, %v", entry, err)
+	}
+}
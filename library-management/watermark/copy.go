@@ -0,0 +1,226 @@
+package watermark
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Copy is a stamped copy of a PDF. It is made of new bytes and runs of
+// stream data from the original file, which are read as the copy is served.
+type Copy struct {
+	parts  []part
+	size   int64
+	digest string
+}
+
+// part is a run of the copy: new bytes, or length bytes of the original
+// from offset
+type part struct {
+	start  int64 // Offset in the copy
+	data   []byte
+	offset int64
+	length int64
+}
+
+func (p part) end() int64 {
+	if p.data != nil {
+		return p.start + int64(len(p.data))
+	}
+	return p.start + p.length
+}
+
+// Size is the length of the copy in bytes
+func (c *Copy) Size() int64 {
+	return c.size
+}
+
+// Digest identifies the copy's content, given the original it was made from
+func (c *Copy) Digest() string {
+	return c.digest
+}
+
+// memory is roughly how much memory the copy holds
+func (c *Copy) memory() int {
+	n := 0
+	for _, p := range c.parts {
+		n += len(p.data) + 64
+	}
+	return n
+}
+
+// Open returns the copy as a seekable file. original must be the file the
+// copy was made from.
+func (c *Copy) Open(original io.ReadSeeker) io.ReadSeeker {
+	return &copyReader{copy: c, original: original, originalPos: -1}
+}
+
+type copyReader struct {
+	copy        *Copy
+	original    io.ReadSeeker
+	offset      int64
+	originalPos int64 // Where original is positioned, -1 if unknown
+}
+
+func (r *copyReader) Read(p []byte) (int, error) {
+	parts := r.copy.parts
+	if r.offset >= r.copy.size || len(p) == 0 {
+		if r.offset >= r.copy.size {
+			return 0, io.EOF
+		}
+		return 0, nil
+	}
+	i := sort.Search(len(parts), func(i int) bool { return parts[i].end() > r.offset })
+	current := parts[i]
+	within := r.offset - current.start
+
+	if current.data != nil {
+		n := copy(p, current.data[within:])
+		r.offset += int64(n)
+		return n, nil
+	}
+
+	if remaining := current.length - within; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	if pos := current.offset + within; r.originalPos != pos {
+		if _, err := r.original.Seek(pos, io.SeekStart); err != nil {
+			r.originalPos = -1
+			return 0, err
+		}
+		r.originalPos = pos
+	}
+	n, err := r.original.Read(p)
+	r.offset += int64(n)
+	r.originalPos += int64(n)
+	if err == io.EOF {
+		// The original is shorter than when the copy was made
+		if n > 0 {
+			err = nil
+		} else {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		r.originalPos = -1
+	}
+	return n, err
+}
+
+func (r *copyReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.copy.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek to a negative offset")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// copyWriter writes the objects of a copy and the cross-reference table
+// that lists them
+type copyWriter struct {
+	parts   []part
+	buf     bytes.Buffer // New bytes not yet in parts
+	size    int64        // Length of parts
+	offsets map[int]int64
+	gens    map[int]int
+}
+
+func newCopyWriter(version string) *copyWriter {
+	w := &copyWriter{offsets: map[int]int64{}, gens: map[int]int{}}
+	fmt.Fprintf(&w.buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+	return w
+}
+
+func (w *copyWriter) pos() int64 {
+	return w.size + int64(w.buf.Len())
+}
+
+func (w *copyWriter) flush() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	data := append([]byte(nil), w.buf.Bytes()...)
+	w.parts = append(w.parts, part{start: w.size, data: data})
+	w.size += int64(len(data))
+	w.buf.Reset()
+}
+
+// original adds length bytes of the original file from offset
+func (w *copyWriter) original(offset, length int64) {
+	if length == 0 {
+		return
+	}
+	w.flush()
+	w.parts = append(w.parts, part{start: w.size, offset: offset, length: length})
+	w.size += length
+}
+
+func (w *copyWriter) start(r ref) {
+	w.offsets[r.num] = w.pos()
+	w.gens[r.num] = r.gen
+	fmt.Fprintf(&w.buf, "%d %d obj\n", r.num, r.gen)
+}
+
+// object writes a direct value, or a stream made for the stamp
+func (w *copyWriter) object(r ref, v value) {
+	w.start(r)
+	if data, ok := v.(newStream); ok {
+		writeValue(&w.buf, dict{"Length": number(strconv.Itoa(len(data)))})
+		w.buf.WriteString("\nstream\n")
+		w.buf.Write(data)
+		w.buf.WriteString("\nendstream\nendobj\n")
+		return
+	}
+	writeValue(&w.buf, v)
+	w.buf.WriteString("\nendobj\n")
+}
+
+// originalStream writes a stream whose data is read from the original
+func (w *copyWriter) originalStream(r ref, s *stream, length int64) {
+	w.start(r)
+	d := copyDict(s.dict)
+	d["Length"] = number(strconv.FormatInt(length, 10))
+	writeValue(&w.buf, d)
+	w.buf.WriteString("\nstream\n")
+	w.original(s.offset, length)
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// finish ends the copy with a cross-reference table and trailer
+func (w *copyWriter) finish(trailer dict) *Copy {
+	size, _ := toInt(trailer["Size"])
+	xref := w.pos()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n", size)
+	for num := 0; num < int(size); num++ {
+		if offset, ok := w.offsets[num]; ok {
+			fmt.Fprintf(&w.buf, "%010d %05d n\r\n", offset, w.gens[num])
+		} else {
+			w.buf.WriteString("0000000000 65535 f\r\n")
+		}
+	}
+	w.buf.WriteString("trailer\n")
+	writeValue(&w.buf, trailer)
+	fmt.Fprintf(&w.buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	w.flush()
+
+	hash := sha256.New()
+	for _, p := range w.parts {
+		if p.data != nil {
+			hash.Write(p.data)
+		} else {
+			fmt.Fprintf(hash, "\x00%d:%d\x00", p.offset, p.length)
+		}
+	}
+	return &Copy{parts: w.parts, size: w.size, digest: hex.EncodeToString(hash.Sum(nil))}
+}
@@ -0,0 +1,111 @@
+package watermark
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Document is what Describe reads from a PDF
+type Document struct {
+	Pages  int
+	Title  string // Empty when the document information has none
+	Author string
+}
+
+// Describe reads a PDF's page count and the title and author in its
+// document information. Files it cannot read could not be stamped either.
+func Describe(r io.ReaderAt, size int64) (_ Document, err error) {
+	defer recoverMalformed(&err)
+
+	f, err := openFile(r, size)
+	if err != nil {
+		return Document{}, err
+	}
+	pages, err := f.pages()
+	if err != nil {
+		return Document{}, err
+	}
+	if len(pages) == 0 {
+		return Document{}, fmt.Errorf("the PDF has no pages")
+	}
+	info, err := f.resolveDict(f.trailer["Info"])
+	if err != nil {
+		return Document{}, err
+	}
+	// Try a stamp, so files that could not be downloaded are found now
+	if _, err := f.stamp(Stamp{}); err != nil {
+		return Document{}, err
+	}
+	return Document{
+		Pages:  len(pages),
+		Title:  f.text(info["Title"]),
+		Author: f.text(info["Author"]),
+	}, nil
+}
+
+// text reads a text string, such as a document information entry
+func (f *file) text(v value) string {
+	v, err := f.resolve(v)
+	s, ok := v.(pdfString)
+	if err != nil || !ok {
+		return ""
+	}
+
+	var text string
+	switch {
+	case len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF:
+		units := make([]uint16, (len(s)-2)/2)
+		for i := range units {
+			units[i] = uint16(s[2+2*i])<<8 | uint16(s[3+2*i])
+		}
+		text = string(utf16.Decode(units))
+	case len(s) >= 3 && s[0] == 0xEF && s[1] == 0xBB && s[2] == 0xBF:
+		text = strings.ToValidUTF8(string(s[3:]), string(utf8.RuneError))
+	default:
+		runes := make([]rune, len(s))
+		for i, c := range s {
+			runes[i] = pdfDocRune(c)
+		}
+		text = string(runes)
+	}
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// pdfDocSpecials are the characters of PDFDocEncoding from 0x80 to 0xA0;
+// the rest match Latin-1
+var pdfDocSpecials = []rune("•†‡…—–ƒ⁄‹›−‰„“”‘’‚™ﬁﬂŁŒŠŸŽıłœšž�€")
+
+// pdfDocRune decodes a character in PDFDocEncoding
+func pdfDocRune(c byte) rune {
+	switch {
+	case c >= 0x80 && c <= 0xA0:
+		return pdfDocSpecials[c-0x80]
+	case c == 0x18:
+		return '˘'
+	case c == 0x19:
+		return 'ˇ'
+	case c == 0x1A:
+		return 'ˆ'
+	case c == 0x1B:
+		return '˙'
+	case c == 0x1C:
+		return '˝'
+	case c == 0x1D:
+		return '˛'
+	case c == 0x1E:
+		return '˚'
+	case c == 0x1F:
+		return '˜'
+	}
+	return rune(c)
+}
@@ -0,0 +1,625 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrEncrypted is returned for PDFs that are encrypted, which cannot be
+// stamped without their password
+var ErrEncrypted = errors.New("the PDF is encrypted")
+
+// maxObjectSize stops parsing of damaged files that never close an object
+const maxObjectSize = 64 << 20
+
+// maxObjectNumber is the largest object number accepted
+const maxObjectNumber = 1 << 23
+
+// xrefEntry is where an object is stored: at an offset in the file, or
+// inside an object stream
+type xrefEntry struct {
+	offset     int64
+	gen        int
+	compressed bool
+	stream     int // Object stream number when compressed
+	index      int // Position in the object stream when compressed
+}
+
+// file reads objects from a PDF through its cross-reference tables
+type file struct {
+	r       io.ReaderAt
+	size    int64
+	xref    map[int]xrefEntry
+	trailer dict // Of the newest revision
+
+	lastXRef       int64 // Offset of the newest cross-reference section
+	lastXRefStream bool  // Whether it is a cross-reference stream
+
+	objects    map[int]value
+	objStreams map[int]*objectStream
+	loading    map[int]bool // Object streams being read, to catch loops
+}
+
+type objectStream struct {
+	data    []byte
+	first   int
+	offsets map[int]int // Object number to offset after first
+}
+
+func openFile(r io.ReaderAt, size int64) (*file, error) {
+	f := &file{
+		r:          r,
+		size:       size,
+		xref:       map[int]xrefEntry{},
+		objects:    map[int]value{},
+		objStreams: map[int]*objectStream{},
+		loading:    map[int]bool{},
+	}
+	offset, err := f.startXRef()
+	if err != nil {
+		return nil, err
+	}
+	f.lastXRef = offset
+
+	// Newer sections come first and win over the ones they replace
+	seen := map[int64]bool{}
+	for first := true; offset > 0 || first; first = false {
+		if seen[offset] {
+			break
+		}
+		seen[offset] = true
+		trailer, isStream, err := f.readXRef(offset)
+		if err != nil {
+			return nil, fmt.Errorf("reading the cross-reference table: %w", err)
+		}
+		if first {
+			f.trailer = trailer
+			f.lastXRefStream = isStream
+		}
+		// Hybrid files keep compressed objects in a separate stream
+		if stm, ok := toInt(trailer["XRefStm"]); ok && !seen[stm] {
+			seen[stm] = true
+			if _, _, err := f.readXRef(stm); err != nil {
+				return nil, fmt.Errorf("reading the cross-reference stream: %w", err)
+			}
+		}
+		offset, _ = toInt(trailer["Prev"])
+	}
+
+	if _, ok := f.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	if _, ok := f.trailer["Root"].(ref); !ok {
+		return nil, fmt.Errorf("the PDF has no document catalog")
+	}
+	return f, nil
+}
+
+// startXRef finds the offset given after the last startxref keyword
+func (f *file) startXRef() (int64, error) {
+	n := int64(2048)
+	if n > f.size {
+		n = f.size
+	}
+	tail := make([]byte, n)
+	if _, err := f.r.ReadAt(tail, f.size-n); err != nil && err != io.EOF {
+		return 0, err
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return 0, fmt.Errorf("not a PDF file: startxref is missing")
+	}
+	l := &lexer{b: tail[i+len("startxref"):], all: true}
+	offset, err := l.integer()
+	if err != nil || offset <= 0 || offset >= f.size {
+		return 0, fmt.Errorf("not a PDF file: bad startxref")
+	}
+	return offset, nil
+}
+
+// parseAt runs parse over the file from offset, giving it more of the file
+// each time it runs out
+func (f *file) parseAt(offset int64, parse func(l *lexer) error) error {
+	if offset < 0 || offset >= f.size {
+		return fmt.Errorf("pdf: offset %d is outside the file", offset)
+	}
+	for window := int64(4096); ; window *= 4 {
+		all := false
+		if offset+window >= f.size {
+			window = f.size - offset
+			all = true
+		}
+		if window > maxObjectSize {
+			return fmt.Errorf("pdf: object at offset %d is too large", offset)
+		}
+		buf := make([]byte, window)
+		if _, err := f.r.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return err
+		}
+		err := parse(&lexer{b: buf, base: offset, all: all})
+		if err != errShort {
+			return err
+		}
+	}
+}
+
+// readXRef reads one cross-reference section, a table or a stream, and
+// returns its trailer
+func (f *file) readXRef(offset int64) (dict, bool, error) {
+	if offset <= 0 || offset >= f.size {
+		return nil, false, fmt.Errorf("bad offset %d", offset)
+	}
+	var trailer dict
+	var xrefStream *stream
+	err := f.parseAt(offset, func(l *lexer) error {
+		l.skipSpace()
+		if !bytes.HasPrefix(l.b[l.pos:], []byte("xref")) {
+			_, v, err := l.indirect()
+			if err != nil {
+				return err
+			}
+			s, ok := v.(*stream)
+			if !ok {
+				return fmt.Errorf("no cross-reference table at offset %d", offset)
+			}
+			xrefStream = s
+			return nil
+		}
+
+		l.pos += len("xref")
+		entries := map[int]xrefEntry{}
+		for {
+			w, err := l.word()
+			if err != nil {
+				return err
+			}
+			if w == "trailer" {
+				break
+			}
+			l.pos -= len(w)
+			start, err := l.integer()
+			if err != nil {
+				return err
+			}
+			count, err := l.integer()
+			if err != nil {
+				return err
+			}
+			if start < 0 || count < 0 || start+count > maxObjectNumber {
+				return fmt.Errorf("bad cross-reference subsection at offset %d", offset)
+			}
+			for i := int64(0); i < count; i++ {
+				off, err := l.integer()
+				if err != nil {
+					return err
+				}
+				gen, err := l.integer()
+				if err != nil {
+					return err
+				}
+				kind, err := l.word()
+				if err != nil {
+					return err
+				}
+				if kind == "n" {
+					entries[int(start+i)] = xrefEntry{offset: off, gen: int(gen)}
+				} else {
+					entries[int(start+i)] = xrefEntry{} // Free
+				}
+			}
+		}
+		v, err := l.value()
+		if err != nil {
+			return err
+		}
+		d, ok := v.(dict)
+		if !ok {
+			return fmt.Errorf("bad trailer at offset %d", offset)
+		}
+		trailer = d
+		f.addEntries(entries)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if xrefStream == nil {
+		return trailer, false, nil
+	}
+	return xrefStream.dict, true, f.readXRefStream(xrefStream)
+}
+
+func (f *file) addEntries(entries map[int]xrefEntry) {
+	for num, entry := range entries {
+		if _, ok := f.xref[num]; !ok {
+			f.xref[num] = entry
+		}
+	}
+}
+
+// readXRefStream reads the entries of a cross-reference stream
+func (f *file) readXRefStream(s *stream) error {
+	data, err := f.streamData(s)
+	if err != nil {
+		return err
+	}
+	w, _ := s.dict["W"].(array)
+	if len(w) != 3 {
+		return fmt.Errorf("bad /W in cross-reference stream")
+	}
+	var widths [3]int
+	rowSize := 0
+	for i := range widths {
+		n, ok := toInt(w[i])
+		if !ok || n < 0 || n > 8 {
+			return fmt.Errorf("bad /W in cross-reference stream")
+		}
+		widths[i] = int(n)
+		rowSize += int(n)
+	}
+	if rowSize == 0 {
+		return fmt.Errorf("bad /W in cross-reference stream")
+	}
+	index, _ := s.dict["Index"].(array)
+	if index == nil {
+		size, _ := toInt(s.dict["Size"])
+		index = array{number("0"), number(fmt.Sprint(size))}
+	}
+
+	entries := map[int]xrefEntry{}
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, ok1 := toInt(index[i])
+		count, ok2 := toInt(index[i+1])
+		if !ok1 || !ok2 || start < 0 || count < 0 || start+count > maxObjectNumber {
+			return fmt.Errorf("bad /Index in cross-reference stream")
+		}
+		for j := int64(0); j < count; j++ {
+			if pos+rowSize > len(data) {
+				return fmt.Errorf("cross-reference stream is too short")
+			}
+			var fields [3]int64
+			for k, width := range widths {
+				for b := 0; b < width; b++ {
+					fields[k] = fields[k]<<8 | int64(data[pos])
+					pos++
+				}
+			}
+			if widths[0] == 0 {
+				fields[0] = 1
+			}
+			num := int(start + j)
+			switch fields[0] {
+			case 1:
+				entries[num] = xrefEntry{offset: fields[1], gen: int(fields[2])}
+			case 2:
+				if fields[1] <= 0 || fields[1] > maxObjectNumber {
+					return fmt.Errorf("bad object stream number in cross-reference stream")
+				}
+				entries[num] = xrefEntry{compressed: true, stream: int(fields[1]), index: int(fields[2])}
+			default:
+				entries[num] = xrefEntry{}
+			}
+		}
+	}
+	f.addEntries(entries)
+	return nil
+}
+
+// object returns object num, reading it on first use
+func (f *file) object(num int) (value, error) {
+	if v, ok := f.objects[num]; ok {
+		return v, nil
+	}
+	entry, ok := f.xref[num]
+	if !ok || (!entry.compressed && entry.offset == 0) {
+		return keyword("null"), nil // Missing objects read as null
+	}
+
+	var v value
+	if entry.compressed {
+		if f.xref[entry.stream].compressed {
+			return nil, fmt.Errorf("object stream %d is itself compressed", entry.stream)
+		}
+		stm, err := f.objectStream(entry.stream)
+		if err != nil {
+			return nil, err
+		}
+		start, ok := stm.offsets[num]
+		if !ok {
+			return nil, fmt.Errorf("object %d is missing from object stream %d", num, entry.stream)
+		}
+		l := &lexer{b: stm.data[stm.first+start:], all: true}
+		if v, err = l.value(); err != nil {
+			return nil, fmt.Errorf("object %d: %w", num, err)
+		}
+	} else {
+		err := f.parseAt(entry.offset, func(l *lexer) error {
+			id, obj, err := l.indirect()
+			if err != nil {
+				return err
+			}
+			if id.num != num {
+				return fmt.Errorf("expected object %d at offset %d, found %d", num, entry.offset, id.num)
+			}
+			v = obj
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	f.objects[num] = v
+	return v, nil
+}
+
+// resolve follows references until it reaches a direct value
+func (f *file) resolve(v value) (value, error) {
+	for depth := 0; depth < 32; depth++ {
+		r, ok := v.(ref)
+		if !ok {
+			return v, nil
+		}
+		var err error
+		if v, err = f.object(r.num); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("pdf: reference loop")
+}
+
+// resolveDict resolves v and returns it if it is a dictionary
+func (f *file) resolveDict(v value) (dict, error) {
+	v, err := f.resolve(v)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case dict:
+		return v, nil
+	case *stream:
+		return v.dict, nil
+	}
+	return nil, nil
+}
+
+func (f *file) objectStream(num int) (*objectStream, error) {
+	if stm, ok := f.objStreams[num]; ok {
+		return stm, nil
+	}
+	if f.loading[num] {
+		return nil, fmt.Errorf("object stream %d depends on itself", num)
+	}
+	f.loading[num] = true
+	defer delete(f.loading, num)
+
+	v, err := f.object(num)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := v.(*stream)
+	if !ok {
+		return nil, fmt.Errorf("object %d is not an object stream", num)
+	}
+	data, err := f.streamData(s)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := toInt(s.dict["N"])
+	first, _ := toInt(s.dict["First"])
+	if first < 0 || first > int64(len(data)) {
+		return nil, fmt.Errorf("object stream %d has a bad /First", num)
+	}
+
+	stm := &objectStream{data: data, first: int(first), offsets: map[int]int{}}
+	l := &lexer{b: data[:first], all: true}
+	for i := int64(0); i < n; i++ {
+		objNum, err1 := l.integer()
+		offset, err2 := l.integer()
+		if err1 != nil || err2 != nil || objNum < 0 || offset < 0 || first+offset > int64(len(data)) {
+			return nil, fmt.Errorf("object stream %d has a bad header", num)
+		}
+		stm.offsets[int(objNum)] = int(offset)
+	}
+	f.objStreams[num] = stm
+	return stm, nil
+}
+
+// streamLength returns the length of a stream's data. Damaged files often
+// give a wrong /Length, so it is checked against where the endstream
+// keyword is, which is searched for if need be.
+func (f *file) streamLength(s *stream) (int64, error) {
+	lengthValue, err := f.resolve(s.dict["Length"])
+	if err != nil {
+		return 0, err
+	}
+	if length, ok := toInt(lengthValue); ok && length >= 0 && length <= f.size-s.offset {
+		tail := make([]byte, 32)
+		n, _ := f.r.ReadAt(tail, s.offset+length)
+		l := &lexer{b: tail[:n], all: true}
+		l.skipSpace()
+		if bytes.HasPrefix(l.b[l.pos:], []byte("endstream")) {
+			return length, nil
+		}
+	}
+
+	marker := []byte("endstream")
+	chunk := make([]byte, 64<<10)
+	for pos := s.offset; pos < f.size; pos += int64(len(chunk) - len(marker)) {
+		n, err := f.r.ReadAt(chunk, pos)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.Index(chunk[:n], marker); i >= 0 {
+			end := pos + int64(i)
+			// The end of line before endstream is not part of the data
+			before := make([]byte, 2)
+			if end-s.offset >= 2 {
+				f.r.ReadAt(before, end-2)
+			}
+			if before[1] == '\n' {
+				end--
+				if before[0] == '\r' {
+					end--
+				}
+			} else if before[1] == '\r' {
+				end--
+			}
+			if end < s.offset {
+				end = s.offset
+			}
+			return end - s.offset, nil
+		}
+		if n < len(chunk) {
+			break
+		}
+	}
+	return 0, fmt.Errorf("stream at offset %d has no end", s.offset)
+}
+
+// version reads the PDF version from the file's header
+func (f *file) version() string {
+	head := make([]byte, 1024)
+	n, _ := f.r.ReadAt(head, 0)
+	i := bytes.Index(head[:n], []byte("%PDF-"))
+	if i >= 0 {
+		l := &lexer{b: head[i+5 : n], all: true}
+		if v, err := l.word(); err == nil && len(v) == 3 && v[0] >= '1' && v[0] <= '9' && v[1] == '.' && v[2] >= '0' && v[2] <= '9' {
+			return v
+		}
+	}
+	return "1.7"
+}
+
+// recoverMalformed turns a panic while reading a malformed file into an
+// error. Everything read is checked, so this is only a last line of defence.
+func recoverMalformed(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("pdf: malformed file: %v", r)
+	}
+}
+
+// streamData reads and decodes a stream's data
+func (f *file) streamData(s *stream) ([]byte, error) {
+	length, err := f.streamLength(s)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxObjectSize {
+		return nil, fmt.Errorf("stream at offset %d is too large", s.offset)
+	}
+	data := make([]byte, length)
+	if _, err := f.r.ReadAt(data, s.offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	filters := s.dict["Filter"]
+	params := s.dict["DecodeParms"]
+	if name, ok := filters.(name); ok {
+		filters, params = array{name}, array{params}
+	}
+	filterList, _ := filters.(array)
+	paramList, _ := params.(array)
+	for i, filter := range filterList {
+		if filter != name("FlateDecode") {
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := io.ReadAll(io.LimitReader(zr, maxObjectSize))
+		if err != nil && len(decoded) == 0 {
+			return nil, err
+		}
+		data = decoded
+		if i < len(paramList) {
+			p, err := f.resolveDict(paramList[i])
+			if err != nil {
+				return nil, err
+			}
+			if data, err = unpredict(data, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
+
+// unpredict undoes the PNG predictors used by cross-reference and object
+// streams
+func unpredict(data []byte, params dict) ([]byte, error) {
+	predictor, _ := toInt(params["Predictor"])
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("unsupported predictor %d", predictor)
+		}
+		return data, nil
+	}
+	columns, ok := toInt(params["Columns"])
+	if !ok {
+		columns = 1
+	}
+	colors, ok := toInt(params["Colors"])
+	if !ok {
+		colors = 1
+	}
+	bits, ok := toInt(params["BitsPerComponent"])
+	if !ok {
+		bits = 8
+	}
+	if columns < 1 || columns > 1<<16 || colors < 1 || colors > 32 ||
+		(bits != 1 && bits != 2 && bits != 4 && bits != 8 && bits != 16) {
+		return nil, fmt.Errorf("bad predictor parameters")
+	}
+	bpp := int((colors*bits + 7) / 8)
+	rowSize := int((columns*colors*bits + 7) / 8)
+
+	var out []byte
+	prev := make([]byte, rowSize)
+	for pos := 0; pos+rowSize+1 <= len(data); pos += rowSize + 1 {
+		kind, row := data[pos], append([]byte(nil), data[pos+1:pos+1+rowSize]...)
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
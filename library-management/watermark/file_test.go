@@ -0,0 +1,106 @@
+package watermark
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// malformedPDFs are files that once made the parser panic or loop, or
+// that must simply be refused
+func malformedPDFs() map[string][]byte {
+	files := map[string][]byte{}
+
+	page := map[int]string{
+		3: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		4: "<< /Title (x) >>",
+	}
+	withObjectStream := func(header, parms string) []byte {
+		b := newPDFBuilder("1.5")
+		b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+		b.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+		compressed := b.objectStream(5, page, header)
+		return b.xrefStream(6, compressed, "/Root 1 0 R /Info 4 0 R", parms)
+	}
+	files["negative object stream offset"] = withObjectStream("3 -40 4 0 ", "")
+	files["object stream offset past the end"] = withObjectStream("3 0 4 99999 ", "")
+	files["negative object number"] = withObjectStream("-3 0 4 10 ", "")
+	files["negative columns"] = withObjectStream("", "<< /Predictor 12 /Columns -7 >>")
+	files["huge columns"] = withObjectStream("", "<< /Predictor 12 /Columns 9999999999999 >>")
+	files["huge colors"] = withObjectStream("", "<< /Predictor 12 /Columns 7 /Colors 1000000 >>")
+	files["odd bits per component"] = withObjectStream("", "<< /Predictor 12 /Columns 7 /BitsPerComponent 3 >>")
+
+	b := newPDFBuilder("1.5")
+	b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	b.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	files["object stream inside itself"] = b.xrefStream(6, map[int][2]int{3: {5, 0}, 5: {5, 1}}, "/Root 1 0 R", "")
+
+	b = newPDFBuilder("1.5")
+	b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	b.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	b.object(3, "<< /Type /Page /Parent 2 0 R /Annots "+strings.Repeat("[", 100000)+" >>")
+	files["deeply nested arrays"] = b.classic("/Root 1 0 R")
+
+	b = newPDFBuilder("1.4")
+	b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	b.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	b.object(3, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 1e400 -1e400] >>")
+	files["infinite media box"] = b.classic("/Root 1 0 R")
+
+	b = newPDFBuilder("1.4")
+	b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	files["huge xref subsection"] = append(b.buf.Bytes(), []byte("xref\n0 999999999\ntrailer\n<< /Size 999999999 /Root 1 0 R >>\nstartxref\n23\n%%EOF\n")...)
+	files["startxref past the end"] = []byte("%PDF-1.4\ntrailer\n<< >>\nstartxref\n99999999\n%%EOF\n")
+	files["startxref negative"] = []byte("%PDF-1.4\ntrailer\n<< >>\nstartxref\n-5\n%%EOF\n")
+
+	classic := classicPDF()
+	files["truncated"] = classic[:len(classic)/2]
+	files["cut before the trailer"] = classic[:len(classic)-40]
+	return files
+}
+
+func TestMalformedPDFsAreRefused(t *testing.T) {
+	for name, pdf := range malformedPDFs() {
+		t.Run(name, func(t *testing.T) {
+			// A panic fails the test; errors are fine
+			if copy, err := PDF(bytes.NewReader(pdf), int64(len(pdf)), testStamp); err == nil {
+				checkStamped(t, readCopy(t, copy, pdf), 1)
+			}
+			Describe(bytes.NewReader(pdf), int64(len(pdf)))
+		})
+	}
+}
+
+func TestUnpredictRejectsBadParameters(t *testing.T) {
+	for _, params := range []dict{
+		{"Predictor": number("12"), "Columns": number("-1")},
+		{"Predictor": number("12"), "Columns": number("0")},
+		{"Predictor": number("12"), "Columns": number("4294967296")},
+		{"Predictor": number("12"), "Columns": number("4"), "Colors": number("-2")},
+		{"Predictor": number("12"), "Columns": number("4"), "BitsPerComponent": number("0")},
+		{"Predictor": number("2")},
+	} {
+		if _, err := unpredict([]byte{2, 1, 2, 3, 4}, params); err == nil {
+			t.Errorf("unpredict accepted %v", params)
+		}
+	}
+}
+
+func FuzzPDF(f *testing.F) {
+	f.Add(classicPDF())
+	f.Add(compressedPDF())
+	for _, pdf := range malformedPDFs() {
+		f.Add(pdf)
+	}
+	f.Fuzz(func(t *testing.T, pdf []byte) {
+		copy, err := PDF(bytes.NewReader(pdf), int64(len(pdf)), testStamp)
+		if err != nil {
+			return
+		}
+		// Whatever is stamped must read back as a PDF
+		out := readCopy(t, copy, pdf)
+		if _, err := openFile(bytes.NewReader(out), int64(len(out))); err != nil {
+			t.Fatalf("the stamped copy cannot be read: %v", err)
+		}
+	})
+}
@@ -0,0 +1,442 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// PDF objects as read from a file. Numbers keep their text so they are
+// written back exactly; strings are written back in hex form.
+type (
+	value     interface{}
+	name      string // Without the slash, as written in the file
+	pdfString []byte
+	number    string
+	keyword   string // true, false or null
+	array     []value
+	dict      map[name]value
+	ref       struct{ num, gen int }
+	stream    struct {
+		dict   dict
+		offset int64 // Where the data starts in the file
+	}
+)
+
+// errShort means an object runs past the end of the bytes being parsed, so
+// more of the file is needed
+var errShort = errors.New("pdf: object runs past the buffer")
+
+// lexer parses PDF objects from part of a file
+type lexer struct {
+	b     []byte
+	pos   int
+	base  int64 // File offset of b[0]
+	all   bool  // b runs to the end of the file
+	depth int   // Of nested arrays and dictionaries
+}
+
+// maxDepth limits how deeply arrays and dictionaries may nest
+const maxDepth = 64
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func isRegular(c byte) bool {
+	return !isSpace(c) && !isDelimiter(c)
+}
+
+func (l *lexer) short() error {
+	if l.all {
+		return fmt.Errorf("pdf: unexpected end of file at offset %d", l.base+int64(l.pos))
+	}
+	return errShort
+}
+
+// skipSpace skips white space and comments
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		switch {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.b) && l.b[l.pos] != '\n' && l.b[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// word reads a run of regular characters, such as a keyword or number
+func (l *lexer) word() (string, error) {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.b) && isRegular(l.b[l.pos]) {
+		l.pos++
+	}
+	if l.pos == len(l.b) && !l.all {
+		return "", errShort
+	}
+	if start == l.pos {
+		return "", fmt.Errorf("pdf: expected a keyword at offset %d", l.base+int64(start))
+	}
+	return string(l.b[start:l.pos]), nil
+}
+
+// keyword reads the keyword want
+func (l *lexer) keyword(want string) error {
+	got, err := l.word()
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("pdf: expected %q at offset %d, found %q", want, l.base+int64(l.pos-len(got)), got)
+	}
+	return nil
+}
+
+// integer reads a whole number
+func (l *lexer) integer() (int64, error) {
+	w, err := l.word()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(w, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("pdf: expected a number at offset %d, found %q", l.base+int64(l.pos-len(w)), w)
+	}
+	return n, nil
+}
+
+func (l *lexer) value() (value, error) {
+	l.skipSpace()
+	if l.pos >= len(l.b) {
+		return nil, l.short()
+	}
+	c := l.b[l.pos]
+	if c == '[' || c == '<' {
+		if l.depth >= maxDepth {
+			return nil, fmt.Errorf("pdf: objects nest too deeply at offset %d", l.base+int64(l.pos))
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+	}
+	switch {
+	case c == '<' && l.pos+1 < len(l.b) && l.b[l.pos+1] == '<':
+		l.pos += 2
+		return l.dict()
+	case c == '<':
+		if l.pos+1 >= len(l.b) {
+			return nil, l.short()
+		}
+		return l.hexString()
+	case c == '(':
+		return l.literalString()
+	case c == '[':
+		l.pos++
+		var a array
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.b) {
+				return nil, l.short()
+			}
+			if l.b[l.pos] == ']' {
+				l.pos++
+				return a, nil
+			}
+			v, err := l.value()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.b) && isRegular(l.b[l.pos]) {
+			l.pos++
+		}
+		if l.pos == len(l.b) && !l.all {
+			return nil, errShort
+		}
+		return name(l.b[start:l.pos]), nil
+	case c == '+' || c == '-' || c == '.' || ('0' <= c && c <= '9'):
+		return l.numberOrRef()
+	case isRegular(c):
+		w, err := l.word()
+		if err != nil {
+			return nil, err
+		}
+		switch w {
+		case "true", "false", "null":
+			return keyword(w), nil
+		}
+		return nil, fmt.Errorf("pdf: unexpected %q at offset %d", w, l.base+int64(l.pos-len(w)))
+	}
+	return nil, fmt.Errorf("pdf: unexpected %q at offset %d", c, l.base+int64(l.pos))
+}
+
+func (l *lexer) dict() (dict, error) {
+	d := dict{}
+	for {
+		l.skipSpace()
+		if l.pos+1 >= len(l.b) {
+			return nil, l.short()
+		}
+		if l.b[l.pos] == '>' && l.b[l.pos+1] == '>' {
+			l.pos += 2
+			return d, nil
+		}
+		key, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(name)
+		if !ok {
+			return nil, fmt.Errorf("pdf: dictionary key is not a name at offset %d", l.base+int64(l.pos))
+		}
+		v, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		d[k] = v
+	}
+}
+
+// numberOrRef reads a number, or a reference such as "12 0 R"
+func (l *lexer) numberOrRef() (value, error) {
+	w, err := l.word()
+	if err != nil {
+		return nil, err
+	}
+	num, err := strconv.Atoi(w)
+	if err != nil || w[0] == '+' || w[0] == '-' {
+		return number(w), nil
+	}
+
+	// Look ahead for "gen R"
+	save := l.pos
+	gen, err := l.word()
+	if err == errShort {
+		return nil, err
+	}
+	if g, convErr := strconv.Atoi(gen); err == nil && convErr == nil && g >= 0 {
+		r, err := l.word()
+		if err == errShort {
+			return nil, err
+		}
+		if err == nil && r == "R" {
+			return ref{num, g}, nil
+		}
+	}
+	l.pos = save
+	return number(w), nil
+}
+
+func (l *lexer) hexString() (value, error) {
+	l.pos++
+	var digits []byte
+	for {
+		if l.pos >= len(l.b) {
+			return nil, l.short()
+		}
+		c := l.b[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make(pdfString, len(digits)/2)
+	for i := range s {
+		n, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("pdf: bad hex string at offset %d", l.base+int64(l.pos))
+		}
+		s[i] = byte(n)
+	}
+	return s, nil
+}
+
+func (l *lexer) literalString() (value, error) {
+	l.pos++
+	var s pdfString
+	depth := 1
+	for {
+		if l.pos >= len(l.b) {
+			return nil, l.short()
+		}
+		c := l.b[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, nil
+			}
+		case '\\':
+			if l.pos >= len(l.b) {
+				return nil, l.short()
+			}
+			c = l.b[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if '0' <= c && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.b) && '0' <= l.b[l.pos] && l.b[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.b[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+}
+
+// indirect reads "num gen obj value endobj"; a stream's data is left in
+// the file and only its offset recorded
+func (l *lexer) indirect() (ref, value, error) {
+	num, err := l.integer()
+	if err != nil {
+		return ref{}, nil, err
+	}
+	gen, err := l.integer()
+	if err != nil {
+		return ref{}, nil, err
+	}
+	if err := l.keyword("obj"); err != nil {
+		return ref{}, nil, err
+	}
+	v, err := l.value()
+	if err != nil {
+		return ref{}, nil, err
+	}
+	id := ref{int(num), int(gen)}
+
+	d, ok := v.(dict)
+	if !ok {
+		return id, v, nil
+	}
+	l.skipSpace()
+	if len(l.b)-l.pos < len("stream\n") {
+		if !l.all {
+			return ref{}, nil, errShort
+		}
+		return id, d, nil
+	}
+	if !bytes.HasPrefix(l.b[l.pos:], []byte("stream")) {
+		return id, d, nil
+	}
+	l.pos += len("stream")
+	if l.b[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+		l.pos++
+	}
+	return id, &stream{dict: d, offset: l.base + int64(l.pos)}, nil
+}
+
+// writeValue writes v in PDF syntax
+func writeValue(b *bytes.Buffer, v value) {
+	switch v := v.(type) {
+	case name:
+		b.WriteString("/" + string(v))
+	case pdfString:
+		fmt.Fprintf(b, "<%X>", []byte(v))
+	case number:
+		b.WriteString(string(v))
+	case keyword:
+		b.WriteString(string(v))
+	case ref:
+		fmt.Fprintf(b, "%d %d R", v.num, v.gen)
+	case array:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeValue(b, item)
+		}
+		b.WriteByte(']')
+	case dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, k := range keys {
+			b.WriteString("/" + k + " ")
+			writeValue(b, v[name(k)])
+		}
+		b.WriteString(">>")
+	case nil:
+		b.WriteString("null")
+	default:
+		panic(fmt.Sprintf("pdf: cannot write %T", v))
+	}
+}
+
+// copyDict returns a shallow copy of d
+func copyDict(d dict) dict {
+	c := make(dict, len(d)+1)
+	for k, v := range d {
+		c[k] = v
+	}
+	return c
+}
+
+// toNumber reads a number value
+func toNumber(v value) (float64, bool) {
+	n, ok := v.(number)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// toInt reads a whole number value
+func toInt(v value) (int64, bool) {
+	f, ok := toNumber(v)
+	return int64(f), ok && f == float64(int64(f))
+}
@@ -0,0 +1,70 @@
+package watermark
+
+import (
+	"errors"
+	"io"
+)
+
+// blockSize is how much of the original file is read at a time while
+// stamping; files in remote storage are fetched in pieces of this size
+const blockSize = 64 << 10
+
+// maxBlocks is how many blocks a reader keeps, 4 MB in all
+const maxBlocks = 64
+
+// NewReaderAt reads a seekable file at random offsets, keeping the blocks
+// it has read most recently. It suits the scattered reads stamping needs.
+func NewReaderAt(r io.ReadSeeker, size int64) io.ReaderAt {
+	return &blockReader{r: r, size: size, blocks: map[int64][]byte{}}
+}
+
+type blockReader struct {
+	r      io.ReadSeeker
+	size   int64
+	blocks map[int64][]byte
+	order  []int64 // Kept blocks, oldest first
+}
+
+func (b *blockReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("watermark: negative offset")
+	}
+	n := 0
+	for n < len(p) && off+int64(n) < b.size {
+		pos := off + int64(n)
+		block, err := b.block(pos / blockSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[pos%blockSize:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (b *blockReader) block(i int64) ([]byte, error) {
+	if block, ok := b.blocks[i]; ok {
+		return block, nil
+	}
+	start := i * blockSize
+	size := int64(blockSize)
+	if start+size > b.size {
+		size = b.size - start
+	}
+	if _, err := b.r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	block := make([]byte, size)
+	if _, err := io.ReadFull(b.r, block); err != nil {
+		return nil, err
+	}
+	if len(b.order) == maxBlocks {
+		delete(b.blocks, b.order[0])
+		b.order = b.order[1:]
+	}
+	b.blocks[i] = block
+	b.order = append(b.order, i)
+	return block, nil
+}
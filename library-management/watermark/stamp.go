@@ -0,0 +1,434 @@
+// Package watermark stamps PDFs with who downloaded them. Stamped copies
+// are rewrites of the file with new pages and document information, whose
+// stream data is read from the original as the copy is served, so large
+// files can still be streamed from storage.
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Stamp is what is added to a PDF
+type Stamp struct {
+	Footer string            // Drawn at the foot of every page
+	Info   map[string]string // Added to the document information
+	Time   time.Time         // Recorded as the modification date
+}
+
+// fontResource is the name the footer's font is given on each page
+const fontResource = "LibraryStampFont"
+
+// footerSize is the footer's font size in points, reduced for narrow pages
+const footerSize = 7.0
+
+// PDF stamps the PDF read from r. The stamped copy is a rewrite of the
+// file in which every page and the document information are replaced, so
+// no part of it is the unstamped original. Stream data, which makes up
+// most of a file, is not copied: the copy refers to it in the original.
+func PDF(r io.ReaderAt, size int64, stamp Stamp) (_ *Copy, err error) {
+	defer recoverMalformed(&err)
+
+	f, err := openFile(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return f.stamp(stamp)
+}
+
+func (f *file) stamp(stamp Stamp) (*Copy, error) {
+	pages, err := f.pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("the PDF has no pages")
+	}
+
+	nextNum := 1
+	for num := range f.xref {
+		if num >= nextNum {
+			nextNum = num + 1
+		}
+	}
+	newRef := func() ref {
+		nextNum++
+		return ref{nextNum - 1, 0}
+	}
+	replaced := map[int]value{}
+	var added []ref
+	addedValues := map[ref]value{}
+	add := func(v value) ref {
+		r := newRef()
+		added = append(added, r)
+		addedValues[r] = v
+		return r
+	}
+
+	font := add(dict{
+		"Type":     name("Font"),
+		"Subtype":  name("Type1"),
+		"BaseFont": name("Helvetica"),
+		"Encoding": name("WinAnsiEncoding"),
+	})
+
+	// The page's own content is wrapped in q/Q so the footer is drawn
+	// with the default graphics state whatever the page leaves behind
+	open := add(newStream("q\n"))
+	footers := map[string]ref{}
+
+	for _, page := range pages {
+		pageDict := copyDict(page.dict)
+
+		var contents array
+		switch v := page.dict["Contents"].(type) {
+		case ref:
+			resolved, err := f.resolve(v)
+			if err != nil {
+				return nil, err
+			}
+			if parts, ok := resolved.(array); ok {
+				contents = parts
+			} else {
+				contents = array{v}
+			}
+		case array:
+			contents = v
+		}
+
+		box, rotate := page.box, page.rotate
+		key := fmt.Sprint(box, rotate)
+		footer, ok := footers[key]
+		if !ok {
+			footer = add(newStream(footerContent(stamp.Footer, box, rotate)))
+			footers[key] = footer
+		}
+		pageDict["Contents"] = append(append(array{open}, contents...), footer)
+
+		resources, err := f.resolveDict(page.resources)
+		if err != nil {
+			return nil, err
+		}
+		resources = copyDict(resources)
+		fonts, err := f.resolveDict(resources["Font"])
+		if err != nil {
+			return nil, err
+		}
+		fonts = copyDict(fonts)
+		fonts[fontResource] = font
+		resources["Font"] = fonts
+		pageDict["Resources"] = resources
+
+		replaced[page.ref.num] = pageDict
+	}
+
+	info, err := f.resolveDict(f.trailer["Info"])
+	if err != nil {
+		return nil, err
+	}
+	info = copyDict(info)
+	for key, text := range stamp.Info {
+		if !infoKey(key) {
+			return nil, fmt.Errorf("invalid document information key %q", key)
+		}
+		info[name(key)] = textString(text)
+	}
+	info["ModDate"] = pdfString(pdfDate(stamp.Time))
+	// The stamped information takes the place of the original's
+	infoRef, ok := f.trailer["Info"].(ref)
+	if ok && f.xref[infoRef.num].gen == infoRef.gen {
+		replaced[infoRef.num] = info
+	} else {
+		infoRef = add(info)
+	}
+
+	w := newCopyWriter(f.version())
+	nums := make([]int, 0, len(f.xref))
+	for num := range f.xref {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		entry := f.xref[num]
+		if num <= 0 || (!entry.compressed && entry.offset == 0) {
+			continue
+		}
+		id := ref{num, entry.gen}
+		if v, ok := replaced[num]; ok {
+			w.object(id, v)
+			continue
+		}
+		v, err := f.object(num)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case *stream:
+			// Cross-reference and object streams are replaced by the new
+			// cross-reference table and the objects written out in full
+			if t := v.dict["Type"]; t == name("XRef") || t == name("ObjStm") {
+				continue
+			}
+			length, err := f.streamLength(v)
+			if err != nil {
+				return nil, err
+			}
+			w.originalStream(id, v, length)
+		case dict:
+			// The linearization hints would no longer match
+			if _, ok := v["Linearized"]; ok {
+				continue
+			}
+			w.object(id, v)
+		case keyword:
+			if v != "null" {
+				w.object(id, v)
+			}
+		default:
+			w.object(id, v)
+		}
+	}
+	for _, r := range added {
+		w.object(r, addedValues[r])
+	}
+
+	trailer := dict{
+		"Size": number(strconv.Itoa(nextNum)),
+		"Root": f.trailer["Root"],
+		"Info": infoRef,
+	}
+	if id, ok := f.trailer["ID"]; ok {
+		trailer["ID"] = id
+	}
+	return w.finish(trailer), nil
+}
+
+// newStream is the content of a stream made for the stamp
+type newStream []byte
+
+// page is a leaf of the page tree with the attributes it inherits
+type page struct {
+	ref       ref
+	dict      dict
+	resources value
+	box       [4]float64 // Visible area: the crop box, or else the media box
+	rotate    int
+}
+
+// pages walks the page tree in order
+func (f *file) pages() ([]page, error) {
+	catalog, err := f.resolveDict(f.trailer["Root"])
+	if err != nil || catalog == nil {
+		return nil, fmt.Errorf("cannot read the document catalog: %v", err)
+	}
+	root, ok := catalog["Pages"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("the document catalog has no page tree")
+	}
+
+	var pages []page
+	seen := map[int]bool{}
+	var walk func(r ref, inherited page, depth int) error
+	walk = func(r ref, inherited page, depth int) error {
+		if seen[r.num] || depth > 64 {
+			return nil
+		}
+		seen[r.num] = true
+		node, err := f.resolveDict(r)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return nil
+		}
+
+		if v, ok := node["Resources"]; ok {
+			inherited.resources = v
+		}
+		for _, key := range []name{"MediaBox", "CropBox"} {
+			if v, ok := node[key]; ok {
+				if box, ok := f.rectangle(v); ok {
+					inherited.box = box
+				}
+			}
+		}
+		if v, ok := node["Rotate"]; ok {
+			if rotate, ok := toInt(v); ok {
+				inherited.rotate = int((rotate%360 + 360) % 360)
+			}
+		}
+
+		kids, err := f.resolve(node["Kids"])
+		if err != nil {
+			return err
+		}
+		if kidList, ok := kids.(array); ok && node["Type"] != name("Page") {
+			for _, kid := range kidList {
+				if kidRef, ok := kid.(ref); ok {
+					if err := walk(kidRef, inherited, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		inherited.ref = r
+		inherited.dict = node
+		pages = append(pages, inherited)
+		return nil
+	}
+	letter := page{box: [4]float64{0, 0, 612, 792}}
+	return pages, walk(root, letter, 0)
+}
+
+// rectangle reads a box such as [0 0 595 842]
+func (f *file) rectangle(v value) ([4]float64, bool) {
+	var box [4]float64
+	v, err := f.resolve(v)
+	a, ok := v.(array)
+	if err != nil || !ok || len(a) != 4 {
+		return box, false
+	}
+	for i := range box {
+		item, err := f.resolve(a[i])
+		if err != nil {
+			return box, false
+		}
+		if box[i], ok = toNumber(item); !ok {
+			return box, false
+		}
+	}
+	// Boxes may give any two opposite corners
+	if box[0] > box[2] {
+		box[0], box[2] = box[2], box[0]
+	}
+	if box[1] > box[3] {
+		box[1], box[3] = box[3], box[1]
+	}
+	return box, true
+}
+
+// footerContent draws text centred along the foot of the page as it is
+// shown, taking the page's rotation into account
+func footerContent(text string, box [4]float64, rotate int) []byte {
+	llx, lly, urx, ury := box[0], box[1], box[2], box[3]
+	width, height := urx-llx, ury-lly
+
+	// Map the upright page onto the rotated user space
+	var matrix [6]float64
+	switch rotate {
+	case 90:
+		matrix = [6]float64{0, 1, -1, 0, urx, lly}
+		width, height = height, width
+	case 180:
+		matrix = [6]float64{-1, 0, 0, -1, urx, ury}
+	case 270:
+		matrix = [6]float64{0, -1, 1, 0, llx, ury}
+		width, height = height, width
+	default:
+		matrix = [6]float64{1, 0, 0, 1, llx, lly}
+	}
+
+	encoded := winAnsi(text)
+	size := footerSize
+	textWidth := helveticaWidth(encoded) * size / 1000
+	if limit := width - 24; textWidth > limit && textWidth > 0 {
+		size = math.Max(size*limit/textWidth, 3)
+		textWidth = helveticaWidth(encoded) * size / 1000
+	}
+	x := math.Max((width-textWidth)/2, 4)
+	y := math.Min(12, height/4)
+
+	var b bytes.Buffer
+	b.WriteString("Q\nq\n")
+	for _, m := range matrix {
+		b.WriteString(formatNumber(m) + " ")
+	}
+	b.WriteString("cm\nBT\n")
+	fmt.Fprintf(&b, "/%s %s Tf\n0.4 g\n%s %s Td\n", fontResource, formatNumber(size), formatNumber(x), formatNumber(y))
+	fmt.Fprintf(&b, "<%X> Tj\nET\nQ\n", encoded)
+	return b.Bytes()
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// winAnsi encodes text for the standard Helvetica font. Latin-1 characters
+// keep their codes; anything the font cannot show becomes a question mark.
+func winAnsi(text string) []byte {
+	var b []byte
+	for _, r := range text {
+		switch {
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// helveticaWidths are the advance widths of printable ASCII in Helvetica,
+// in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+	278, 278, 584, 584, 584, 556, 1015, // : to @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+	278, 278, 278, 469, 556, 333, // [ to `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+	334, 260, 334, 584, // { to ~
+}
+
+func helveticaWidth(text []byte) float64 {
+	total := 0
+	for _, c := range text {
+		if c >= 0x20 && c < 0x7f {
+			total += helveticaWidths[c-0x20]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total)
+}
+
+// textString encodes text for the document information: plain bytes for
+// ASCII, UTF-16 with a byte order mark for anything else
+func textString(text string) pdfString {
+	ascii := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return pdfString(text)
+	}
+	s := pdfString{0xfe, 0xff}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		s = append(s, byte(unit>>8), byte(unit))
+	}
+	return s
+}
+
+// pdfDate formats a time as a PDF date, e.g. D:20261018093000Z
+func pdfDate(t time.Time) string {
+	return "D:" + t.UTC().Format("20060102150405") + "Z"
+}
+
+// infoKey checks a document information key, which is written as a name
+func infoKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, " ()<>[]{}/%#\t\r\n")
+}
@@ -0,0 +1,329 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pdfBuilder writes small PDFs for the tests
+type pdfBuilder struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+}
+
+func newPDFBuilder(version string) *pdfBuilder {
+	b := &pdfBuilder{offsets: map[int]int{}}
+	fmt.Fprintf(&b.buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+	return b
+}
+
+func (b *pdfBuilder) object(num int, body string) {
+	b.offsets[num] = b.buf.Len()
+	fmt.Fprintf(&b.buf, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+// stream writes a stream object, compressed if dict names FlateDecode
+func (b *pdfBuilder) stream(num int, dict string, data []byte) {
+	if strings.Contains(dict, "/FlateDecode") {
+		data = deflate(data)
+	}
+	b.object(num, fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data))
+}
+
+// classic ends the file with a cross-reference table
+func (b *pdfBuilder) classic(trailer string) []byte {
+	size := 0
+	for num := range b.offsets {
+		if num >= size {
+			size = num + 1
+		}
+	}
+	xref := b.buf.Len()
+	fmt.Fprintf(&b.buf, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for num := 1; num < size; num++ {
+		if offset, ok := b.offsets[num]; ok {
+			fmt.Fprintf(&b.buf, "%010d 00000 n \n", offset)
+		} else {
+			b.buf.WriteString("0000000000 65535 f \n")
+		}
+	}
+	fmt.Fprintf(&b.buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", size, trailer, xref)
+	return b.buf.Bytes()
+}
+
+// xrefStream ends the file with cross-reference stream num. compressed
+// maps objects kept in object streams to the stream and their index in it.
+// The rows are PNG Up predicted; parms replaces the decode parameters.
+func (b *pdfBuilder) xrefStream(num int, compressed map[int][2]int, trailer, parms string) []byte {
+	b.offsets[num] = b.buf.Len()
+	size := num + 1
+	for n := range compressed {
+		if n >= size {
+			size = n + 1
+		}
+	}
+	var raw []byte
+	prev := make([]byte, 7)
+	for n := 0; n < size; n++ {
+		row := make([]byte, 7)
+		if offset, ok := b.offsets[n]; ok {
+			row[0] = 1
+			binary.BigEndian.PutUint32(row[1:], uint32(offset))
+		} else if c, ok := compressed[n]; ok {
+			row[0] = 2
+			binary.BigEndian.PutUint32(row[1:], uint32(c[0]))
+			binary.BigEndian.PutUint16(row[5:], uint16(c[1]))
+		} else {
+			binary.BigEndian.PutUint16(row[5:], 0xffff)
+		}
+		raw = append(raw, 2)
+		for i := range row {
+			raw = append(raw, row[i]-prev[i])
+		}
+		prev = row
+	}
+	if parms == "" {
+		parms = "<< /Predictor 12 /Columns 7 >>"
+	}
+	xref := b.buf.Len()
+	b.stream(num, fmt.Sprintf("/Type /XRef /Size %d /W [1 4 2] /Filter /FlateDecode /DecodeParms %s %s", size, parms, trailer), raw)
+	fmt.Fprintf(&b.buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return b.buf.Bytes()
+}
+
+// objectStream writes objects into stream num, with header replacing the
+// generated list of object numbers and offsets if given
+func (b *pdfBuilder) objectStream(num int, objects map[int]string, header string) map[int][2]int {
+	nums := make([]int, 0, len(objects))
+	for n := range objects {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	var body, generated strings.Builder
+	compressed := map[int][2]int{}
+	for i, n := range nums {
+		fmt.Fprintf(&generated, "%d %d ", n, body.Len())
+		body.WriteString(objects[n] + "\n")
+		compressed[n] = [2]int{num, i}
+	}
+	if header == "" {
+		header = generated.String()
+	}
+	b.stream(num, fmt.Sprintf("/Type /ObjStm /N %d /First %d /Filter /FlateDecode", len(nums), len(header)), []byte(header+body.String()))
+	return compressed
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// classicPDF has two pages, one rotated, sharing a content stream
+func classicPDF() []byte {
+	b := newPDFBuilder("1.4")
+	b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	b.object(2, "<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 595 842] /Resources << /Font << /F1 5 0 R >> >> >>")
+	b.object(3, "<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>")
+	b.object(4, "<< /Type /Page /Parent 2 0 R /Rotate 90 /Contents [6 0 R 7 0 R] /CropBox [10 10 585 832] >>")
+	b.object(5, "<< /Type /Font /Subtype /Type1 /BaseFont /Times-Roman >>")
+	b.stream(6, "", []byte("BT /F1 24 Tf 72 700 Td (Page one) Tj ET"))
+	b.stream(7, "", []byte("BT /F1 12 Tf 72 600 Td (second) Tj ET"))
+	b.object(8, "<< /Title (Classic \\(test\\)) /Author (Someone) >>")
+	return b.classic("/Root 1 0 R /Info 8 0 R /ID [<AABB> <AABB>]")
+}
+
+// compressedPDF keeps its page, resources and information in an object
+// stream listed by a cross-reference stream
+func compressedPDF() []byte {
+	b := newPDFBuilder("1.5")
+	b.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	b.object(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	b.stream(8, "/Filter /FlateDecode", []byte("BT /F1 24 Tf 72 700 Td (Compressed) Tj ET"))
+	compressed := b.objectStream(7, map[int]string{
+		3: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 8 0 R /Resources 4 0 R >>",
+		4: "<< /Font 5 0 R >>",
+		5: "<< /F1 6 0 R >>",
+		6: "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+		9: "<< /Title <FEFF004200F6006B> >>",
+	}, "")
+	return b.xrefStream(10, compressed, "/Root 1 0 R /Info 9 0 R", "")
+}
+
+var testStamp = Stamp{
+	Footer: "Downloaded by Asha (1AB21CS001)",
+	Info:   map[string]string{"DownloadedBy": "Asha"},
+	Time:   time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+}
+
+// stampAndRead stamps a PDF and reads the whole copy
+func stampAndRead(t *testing.T, original []byte) []byte {
+	t.Helper()
+	copy, err := PDF(bytes.NewReader(original), int64(len(original)), testStamp)
+	if err != nil {
+		t.Fatalf("PDF: %v", err)
+	}
+	return readCopy(t, copy, original)
+}
+
+func readCopy(t *testing.T, copy *Copy, original []byte) []byte {
+	t.Helper()
+	out, err := io.ReadAll(copy.Open(bytes.NewReader(original)))
+	if err != nil {
+		t.Fatalf("reading the copy: %v", err)
+	}
+	if int64(len(out)) != copy.Size() {
+		t.Fatalf("read %d bytes of a %d byte copy", len(out), copy.Size())
+	}
+	return out
+}
+
+// checkStamped checks every page of a stamped copy draws the footer and
+// the document information carries the stamp
+func checkStamped(t *testing.T, out []byte, pageCount int) {
+	t.Helper()
+	f, err := openFile(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatalf("the copy cannot be read: %v", err)
+	}
+	pages, err := f.pages()
+	if err != nil {
+		t.Fatalf("reading the copy's pages: %v", err)
+	}
+	if len(pages) != pageCount {
+		t.Fatalf("the copy has %d pages, want %d", len(pages), pageCount)
+	}
+	footer := fmt.Sprintf("<%X> Tj", winAnsi(testStamp.Footer))
+	for i, page := range pages {
+		resources, _ := f.resolveDict(page.resources)
+		fonts, _ := f.resolveDict(resources["Font"])
+		if _, ok := fonts[fontResource]; !ok {
+			t.Errorf("page %d has no footer font", i+1)
+		}
+		contents, ok := page.dict["Contents"].(array)
+		if !ok || len(contents) < 2 {
+			t.Fatalf("page %d contents = %v", i+1, page.dict["Contents"])
+		}
+		last, _ := f.resolve(contents[len(contents)-1])
+		s, ok := last.(*stream)
+		if !ok {
+			t.Fatalf("page %d does not end with a stream", i+1)
+		}
+		data, err := f.streamData(s)
+		if err != nil || !bytes.Contains(data, []byte(footer)) {
+			t.Errorf("page %d does not draw the footer: %q, %v", i+1, data, err)
+		}
+	}
+	info, err := f.resolveDict(f.trailer["Info"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.text(info["DownloadedBy"]); got != "Asha" {
+		t.Errorf("DownloadedBy = %q", got)
+	}
+	if got := string(info["ModDate"].(pdfString)); got != "D:20261018093000Z" {
+		t.Errorf("ModDate = %q", got)
+	}
+}
+
+func TestPDFRewritesClassicFile(t *testing.T) {
+	original := classicPDF()
+	out := stampAndRead(t, original)
+	checkStamped(t, out, 2)
+
+	if len(out) >= len(original) && bytes.Equal(out[:len(original)], original) {
+		t.Error("the copy begins with the unstamped original")
+	}
+	if bytes.Contains(out, []byte("/Type /Page /Parent 2 0 R /Contents 6 0 R")) {
+		t.Error("the copy still holds an unstamped page")
+	}
+}
+
+func TestPDFRewritesCompressedFile(t *testing.T) {
+	original := compressedPDF()
+	out := stampAndRead(t, original)
+	checkStamped(t, out, 1)
+
+	if bytes.Contains(out, []byte("/ObjStm")) || bytes.Contains(out, []byte("/XRef")) {
+		t.Error("the copy still has the original's object or cross-reference streams")
+	}
+	f, _ := openFile(bytes.NewReader(out), int64(len(out)))
+	info, _ := f.resolveDict(f.trailer["Info"])
+	if got := f.text(info["Title"]); got != "Bök" {
+		t.Errorf("Title = %q, want the original's", got)
+	}
+}
+
+func TestPDFStampsAStampedCopy(t *testing.T) {
+	out := stampAndRead(t, stampAndRead(t, classicPDF()))
+	checkStamped(t, out, 2)
+}
+
+func TestCopyReadsRanges(t *testing.T) {
+	original := classicPDF()
+	copy, err := PDF(bytes.NewReader(original), int64(len(original)), testStamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole, _ := io.ReadAll(copy.Open(bytes.NewReader(original)))
+
+	r := copy.Open(bytes.NewReader(original))
+	for _, span := range [][2]int64{{0, 10}, {100, 400}, {int64(len(whole)) - 50, 50}, {7, 1}} {
+		if _, err := r.Seek(span[0], io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, span[1])
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("reading %v: %v", span, err)
+		}
+		if want := whole[span[0] : span[0]+span[1]]; !bytes.Equal(got, want) {
+			t.Errorf("range %v = %q, want %q", span, got, want)
+		}
+	}
+}
+
+func TestPDFDigestFollowsTheStamp(t *testing.T) {
+	original := classicPDF()
+	a, _ := PDF(bytes.NewReader(original), int64(len(original)), testStamp)
+	b, _ := PDF(bytes.NewReader(original), int64(len(original)), testStamp)
+	other := testStamp
+	other.Footer = "Downloaded by someone else"
+	c, _ := PDF(bytes.NewReader(original), int64(len(original)), other)
+	if a.Digest() != b.Digest() {
+		t.Error("the same stamp gave different digests")
+	}
+	if a.Digest() == c.Digest() {
+		t.Error("different stamps gave the same digest")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  []byte
+		want Document
+	}{
+		{"classic", classicPDF(), Document{Pages: 2, Title: "Classic (test)", Author: "Someone"}},
+		{"compressed", compressedPDF(), Document{Pages: 1, Title: "Bök"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Describe(bytes.NewReader(tt.pdf), int64(len(tt.pdf)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Describe = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}